@@ -0,0 +1,144 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+// Package cdc contains functionality shared by the change data capture inputs.
+package cdc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+)
+
+// SignalTypeExecuteSnapshot is the type of signals that trigger an incremental
+// snapshot of tables.
+const SignalTypeExecuteSnapshot = "execute-snapshot"
+
+// SignalTableDescription describes the signal table for the documentation of
+// CDC inputs.
+const SignalTableDescription = `The signal table must have the columns ` + "`id`, `type` and `data`" + `, e.g. ` + "`CREATE TABLE signals (id VARCHAR(64) PRIMARY KEY, type VARCHAR(32) NOT NULL, data VARCHAR(2048))`" + `. Inserting a row with the type ` + "`" + SignalTypeExecuteSnapshot + "`" + ` and the data ` + "`" + `{"data-collections": ["foo", "bar"]}` + "`" + ` triggers an incremental snapshot of the tables ` + "`foo`" + ` and ` + "`bar`" + `, which must be part of the streamed tables. Changes to the signal table are not emitted as messages.`
+
+// ErrUnknownSignal is returned when parsing a signal of an unsupported type.
+var ErrUnknownSignal = errors.New("unknown signal type")
+
+// ParseSnapshotSignal parses the tables of a signal read from a signal table.
+func ParseSnapshotSignal(signalType, data string) ([]string, error) {
+	if signalType != SignalTypeExecuteSnapshot {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSignal, signalType)
+	}
+	var payload struct {
+		DataCollections []string `json:"data-collections"`
+		Type            string   `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		return nil, fmt.Errorf("invalid signal data: %w", err)
+	}
+	if payload.Type != "" && payload.Type != "incremental" {
+		return nil, fmt.Errorf("unsupported snapshot type: %s", payload.Type)
+	}
+	if len(payload.DataCollections) == 0 {
+		return nil, errors.New("signal data does not contain any data-collections")
+	}
+	return payload.DataCollections, nil
+}
+
+// SnapshotTrigger triggers an incremental snapshot of tables.
+type SnapshotTrigger func(tables []string) error
+
+type triggerRegistration struct {
+	fn SnapshotTrigger
+}
+
+var (
+	triggersMut sync.RWMutex
+	triggers    = map[string]*triggerRegistration{}
+)
+
+// RegisterSnapshotTrigger registers the trigger of a CDC input with a label,
+// which allows snapshots to be triggered with the `cdc_snapshot` bloblang
+// function. Labels are shared by all streams of the process, therefore an
+// error is returned if another input has already registered the label. The
+// returned function deregisters the trigger.
+func RegisterSnapshotTrigger(label string, fn SnapshotTrigger) (deregister func(), err error) {
+	if label == "" {
+		return func() {}, nil
+	}
+	triggersMut.Lock()
+	defer triggersMut.Unlock()
+	if _, exists := triggers[label]; exists {
+		return nil, fmt.Errorf("a CDC input with the label %s already exists", label)
+	}
+	reg := &triggerRegistration{fn: fn}
+	triggers[label] = reg
+	return func() {
+		triggersMut.Lock()
+		// The label may have been registered again by another input since
+		if triggers[label] == reg {
+			delete(triggers, label)
+		}
+		triggersMut.Unlock()
+	}, nil
+}
+
+// TriggerSnapshot triggers an incremental snapshot of tables of the input with
+// the label.
+func TriggerSnapshot(label string, tables []string) error {
+	triggersMut.RLock()
+	reg, exists := triggers[label]
+	triggersMut.RUnlock()
+	if !exists {
+		return fmt.Errorf("no CDC input with the label %s", label)
+	}
+	return reg.fn(tables)
+}
+
+func init() {
+	spec := bloblang.NewPluginSpec().
+		Impure().
+		Category("General").
+		Description("Triggers an incremental snapshot of tables of a `mysql_cdc` or `postgres_cdc` input, identified by its label, which must be unique across all streams of the process. The rows of the tables are emitted with the operation `read`, interleaved with the changes streamed by the input. Returns `true` once the snapshot has been scheduled.").
+		Param(bloblang.NewStringParam("input").Description("The label of the input.")).
+		Param(bloblang.NewAnyParam("tables").Description("An array of tables to snapshot, which must be part of the tables streamed by the input.")).
+		ExampleNotTested("Trigger a snapshot of the tables in the payload of a request, e.g. received by an `http_server` input:",
+			`root = cdc_snapshot("my_cdc_input", this.tables)`)
+
+	if err := bloblang.RegisterFunctionV2(
+		"cdc_snapshot", spec,
+		func(args *bloblang.ParsedParams) (bloblang.Function, error) {
+			label, err := args.GetString("input")
+			if err != nil {
+				return nil, err
+			}
+			tablesArg, err := args.Get("tables")
+			if err != nil {
+				return nil, err
+			}
+			tablesAny, ok := tablesArg.([]any)
+			if !ok {
+				return nil, fmt.Errorf("expected array of tables, got %T", tablesArg)
+			}
+			tables := make([]string, len(tablesAny))
+			for i, t := range tablesAny {
+				if tables[i], ok = t.(string); !ok {
+					return nil, fmt.Errorf("expected table name string, got %T", t)
+				}
+			}
+			return func() (any, error) {
+				if err := TriggerSnapshot(label, tables); err != nil {
+					return nil, err
+				}
+				return true, nil
+			}, nil
+		},
+	); err != nil {
+		panic(err)
+	}
+}
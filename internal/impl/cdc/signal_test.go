@@ -0,0 +1,100 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package cdc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSnapshotSignal(t *testing.T) {
+	tables, err := ParseSnapshotSignal(SignalTypeExecuteSnapshot, `{"data-collections":["foo","bar"],"type":"incremental"}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo", "bar"}, tables)
+
+	_, err = ParseSnapshotSignal("pause-snapshot", `{}`)
+	require.ErrorIs(t, err, ErrUnknownSignal)
+
+	for _, data := range []string{
+		`{"data-collections":[]}`,
+		`{"data-collections":["foo"],"type":"blocking"}`,
+		`nope`,
+	} {
+		_, err = ParseSnapshotSignal(SignalTypeExecuteSnapshot, data)
+		require.Error(t, err, data)
+	}
+}
+
+func TestSnapshotBloblangFunction(t *testing.T) {
+	var triggered []string
+	deregister, err := RegisterSnapshotTrigger("foo_input", func(tables []string) error {
+		if len(tables) == 0 {
+			return errors.New("no tables")
+		}
+		triggered = append(triggered, tables...)
+		return nil
+	})
+	require.NoError(t, err)
+
+	exe, err := bloblang.Parse(`root = cdc_snapshot(this.input, this.tables)`)
+	require.NoError(t, err)
+
+	res, err := exe.Query(map[string]any{"input": "foo_input", "tables": []any{"a", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, true, res)
+	assert.Equal(t, []string{"a", "b"}, triggered)
+
+	_, err = exe.Query(map[string]any{"input": "foo_input", "tables": []any{}})
+	require.ErrorContains(t, err, "no tables")
+
+	_, err = exe.Query(map[string]any{"input": "foo_input", "tables": "a"})
+	require.Error(t, err)
+
+	deregister()
+	_, err = exe.Query(map[string]any{"input": "foo_input", "tables": []any{"a"}})
+	require.ErrorContains(t, err, "no CDC input with the label foo_input")
+}
+
+func TestSnapshotTriggerRegistration(t *testing.T) {
+	var triggered []string
+	newTrigger := func(name string) SnapshotTrigger {
+		return func([]string) error {
+			triggered = append(triggered, name)
+			return nil
+		}
+	}
+
+	deregisterFirst, err := RegisterSnapshotTrigger("bar_input", newTrigger("first"))
+	require.NoError(t, err)
+
+	// Inputs of other streams cannot take over the label
+	_, err = RegisterSnapshotTrigger("bar_input", newTrigger("duplicate"))
+	require.ErrorContains(t, err, "a CDC input with the label bar_input already exists")
+
+	deregisterFirst()
+	deregisterSecond, err := RegisterSnapshotTrigger("bar_input", newTrigger("second"))
+	require.NoError(t, err)
+
+	// A stale deregistration leaves the trigger of another input in place
+	deregisterFirst()
+	require.NoError(t, TriggerSnapshot("bar_input", []string{"a"}))
+	assert.Equal(t, []string{"second"}, triggered)
+
+	deregisterSecond()
+	require.Error(t, TriggerSnapshot("bar_input", []string{"a"}))
+
+	_, err = RegisterSnapshotTrigger("", newTrigger("unlabelled"))
+	require.NoError(t, err)
+	_, err = RegisterSnapshotTrigger("", newTrigger("unlabelled"))
+	require.NoError(t, err)
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -75,11 +76,62 @@ This processor encodes protobuf messages either from any format parsed within Re
 When a target subject presents a protobuf schema that contains multiple messages it becomes ambiguous which message definition a given input data should be encoded against. In such scenarios Redpanda Connect will attempt to encode the data against each of them and select the first to successfully match against the data, this process currently *ignores all nested message definitions*. In order to speed up this exhaustive search the last known successful message will be attempted first for each subsequent input.
 
 We will be considering alternative approaches in future so please https://redpanda.com/slack[get in touch^] with thoughts and feedback.
+
+== Subject name strategies
+
+Instead of an explicit ` + "`subject`" + `, the subject of each message can be derived with a ` + "`subject_name_strategy`" + ` from the ` + "`topic`" + ` the message is written to and the fully qualified record name of its schema, following the strategies of Confluent serializers. The record name strategies require ` + "`auto_register`" + ` to be enabled, as the record name is taken from the schema that is registered, which is the full name of an Avro schema, the title of a JSON schema or the full name of the first message of a Protobuf schema.
+
+== Automatic registration
+
+When ` + "`auto_register.enabled`" + ` is ` + "`true`" + ` messages are encoded with either the schema provided in ` + "`auto_register.schema`" + `, or a schema inferred from the structure of each message, which is registered under the subject of the message if it isn't already. Schemas can be inferred as Avro records or JSON schemas, where every field of a message is required and null values are inferred as the null type, therefore messages with a different structure result in the registration of a new schema version.
+
+By default the compatibility of a schema is checked against the latest schema of the subject before registering it, according to the compatibility level of the subject, and messages with an incompatible schema are not encoded and flagged with an error that can be caught using xref:configuration:error_handling.adoc[error handling methods].
 `).
 		Field(service.NewURLField("url").Description("The base URL of the schema registry service.")).
-		Field(service.NewInterpolatedStringField("subject").Description("The schema subject to derive schemas from.").
+		Field(service.NewInterpolatedStringField("subject").Description("The schema subject to derive schemas from. Either this field or `subject_name_strategy` must be specified.").
 			Example("foo").
-			Example(`${! meta("kafka_topic") }`)).
+			Example(`${! meta("kafka_topic") }`).
+			Optional()).
+		Field(service.NewStringAnnotatedEnumField("subject_name_strategy", map[string]string{
+			"topic_name":        "The subject is the topic followed by `-key` or `-value`.",
+			"record_name":       "The subject is the fully qualified record name of the schema.",
+			"topic_record_name": "The subject is the topic followed by `-` and the fully qualified record name of the schema.",
+		}).
+			Description("A strategy for deriving the subject of each message, as an alternative to the field `subject`.").
+			Optional().
+			Advanced().
+			Version("4.47.0")).
+		Field(service.NewInterpolatedStringField("topic").
+			Description("The topic that messages are written to, which is used by the topic subject name strategies. Messages that the topic cannot be resolved for are flagged with an error.").
+			Default(`${! @kafka_topic.not_null() }`).
+			Advanced().
+			Version("4.47.0")).
+		Field(service.NewBoolField("key").
+			Description("Whether messages are encoded as record keys rather than record values, which determines the suffix of subjects derived with the `topic_name` strategy.").
+			Default(false).
+			Advanced().
+			Version("4.47.0")).
+		Field(service.NewObjectField("auto_register",
+			service.NewBoolField("enabled").
+				Description("Whether to register the schemas of messages under their subject.").
+				Default(false),
+			service.NewStringField("schema").
+				Description("A schema to encode messages with. When not specified a schema is inferred from the structure of each message.").
+				Optional(),
+			service.NewStringEnumField("schema_type", "AVRO", "JSON", "PROTOBUF").
+				Description("The type of the provided or inferred schema. Schemas of the type `PROTOBUF` cannot be inferred.").
+				Default("AVRO"),
+			service.NewStringField("record_name").
+				Description("The name of inferred schemas, which is the (optionally namespaced) name of Avro records and the title of JSON schemas.").
+				Default("Record").
+				Example("com.example.Order"),
+			service.NewBoolField("check_compatibility").
+				Description("Whether to check the compatibility of a schema with the latest schema of the subject before registering it, and flag messages with an error instead of registering the schema when it is incompatible.").
+				Default(true),
+		).
+			Description("Automatically register the schemas that messages are encoded with.").
+			Advanced().
+			Version("4.47.0")).
 		Field(service.NewStringField("refresh_period").
 			Description("The period after which a schema is refreshed for each subject, this is done by polling the schema registry service.").
 			Default("10m").
@@ -109,12 +161,35 @@ func init() {
 
 //------------------------------------------------------------------------------
 
+type subjectNameStrategy string
+
+const (
+	subjectNameStrategyTopicName       subjectNameStrategy = "topic_name"
+	subjectNameStrategyRecordName      subjectNameStrategy = "record_name"
+	subjectNameStrategyTopicRecordName subjectNameStrategy = "topic_record_name"
+)
+
+type autoRegisterConfig struct {
+	schema             *franz_sr.Schema
+	schemaType         franz_sr.SchemaType
+	recordName         string
+	checkCompatibility bool
+}
+
 type schemaRegistryEncoder struct {
 	client             *sr.Client
 	subject            *service.InterpolatedString
 	avroRawJSON        bool
 	schemaRefreshAfter time.Duration
 
+	subjectStrategy subjectNameStrategy
+	topic           *service.InterpolatedString
+	isKey           bool
+	// autoRegister is nil unless schemas are registered automatically
+	autoRegister *autoRegisterConfig
+	// recordName is the record name of the provided or inferred schemas
+	recordName string
+
 	schemas    map[string]cachedSchemaEncoder
 	cacheMut   sync.RWMutex
 	requestMut sync.Mutex
@@ -130,9 +205,11 @@ func newSchemaRegistryEncoderFromConfig(conf *service.ParsedConfig, mgr *service
 	if err != nil {
 		return nil, err
 	}
-	subject, err := conf.FieldInterpolatedString("subject")
-	if err != nil {
-		return nil, err
+	var subject *service.InterpolatedString
+	if conf.Contains("subject") {
+		if subject, err = conf.FieldInterpolatedString("subject"); err != nil {
+			return nil, err
+		}
 	}
 	avroRawJSON, err := conf.FieldBool("avro_raw_json")
 	if err != nil {
@@ -158,7 +235,66 @@ func newSchemaRegistryEncoderFromConfig(conf *service.ParsedConfig, mgr *service
 	if err != nil {
 		return nil, err
 	}
-	return newSchemaRegistryEncoder(urlStr, authSigner, tlsConf, subject, avroRawJSON, refreshPeriod, refreshTicker, mgr)
+
+	var strategy subjectNameStrategy
+	if conf.Contains("subject_name_strategy") {
+		strategyStr, err := conf.FieldString("subject_name_strategy")
+		if err != nil {
+			return nil, err
+		}
+		strategy = subjectNameStrategy(strategyStr)
+	}
+	if (subject == nil) == (strategy == "") {
+		return nil, errors.New("exactly one of subject or subject_name_strategy must be specified")
+	}
+	topic, err := conf.FieldInterpolatedString("topic")
+	if err != nil {
+		return nil, err
+	}
+	isKey, err := conf.FieldBool("key")
+	if err != nil {
+		return nil, err
+	}
+
+	var autoRegister *autoRegisterConfig
+	arConf := conf.Namespace("auto_register")
+	if enabled, err := arConf.FieldBool("enabled"); err != nil {
+		return nil, err
+	} else if enabled {
+		autoRegister = &autoRegisterConfig{}
+		schemaType, err := arConf.FieldString("schema_type")
+		if err != nil {
+			return nil, err
+		}
+		if err := autoRegister.schemaType.UnmarshalText([]byte(schemaType)); err != nil {
+			return nil, err
+		}
+		if arConf.Contains("schema") {
+			schemaStr, err := arConf.FieldString("schema")
+			if err != nil {
+				return nil, err
+			}
+			autoRegister.schema = &franz_sr.Schema{Schema: schemaStr, Type: autoRegister.schemaType}
+		} else if autoRegister.schemaType == franz_sr.TypeProtobuf {
+			return nil, errors.New("protobuf schemas cannot be inferred, a schema must be provided")
+		}
+		if autoRegister.recordName, err = arConf.FieldString("record_name"); err != nil {
+			return nil, err
+		}
+		if autoRegister.checkCompatibility, err = arConf.FieldBool("check_compatibility"); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := newSchemaRegistryEncoder(urlStr, authSigner, tlsConf, subject, avroRawJSON, refreshPeriod, refreshTicker, mgr)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setSubjectStrategy(strategy, topic, isKey, autoRegister); err != nil {
+		_ = s.Close(context.Background())
+		return nil, err
+	}
+	return s, nil
 }
 
 func newSchemaRegistryEncoder(
@@ -198,17 +334,112 @@ func newSchemaRegistryEncoder(
 	return s, nil
 }
 
+// setSubjectStrategy configures the derivation of subjects with a strategy, if
+// any, and the automatic registration of schemas, if enabled.
+func (s *schemaRegistryEncoder) setSubjectStrategy(strategy subjectNameStrategy, topic *service.InterpolatedString, isKey bool, autoRegister *autoRegisterConfig) error {
+	s.subjectStrategy = strategy
+	s.topic = topic
+	s.isKey = isKey
+	s.autoRegister = autoRegister
+
+	switch strategy {
+	case "", subjectNameStrategyTopicName:
+		return nil
+	case subjectNameStrategyRecordName, subjectNameStrategyTopicRecordName:
+	default:
+		return fmt.Errorf("unknown subject name strategy: %v", strategy)
+	}
+
+	if autoRegister == nil {
+		return fmt.Errorf("the subject name strategy %v requires auto_register to be enabled", strategy)
+	}
+	if autoRegister.schema == nil {
+		s.recordName = autoRegister.recordName
+		return nil
+	}
+	var err error
+	if s.recordName, err = schemaRecordName(*autoRegister.schema); err != nil {
+		return fmt.Errorf("failed to determine the record name of the schema: %w", err)
+	}
+	return nil
+}
+
+// messageSubject returns the subject of a message, which is derived from its
+// topic and the record name of its schema when a strategy is configured.
+func (s *schemaRegistryEncoder) messageSubject(batch service.MessageBatch, i int) (string, error) {
+	if s.subjectStrategy == "" {
+		subject, err := batch.TryInterpolatedString(i, s.subject)
+		if err != nil {
+			return "", fmt.Errorf("subject interpolation error: %w", err)
+		}
+		return subject, nil
+	}
+
+	if s.subjectStrategy == subjectNameStrategyRecordName {
+		return s.recordName, nil
+	}
+
+	topic, err := batch.TryInterpolatedString(i, s.topic)
+	if err != nil {
+		return "", fmt.Errorf("topic interpolation error: %w", err)
+	}
+	if topic == "" {
+		return "", errors.New("topic is empty")
+	}
+	if s.subjectStrategy == subjectNameStrategyTopicRecordName {
+		return topic + "-" + s.recordName, nil
+	}
+	if s.isKey {
+		return topic + "-key", nil
+	}
+	return topic + "-value", nil
+}
+
+// messageSchema returns the schema to register for a message, which is either
+// the provided schema or one inferred from the message.
+func (s *schemaRegistryEncoder) messageSchema(msg *service.Message) (franz_sr.Schema, error) {
+	if s.autoRegister.schema != nil {
+		return *s.autoRegister.schema, nil
+	}
+
+	structured, err := msg.AsStructured()
+	if err != nil {
+		return franz_sr.Schema{}, fmt.Errorf("unable to infer schema from message: %w", err)
+	}
+
+	var schemaStr string
+	switch s.autoRegister.schemaType {
+	case franz_sr.TypeJSON:
+		schemaStr, err = inferJSONSchema(s.autoRegister.recordName, structured)
+	default:
+		schemaStr, err = inferAvroSchema(s.autoRegister.recordName, structured)
+	}
+	if err != nil {
+		return franz_sr.Schema{}, fmt.Errorf("unable to infer schema from message: %w", err)
+	}
+	return franz_sr.Schema{Schema: schemaStr, Type: s.autoRegister.schemaType}, nil
+}
+
 func (s *schemaRegistryEncoder) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
 	batch = batch.Copy()
 	for i, msg := range batch {
-		subject, err := batch.TryInterpolatedString(i, s.subject)
+		subject, err := s.messageSubject(batch, i)
 		if err != nil {
-			s.logger.Errorf("Subject interpolation error: %v", err)
-			msg.SetError(fmt.Errorf("subject interpolation error: %w", err))
+			s.logger.Errorf("Subject error: %v", err)
+			msg.SetError(err)
 			continue
 		}
 
-		encoder, id, err := s.getEncoder(subject)
+		var encoder schemaEncoder
+		var id int
+		if s.autoRegister != nil {
+			var schema franz_sr.Schema
+			if schema, err = s.messageSchema(msg); err == nil {
+				encoder, id, err = s.getRegisteredEncoder(subject, schema)
+			}
+		} else {
+			encoder, id, err = s.getEncoder(subject)
+		}
 		if err != nil {
 			msg.SetError(err)
 			continue
@@ -256,6 +487,9 @@ type cachedSchemaEncoder struct {
 	lastUpdatedUnixSeconds int64
 	id                     int
 	encoder                schemaEncoder
+	// registered is true for encoders of registered schemas, which are never
+	// refreshed as the ID of a schema does not change
+	registered bool
 }
 
 func insertID(id int, content []byte) ([]byte, error) {
@@ -277,7 +511,7 @@ func (s *schemaRegistryEncoder) refreshEncoders() {
 	for k, v := range s.schemas {
 		if atomic.LoadInt64(&v.lastUsedUnixSeconds) < purgeTargetTime {
 			purgeTargets = append(purgeTargets, k)
-		} else if atomic.LoadInt64(&v.lastUpdatedUnixSeconds) < updateTargetTime {
+		} else if !v.registered && atomic.LoadInt64(&v.lastUpdatedUnixSeconds) < updateTargetTime {
 			refreshTargets = append(refreshTargets, k)
 		}
 	}
@@ -327,15 +561,7 @@ func (s *schemaRegistryEncoder) getLatestEncoder(subject string) (schemaEncoder,
 
 	s.logger.Tracef("Loaded new codec for subject %v: %s", subject, resPayload.Schema)
 
-	var encoder schemaEncoder
-	switch resPayload.Type {
-	case franz_sr.TypeProtobuf:
-		encoder, err = s.getProtobufEncoder(ctx, resPayload.Schema)
-	case franz_sr.TypeJSON:
-		encoder, err = s.getJSONEncoder(ctx, resPayload.Schema)
-	default:
-		encoder, err = s.getAvroEncoder(ctx, resPayload.Schema)
-	}
+	encoder, err := s.getSchemaEncoder(ctx, resPayload.Schema)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -343,6 +569,17 @@ func (s *schemaRegistryEncoder) getLatestEncoder(subject string) (schemaEncoder,
 	return encoder, resPayload.ID, nil
 }
 
+func (s *schemaRegistryEncoder) getSchemaEncoder(ctx context.Context, schema franz_sr.Schema) (schemaEncoder, error) {
+	switch schema.Type {
+	case franz_sr.TypeProtobuf:
+		return s.getProtobufEncoder(ctx, schema)
+	case franz_sr.TypeJSON:
+		return s.getJSONEncoder(ctx, schema)
+	default:
+		return s.getAvroEncoder(ctx, schema)
+	}
+}
+
 func (s *schemaRegistryEncoder) getEncoder(subject string) (schemaEncoder, int, error) {
 	s.cacheMut.RLock()
 	c, ok := s.schemas[subject]
@@ -381,3 +618,71 @@ func (s *schemaRegistryEncoder) getEncoder(subject string) (schemaEncoder, int,
 
 	return encoder, id, nil
 }
+
+// getRegisteredEncoder returns an encoder of a schema, registering the schema
+// under the subject if it hasn't been registered already.
+func (s *schemaRegistryEncoder) getRegisteredEncoder(subject string, schema franz_sr.Schema) (schemaEncoder, int, error) {
+	key := subject + "\x00" + schema.Schema
+
+	s.cacheMut.RLock()
+	c, ok := s.schemas[key]
+	s.cacheMut.RUnlock()
+	if ok {
+		atomic.StoreInt64(&c.lastUsedUnixSeconds, s.nowFn().Unix())
+		return c.encoder, c.id, nil
+	}
+
+	s.requestMut.Lock()
+	defer s.requestMut.Unlock()
+
+	s.cacheMut.RLock()
+	c, ok = s.schemas[key]
+	s.cacheMut.RUnlock()
+	if ok {
+		atomic.StoreInt64(&c.lastUsedUnixSeconds, s.nowFn().Unix())
+		return c.encoder, c.id, nil
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	encoder, err := s.getSchemaEncoder(ctx, schema)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid schema for subject %q: %w", subject, err)
+	}
+
+	id, found, err := s.client.LookupSchema(ctx, subject, schema)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !found {
+		if s.autoRegister.checkCompatibility {
+			res, err := s.client.CheckCompatibility(ctx, subject, schema)
+			if err != nil {
+				return nil, 0, err
+			}
+			if !res.Is {
+				return nil, 0, fmt.Errorf("schema is incompatible with the latest schema of subject %q: %v", subject, strings.Join(res.Messages, ", "))
+			}
+		}
+
+		if id, err = s.client.CreateSchema(ctx, subject, schema); err != nil {
+			return nil, 0, err
+		}
+
+		s.logger.Debugf("Registered schema %v for subject %v", id, subject)
+	}
+
+	s.cacheMut.Lock()
+	s.schemas[key] = cachedSchemaEncoder{
+		lastUsedUnixSeconds:    s.nowFn().Unix(),
+		lastUpdatedUnixSeconds: s.nowFn().Unix(),
+		id:                     id,
+		encoder:                encoder,
+		registered:             true,
+	}
+	s.cacheMut.Unlock()
+
+	return encoder, id, nil
+}
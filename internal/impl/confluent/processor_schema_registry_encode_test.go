@@ -30,6 +30,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	franz_sr "github.com/twmb/franz-go/pkg/sr"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 )
@@ -96,6 +97,69 @@ subject: foo
 `,
 			expectedBaseURL: "http://example.com/v1",
 		},
+		{
+			name: "no subject",
+			config: `
+url: http://example.com
+`,
+			errContains: "exactly one of subject or subject_name_strategy must be specified",
+		},
+		{
+			name: "subject and strategy",
+			config: `
+url: http://example.com
+subject: foo
+subject_name_strategy: topic_name
+`,
+			errContains: "exactly one of subject or subject_name_strategy must be specified",
+		},
+		{
+			name: "topic name strategy",
+			config: `
+url: http://example.com
+subject_name_strategy: topic_name
+`,
+		},
+		{
+			name: "record name strategy without auto register",
+			config: `
+url: http://example.com
+subject_name_strategy: record_name
+`,
+			errContains: "requires auto_register to be enabled",
+		},
+		{
+			name: "record name strategy with inferred schema",
+			config: `
+url: http://example.com
+subject_name_strategy: topic_record_name
+auto_register:
+  enabled: true
+  record_name: com.example.Order
+`,
+		},
+		{
+			name: "record name strategy with unnamed schema",
+			config: `
+url: http://example.com
+subject_name_strategy: record_name
+auto_register:
+  enabled: true
+  schema: '"string"'
+`,
+			errContains: "failed to determine the record name of the schema",
+		},
+		{
+			name: "inferred protobuf schema",
+			config: `
+url: http://example.com
+subject: foo
+auto_register:
+  enabled: true
+  schema_type: PROTOBUF
+`,
+			errContains: "protobuf schemas cannot be inferred",
+		},
 	}
 
 	spec := schemaRegistryEncoderConfig()
@@ -746,3 +810,155 @@ func TestSchemaRegistryEncodeJSONConstantRefreshes(t *testing.T) {
 	assert.Empty(t, encoder.schemas)
 	encoder.cacheMut.Unlock()
 }
+
+// runAutoRegisterSchemaRegistryServer runs a schema registry that registers
+// schemas under any subject, with the exception of incompatible subjects. The
+// subject "registered" already has every schema registered under it, with
+// subsequent schemas being incompatible.
+func runAutoRegisterSchemaRegistryServer(t testing.TB, registrations *int32) string {
+	t.Helper()
+
+	var lastSubject string
+	return runSchemaRegistryServer(t, func(path string) ([]byte, error) {
+		switch {
+		case path == "/subjects/registered":
+			return []byte(`{"subject":"registered","version":1,"id":7,"schema":"{}"}`), nil
+		case strings.HasPrefix(path, "/subjects/") && strings.Count(path, "/") == 2:
+			return nil, nil
+		case path == "/compatibility/subjects/incompatible/versions/latest",
+			path == "/compatibility/subjects/registered/versions/latest":
+			return []byte(`{"is_compatible":false,"messages":["field removed"]}`), nil
+		case strings.HasPrefix(path, "/compatibility/subjects/"):
+			return []byte(`{"is_compatible":true}`), nil
+		case strings.HasPrefix(path, "/subjects/") && strings.HasSuffix(path, "/versions"):
+			lastSubject = strings.TrimSuffix(strings.TrimPrefix(path, "/subjects/"), "/versions")
+			id := atomic.AddInt32(registrations, 1)
+			return []byte(fmt.Sprintf(`{"id":%v}`, id)), nil
+		case strings.HasPrefix(path, "/schemas/ids/"):
+			return []byte(fmt.Sprintf(`[{"subject":%q,"version":1}]`, lastSubject)), nil
+		case strings.HasPrefix(path, "/subjects/") && strings.HasSuffix(path, "/versions/1"):
+			return []byte(fmt.Sprintf(`{"subject":%q,"version":1,"id":%v,"schema":"{}"}`, lastSubject, atomic.LoadInt32(registrations))), nil
+		}
+		return nil, errors.New("nope")
+	})
+}
+
+func TestSchemaRegistryEncodeAutoRegister(t *testing.T) {
+	var registrations int32
+	urlStr := runAutoRegisterSchemaRegistryServer(t, &registrations)
+
+	topic, err := service.NewInterpolatedString(`${! @topic.not_null() }`)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		strategy     subjectNameStrategy
+		autoRegister autoRegisterConfig
+		topic        string
+		inputs       []string
+		outputs      []string
+		errContains  string
+	}{
+		{
+			name:     "inferred avro schema",
+			strategy: subjectNameStrategyTopicName,
+			autoRegister: autoRegisterConfig{
+				schemaType:         franz_sr.TypeAvro,
+				recordName:         "Record",
+				checkCompatibility: true,
+			},
+			topic:   "foo",
+			inputs:  []string{`{"name":"foo","age":30}`, `{"age":10,"name":"bar"}`},
+			outputs: []string{"\x00\x00\x00\x00\x01\x3c\x06foo", "\x00\x00\x00\x00\x01\x14\x06bar"},
+		},
+		{
+			name:     "inferred json schema",
+			strategy: subjectNameStrategyTopicRecordName,
+			autoRegister: autoRegisterConfig{
+				schemaType: franz_sr.TypeJSON,
+				recordName: "Record",
+			},
+			topic:   "foo",
+			inputs:  []string{`{"name":"foo"}`},
+			outputs: []string{"\x00\x00\x00\x00\x02{\"name\":\"foo\"}"},
+		},
+		{
+			name:     "provided avro schema",
+			strategy: subjectNameStrategyRecordName,
+			autoRegister: autoRegisterConfig{
+				schema:     &franz_sr.Schema{Schema: testSchema},
+				schemaType: franz_sr.TypeAvro,
+			},
+			inputs:  []string{`{"Name":"foo","MaybeHobby":null}`},
+			outputs: []string{"\x00\x00\x00\x00\x03\x06foo\x00\x00"},
+		},
+		{
+			name:     "incompatible schema",
+			strategy: subjectNameStrategyRecordName,
+			autoRegister: autoRegisterConfig{
+				schemaType:         franz_sr.TypeAvro,
+				recordName:         "incompatible",
+				checkCompatibility: true,
+			},
+			inputs:      []string{`{"name":"foo"}`},
+			errContains: "schema is incompatible with the latest schema of subject \"incompatible\": field removed",
+		},
+		{
+			name:     "already registered schema",
+			strategy: subjectNameStrategyRecordName,
+			autoRegister: autoRegisterConfig{
+				schemaType:         franz_sr.TypeAvro,
+				recordName:         "registered",
+				checkCompatibility: true,
+			},
+			inputs:  []string{`{"name":"foo"}`},
+			outputs: []string{"\x00\x00\x00\x00\x07\x06foo"},
+		},
+		{
+			name:     "missing topic",
+			strategy: subjectNameStrategyTopicName,
+			autoRegister: autoRegisterConfig{
+				schemaType: franz_sr.TypeAvro,
+				recordName: "Record",
+			},
+			inputs:      []string{`{"name":"foo"}`},
+			errContains: "topic interpolation error",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			encoder, err := newSchemaRegistryEncoder(urlStr, noopReqSign, nil, nil, false, time.Minute*10, time.Minute, service.MockResources())
+			require.NoError(t, err)
+			require.NoError(t, encoder.setSubjectStrategy(test.strategy, topic, false, &test.autoRegister))
+
+			for i, input := range test.inputs {
+				msg := service.NewMessage([]byte(input))
+				if test.topic != "" {
+					msg.MetaSetMut("topic", test.topic)
+				}
+				outBatches, err := encoder.ProcessBatch(context.Background(), service.MessageBatch{msg})
+				require.NoError(t, err)
+				require.Len(t, outBatches, 1)
+				require.Len(t, outBatches[0], 1)
+
+				err = outBatches[0][0].GetError()
+				if test.errContains != "" {
+					require.Error(t, err)
+					assert.Contains(t, err.Error(), test.errContains)
+					continue
+				}
+				require.NoError(t, err)
+
+				b, err := outBatches[0][0].AsBytes()
+				require.NoError(t, err)
+				assert.Equal(t, test.outputs[i], string(b))
+			}
+
+			require.NoError(t, encoder.Close(context.Background()))
+		})
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&registrations))
+}
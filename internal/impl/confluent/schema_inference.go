@@ -0,0 +1,268 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confluent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	franz_sr "github.com/twmb/franz-go/pkg/sr"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/protobuf"
+)
+
+var avroNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type avroInferredField struct {
+	Name string `json:"name"`
+	Type any    `json:"type"`
+}
+
+type avroInferredRecord struct {
+	Type   string              `json:"type"`
+	Name   string              `json:"name"`
+	Fields []avroInferredField `json:"fields"`
+}
+
+type avroInferredArray struct {
+	Type  string `json:"type"`
+	Items any    `json:"items"`
+}
+
+// inferAvroSchema infers an avro schema of a record with the given (optionally
+// namespaced) name from a structured message. Nested objects are inferred as
+// records named after the path of their field.
+func inferAvroSchema(name string, v any) (string, error) {
+	root, ok := v.(map[string]any)
+	if !ok {
+		return "", errors.New("an avro schema can only be inferred from an object")
+	}
+	schema, err := inferAvroRecord(name, root)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func inferAvroRecord(name string, obj map[string]any) (avroInferredRecord, error) {
+	// Nested records inherit the namespace of the root record, and so only the
+	// simple name is used as a prefix.
+	simpleName := name[strings.LastIndex(name, ".")+1:]
+
+	record := avroInferredRecord{
+		Type:   "record",
+		Name:   name,
+		Fields: []avroInferredField{},
+	}
+	for _, key := range sortedKeys(obj) {
+		if !avroNameRegexp.MatchString(key) {
+			return avroInferredRecord{}, fmt.Errorf("field name '%v' is not a valid avro name", key)
+		}
+		t, err := inferAvroType(simpleName+"_"+key, obj[key])
+		if err != nil {
+			return avroInferredRecord{}, fmt.Errorf("field '%v': %w", key, err)
+		}
+		record.Fields = append(record.Fields, avroInferredField{Name: key, Type: t})
+	}
+	return record, nil
+}
+
+func inferAvroType(name string, v any) (any, error) {
+	switch t := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return "boolean", nil
+	case string:
+		return "string", nil
+	case []byte:
+		return "bytes", nil
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return "long", nil
+		}
+		return "double", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "long", nil
+	case float32, float64:
+		return "double", nil
+	case map[string]any:
+		return inferAvroRecord(name, t)
+	case []any:
+		items, err := inferItems(t, func(elem any) (any, error) {
+			return inferAvroType(name, elem)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if items == nil {
+			items = "null"
+		}
+		return avroInferredArray{Type: "array", Items: items}, nil
+	}
+	return nil, fmt.Errorf("unable to infer avro type of %T", v)
+}
+
+// inferJSONSchema infers a JSON schema with the given title from a structured
+// message, where all properties of objects are required.
+func inferJSONSchema(title string, v any) (string, error) {
+	schema, err := inferJSONSchemaType(v)
+	if err != nil {
+		return "", err
+	}
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = title
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func inferJSONSchemaType(v any) (map[string]any, error) {
+	switch t := v.(type) {
+	case nil:
+		return map[string]any{"type": "null"}, nil
+	case bool:
+		return map[string]any{"type": "boolean"}, nil
+	case string:
+		return map[string]any{"type": "string"}, nil
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return map[string]any{"type": "integer"}, nil
+		}
+		return map[string]any{"type": "number"}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return map[string]any{"type": "integer"}, nil
+	case float32, float64:
+		return map[string]any{"type": "number"}, nil
+	case map[string]any:
+		properties := map[string]any{}
+		required := sortedKeys(t)
+		for _, key := range required {
+			p, err := inferJSONSchemaType(t[key])
+			if err != nil {
+				return nil, fmt.Errorf("property '%v': %w", key, err)
+			}
+			properties[key] = p
+		}
+		return map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}, nil
+	case []any:
+		items, err := inferItems(t, func(elem any) (any, error) {
+			return inferJSONSchemaType(elem)
+		})
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"type": "array"}
+		if items != nil {
+			schema["items"] = items
+		}
+		return schema, nil
+	}
+	return nil, fmt.Errorf("unable to infer json schema type of %T", v)
+}
+
+// inferItems infers the type of the elements of an array, which must all be of
+// the same type, returning nil for empty arrays.
+func inferItems(arr []any, inferFn func(elem any) (any, error)) (any, error) {
+	var items any
+	var itemsJSON []byte
+	for i, elem := range arr {
+		t, err := inferFn(elem)
+		if err != nil {
+			return nil, fmt.Errorf("element %v: %w", i, err)
+		}
+		tJSON, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			items, itemsJSON = t, tJSON
+		} else if string(tJSON) != string(itemsJSON) {
+			return nil, errors.New("unable to infer the type of arrays with elements of different types")
+		}
+	}
+	return items, nil
+}
+
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// schemaRecordName returns the fully qualified name of the record described by
+// a schema, which is used by the record name subject strategies: the full name
+// of an avro named type, the title of a JSON schema or the full name of the
+// first message of a protobuf schema.
+func schemaRecordName(schema franz_sr.Schema) (string, error) {
+	switch schema.Type {
+	case franz_sr.TypeProtobuf:
+		files, _, err := protobuf.RegistriesFromMap(map[string]string{".": schema.Schema})
+		if err != nil {
+			return "", fmt.Errorf("failed to parse proto schema: %v", err)
+		}
+		targetFile, err := files.FindFileByPath(".")
+		if err != nil {
+			return "", err
+		}
+		if targetFile.Messages().Len() == 0 {
+			return "", errors.New("proto schema does not contain any messages")
+		}
+		return string(targetFile.Messages().Get(0).FullName()), nil
+	case franz_sr.TypeJSON:
+		var obj struct {
+			Title string `json:"title"`
+		}
+		if err := json.Unmarshal([]byte(schema.Schema), &obj); err != nil {
+			return "", fmt.Errorf("failed to parse json schema: %w", err)
+		}
+		if obj.Title == "" {
+			return "", errors.New("json schema does not have a title")
+		}
+		return obj.Title, nil
+	default:
+		var obj struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		}
+		if err := json.Unmarshal([]byte(schema.Schema), &obj); err != nil {
+			return "", fmt.Errorf("failed to parse avro schema as a named type: %w", err)
+		}
+		if obj.Name == "" {
+			return "", errors.New("avro schema does not have a name")
+		}
+		if obj.Namespace == "" || strings.Contains(obj.Name, ".") {
+			return obj.Name, nil
+		}
+		return obj.Namespace + "." + obj.Name, nil
+	}
+}
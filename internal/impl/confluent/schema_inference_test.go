@@ -0,0 +1,130 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confluent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	franz_sr "github.com/twmb/franz-go/pkg/sr"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestInferAvroSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		output      string
+		errContains string
+	}{
+		{
+			name:   "flat object",
+			input:  `{"name":"foo","age":30,"score":1.5,"active":true,"nickname":null}`,
+			output: `{"type":"record","name":"com.example.Record","fields":[{"name":"active","type":"boolean"},{"name":"age","type":"long"},{"name":"name","type":"string"},{"name":"nickname","type":"null"},{"name":"score","type":"double"}]}`,
+		},
+		{
+			name:   "nested object and arrays",
+			input:  `{"address":{"city":"foo"},"tags":["a","b"],"empty":[]}`,
+			output: `{"type":"record","name":"com.example.Record","fields":[{"name":"address","type":{"type":"record","name":"Record_address","fields":[{"name":"city","type":"string"}]}},{"name":"empty","type":{"type":"array","items":"null"}},{"name":"tags","type":{"type":"array","items":"string"}}]}`,
+		},
+		{
+			name:        "mixed array",
+			input:       `{"tags":["a",1]}`,
+			errContains: "elements of different types",
+		},
+		{
+			name:        "invalid field name",
+			input:       `{"foo-bar":"a"}`,
+			errContains: "is not a valid avro name",
+		},
+		{
+			name:        "not an object",
+			input:       `["a"]`,
+			errContains: "can only be inferred from an object",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			v, err := service.NewMessage([]byte(test.input)).AsStructured()
+			require.NoError(t, err)
+
+			schema, err := inferAvroSchema("com.example.Record", v)
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, test.output, schema)
+		})
+	}
+}
+
+func TestInferJSONSchema(t *testing.T) {
+	v, err := service.NewMessage([]byte(`{"name":"foo","age":30,"tags":["a"],"address":{"city":"foo"}}`)).AsStructured()
+	require.NoError(t, err)
+
+	schema, err := inferJSONSchema("Record", v)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "Record",
+  "type": "object",
+  "properties": {
+    "address": {"type":"object","properties":{"city":{"type":"string"}},"required":["city"]},
+    "age": {"type":"integer"},
+    "name": {"type":"string"},
+    "tags": {"type":"array","items":{"type":"string"}}
+  },
+  "required": ["address","age","name","tags"]
+}`, schema)
+}
+
+func TestSchemaRecordName(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema franz_sr.Schema
+		output string
+	}{
+		{
+			name:   "avro",
+			schema: franz_sr.Schema{Schema: testSchema},
+			output: "foo.namespace.com.identity",
+		},
+		{
+			name:   "json",
+			schema: franz_sr.Schema{Schema: `{"title":"Order","type":"object"}`, Type: franz_sr.TypeJSON},
+			output: "Order",
+		},
+		{
+			name:   "protobuf",
+			schema: franz_sr.Schema{Schema: testProtoSchema, Type: franz_sr.TypeProtobuf},
+			output: "ksql.users",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			name, err := schemaRecordName(test.schema)
+			require.NoError(t, err)
+			assert.Equal(t, test.output, name)
+		})
+	}
+}
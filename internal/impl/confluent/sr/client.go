@@ -17,6 +17,7 @@ package sr
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -159,6 +160,35 @@ func (c *Client) CreateSchema(ctx context.Context, subject string, schema sr.Sch
 	return ss.ID, nil
 }
 
+// LookupSchema returns the ID of a schema registered under the given subject,
+// and whether the schema is registered under the subject at all.
+func (c *Client) LookupSchema(ctx context.Context, subject string, schema sr.Schema) (int, bool, error) {
+	ss, err := c.Client.LookupSchema(ctx, subject, schema)
+	if err != nil {
+		var respErr *sr.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to look up schema for subject %q: %s", subject, err)
+	}
+	return ss.ID, true, nil
+}
+
+// CheckCompatibility checks whether a schema is compatible with the latest
+// schema of a subject according to the compatibility level of the subject. A
+// schema is always compatible with a subject that does not exist yet.
+func (c *Client) CheckCompatibility(ctx context.Context, subject string, schema sr.Schema) (sr.CheckCompatibilityResult, error) {
+	res, err := c.Client.CheckCompatibility(sr.WithParams(ctx, sr.Verbose), subject, -1, schema)
+	if err != nil {
+		var respErr *sr.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			return sr.CheckCompatibilityResult{Is: true}, nil
+		}
+		return sr.CheckCompatibilityResult{}, fmt.Errorf("failed to check compatibility of schema for subject %q: %s", subject, err)
+	}
+	return res, nil
+}
+
 type refWalkFn func(ctx context.Context, name string, info sr.Schema) error
 
 // WalkReferences goes through the provided schema info and for each reference
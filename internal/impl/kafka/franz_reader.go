@@ -15,7 +15,10 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/dustin/go-humanize"
@@ -53,6 +56,8 @@ const (
 	kfrFieldTopics                 = "topics"
 	kfrFieldRegexpTopics           = "regexp_topics"
 	kfrFieldStartFromOldest        = "start_from_oldest"
+	kfrFieldStartOffset            = "start_offset"
+	kfrFieldStartOffsets           = "start_offsets"
 	kfrFieldFetchMaxBytes          = "fetch_max_bytes"
 	kfrFieldFetchMinBytes          = "fetch_min_bytes"
 	kfrFieldFetchMaxPartitionBytes = "fetch_max_partition_bytes"
@@ -60,6 +65,7 @@ const (
 	kfrFieldSessionTimeout         = "session_timeout"
 	kfrFieldRebalanceTimeout       = "rebalance_timeout"
 	kfrFieldHeartbeatInterval      = "heartbeat_interval"
+	kfrFieldIsolationLevel         = "isolation_level"
 )
 
 // FranzConsumerFields returns a slice of fields specifically for customising
@@ -103,9 +109,22 @@ Finally, it's also possible to specify an explicit offset to consume from by add
 			Default("3s").
 			Advanced(),
 		service.NewBoolField(kfrFieldStartFromOldest).
-			Description("Determines whether to consume from the oldest available offset, otherwise messages are consumed from the latest offset. The setting is applied when creating a new consumer group or the saved offset no longer exists.").
+			Description("Determines whether to consume from the oldest available offset, otherwise messages are consumed from the latest offset. The setting is applied when creating a new consumer group or the saved offset no longer exists. This field is ignored when `start_offset` is set.").
 			Default(true).
 			Advanced(),
+		service.NewStringField(kfrFieldStartOffset).
+			Description("Determines the offset to consume from, which is either `earliest`, `latest`, an RFC3339 timestamp, in which case records are consumed from the first record written at or after that time, or a duration such as `1h`, in which case records are consumed from the first record written at or after that period of time before the input was created. As with `start_from_oldest`, the setting is applied when creating a new consumer group or the saved offset no longer exists, and to partitions listed in `topics` without an explicit offset. When set this field takes precedence over `start_from_oldest`.").
+			Example("earliest").
+			Example("2006-01-02T15:04:05Z").
+			Example("24h").
+			Optional().
+			Advanced(),
+		service.NewStringListField(kfrFieldStartOffsets).
+			Description("A list of explicit offsets to consume from in the form `topic:partition:offset`, where the partition can also be a range such as `0-10`. Multiple comma separated offsets can be listed in a single element. The offsets are applied to partitions without a committed offset when using a consumer group, and to partitions listed in `topics` without an explicit offset otherwise, taking precedence over `start_offset` and `start_from_oldest`.").
+			Example([]string{"foo:0:1000", "foo:1:1200"}).
+			Example([]string{"foo:0-3:500"}).
+			Optional().
+			Advanced(),
 		service.NewStringField(kfrFieldFetchMaxBytes).
 			Description("Sets the maximum amount of bytes a broker will try to send during a fetch. Note that brokers may not obey this limit if it has records larger than this limit. This is the equivalent to the Java fetch.max.bytes setting.").
 			Advanced().
@@ -122,6 +141,13 @@ Finally, it's also possible to specify an explicit offset to consume from by add
 			Description("Sets the maximum amount of bytes that will be consumed for a single partition in a fetch request. Note that if a single batch is larger than this number, that batch will still be returned so the client can make progress. This is the equivalent to the Java fetch.max.partition.bytes setting.").
 			Advanced().
 			Default("1MiB"),
+		service.NewStringAnnotatedEnumField(kfrFieldIsolationLevel, map[string]string{
+			"read_uncommitted": "Records are consumed as soon as they are written, including records of transactions that are still open or that end up aborted.",
+			"read_committed":   "Only records of committed transactions (and records written outside of transactions) are consumed, and records of aborted transactions are skipped.",
+		}).
+			Description("Determines which records written within transactions are consumed. This is the equivalent to the Java isolation.level setting.").
+			Advanced().
+			Default("read_uncommitted"),
 	}
 }
 
@@ -136,11 +162,13 @@ type FranzConsumerDetails struct {
 	InitialOffset          kgo.Offset
 	Topics                 []string
 	TopicPartitions        map[string]map[int32]kgo.Offset
+	StartOffsets           map[string]map[int32]kgo.Offset
 	RegexPattern           bool
 	FetchMinBytes          int32
 	FetchMaxBytes          int32
 	FetchMaxPartitionBytes int32
 	FetchMaxWait           time.Duration
+	IsolationLevel         kgo.IsolationLevel
 }
 
 // FranzConsumerDetailsFromConfig returns a summary of kafka consumer
@@ -180,6 +208,26 @@ func FranzConsumerDetailsFromConfig(conf *service.ParsedConfig) (*FranzConsumerD
 		d.InitialOffset = kgo.NewOffset().AtEnd()
 	}
 
+	if conf.Contains(kfrFieldStartOffset) {
+		startOffsetStr, err := conf.FieldString(kfrFieldStartOffset)
+		if err != nil {
+			return nil, err
+		}
+		if d.InitialOffset, err = parseStartOffset(startOffsetStr, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %w", kfrFieldStartOffset, err)
+		}
+	}
+
+	if conf.Contains(kfrFieldStartOffsets) {
+		startOffsetsList, err := conf.FieldStringList(kfrFieldStartOffsets)
+		if err != nil {
+			return nil, err
+		}
+		if d.StartOffsets, err = parseStartOffsets(startOffsetsList); err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %w", kfrFieldStartOffsets, err)
+		}
+	}
+
 	topicList, err := conf.FieldStringList(kfrFieldTopics)
 	if err != nil {
 		return nil, err
@@ -195,7 +243,13 @@ func FranzConsumerDetailsFromConfig(conf *service.ParsedConfig) (*FranzConsumerD
 		for topic, partitions := range topicPartitionsInts {
 			partMap := map[int32]kgo.Offset{}
 			for part, offset := range partitions {
-				partMap[part] = kgo.NewOffset().At(offset)
+				if offset != defaultOffset {
+					partMap[part] = kgo.NewOffset().At(offset)
+				} else if startOffset, exists := d.StartOffsets[topic][part]; exists {
+					partMap[part] = startOffset
+				} else {
+					partMap[part] = d.InitialOffset
+				}
 			}
 			d.TopicPartitions[topic] = partMap
 		}
@@ -219,6 +273,19 @@ func FranzConsumerDetailsFromConfig(conf *service.ParsedConfig) (*FranzConsumerD
 		return nil, err
 	}
 
+	isolationLevel, err := conf.FieldString(kfrFieldIsolationLevel)
+	if err != nil {
+		return nil, err
+	}
+	switch isolationLevel {
+	case "read_uncommitted":
+		d.IsolationLevel = kgo.ReadUncommitted()
+	case "read_committed":
+		d.IsolationLevel = kgo.ReadCommitted()
+	default:
+		return nil, fmt.Errorf("unknown isolation level: %v", isolationLevel)
+	}
+
 	return &d, nil
 }
 
@@ -234,6 +301,7 @@ func (d *FranzConsumerDetails) FranzOpts() []kgo.Opt {
 		kgo.FetchMinBytes(d.FetchMinBytes),
 		kgo.FetchMaxPartitionBytes(d.FetchMaxPartitionBytes),
 		kgo.FetchMaxWait(d.FetchMaxWait),
+		kgo.FetchIsolationLevel(d.IsolationLevel),
 		kgo.SessionTimeout(d.SessionTimeout),
 		kgo.RebalanceTimeout(d.RebalanceTimeout),
 		kgo.HeartbeatInterval(d.HeartbeatInterval),
@@ -247,9 +315,77 @@ func (d *FranzConsumerDetails) FranzOpts() []kgo.Opt {
 		opts = append(opts, kgo.InstanceID(d.InstanceID))
 	}
 
+	if len(d.StartOffsets) > 0 {
+		// Partitions of a consumer group without a committed offset are
+		// assigned the reset offset, which we replace with the explicit start
+		// offset of the partition if there is one.
+		opts = append(opts, kgo.AdjustFetchOffsetsFn(func(_ context.Context, offsets map[string]map[int32]kgo.Offset) (map[string]map[int32]kgo.Offset, error) {
+			for topic, partitions := range offsets {
+				for part, offset := range partitions {
+					if startOffset, exists := d.StartOffsets[topic][part]; exists && offset == d.InitialOffset {
+						partitions[part] = startOffset
+					}
+				}
+			}
+			return offsets, nil
+		}))
+	}
+
 	return opts
 }
 
+// parseStartOffset parses the offset to start consuming from, which is either
+// `earliest`, `latest`, an RFC3339 timestamp or a duration relative to now.
+func parseStartOffset(s string, now time.Time) (kgo.Offset, error) {
+	switch s {
+	case "earliest":
+		return kgo.NewOffset().AtStart(), nil
+	case "latest":
+		return kgo.NewOffset().AtEnd(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return kgo.NewOffset().AfterMilli(t.UnixMilli()), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		if d < 0 {
+			return kgo.Offset{}, fmt.Errorf("duration '%v' must not be negative", s)
+		}
+		return kgo.NewOffset().AfterMilli(now.Add(-d).UnixMilli()), nil
+	}
+	return kgo.Offset{}, fmt.Errorf("'%v' is not earliest, latest, an RFC3339 timestamp or a duration", s)
+}
+
+// parseStartOffsets parses a list of explicit offsets of the form
+// `topic:partition:offset`.
+func parseStartOffsets(list []string) (map[string]map[int32]kgo.Offset, error) {
+	// The default offset is used in order to detect missing offsets
+	const noOffset = math.MinInt64
+
+	topics, topicPartitions, err := ParseTopics(list, noOffset, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(topics) > 0 {
+		return nil, fmt.Errorf("offset '%v' is invalid, a partition and an offset must be specified", strings.Join(topics, ","))
+	}
+
+	startOffsets := map[string]map[int32]kgo.Offset{}
+	for topic, partitions := range topicPartitions {
+		partMap := map[int32]kgo.Offset{}
+		for part, offset := range partitions {
+			if offset == noOffset {
+				return nil, fmt.Errorf("offset '%v:%v' is invalid, an offset must be specified", topic, part)
+			}
+			if offset < 0 {
+				return nil, fmt.Errorf("offset '%v:%v:%v' is invalid, offsets must not be negative", topic, part, offset)
+			}
+			partMap[part] = kgo.NewOffset().At(offset)
+		}
+		startOffsets[topic] = partMap
+	}
+	return startOffsets, nil
+}
+
 // FranzConsumerOptsFromConfig returns a slice of franz-go client opts from a
 // parsed config.
 func FranzConsumerOptsFromConfig(conf *service.ParsedConfig) ([]kgo.Opt, error) {
@@ -0,0 +1,131 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestKafkaStartOffsetParsing(t *testing.T) {
+	now := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    kgo.Offset
+		expectedErr string
+	}{
+		{
+			name:     "earliest",
+			input:    "earliest",
+			expected: kgo.NewOffset().AtStart(),
+		},
+		{
+			name:     "latest",
+			input:    "latest",
+			expected: kgo.NewOffset().AtEnd(),
+		},
+		{
+			name:     "timestamp",
+			input:    "2026-10-01T00:00:00Z",
+			expected: kgo.NewOffset().AfterMilli(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).UnixMilli()),
+		},
+		{
+			name:     "duration",
+			input:    "24h",
+			expected: kgo.NewOffset().AfterMilli(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).UnixMilli()),
+		},
+		{
+			name:        "negative duration",
+			input:       "-1h",
+			expectedErr: "must not be negative",
+		},
+		{
+			name:        "unknown",
+			input:       "oldest",
+			expectedErr: "is not earliest, latest, an RFC3339 timestamp or a duration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset, err := parseStartOffset(test.input, now)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, offset)
+		})
+	}
+}
+
+func TestKafkaStartOffsetsParsing(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []string
+		expected    map[string]map[int32]kgo.Offset
+		expectedErr string
+	}{
+		{
+			name:  "explicit offsets",
+			input: []string{"foo:0:10", "foo:1:20,bar:0-1:30"},
+			expected: map[string]map[int32]kgo.Offset{
+				"foo": {
+					0: kgo.NewOffset().At(10),
+					1: kgo.NewOffset().At(20),
+				},
+				"bar": {
+					0: kgo.NewOffset().At(30),
+					1: kgo.NewOffset().At(30),
+				},
+			},
+		},
+		{
+			name:        "missing partition",
+			input:       []string{"foo"},
+			expectedErr: "a partition and an offset must be specified",
+		},
+		{
+			name:        "missing offset",
+			input:       []string{"foo:0"},
+			expectedErr: "an offset must be specified",
+		},
+		{
+			name:        "negative offset",
+			input:       []string{"foo:0:-2"},
+			expectedErr: "offsets must not be negative",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offsets, err := parseStartOffsets(test.input)
+			if test.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, offsets)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// FranzReaderTransactional implements a kafka reader using the franz-go library
+// where each batch of consumed records is processed within a producer
+// transaction, and the offsets of the records are committed as part of that
+// transaction. Records written to the transaction via the shared client are
+// therefore only visible to read committed consumers once the records that
+// they were produced from are marked as consumed, which provides exactly once
+// semantics for read-process-write pipelines.
+//
+// Since the offsets of all consumed records are committed at the end of a
+// transaction, only one batch is ever in flight.
+type FranzReaderTransactional struct {
+	clientOpts      func() ([]kgo.Opt, error)
+	connDetails     *FranzConnectionDetails
+	consumerGroup   string
+	transactionalID string
+	clientLabel     string
+
+	sessionMut sync.Mutex
+	session    *kgo.GroupTransactSession
+
+	// txnSlot is held from the moment records are polled until the
+	// transaction they belong to has ended.
+	txnSlot chan struct{}
+
+	res *service.Resources
+	log *service.Logger
+}
+
+// NewFranzReaderTransactional attempts to instantiate a new
+// FranzReaderTransactional reader. The client is shared under the label of the
+// component so that outputs can produce records within its transactions.
+func NewFranzReaderTransactional(connDetails *FranzConnectionDetails, consumerGroup, transactionalID string, res *service.Resources, optsFn func() ([]kgo.Opt, error)) (*FranzReaderTransactional, error) {
+	if consumerGroup == "" {
+		return nil, errors.New("a consumer group is required in order to consume within transactions")
+	}
+	if res.Label() == "" {
+		return nil, errors.New("a label is required in order to share the transactional client with outputs")
+	}
+	return &FranzReaderTransactional{
+		clientOpts:      optsFn,
+		connDetails:     connDetails,
+		consumerGroup:   consumerGroup,
+		transactionalID: transactionalID,
+		clientLabel:     res.Label(),
+		txnSlot:         make(chan struct{}, 1),
+		res:             res,
+		log:             res.Logger(),
+	}, nil
+}
+
+// Connect to the kafka seed brokers.
+func (f *FranzReaderTransactional) Connect(ctx context.Context) error {
+	f.sessionMut.Lock()
+	defer f.sessionMut.Unlock()
+
+	if f.session != nil {
+		return nil
+	}
+
+	clientOpts, err := f.clientOpts()
+	if err != nil {
+		return err
+	}
+	clientOpts = append(clientOpts,
+		kgo.ConsumerGroup(f.consumerGroup),
+		kgo.TransactionalID(f.transactionalID),
+		// Prevents fetching offsets of partitions that still have a pending
+		// transactional commit from a previous owner, which would otherwise
+		// result in duplicates after a rebalance.
+		kgo.RequireStableFetchOffsets(),
+		kgo.WithLogger(&KGoLogger{f.log}),
+	)
+
+	session, err := kgo.NewGroupTransactSession(clientOpts...)
+	if err != nil {
+		return err
+	}
+
+	// Check connectivity to cluster
+	if err := session.Client().Ping(ctx); err != nil {
+		session.Close()
+		return fmt.Errorf("failed to connect to cluster: %s", err)
+	}
+
+	if err := FranzSharedClientSet(f.clientLabel, &FranzSharedClientInfo{
+		Client:      session.Client(),
+		ConnDetails: f.connDetails,
+	}, f.res); err != nil {
+		session.Close()
+		return fmt.Errorf("failed to store client connection for sharing: %w", err)
+	}
+
+	f.session = session
+	return nil
+}
+
+func (f *FranzReaderTransactional) getSession() *kgo.GroupTransactSession {
+	f.sessionMut.Lock()
+	defer f.sessionMut.Unlock()
+	return f.session
+}
+
+// closeSession closes the given session if it is still the active one, which
+// results in consumption resuming from the last committed offsets after the
+// next call to Connect.
+func (f *FranzReaderTransactional) closeSession(session *kgo.GroupTransactSession) {
+	f.sessionMut.Lock()
+	defer f.sessionMut.Unlock()
+
+	if f.session != session {
+		return
+	}
+	_, _ = FranzSharedClientPop(f.clientLabel, f.res)
+	f.session.Close()
+	f.session = nil
+}
+
+// ReadBatch attempts to extract a batch of messages from the target topics and
+// begins the transaction that they are processed within.
+func (f *FranzReaderTransactional) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	select {
+	case f.txnSlot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+
+	batch, ackFn, err := f.readBatch(ctx)
+	if err != nil {
+		<-f.txnSlot
+		return nil, nil, err
+	}
+	return batch, ackFn, nil
+}
+
+func (f *FranzReaderTransactional) readBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	session := f.getSession()
+	if session == nil {
+		return nil, nil, service.ErrNotConnected
+	}
+
+	var fetches kgo.Fetches
+	for {
+		fetches = session.PollFetches(ctx)
+
+		for _, kerr := range fetches.Errors() {
+			if errors.Is(kerr.Err, context.DeadlineExceeded) ||
+				errors.Is(kerr.Err, context.Canceled) {
+				continue
+			}
+			if !errors.Is(kerr.Err, kgo.ErrClientClosed) {
+				f.log.Errorf("Kafka poll error on topic %v, partition %v: %v", kerr.Topic, kerr.Partition, kerr.Err)
+			}
+			f.closeSession(session)
+			return nil, nil, service.ErrNotConnected
+		}
+
+		// Records that have been polled must be delivered, as otherwise their
+		// offsets would be committed with the next transaction.
+		if fetches.NumRecords() > 0 {
+			break
+		}
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	if err := session.Begin(); err != nil {
+		// The polled records are not part of a transaction and therefore we
+		// reconnect in order to consume them again.
+		f.closeSession(session)
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	var batch service.MessageBatch
+	fetches.EachPartition(func(p kgo.FetchTopicPartition) {
+		for _, r := range p.Records {
+			lag := max(p.HighWatermark-r.Offset-1, 0)
+
+			msg := FranzRecordToMessageV1(r)
+			msg.MetaSetMut("kafka_lag", lag)
+			batch = append(batch, msg)
+		}
+	})
+
+	return batch, func(ctx context.Context, res error) error {
+		defer func() {
+			<-f.txnSlot
+		}()
+
+		// Aborting the transaction rewinds the session to the last committed
+		// offsets, and so the records of this batch are consumed again.
+		commit := kgo.TryCommit
+		if res != nil {
+			commit = kgo.TryAbort
+		}
+
+		committed, err := session.End(ctx, commit)
+		if err != nil {
+			f.closeSession(session)
+			return fmt.Errorf("failed to end transaction: %w", err)
+		}
+		if res == nil && !committed {
+			f.log.Warn("Transaction was aborted due to a rebalance, records will be consumed again")
+		}
+		return nil
+	}, nil
+}
+
+// Close underlying connections.
+func (f *FranzReaderTransactional) Close(ctx context.Context) error {
+	f.sessionMut.Lock()
+	defer f.sessionMut.Unlock()
+
+	if f.session == nil {
+		return nil
+	}
+	_, _ = FranzSharedClientPop(f.clientLabel, f.res)
+	f.session.Close()
+	f.session = nil
+	return nil
+}
@@ -15,12 +15,17 @@
 package kafka
 
 import (
+	"errors"
 	"slices"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
+const (
+	riFieldTransactionalID = "transactional_id"
+)
+
 func redpandaInputConfig() *service.ConfigSpec {
 	return service.NewConfigSpec().
 		Beta().
@@ -33,6 +38,29 @@ When a consumer group is specified this input consumes one or more topics where
 
 When using consumer groups the offsets of "delivered" records will be committed automatically and continuously, and in the event of restarts these committed offsets will be used in order to resume from where the input left off. Redpanda Connect guarantees at least once delivery by ensuring that records are only considerd to be delivered when all configured outputs that the record is routed to have confirmed delivery.
 
+=== Exactly Once
+
+When a ` + "`transactional_id`" + ` is specified each batch of consumed records is processed within a Kafka transaction, and the offsets of the records are committed as part of that transaction instead of periodically. The client of this input is shared with ` + "`redpanda`" + ` outputs that reference the label of this input in their ` + "`transactional_input`" + ` field, which write their records within the same transaction. Therefore the records written by those outputs become visible to consumers with an ` + "`isolation_level`" + ` of ` + "`read_committed`" + ` if and only if the offsets of the records they were produced from are committed, which prevents duplicates when the pipeline restarts or the consumer group rebalances.
+
+In this mode only one batch of records is processed at a time, a delivery error aborts the transaction and the records are consumed again, and the field ` + "`auto_retry_nacks`" + ` has no effect. For example, the following config moves records between topics exactly once:
+
+` + "```yaml" + `
+input:
+  label: exactly_once_in
+  redpanda:
+    seed_brokers: [ localhost:9092 ]
+    topics: [ foo ]
+    consumer_group: foo_to_bar
+    transactional_id: foo_to_bar
+    isolation_level: read_committed
+
+output:
+  redpanda:
+    seed_brokers: [ localhost:9092 ]
+    topic: bar
+    transactional_input: exactly_once_in
+` + "```" + `
+
 == Ordering
 
 In order to preserve ordering of topic partitions, records consumed from each partition are processed and delivered in the order that they are received, and only one batch of records of a given partition will ever be processed at a time. This means that parallel processing can only occur when multiple topic partitions are being consumed, but ensures that data is processed in a sequential order as determined from the source partition.
@@ -103,6 +131,10 @@ func redpandaInputConfigFields() []*service.ConfigField {
 		FranzConsumerFields(),
 		FranzReaderOrderedConfigFields(),
 		[]*service.ConfigField{
+			service.NewStringField(riFieldTransactionalID).
+				Description("When specified each batch of consumed records is processed within a transaction with this ID, where the offsets of the records are committed along with the records written by outputs that reference this input in their `transactional_input` field. The ID must be unique per consumer within the consumer group and should remain the same between restarts. Requires a consumer group and a label to be set on this input.").
+				Optional().
+				Advanced(),
 			service.NewAutoRetryNacksToggleField(),
 		},
 	)
@@ -111,17 +143,39 @@ func redpandaInputConfigFields() []*service.ConfigField {
 func init() {
 	err := service.RegisterBatchInput("redpanda", redpandaInputConfig(),
 		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
-			tmpOpts, err := FranzConnectionOptsFromConfig(conf, mgr.Logger())
+			connDetails, err := FranzConnectionDetailsFromConfig(conf, mgr.Logger())
 			if err != nil {
 				return nil, err
 			}
-			clientOpts := append([]kgo.Opt{}, tmpOpts...)
+			clientOpts := append([]kgo.Opt{}, connDetails.FranzOpts()...)
 
-			if tmpOpts, err = FranzConsumerOptsFromConfig(conf); err != nil {
+			tmpOpts, err := FranzConsumerOptsFromConfig(conf)
+			if err != nil {
 				return nil, err
 			}
 			clientOpts = append(clientOpts, tmpOpts...)
 
+			if conf.Contains(riFieldTransactionalID) {
+				transactionalID, err := conf.FieldString(riFieldTransactionalID)
+				if err != nil {
+					return nil, err
+				}
+				if transactionalID == "" {
+					return nil, errors.New("transactional_id must not be empty")
+				}
+				consumerGroup, _ := conf.FieldString(kroFieldConsumerGroup)
+
+				// Nacks must abort the transaction rather than being retried
+				// within it, and so auto retries are not applied.
+				rdr, err := NewFranzReaderTransactional(connDetails, consumerGroup, transactionalID, mgr, func() ([]kgo.Opt, error) {
+					return clientOpts, nil
+				})
+				if err != nil {
+					return nil, err
+				}
+				return rdr, nil
+			}
+
 			rdr, err := NewFranzReaderOrderedFromConfig(conf, mgr, func() ([]kgo.Opt, error) {
 				return clientOpts, nil
 			})
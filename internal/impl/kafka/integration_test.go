@@ -23,12 +23,14 @@ import (
 	"testing"
 	"time"
 
+	"github.com/redpanda-data/benthos/v4/public/service"
 	"github.com/redpanda-data/benthos/v4/public/service/integration"
 
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/kmsg"
@@ -433,6 +435,129 @@ input:
 	)
 }
 
+func TestIntegrationRedpandaTransactions(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	kafkaPort, err := integration.GetFreePort()
+	require.NoError(t, err)
+
+	kafkaPortStr := strconv.Itoa(kafkaPort)
+
+	options := &dockertest.RunOptions{
+		Repository:   "redpandadata/redpanda",
+		Tag:          "latest",
+		Hostname:     "redpanda",
+		ExposedPorts: []string{"9092/tcp"},
+		PortBindings: map[docker.Port][]docker.PortBinding{
+			"9092/tcp": {{HostIP: "", HostPort: kafkaPortStr + "/tcp"}},
+		},
+		Cmd: []string{
+			"redpanda",
+			"start",
+			"--node-id 0",
+			"--mode dev-container",
+			"--set rpk.additional_start_flags=[--reactor-backend=epoll]",
+			"--kafka-addr 0.0.0.0:9092",
+			fmt.Sprintf("--advertise-kafka-addr localhost:%v", kafkaPort),
+		},
+	}
+
+	pool.MaxWait = time.Minute
+	resource, err := pool.RunWithOptions(options)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, pool.Purge(resource))
+	})
+
+	_ = resource.Expire(900)
+	require.NoError(t, pool.Retry(func() error {
+		return createKafkaTopic(context.Background(), "localhost:"+kafkaPortStr, "txn_in", 2)
+	}))
+	require.NoError(t, createKafkaTopic(context.Background(), "localhost:"+kafkaPortStr, "txn_out", 2))
+
+	ctx, done := context.WithTimeout(context.Background(), time.Minute*2)
+	defer done()
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers("localhost:" + kafkaPortStr))
+	require.NoError(t, err)
+	defer producer.Close()
+
+	n := 100
+	for i := 0; i < n; i++ {
+		require.NoError(t, producer.ProduceSync(ctx, &kgo.Record{
+			Topic: "topic-txn_in",
+			Value: fmt.Appendf(nil, "hello world %v", i),
+		}).FirstErr())
+	}
+
+	streamBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamBuilder.SetYAML(fmt.Sprintf(`
+input:
+  label: txn_input
+  redpanda:
+    seed_brokers: [ localhost:%[1]v ]
+    topics: [ topic-txn_in ]
+    consumer_group: txn_group
+    transactional_id: txn_id
+    isolation_level: read_committed
+  processors:
+    - mapping: 'root = content().uppercase()'
+
+output:
+  redpanda:
+    seed_brokers: [ localhost:%[1]v ]
+    topic: topic-txn_out
+    transactional_input: txn_input
+`, kafkaPortStr)))
+
+	stream, err := streamBuilder.Build()
+	require.NoError(t, err)
+	go func() {
+		assert.NoError(t, stream.Run(ctx))
+	}()
+
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers("localhost:"+kafkaPortStr),
+		kgo.ConsumeTopics("topic-txn_out"),
+		kgo.FetchIsolationLevel(kgo.ReadCommitted()),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	received := map[string]int{}
+	for len(received) < n && ctx.Err() == nil {
+		fetches := consumer.PollFetches(ctx)
+		fetches.EachRecord(func(r *kgo.Record) {
+			received[string(r.Value)]++
+		})
+	}
+	for i := 0; i < n; i++ {
+		assert.Equal(t, 1, received[fmt.Sprintf("HELLO WORLD %v", i)])
+	}
+
+	// The offsets of all consumed records are committed along with the
+	// transactions.
+	adm := kadm.NewClient(producer)
+	assert.Eventually(t, func() bool {
+		offsets, err := adm.FetchOffsets(ctx, "txn_group")
+		if err != nil {
+			return false
+		}
+		var committed int64
+		offsets.Each(func(o kadm.OffsetResponse) {
+			committed += o.At
+		})
+		return committed == int64(n)
+	}, time.Second*30, time.Millisecond*500)
+
+	require.NoError(t, stream.Stop(ctx))
+}
+
 func BenchmarkIntegrationRedpanda(b *testing.B) {
 	integration.CheckSkip(b)
 
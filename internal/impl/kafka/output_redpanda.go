@@ -25,7 +25,8 @@ import (
 )
 
 const (
-	roFieldMaxInFlight = "max_in_flight"
+	roFieldMaxInFlight        = "max_in_flight"
+	roFieldTransactionalInput = "transactional_input"
 )
 
 func redpandaOutputConfig() *service.ConfigSpec {
@@ -35,6 +36,8 @@ func redpandaOutputConfig() *service.ConfigSpec {
 		Summary("A Kafka output using the https://github.com/twmb/franz-go[Franz Kafka client library^].").
 		Description(`
 Writes a batch of messages to Kafka brokers and waits for acknowledgement before propagating it back to the input.
+
+When the field ` + "`transactional_input`" + ` is set messages are written within the transactions of a ` + "`redpanda`" + ` input with a ` + "`transactional_id`" + `, using the client of that input, so that the records are committed along with the offsets of the records they were produced from. Consult the documentation of the ` + "`redpanda`" + ` input for more details. In this mode the client settings of this output, such as the ` + "`seed_brokers`" + ` and the producer settings, are not used.
 `).
 		Fields(redpandaOutputConfigFields()...).
 		LintRule(FranzWriterConfigLints())
@@ -48,6 +51,10 @@ func redpandaOutputConfigFields() []*service.ConfigField {
 			service.NewIntField(roFieldMaxInFlight).
 				Description("The maximum number of batches to be sending in parallel at any given time.").
 				Default(256),
+			service.NewStringField(roFieldTransactionalInput).
+				Description("The label of a `redpanda` input with a `transactional_id` within the transactions of which messages are written, providing exactly once delivery from that input to this output.").
+				Optional().
+				Advanced(),
 		},
 		FranzProducerFields(),
 	)
@@ -65,6 +72,23 @@ func init() {
 				return
 			}
 
+			if conf.Contains(roFieldTransactionalInput) {
+				var inputLabel string
+				if inputLabel, err = conf.FieldString(roFieldTransactionalInput); err != nil {
+					return
+				}
+
+				// The client is owned by the input, which begins and ends the
+				// transaction of each batch.
+				output, err = NewFranzWriterFromConfig(
+					conf,
+					NewFranzWriterHooks(
+						func(_ context.Context, fn FranzSharedClientUseFn) error {
+							return FranzSharedClientUse(inputLabel, mgr, fn)
+						}))
+				return
+			}
+
 			var tmpOpts, clientOpts []kgo.Opt
 
 			var connDetails *FranzConnectionDetails
@@ -9,6 +9,8 @@
 package mysql
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -30,6 +32,8 @@ const (
 	MessageOperationUpdate MessageOperation = "update"
 	// MessageOperationDelete represents delete statement in mysql binlog
 	MessageOperationDelete MessageOperation = "delete"
+	// MessageOperationDDL represents a schema change of a table in mysql binlog
+	MessageOperationDDL MessageOperation = "ddl"
 )
 
 // MessageEvent represents a message from mysql cdc plugin
@@ -38,6 +42,93 @@ type MessageEvent struct {
 	Table     string           `json:"table"`
 	Operation MessageOperation `json:"operation"`
 	Position  *position        `json:"position"`
+	// Before is the row before an update
+	Before map[string]any `json:"before,omitempty"`
+	// GTID is the GTID of the transaction of the message, it is only set in GTID mode
+	GTID string `json:"gtid,omitempty"`
+	// GTIDSet is the set of transactions executed before the transaction of the message, it is only set in GTID mode
+	GTIDSet string `json:"gtid_set,omitempty"`
+
+	// snapshotKey are the primary key values of a snapshot row
+	snapshotKey []any
+	// snapshotStart marks the start of an incremental snapshot of a table, it is not emitted as a message
+	snapshotStart bool
+	// snapshotDone marks the end of the snapshot of a table, it is not emitted as a message
+	snapshotDone bool
+}
+
+// checkpointVersion is the version of the format of the checkpoints written to the cache. Version 1 checkpoints
+// are plain binlog positions as produced by binlogPositionToString.
+const checkpointVersion = 2
+
+// binlogCheckpoint is the position the stream resumes from without missing any message.
+type binlogCheckpoint struct {
+	Position *position
+	// GTIDSet is empty for checkpoints written in file mode or migrated from version 1
+	GTIDSet string
+	// Snapshot is the progress of each table while a snapshot is being read, and nil once it has completed
+	Snapshot map[string]snapshotProgress
+}
+
+// snapshotProgress is the progress of the snapshot of a single table.
+type snapshotProgress struct {
+	// LastKey are the primary key values of the last row emitted, as returned by snapshotKeyValues
+	LastKey []any `json:"last_key,omitempty"`
+	Done    bool  `json:"done,omitempty"`
+}
+
+type binlogCheckpointJSON struct {
+	Version        int                         `json:"version"`
+	BinlogPosition string                      `json:"binlog_position,omitempty"`
+	GTIDSet        string                      `json:"gtid_set,omitempty"`
+	Snapshot       map[string]snapshotProgress `json:"snapshot,omitempty"`
+}
+
+func checkpointToString(cp binlogCheckpoint) (string, error) {
+	j := binlogCheckpointJSON{
+		Version:  checkpointVersion,
+		GTIDSet:  cp.GTIDSet,
+		Snapshot: cp.Snapshot,
+	}
+	if cp.Position != nil {
+		j.BinlogPosition = binlogPositionToString(*cp.Position)
+	}
+	b, err := json.Marshal(j)
+	return string(b), err
+}
+
+func parseCheckpoint(str string) (*binlogCheckpoint, error) {
+	if !strings.HasPrefix(str, "{") {
+		pos, err := parseBinlogPosition(str)
+		if err != nil {
+			return nil, err
+		}
+		return &binlogCheckpoint{Position: &pos}, nil
+	}
+
+	var j binlogCheckpointJSON
+	// Keep primary key values as numbers rather than floats, which would lose the precision of large integers
+	dec := json.NewDecoder(strings.NewReader(str))
+	dec.UseNumber()
+	if err := dec.Decode(&j); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	if j.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version: %d", j.Version)
+	}
+
+	cp := &binlogCheckpoint{GTIDSet: j.GTIDSet, Snapshot: j.Snapshot}
+	if j.BinlogPosition != "" {
+		pos, err := parseBinlogPosition(j.BinlogPosition)
+		if err != nil {
+			return nil, err
+		}
+		cp.Position = &pos
+	}
+	if cp.Position == nil && cp.GTIDSet == "" {
+		return nil, errors.New("invalid checkpoint: neither a binlog position nor a GTID set is set")
+	}
+	return cp, nil
 }
 
 func binlogPositionToString(pos position) string {
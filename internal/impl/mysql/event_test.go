@@ -9,6 +9,7 @@
 package mysql
 
 import (
+	"encoding/json"
 	"math"
 	"strconv"
 	"testing"
@@ -39,3 +40,43 @@ func TestBinlogString(t *testing.T) {
 		require.Error(t, err)
 	}
 }
+
+func TestCheckpointString(t *testing.T) {
+	good := []binlogCheckpoint{
+		{Position: &position{Name: "log.0000", Pos: 32}},
+		{Position: &position{Name: "log@0000", Pos: 32}, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"},
+		{GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,5d1c7b5e-71ca-11e1-9e33-c80aa9429562:1-2"},
+		{
+			Position: &position{Name: "log.0000", Pos: 32},
+			Snapshot: map[string]snapshotProgress{
+				"foo": {},
+				"bar": {LastKey: []any{json.Number("9007199254740993"), "b"}},
+				"baz": {LastKey: []any{json.Number("1")}, Done: true},
+			},
+		},
+	}
+	for _, expected := range good {
+		str, err := checkpointToString(expected)
+		require.NoError(t, err)
+		actual, err := parseCheckpoint(str)
+		require.NoError(t, err)
+		require.Equal(t, expected, *actual)
+	}
+
+	// Version 1 checkpoints are plain binlog positions
+	actual, err := parseCheckpoint(binlogPositionToString(position{Name: "log.0001", Pos: 4}))
+	require.NoError(t, err)
+	require.Equal(t, binlogCheckpoint{Position: &position{Name: "log.0001", Pos: 4}}, *actual)
+
+	bad := []string{
+		"log.000",
+		`{"version":3,"gtid_set":"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}`,
+		`{"version":2}`,
+		`{"version":2,"binlog_position":"log.000"}`,
+		`{"version":2`,
+	}
+	for _, str := range bad {
+		_, err := parseCheckpoint(str)
+		require.Error(t, err, str)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package mysql
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"strings"
+
+	mysqlReplication "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	positionModeFile = "file"
+	positionModeGTID = "gtid"
+)
+
+// gtidSetAtPosition computes the set of transactions executed before `pos` by reading the binlog file of `pos` from
+// its start, which is used in order to migrate file based checkpoints to GTID sets. Transactions which are not
+// committed before `pos` are not part of the set, and are therefore replayed.
+func gtidSetAtPosition(ctx context.Context, logger *service.Logger, addr, user, password string, tlsConf *tls.Config, pos position) (mysqlReplication.GTIDSet, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s: %w", portStr, err)
+	}
+
+	syncer := replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		// Use a distinct server ID so that the scan does not kick out the main binlog stream
+		ServerID:  uint32(rand.IntN(1000)) + 2001,
+		Flavor:    mysqlReplication.MySQLFlavor,
+		Host:      host,
+		Port:      uint16(port),
+		User:      user,
+		Password:  password,
+		TLSConfig: tlsConf,
+	})
+	defer syncer.Close()
+
+	streamer, err := syncer.StartSync(position{Name: pos.Name, Pos: 4})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read binlog %s: %w", pos.Name, err)
+	}
+
+	var (
+		gset    *mysqlReplication.MysqlGTIDSet
+		pending mysqlReplication.GTIDSet
+	)
+	commit := func() error {
+		if pending == nil {
+			return nil
+		}
+		if gset == nil {
+			return errors.New("binlog does not contain a previous GTIDs event, is GTID mode enabled?")
+		}
+		if err := gset.Update(pending.String()); err != nil {
+			return err
+		}
+		pending = nil
+		return nil
+	}
+
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read binlog %s: %w", pos.Name, err)
+		}
+
+		switch e := ev.Event.(type) {
+		case *replication.RotateEvent:
+			// The first event is a fake rotate event to the requested file, a real rotate means the file has ended
+			if ev.Header.Timestamp != 0 && string(e.NextLogName) != pos.Name {
+				if gset == nil {
+					return nil, fmt.Errorf("binlog %s does not contain a previous GTIDs event", pos.Name)
+				}
+				return gset, nil
+			}
+			continue
+		case *replication.PreviousGTIDsEvent:
+			set, err := mysqlReplication.ParseMysqlGTIDSet(e.GTIDSets)
+			if err != nil {
+				return nil, fmt.Errorf("invalid previous GTIDs: %w", err)
+			}
+			gset = set.(*mysqlReplication.MysqlGTIDSet)
+		case *replication.GTIDEvent:
+			if ev.Header.LogPos <= pos.Pos {
+				if pending, err = e.GTIDNext(); err != nil {
+					return nil, err
+				}
+			}
+		case *replication.XIDEvent:
+			if ev.Header.LogPos <= pos.Pos {
+				if err := commit(); err != nil {
+					return nil, err
+				}
+			}
+		case *replication.QueryEvent:
+			// DDL statements are committed implicitly, whereas DML transactions start with `BEGIN`
+			if ev.Header.LogPos <= pos.Pos && !strings.EqualFold(string(e.Query), "BEGIN") {
+				if err := commit(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if ev.Header.LogPos >= pos.Pos {
+			if gset == nil {
+				return nil, fmt.Errorf("binlog %s does not contain a previous GTIDs event", pos.Name)
+			}
+			logger.Debugf("binlog position %s corresponds to GTID set %s", binlogPositionToString(pos), gset)
+			return gset, nil
+		}
+	}
+}
@@ -14,7 +14,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -22,12 +24,15 @@ import (
 	"github.com/Jeffail/checkpoint"
 	"github.com/Jeffail/shutdown"
 	"github.com/go-mysql-org/go-mysql/canal"
+	mysqlReplication "github.com/go-mysql-org/go-mysql/mysql"
 	"github.com/go-mysql-org/go-mysql/replication"
 	"github.com/go-mysql-org/go-mysql/schema"
 	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
 	"github.com/redpanda-data/benthos/v4/public/service"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/redpanda-data/connect/v4/internal/impl/cdc"
 	"github.com/redpanda-data/connect/v4/internal/license"
 )
 
@@ -40,6 +45,11 @@ const (
 	fieldCheckpointKey        = "checkpoint_key"
 	fieldCheckpointCache      = "checkpoint_cache"
 	fieldCheckpointLimit      = "checkpoint_limit"
+	fieldPositionMode         = "position_mode"
+	fieldStreamDDL            = "stream_ddl"
+	fieldSnapshotMode         = "snapshot_mode"
+	fieldMaxParallelSnapshot  = "max_parallel_snapshot_tables"
+	fieldSignalTable          = "signal_table"
 
 	shutdownTimeout = 5 * time.Second
 )
@@ -57,6 +67,46 @@ This input adds the following metadata fields to each message:
 - operation
 - table
 - binlog_position
+- gtid (only in `+"`gtid`"+` position mode)
+- before (only for updates)
+
+The operation is one of `+"`read`"+` (snapshot), `+"`insert`"+`, `+"`update`"+`, `+"`delete`"+` or `+"`ddl`"+`. The `+"`before`"+` metadata field of updates contains the row before the update as a structured object, it can be accessed via `+"`@before`"+` in xref:guides:bloblang/about.adoc[Bloblang].
+
+== Schema changes
+
+When `+"`"+fieldStreamDDL+"`"+` is enabled a message with the operation `+"`ddl`"+` is emitted for each streamed table changed by a DDL statement. It contains the statement and the schema of the table after the change, which allows downstream sinks to evolve their tables in lockstep:
+
+[source,json]
+----
+{
+  "statement": "ALTER TABLE foo ADD COLUMN b VARCHAR(32)",
+  "columns": [{ "name": "a", "type": "int" }, { "name": "b", "type": "varchar(32)" }],
+  "primary_key": ["a"]
+}
+----
+
+The fields `+"`columns`"+` and `+"`primary_key`"+` are omitted when the table has been dropped. Note that the schema is fetched from the server when the statement is read from the binlog, so when the stream lags behind it reflects any later changes as well.
+
+== Snapshots
+
+When `+"`"+fieldStreamSnapshot+"`"+` is enabled the existing rows of the tables are read in chunks of `+"`"+fieldSnapshotMaxBatchSize+"`"+` rows ordered by primary key, with up to `+"`"+fieldMaxParallelSnapshot+"`"+` tables read in parallel, before (or while) the changes are streamed from the binlog. The progress of the snapshot is stored in `+"`"+fieldCheckpointCache+"`"+`, which allows a snapshot that has been interrupted to resume from the last row delivered rather than from the start. When resuming, changes made since the snapshot started may be delivered more than once.
+
+The `+"`"+fieldSnapshotMode+"`"+` field determines how the snapshot is made consistent with the binlog:
+
+- `+"`"+snapshotModeGlobalLock+"`"+` briefly acquires a global read lock with `+"`FLUSH TABLES WITH READ LOCK`"+` in order to start consistent snapshot transactions at a known binlog position, and streams the binlog once all tables have been read. This requires the `+"`RELOAD`"+` privilege, which is often not granted on managed services such as Amazon RDS or Google Cloud SQL.
+- `+"`"+snapshotModeWatermark+"`"+` does not acquire any locks. The binlog is streamed while the tables are read, each chunk is emitted once the stream has caught up with the binlog position read after querying it, and rows of the chunk changed in the meantime are dropped in favour of the changes from the stream. Rows are therefore emitted in an order that reflects the history of each row, but changes to different tables or rows may be interleaved with snapshot rows. When `+"`"+fieldSignalTable+"`"+` is set, a low watermark is written to the signal table before each chunk is queried and changes are only tracked from the point the stream reads it, which guarantees that no change is missed. Without a signal table a change that is written to the binlog but not yet visible to the query of a chunk can, in rare cases, be overwritten by a stale row of the chunk.
+
+== Incremental snapshots
+
+Snapshots of tables can be triggered while streaming, e.g. after adding a table to `+"`"+fieldMySQLTables+"`"+` or in order to backfill a sink, either by inserting a row into the table `+"`"+fieldSignalTable+"`"+` or with the xref:guides:bloblang/functions.adoc#cdc_snapshot[`+"`cdc_snapshot`"+` Bloblang function] using the label of the input. Incremental snapshots are read in chunks without any locks in the same way as snapshots in the `+"`"+snapshotModeWatermark+"`"+` mode, and their progress is stored in `+"`"+fieldCheckpointCache+"`"+` so that they are resumed after a restart.
+
+`+cdc.SignalTableDescription+`
+
+== Position modes
+
+By default the position of the stream is checkpointed as a binlog file name and offset, which are specific to a single MySQL server. When `+"`"+fieldPositionMode+"`"+` is set to `+"`gtid`"+` the set of executed global transaction identifiers (GTIDs) is checkpointed instead, which allows the stream to resume from any server of a replication topology, e.g. after a failover from the primary to a replica. This requires `+"`gtid_mode=ON`"+` and `+"`enforce_gtid_consistency=ON`"+` on all servers, and `+"`log_replica_updates=ON`"+` on replicas.
+
+Checkpoints written by older versions of this input, which only contain a binlog file name and offset, are migrated to GTID sets when the input starts in `+"`gtid`"+` mode by reading the binlog file up to the checkpointed offset. The binlog file therefore needs to still be available on the server.
 `).
 	Fields(
 		service.NewStringField(fieldMySQLDSN).
@@ -73,8 +123,28 @@ This input adds the following metadata fields to each message:
 		service.NewIntField(fieldSnapshotMaxBatchSize).
 			Description("The maximum number of rows to be streamed in a single batch when taking a snapshot.").
 			Default(1000),
+		service.NewStringEnumField(fieldSnapshotMode, snapshotModeGlobalLock, snapshotModeWatermark).
+			Description("How the snapshot is made consistent with the binlog, either by briefly acquiring a global read lock (`"+snapshotModeGlobalLock+"`) or by reading chunks of rows between binlog watermarks without any locks (`"+snapshotModeWatermark+"`).").
+			Default(snapshotModeGlobalLock).
+			Advanced(),
+		service.NewIntField(fieldMaxParallelSnapshot).
+			Description("The number of tables that are read in parallel when taking a snapshot.").
+			Default(1).
+			Advanced(),
+		service.NewStringEnumField(fieldPositionMode, positionModeFile, positionModeGTID).
+			Description("How the position of the stream is checkpointed, either as a binlog file name and offset (`"+positionModeFile+"`) or as a set of executed GTIDs (`"+positionModeGTID+"`), which survives failovers between servers.").
+			Default(positionModeFile).
+			Advanced(),
 		service.NewBoolField(fieldStreamSnapshot).
 			Description("If set to true, the connector will query all the existing data as a part of snapshot process. Otherwise, it will start from the current binlog position."),
+		service.NewStringField(fieldSignalTable).
+			Description("A table of the database that is watched for signals, such as requests for incremental snapshots. The table is streamed in addition to `"+fieldMySQLTables+"`. The low watermarks of lock-free snapshots are written to and deleted from this table, which therefore requires the `INSERT` and `DELETE` privileges.").
+			Example("connect_signals").
+			Optional().
+			Advanced(),
+		service.NewBoolField(fieldStreamDDL).
+			Description("If set to true, schema changes of the streamed tables, such as `ALTER TABLE`, are emitted as messages with the operation `ddl`.").
+			Default(false),
 		service.NewAutoRetryNacksToggleField(),
 		service.NewIntField(fieldCheckpointLimit).
 			Description("The maximum number of messages that can be processed at a given time. Increasing this limit enables parallel processing and batching at the output level. Any given BinLog Position will not be acknowledged unless all messages under that offset are delivered in order to preserve at least once delivery guarantees.").
@@ -82,6 +152,8 @@ This input adds the following metadata fields to each message:
 		service.NewBatchPolicyField(fieldBatching),
 	)
 
+type checkpointCtxKey struct{}
+
 type asyncMessage struct {
 	msg   service.MessageBatch
 	ackFn service.AckFunc
@@ -97,6 +169,15 @@ type mysqlStreamInput struct {
 	binLogCache       string
 	binLogCacheKey    string
 	currentBinlogName string
+	positionMode      string
+	streamDDL         bool
+	snapshotMode      string
+	// changedTables are the streamed tables changed by the DDL statement being processed
+	changedTables []string
+	// currentGTID is the GTID of the current transaction and executedGTIDSet the set of all transactions committed
+	// before it, they are only tracked in GTID mode
+	currentGTID     string
+	executedGTIDSet string
 
 	dsn            string
 	tables         []string
@@ -107,13 +188,26 @@ type mysqlStreamInput struct {
 	tablesFilterMap           map[string]bool
 	checkPointLimit           int
 	fieldSnapshotMaxBatchSize int
+	maxParallelSnapshot       int
+
+	// snapshotProgress is the progress of each table while a snapshot is being read, it is owned by readMessages
+	snapshotProgress map[string]snapshotProgress
+	// watermarks interleaves the chunks of a lock-free snapshot with the binlog stream
+	watermarks *watermarkTracker
+
+	signalTable string
+	// snapshotRequests are the tables of triggered incremental snapshots that have not been started yet
+	snapshotRequestsMut sync.Mutex
+	snapshotRequests    []string
+	snapshotRequested   chan struct{}
+	deregisterTrigger   func()
 
 	logger *service.Logger
 	res    *service.Resources
 
 	rawMessageEvents chan MessageEvent
 	msgChan          chan asyncMessage
-	cp               *checkpoint.Capped[*position]
+	cp               *checkpoint.Capped[*binlogCheckpoint]
 
 	shutSig *shutdown.Signaller
 }
@@ -124,10 +218,11 @@ func newMySQLStreamInput(conf *service.ParsedConfig, res *service.Resources) (s
 	}
 
 	i := mysqlStreamInput{
-		logger:           res.Logger(),
-		rawMessageEvents: make(chan MessageEvent),
-		msgChan:          make(chan asyncMessage),
-		res:              res,
+		logger:            res.Logger(),
+		rawMessageEvents:  make(chan MessageEvent),
+		msgChan:           make(chan asyncMessage),
+		snapshotRequested: make(chan struct{}, 1),
+		res:               res,
 	}
 
 	var batching service.BatchPolicy
@@ -145,14 +240,33 @@ func newMySQLStreamInput(conf *service.ParsedConfig, res *service.Resources) (s
 		return nil, err
 	}
 
+	if i.positionMode, err = conf.FieldString(fieldPositionMode); err != nil {
+		return nil, err
+	}
+
 	if i.streamSnapshot, err = conf.FieldBool(fieldStreamSnapshot); err != nil {
 		return nil, err
 	}
 
+	if i.streamDDL, err = conf.FieldBool(fieldStreamDDL); err != nil {
+		return nil, err
+	}
+
 	if i.fieldSnapshotMaxBatchSize, err = conf.FieldInt(fieldSnapshotMaxBatchSize); err != nil {
 		return nil, err
 	}
 
+	if i.snapshotMode, err = conf.FieldString(fieldSnapshotMode); err != nil {
+		return nil, err
+	}
+
+	if i.maxParallelSnapshot, err = conf.FieldInt(fieldMaxParallelSnapshot); err != nil {
+		return nil, err
+	}
+	if i.maxParallelSnapshot < 1 {
+		return nil, fmt.Errorf("field `%s` must be at least 1", fieldMaxParallelSnapshot)
+	}
+
 	if i.checkPointLimit, err = conf.FieldInt(fieldCheckpointLimit); err != nil {
 		return nil, err
 	}
@@ -167,7 +281,16 @@ func newMySQLStreamInput(conf *service.ParsedConfig, res *service.Resources) (s
 		return nil, err
 	}
 
-	i.cp = checkpoint.NewCapped[*position](int64(i.checkPointLimit))
+	i.cp = checkpoint.NewCapped[*binlogCheckpoint](int64(i.checkPointLimit))
+
+	if conf.Contains(fieldSignalTable) {
+		if i.signalTable, err = conf.FieldString(fieldSignalTable); err != nil {
+			return nil, err
+		}
+		if err = validateTableName(i.signalTable); err != nil {
+			return nil, err
+		}
+	}
 
 	i.tablesFilterMap = map[string]bool{}
 	for _, table := range i.tables {
@@ -194,6 +317,9 @@ func newMySQLStreamInput(conf *service.ParsedConfig, res *service.Resources) (s
 	if err != nil {
 		return nil, err
 	}
+	if i.deregisterTrigger, err = cdc.RegisterSnapshotTrigger(res.Label(), i.triggerSnapshot); err != nil {
+		return nil, err
+	}
 
 	return conf.WrapBatchInputExtractTracingSpanMapping("mysql_cdc", r)
 }
@@ -225,7 +351,11 @@ func (i *mysqlStreamInput) Connect(ctx context.Context) error {
 	canalConfig.ParseTime = true
 	// canalConfig.Logger
 
-	for _, table := range i.tables {
+	streamedTables := i.tables
+	if i.signalTable != "" {
+		streamedTables = append(slices.Clone(streamedTables), i.signalTable)
+	}
+	for _, table := range streamedTables {
 		canalConfig.IncludeTableRegex = append(
 			canalConfig.IncludeTableRegex,
 			"^"+regexp.QuoteMeta(i.mysqlConfig.DBName+"."+table)+"$",
@@ -239,19 +369,54 @@ func (i *mysqlStreamInput) Connect(ctx context.Context) error {
 
 	i.canal = c
 
+	if i.positionMode == positionModeGTID {
+		if err := i.checkGTIDMode(); err != nil {
+			i.canal.Close()
+			return err
+		}
+	}
+
 	pos, err := i.getCachedBinlogPosition(ctx)
 	if err != nil {
+		i.canal.Close()
 		return fmt.Errorf("unable to get cached binlog position: %s", err)
 	}
-	// create snapshot instance if we were requested and haven't finished it before.
+	if pos != nil && i.positionMode == positionModeGTID && pos.GTIDSet == "" {
+		if pos, err = i.migrateCheckpointToGTID(ctx, pos); err != nil {
+			i.canal.Close()
+			return err
+		}
+	}
+	if pos != nil && i.positionMode == positionModeFile && pos.Position == nil {
+		i.canal.Close()
+		return fmt.Errorf("the cached checkpoint only contains a GTID set, set `%s` to `%s` in order to resume from it", fieldPositionMode, positionModeGTID)
+	}
+	// create snapshot instance if we were requested and haven't finished it before, or an incremental snapshot is in
+	// progress.
 	var snapshot *Snapshot
-	if i.streamSnapshot && pos == nil {
+	i.snapshotProgress = nil
+	if (i.streamSnapshot && pos == nil) || (pos != nil && pos.Snapshot != nil) {
 		db, err := sql.Open("mysql", i.dsn)
 		if err != nil {
+			i.canal.Close()
 			return fmt.Errorf("failed to connect to MySQL server: %s", err)
 		}
 		snapshot = NewSnapshot(i.logger, db)
+
+		i.snapshotProgress = map[string]snapshotProgress{}
+		for _, table := range i.tables {
+			if pos == nil {
+				i.snapshotProgress[table] = snapshotProgress{}
+			} else if p, exists := pos.Snapshot[table]; exists {
+				i.snapshotProgress[table] = p
+			}
+		}
+		if pos != nil {
+			i.logger.Infof("resuming interrupted snapshot")
+		}
 	}
+	// The progress is read by the snapshot and updated by readMessages concurrently
+	progress := maps.Clone(i.snapshotProgress)
 
 	// Reset the shutSig
 	sig := shutdown.NewSignaller()
@@ -265,7 +430,7 @@ func (i *mysqlStreamInput) Connect(ctx context.Context) error {
 			return nil
 		})
 		wg.Go(func() error { return i.readMessages(ctx) })
-		wg.Go(func() error { return i.startMySQLSync(ctx, pos, snapshot) })
+		wg.Go(func() error { return i.startMySQLSync(ctx, pos, snapshot, progress) })
 		if err := wg.Wait(); err != nil && !errors.Is(err, context.Canceled) {
 			i.logger.Errorf("error during MySQL CDC: %s", err)
 		} else {
@@ -277,15 +442,20 @@ func (i *mysqlStreamInput) Connect(ctx context.Context) error {
 	return nil
 }
 
-func (i *mysqlStreamInput) startMySQLSync(ctx context.Context, pos *position, snapshot *Snapshot) error {
-	// If we are given a snapshot, then we need to read it.
-	if snapshot != nil {
-		startPos, err := snapshot.prepareSnapshot(ctx)
+func (i *mysqlStreamInput) startMySQLSync(ctx context.Context, pos *binlogCheckpoint, snapshot *Snapshot, progress map[string]snapshotProgress) error {
+	// If we are given a snapshot taken with a global lock, then we need to read it before streaming.
+	if snapshot != nil && i.snapshotMode == snapshotModeGlobalLock {
+		startPos, err := snapshot.prepareSnapshot(ctx, i.maxParallelSnapshot)
 		if err != nil {
 			_ = snapshot.close()
 			return fmt.Errorf("unable to prepare snapshot: %w", err)
 		}
-		if err = i.readSnapshot(ctx, snapshot); err != nil {
+		// When resuming a snapshot the stream continues from the checkpoint rather than the new snapshot, which
+		// replays the changes made since the snapshot was started.
+		if pos == nil {
+			pos = startPos
+		}
+		if err = i.readLockedSnapshot(ctx, snapshot, pos, progress); err != nil {
 			_ = snapshot.close()
 			return fmt.Errorf("failed reading snapshot: %w", err)
 		}
@@ -296,103 +466,306 @@ func (i *mysqlStreamInput) startMySQLSync(ctx context.Context, pos *position, sn
 		if err = snapshot.close(); err != nil {
 			return fmt.Errorf("unable to close snapshot: %w", err)
 		}
-		pos = startPos
+		snapshot = nil
 	} else if pos == nil {
 		coords, err := i.canal.GetMasterPos()
 		if err != nil {
 			return fmt.Errorf("unable to get start binlog position: %w", err)
 		}
-		pos = &coords
+		pos = &binlogCheckpoint{Position: &coords}
+		if i.positionMode == positionModeGTID {
+			gset, err := i.canal.GetMasterGTIDSet()
+			if err != nil {
+				return fmt.Errorf("unable to get start GTID set: %w", err)
+			}
+			pos.GTIDSet = gset.String()
+		}
 	}
-	i.logger.Infof("starting MySQL CDC stream from binlog %s at offset %d", pos.Name, pos.Pos)
-	i.currentBinlogName = pos.Name
 	i.canal.SetEventHandler(i)
-	if err := i.canal.RunFrom(*pos); err != nil {
+
+	// Lock-free and incremental snapshots are read while streaming
+	i.watermarks = newWatermarkTracker(binlogCheckpoint{Position: pos.Position, GTIDSet: pos.GTIDSet})
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.Go(func() error {
+		if err := i.runWatermarkSnapshots(ctx, snapshot, progress); err != nil {
+			// Stop the stream, which does not observe the context
+			i.canal.Close()
+			return fmt.Errorf("failed reading snapshot: %w", err)
+		}
+		return nil
+	})
+	wg.Go(func() error { return i.runBinlogStream(pos) })
+	return wg.Wait()
+}
+
+// runWatermarkSnapshots reads the lock-free snapshot if one is given, followed by incremental snapshots as they are
+// triggered.
+func (i *mysqlStreamInput) runWatermarkSnapshots(ctx context.Context, snapshot *Snapshot, progress map[string]snapshotProgress) error {
+	for {
+		if snapshot != nil {
+			err := i.readWatermarkSnapshot(ctx, snapshot, progress)
+			if cErr := snapshot.close(); err == nil && cErr != nil {
+				err = fmt.Errorf("unable to close snapshot: %w", cErr)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-i.snapshotRequested:
+		case <-ctx.Done():
+			return nil
+		}
+
+		i.snapshotRequestsMut.Lock()
+		tables := i.snapshotRequests
+		i.snapshotRequests = nil
+		i.snapshotRequestsMut.Unlock()
+
+		i.logger.Infof("starting incremental snapshot of tables %v", tables)
+		db, err := sql.Open("mysql", i.dsn)
+		if err != nil {
+			return fmt.Errorf("failed to connect to MySQL server: %s", err)
+		}
+		snapshot = NewSnapshot(i.logger, db)
+		progress = map[string]snapshotProgress{}
+		for _, table := range tables {
+			progress[table] = snapshotProgress{}
+			// Restart the progress of the table in the checkpoint
+			if err := i.sendEvent(ctx, MessageEvent{Table: table, snapshotStart: true}); err != nil {
+				_ = snapshot.close()
+				return err
+			}
+		}
+	}
+}
+
+// triggerSnapshot schedules an incremental snapshot of the tables.
+func (i *mysqlStreamInput) triggerSnapshot(tables []string) error {
+	for _, table := range tables {
+		if _, ok := i.tablesFilterMap[table]; !ok {
+			return fmt.Errorf("table %s is not streamed by this input", table)
+		}
+	}
+
+	i.snapshotRequestsMut.Lock()
+	for _, table := range tables {
+		if !slices.Contains(i.snapshotRequests, table) {
+			i.snapshotRequests = append(i.snapshotRequests, table)
+		}
+	}
+	i.snapshotRequestsMut.Unlock()
+
+	select {
+	case i.snapshotRequested <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (i *mysqlStreamInput) runBinlogStream(pos *binlogCheckpoint) error {
+	if i.positionMode == positionModeGTID {
+		gset, err := mysqlReplication.ParseMysqlGTIDSet(pos.GTIDSet)
+		if err != nil {
+			return fmt.Errorf("invalid GTID set %s: %w", pos.GTIDSet, err)
+		}
+		i.logger.Infof("starting MySQL CDC stream from GTID set %s", gset)
+		i.executedGTIDSet = gset.String()
+		if err := i.canal.StartFromGTID(gset); err != nil {
+			return fmt.Errorf("failed to start streaming: %w", err)
+		}
+		return nil
+	}
+
+	i.logger.Infof("starting MySQL CDC stream from binlog %s at offset %d", pos.Position.Name, pos.Position.Pos)
+	i.currentBinlogName = pos.Position.Name
+	if err := i.canal.RunFrom(*pos.Position); err != nil {
 		return fmt.Errorf("failed to start streaming: %w", err)
 	}
 	return nil
 }
 
-func (i *mysqlStreamInput) readSnapshot(ctx context.Context, snapshot *Snapshot) error {
-	// TODO(cdc): Process tables in parallel
+// checkGTIDMode returns an error if GTIDs are not enabled on the server.
+func (i *mysqlStreamInput) checkGTIDMode() error {
+	res, err := i.canal.Execute("SELECT @@GLOBAL.gtid_mode")
+	if err != nil {
+		return fmt.Errorf("unable to check gtid_mode: %w", err)
+	}
+	mode, err := res.GetString(0, 0)
+	if err != nil {
+		return fmt.Errorf("unable to check gtid_mode: %w", err)
+	}
+	if !strings.EqualFold(mode, "ON") {
+		return fmt.Errorf("`%s: %s` requires gtid_mode=ON, got %s", fieldPositionMode, positionModeGTID, mode)
+	}
+	return nil
+}
+
+// migrateCheckpointToGTID converts a checkpoint that only contains a binlog position, e.g. one written before GTID
+// mode was enabled, into a GTID set and persists it.
+func (i *mysqlStreamInput) migrateCheckpointToGTID(ctx context.Context, pos *binlogCheckpoint) (*binlogCheckpoint, error) {
+	i.logger.Infof("migrating checkpoint at binlog %s offset %d to a GTID set", pos.Position.Name, pos.Position.Pos)
+
+	gset, err := gtidSetAtPosition(ctx, i.logger, i.mysqlConfig.Addr, i.mysqlConfig.User, i.mysqlConfig.Passwd, i.mysqlConfig.TLS, *pos.Position)
+	if err != nil {
+		return nil, fmt.Errorf("unable to migrate checkpoint to a GTID set: %w", err)
+	}
+
+	migrated := &binlogCheckpoint{Position: pos.Position, GTIDSet: gset.String()}
+	if err := i.setCachedBinlogPosition(ctx, *migrated); err != nil {
+		return nil, err
+	}
+	i.logger.Infof("migrated checkpoint to GTID set %s", migrated.GTIDSet)
+	return migrated, nil
+}
+
+// readSnapshot reads the tables that have not been completed in parallel, `readTable` reads a table starting after
+// the primary key values `lastKey`.
+func (i *mysqlStreamInput) readSnapshot(
+	ctx context.Context,
+	progress map[string]snapshotProgress,
+	readTable func(ctx context.Context, table string, lastKey []any) error,
+) error {
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.SetLimit(i.maxParallelSnapshot)
 	for _, table := range i.tables {
-		tablePks, err := snapshot.getTablePrimaryKeys(ctx, table)
+		p, exists := progress[table]
+		if !exists || p.Done {
+			continue
+		}
+		wg.Go(func() error {
+			if err := readTable(ctx, table, p.LastKey); err != nil {
+				return fmt.Errorf("failed to snapshot table %s: %w", table, err)
+			}
+			return i.sendEvent(ctx, MessageEvent{Table: table, snapshotDone: true})
+		})
+	}
+	return wg.Wait()
+}
+
+func (i *mysqlStreamInput) readLockedSnapshot(ctx context.Context, snapshot *Snapshot, pos *binlogCheckpoint, progress map[string]snapshotProgress) error {
+	txs := make(chan *sql.Tx, len(snapshot.txs))
+	for _, tx := range snapshot.txs {
+		txs <- tx
+	}
+	return i.readSnapshot(ctx, progress, func(ctx context.Context, table string, lastKey []any) error {
+		tx := <-txs
+		defer func() { txs <- tx }()
+
+		tablePks, err := snapshot.getTablePrimaryKeys(ctx, tx, table)
 		if err != nil {
 			return err
 		}
 		i.logger.Tracef("primary keys for table %s: %v", table, tablePks)
-		lastSeenPksValues := map[string]any{}
-		for _, pk := range tablePks {
-			lastSeenPksValues[pk] = nil
-		}
 
-		var numRowsProcessed int
 		for {
-			var batchRows *sql.Rows
-			if numRowsProcessed == 0 {
-				batchRows, err = snapshot.querySnapshotTable(ctx, table, tablePks, nil, i.fieldSnapshotMaxBatchSize)
-			} else {
-				batchRows, err = snapshot.querySnapshotTable(ctx, table, tablePks, &lastSeenPksValues, i.fieldSnapshotMaxBatchSize)
-			}
+			rows, err := snapshot.querySnapshotChunk(ctx, tx, table, tablePks, lastKey, i.fieldSnapshotMaxBatchSize)
 			if err != nil {
-				return fmt.Errorf("failed to execute snapshot table query: %s", err)
+				return err
 			}
-
-			types, err := batchRows.ColumnTypes()
-			if err != nil {
-				return fmt.Errorf("failed to fetch column types: %s", err)
+			for _, row := range rows {
+				lastKey = snapshotKeyValues(row, tablePks)
+				if err := i.sendEvent(ctx, MessageEvent{
+					Row:         row,
+					Operation:   MessageOperationRead,
+					Table:       table,
+					Position:    pos.Position,
+					GTIDSet:     pos.GTIDSet,
+					snapshotKey: lastKey,
+				}); err != nil {
+					return err
+				}
+			}
+			if len(rows) < i.fieldSnapshotMaxBatchSize {
+				return nil
 			}
+		}
+	})
+}
 
-			values, mappers := prepSnapshotScannerAndMappers(types)
+func (i *mysqlStreamInput) readWatermarkSnapshot(ctx context.Context, snapshot *Snapshot, progress map[string]snapshotProgress) error {
+	return i.readSnapshot(ctx, progress, func(ctx context.Context, table string, lastKey []any) error {
+		tablePks, err := snapshot.getTablePrimaryKeys(ctx, snapshot.db, table)
+		if err != nil {
+			return err
+		}
+		i.logger.Tracef("primary keys for table %s: %v", table, tablePks)
 
-			columns, err := batchRows.Columns()
+		for {
+			cw, err := i.openWindow(ctx, snapshot.db, table)
 			if err != nil {
-				return fmt.Errorf("failed to fetch columns: %s", err)
+				return err
+			}
+			rows, err := snapshot.querySnapshotChunk(ctx, snapshot.db, table, tablePks, lastKey, i.fieldSnapshotMaxBatchSize)
+			if err != nil {
+				return err
+			}
+			high, err := i.canal.GetMasterPos()
+			if err != nil {
+				return fmt.Errorf("unable to get high watermark: %w", err)
 			}
 
-			var batchRowsCount int
-			for batchRows.Next() {
-				numRowsProcessed++
-				batchRowsCount++
-
-				if err := batchRows.Scan(values...); err != nil {
-					return err
-				}
-
-				row := map[string]any{}
-				for idx, value := range values {
-					v, err := mappers[idx](value)
-					if err != nil {
-						return err
-					}
-					row[columns[idx]] = v
-					if _, ok := lastSeenPksValues[columns[idx]]; ok {
-						lastSeenPksValues[columns[idx]] = value
-					}
-				}
-
-				select {
-				case i.rawMessageEvents <- MessageEvent{
-					Row:       row,
-					Operation: MessageOperationRead,
-					Table:     table,
-					Position:  nil,
-				}:
-				case <-ctx.Done():
-					return ctx.Err()
+			events := make([]MessageEvent, len(rows))
+			keys := make([]string, len(rows))
+			for idx, row := range rows {
+				lastKey = snapshotKeyValues(row, tablePks)
+				events[idx] = MessageEvent{
+					Row:         row,
+					Operation:   MessageOperationRead,
+					Table:       table,
+					snapshotKey: lastKey,
 				}
+				keys[idx] = rowKey(lastKey)
 			}
-
-			if err := batchRows.Err(); err != nil {
-				return fmt.Errorf("failed to iterate snapshot table: %s", err)
+			if err := i.watermarks.close(cw, high, events, keys, func(me MessageEvent) error {
+				return i.sendEvent(ctx, me)
+			}); err != nil {
+				return err
 			}
 
-			if batchRowsCount < i.fieldSnapshotMaxBatchSize {
-				break
+			select {
+			case <-cw.done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if len(rows) < i.fieldSnapshotMaxBatchSize {
+				return nil
 			}
 		}
+	})
+}
+
+// openWindow opens the window of a chunk of the table that is about to be queried. With a signal table the window is
+// opened at a low watermark written to the signal table, see watermarkTracker, otherwise it is opened right away.
+func (i *mysqlStreamInput) openWindow(ctx context.Context, db *sql.DB, table string) (*chunkWindow, error) {
+	if i.signalTable == "" {
+		return i.watermarks.open(table), nil
+	}
+
+	id := uuid.NewString()
+	cw := i.watermarks.openAt(table, id)
+	if _, err := db.ExecContext(ctx, "INSERT INTO "+i.signalTable+" (id, type, data) VALUES (?, ?, ?)", id, signalTypeSnapshotWindowOpen, table); err != nil {
+		return nil, fmt.Errorf("unable to write low watermark: %w", err)
+	}
+	select {
+	case <-cw.opened:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM "+i.signalTable+" WHERE id = ?", id); err != nil {
+		i.logger.Warnf("unable to delete low watermark %s: %s", id, err)
+	}
+	return cw, nil
+}
+
+func (i *mysqlStreamInput) sendEvent(ctx context.Context, me MessageEvent) error {
+	select {
+	case i.rawMessageEvents <- me:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return nil
 }
 
 func snapshotValueMapper[T any](v any) (any, error) {
@@ -502,6 +875,23 @@ func (i *mysqlStreamInput) readMessages(ctx context.Context) error {
 				return fmt.Errorf("failed to flush periodic batch: %w", err)
 			}
 		case me := <-i.rawMessageEvents:
+			if me.snapshotStart {
+				if i.snapshotProgress == nil {
+					i.snapshotProgress = map[string]snapshotProgress{}
+				}
+				i.snapshotProgress[me.Table] = snapshotProgress{}
+				continue
+			}
+			if me.snapshotDone {
+				i.completeSnapshotTable(me.Table)
+				continue
+			}
+			if me.snapshotKey != nil {
+				p := i.snapshotProgress[me.Table]
+				p.LastKey = me.snapshotKey
+				i.snapshotProgress[me.Table] = p
+			}
+
 			row, err := json.Marshal(me.Row)
 			if err != nil {
 				return fmt.Errorf("failed to serialize row: %w", err)
@@ -510,9 +900,22 @@ func (i *mysqlStreamInput) readMessages(ctx context.Context) error {
 			mb := service.NewMessage(row)
 			mb.MetaSet("operation", string(me.Operation))
 			mb.MetaSet("table", me.Table)
-			if me.Position != nil {
+			if me.Position != nil && me.Operation != MessageOperationRead {
 				mb.MetaSet("binlog_position", binlogPositionToString(*me.Position))
 			}
+			if me.Position != nil || me.GTIDSet != "" {
+				mb = mb.WithContext(context.WithValue(mb.Context(), checkpointCtxKey{}, &binlogCheckpoint{
+					Position: me.Position,
+					GTIDSet:  me.GTIDSet,
+					Snapshot: maps.Clone(i.snapshotProgress),
+				}))
+			}
+			if me.GTID != "" {
+				mb.MetaSet("gtid", me.GTID)
+			}
+			if me.Before != nil {
+				mb.MetaSetMut("before", me.Before)
+			}
 
 			if i.batchPolicy.Add(mb) {
 				nextTimedBatchChan = nil
@@ -533,9 +936,24 @@ func (i *mysqlStreamInput) readMessages(ctx context.Context) error {
 	}
 }
 
+// completeSnapshotTable marks the snapshot of a table as done, once all tables are done the progress is no longer
+// checkpointed.
+func (i *mysqlStreamInput) completeSnapshotTable(table string) {
+	p := i.snapshotProgress[table]
+	p.Done = true
+	i.snapshotProgress[table] = p
+	for _, p := range i.snapshotProgress {
+		if !p.Done {
+			return
+		}
+	}
+	i.logger.Info("snapshot completed")
+	i.snapshotProgress = nil
+}
+
 func (i *mysqlStreamInput) flushBatch(
 	ctx context.Context,
-	checkpointer *checkpoint.Capped[*position],
+	checkpointer *checkpoint.Capped[*binlogCheckpoint],
 	batch service.MessageBatch,
 ) error {
 	if len(batch) == 0 {
@@ -543,15 +961,7 @@ func (i *mysqlStreamInput) flushBatch(
 	}
 
 	lastMsg := batch[len(batch)-1]
-	strPosition, ok := lastMsg.MetaGet("binlog_position")
-	var binLogPos *position
-	if ok {
-		pos, err := parseBinlogPosition(strPosition)
-		if err != nil {
-			return err
-		}
-		binLogPos = &pos
-	}
+	binLogPos, _ := lastMsg.Context().Value(checkpointCtxKey{}).(*binlogCheckpoint)
 
 	resolveFn, err := checkpointer.Track(ctx, binLogPos, int64(len(batch)))
 	if err != nil {
@@ -568,7 +978,6 @@ func (i *mysqlStreamInput) flushBatch(
 				return nil
 			}
 			offset := *maxOffset
-			// This has no offset - it's a snapshot message
 			if offset == nil {
 				return nil
 			}
@@ -595,6 +1004,7 @@ func (i *mysqlStreamInput) ReadBatch(ctx context.Context) (service.MessageBatch,
 }
 
 func (i *mysqlStreamInput) Close(ctx context.Context) error {
+	i.deregisterTrigger()
 	if i.shutSig == nil {
 		return nil // Never connected
 	}
@@ -618,7 +1028,7 @@ func (i *mysqlStreamInput) Close(ctx context.Context) error {
 
 // ---- cache methods start ----
 
-func (i *mysqlStreamInput) getCachedBinlogPosition(ctx context.Context) (*position, error) {
+func (i *mysqlStreamInput) getCachedBinlogPosition(ctx context.Context) (*binlogCheckpoint, error) {
 	var (
 		cacheVal []byte
 		cErr     error
@@ -635,17 +1045,20 @@ func (i *mysqlStreamInput) getCachedBinlogPosition(ctx context.Context) (*positi
 	} else if cacheVal == nil {
 		return nil, nil
 	}
-	pos, err := parseBinlogPosition(string(cacheVal))
-	return &pos, err
+	return parseCheckpoint(string(cacheVal))
 }
 
-func (i *mysqlStreamInput) setCachedBinlogPosition(ctx context.Context, binLogPos position) error {
+func (i *mysqlStreamInput) setCachedBinlogPosition(ctx context.Context, binLogPos binlogCheckpoint) error {
+	cpStr, err := checkpointToString(binLogPos)
+	if err != nil {
+		return fmt.Errorf("unable to serialize checkpoint: %w", err)
+	}
 	var cErr error
 	if err := i.res.AccessCache(ctx, i.binLogCache, func(c service.Cache) {
 		cErr = c.Set(
 			ctx,
 			i.binLogCacheKey,
-			[]byte(binlogPositionToString(binLogPos)),
+			[]byte(cpStr),
 			nil,
 		)
 	}); err != nil {
@@ -666,44 +1079,197 @@ func (i *mysqlStreamInput) OnRotate(eh *replication.EventHeader, re *replication
 	return nil
 }
 
+func (i *mysqlStreamInput) OnGTID(eh *replication.EventHeader, e mysqlReplication.BinlogGTIDEvent) error {
+	gtid, err := e.GTIDNext()
+	if err != nil {
+		return err
+	}
+	i.currentGTID = gtid.String()
+	return nil
+}
+
+func (i *mysqlStreamInput) OnPosSynced(eh *replication.EventHeader, pos position, set mysqlReplication.GTIDSet, force bool) error {
+	// The set is updated once a transaction is committed, which makes it the position of the next transaction
+	if set != nil && i.positionMode == positionModeGTID {
+		i.executedGTIDSet = set.String()
+	}
+	// Called without a header when the canal is closed, in which case the rows of pending chunks can't be emitted
+	if eh != nil {
+		return i.watermarks.sync(binlogCheckpoint{Position: &pos, GTIDSet: i.executedGTIDSet}, func(me MessageEvent) error {
+			i.rawMessageEvents <- me
+			return nil
+		})
+	}
+	return nil
+}
+
 func (i *mysqlStreamInput) OnRow(e *canal.RowsEvent) error {
+	if e.Table.Name == i.signalTable && e.Table.Schema == i.mysqlConfig.DBName {
+		if e.Action == canal.InsertAction {
+			i.onSignal(e)
+		}
+		return nil
+	}
 	if _, ok := i.tablesFilterMap[e.Table.Name]; !ok {
 		return nil
 	}
+	if err := i.watermarks.change(e.Table.Name, func() ([]string, error) { return rowKeys(e) }); err != nil {
+		return err
+	}
 	switch e.Action {
 	case canal.InsertAction:
 		return i.onMessage(e, 0, 1)
 	case canal.DeleteAction:
 		return i.onMessage(e, 0, 1)
 	case canal.UpdateAction:
-		// Updates send both the new and old data - we only emit the new data.
+		// Updates send both the old and new data - the new data is emitted and the old data is added as metadata.
 		return i.onMessage(e, 1, 2)
 	default:
 		return errors.New("invalid rows action")
 	}
 }
 
+// onSignal handles rows inserted into the signal table, invalid signals are logged rather than stopping the stream.
+func (i *mysqlStreamInput) onSignal(e *canal.RowsEvent) {
+	for _, r := range e.Rows {
+		row, err := mapMessageRow(r, e.Table)
+		if err != nil {
+			i.logger.Warnf("unable to read signal: %s", err)
+			continue
+		}
+		signalType, _ := row["type"].(string)
+		if signalType == signalTypeSnapshotWindowOpen {
+			id, _ := row["id"].(string)
+			if !i.watermarks.reachLowWatermark(id) {
+				i.logger.Debugf("ignoring unknown low watermark %s", id)
+			}
+			continue
+		}
+		data, _ := row["data"].(string)
+		tables, err := cdc.ParseSnapshotSignal(signalType, data)
+		if err == nil {
+			err = i.triggerSnapshot(tables)
+		}
+		if err != nil {
+			i.logger.Warnf("ignoring signal %v: %s", row["id"], err)
+		}
+	}
+}
+
 func (i *mysqlStreamInput) onMessage(e *canal.RowsEvent, initValue, incrementValue int) error {
 	for pi := initValue; pi < len(e.Rows); pi += incrementValue {
-		message := map[string]any{}
-		for i, v := range e.Rows[pi] {
-			col := e.Table.Columns[i]
-			v, err := mapMessageColumn(v, col)
-			if err != nil {
-				return err
-			}
-			message[col.Name] = v
+		message, err := mapMessageRow(e.Rows[pi], e.Table)
+		if err != nil {
+			return err
 		}
-		i.rawMessageEvents <- MessageEvent{
+		me := MessageEvent{
 			Row:       message,
 			Operation: MessageOperation(e.Action),
 			Table:     e.Table.Name,
 			Position:  &position{Name: i.currentBinlogName, Pos: e.Header.LogPos},
 		}
+		if e.Action == canal.UpdateAction {
+			if me.Before, err = mapMessageRow(e.Rows[pi-1], e.Table); err != nil {
+				return err
+			}
+		}
+		if i.positionMode == positionModeGTID {
+			// Resuming from the set executed before this transaction replays the whole transaction, as GTID sets
+			// cannot point into a transaction
+			me.GTID = i.currentGTID
+			me.GTIDSet = i.executedGTIDSet
+		}
+		i.rawMessageEvents <- me
 	}
 	return nil
 }
 
+func (i *mysqlStreamInput) OnTableChanged(eh *replication.EventHeader, db, table string) error {
+	if _, ok := i.tablesFilterMap[table]; ok && i.streamDDL && db == i.mysqlConfig.DBName {
+		i.changedTables = append(i.changedTables, table)
+	}
+	return nil
+}
+
+func (i *mysqlStreamInput) OnDDL(eh *replication.EventHeader, nextPos position, e *replication.QueryEvent) error {
+	// OnTableChanged is called for each table of the statement before OnDDL
+	tables := i.changedTables
+	i.changedTables = nil
+
+	for _, table := range tables {
+		message := map[string]any{
+			"statement": string(e.Query),
+		}
+
+		// The table cache has been cleared, so this fetches the schema after the statement, unless the table has been
+		// dropped.
+		t, err := i.canal.GetTable(i.mysqlConfig.DBName, table)
+		if err == nil {
+			message["columns"], message["primary_key"] = tableSchema(t)
+		} else if !errors.Is(err, schema.ErrTableNotExist) {
+			return fmt.Errorf("unable to fetch schema of table %s: %w", table, err)
+		}
+
+		me := MessageEvent{
+			Row:       message,
+			Operation: MessageOperationDDL,
+			Table:     table,
+			Position:  &position{Name: i.currentBinlogName, Pos: nextPos.Pos},
+		}
+		if i.positionMode == positionModeGTID {
+			me.GTID = i.currentGTID
+			me.GTIDSet = i.executedGTIDSet
+		}
+		i.rawMessageEvents <- me
+	}
+	return nil
+}
+
+// rowKeys returns the primary keys of all rows of an event, including the rows before an update.
+func rowKeys(e *canal.RowsEvent) ([]string, error) {
+	keys := make([]string, len(e.Rows))
+	for idx, row := range e.Rows {
+		values := make([]any, len(e.Table.PKColumns))
+		for pi, colIdx := range e.Table.PKColumns {
+			v, err := mapMessageColumn(row[colIdx], e.Table.Columns[colIdx])
+			if err != nil {
+				return nil, err
+			}
+			values[pi] = v
+		}
+		keys[idx] = rowKey(values)
+	}
+	return keys, nil
+}
+
+func tableSchema(t *schema.Table) (columns []any, primaryKey []any) {
+	columns = make([]any, len(t.Columns))
+	for idx, col := range t.Columns {
+		columns[idx] = map[string]any{
+			"name": col.Name,
+			"type": col.RawType,
+		}
+	}
+	primaryKey = make([]any, len(t.PKColumns))
+	for idx, colIdx := range t.PKColumns {
+		primaryKey[idx] = t.Columns[colIdx].Name
+	}
+	return
+}
+
+func mapMessageRow(row []any, table *schema.Table) (map[string]any, error) {
+	message := map[string]any{}
+	for i, v := range row {
+		col := table.Columns[i]
+		v, err := mapMessageColumn(v, col)
+		if err != nil {
+			return nil, err
+		}
+		message[col.Name] = v
+	}
+	return message, nil
+}
+
 func mapMessageColumn(v any, col schema.TableColumn) (any, error) {
 	if v == nil {
 		return v, nil
@@ -11,7 +11,10 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -45,7 +48,7 @@ func (db *testDB) Exec(query string, args ...any) {
 	require.NoError(db.t, err)
 }
 
-func setupTestWithMySQLVersion(t *testing.T, version string) (string, *testDB) {
+func setupTestWithMySQLVersion(t *testing.T, version string, extraArgs ...string) (string, *testDB) {
 	t.Parallel()
 	integration.CheckSkip(t)
 	pool, err := dockertest.NewPool("")
@@ -61,13 +64,13 @@ func setupTestWithMySQLVersion(t *testing.T, version string) (string, *testDB) {
 			"MYSQL_ROOT_PASSWORD=password",
 			"MYSQL_DATABASE=testdb",
 		},
-		Cmd: []string{
+		Cmd: append([]string{
 			"--server-id=1",
 			"--log-bin=mysql-bin",
 			"--binlog-format=ROW",
 			"--binlog-row-image=FULL",
 			"--log-slave-updates=ON",
-		},
+		}, extraArgs...),
 		ExposedPorts: []string{"3306/tcp"},
 	}, func(config *docker.HostConfig) {
 		// set AutoRemove to true so that stopped container goes away by itself
@@ -213,6 +216,291 @@ file:
 	}
 }
 
+func TestIntegrationMySQLCDCGTID(t *testing.T) {
+	dsn, db := setupTestWithMySQLVersion(t, "8.0", "--gtid-mode=ON", "--enforce-gtid-consistency=ON")
+	db.Exec(`
+    CREATE TABLE IF NOT EXISTS foo (
+        a INT PRIMARY KEY
+    )
+`)
+
+	cacheConf := fmt.Sprintf(`
+label: foocache
+file:
+  directory: %s`, t.TempDir())
+
+	var (
+		seen    = map[string]int{}
+		seenMut sync.Mutex
+	)
+	runStream := func(positionMode string, expected int, insert func()) {
+		streamOutBuilder := service.NewStreamBuilder()
+		require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+		require.NoError(t, streamOutBuilder.AddCacheYAML(cacheConf))
+		require.NoError(t, streamOutBuilder.AddInputYAML(fmt.Sprintf(`
+mysql_cdc:
+  dsn: %s
+  stream_snapshot: false
+  checkpoint_cache: foocache
+  position_mode: %s
+  tables:
+    - foo
+`, dsn, positionMode)))
+
+		seenMut.Lock()
+		seen = map[string]int{}
+		seenMut.Unlock()
+		require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, mb service.MessageBatch) error {
+			msgBytes, err := mb[0].AsBytes()
+			require.NoError(t, err)
+			if positionMode == positionModeGTID {
+				_, ok := mb[0].MetaGet("gtid")
+				assert.True(t, ok)
+			}
+			seenMut.Lock()
+			seen[string(msgBytes)]++
+			seenMut.Unlock()
+			return nil
+		}))
+
+		streamOut, err := streamOutBuilder.Build()
+		require.NoError(t, err)
+		license.InjectTestService(streamOut.Resources())
+
+		go func() {
+			err := streamOut.Run(context.Background())
+			require.NoError(t, err)
+		}()
+
+		time.Sleep(time.Second * 5)
+		insert()
+
+		// The last acknowledged transaction may be replayed in GTID mode, since GTID sets cannot point into a
+		// transaction
+		assert.Eventually(t, func() bool {
+			seenMut.Lock()
+			defer seenMut.Unlock()
+			return len(seen) >= expected
+		}, time.Minute*5, time.Millisecond*100)
+
+		require.NoError(t, streamOut.StopWithin(time.Second*10))
+	}
+
+	// Start in file mode so that the checkpoint only contains a binlog position
+	runStream(positionModeFile, 1000, func() {
+		for i := 0; i < 1000; i++ {
+			db.Exec("INSERT INTO foo VALUES (?)", i)
+		}
+	})
+
+	for i := 1000; i < 2000; i++ {
+		db.Exec("INSERT INTO foo VALUES (?)", i)
+	}
+
+	// The checkpoint is migrated to a GTID set and no rows are missed
+	runStream(positionModeGTID, 1000, func() {})
+	seenMut.Lock()
+	for i := 1000; i < 2000; i++ {
+		assert.Contains(t, seen, fmt.Sprintf(`{"a":%d}`, i))
+	}
+	assert.LessOrEqual(t, len(seen), 1001)
+	seenMut.Unlock()
+
+	for i := 2000; i < 3000; i++ {
+		db.Exec("INSERT INTO foo VALUES (?)", i)
+	}
+
+	// Resume from the GTID set
+	runStream(positionModeGTID, 1000, func() {})
+	seenMut.Lock()
+	for i := 2000; i < 3000; i++ {
+		assert.Contains(t, seen, fmt.Sprintf(`{"a":%d}`, i))
+	}
+	assert.LessOrEqual(t, len(seen), 1001)
+	seenMut.Unlock()
+}
+
+func TestIntegrationMySQLCDCBeforeImageAndDDL(t *testing.T) {
+	dsn, db := setupTestWithMySQLVersion(t, "8.0")
+	db.Exec(`
+    CREATE TABLE IF NOT EXISTS foo (
+        a INT PRIMARY KEY,
+        b VARCHAR(32)
+    )
+`)
+	db.Exec(`
+    CREATE TABLE IF NOT EXISTS foo_non_streamed (
+        a INT PRIMARY KEY
+    )
+`)
+
+	template := fmt.Sprintf(`
+mysql_cdc:
+  dsn: %s
+  stream_snapshot: false
+  stream_ddl: true
+  checkpoint_cache: foocache
+  tables:
+    - foo
+`, dsn)
+
+	cacheConf := fmt.Sprintf(`
+label: foocache
+file:
+  directory: %s`, t.TempDir())
+
+	streamOutBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+	require.NoError(t, streamOutBuilder.AddCacheYAML(cacheConf))
+	require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+	type result struct {
+		operation string
+		body      string
+		before    any
+	}
+	var outMsgs []result
+	var outMsgsMut sync.Mutex
+	require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, mb service.MessageBatch) error {
+		for _, msg := range mb {
+			msgBytes, err := msg.AsBytes()
+			require.NoError(t, err)
+			operation, _ := msg.MetaGet("operation")
+			before, _ := msg.MetaGetMut("before")
+			outMsgsMut.Lock()
+			outMsgs = append(outMsgs, result{operation: operation, body: string(msgBytes), before: before})
+			outMsgsMut.Unlock()
+		}
+		return nil
+	}))
+
+	streamOut, err := streamOutBuilder.Build()
+	require.NoError(t, err)
+	license.InjectTestService(streamOut.Resources())
+
+	go func() {
+		err = streamOut.Run(context.Background())
+		require.NoError(t, err)
+	}()
+
+	time.Sleep(time.Second * 5)
+	db.Exec("INSERT INTO foo VALUES (1, 'a')")
+	db.Exec("UPDATE foo SET b = 'b' WHERE a = 1")
+	db.Exec("ALTER TABLE foo_non_streamed ADD COLUMN b INT")
+	db.Exec("ALTER TABLE foo ADD COLUMN c INT")
+	db.Exec("INSERT INTO foo VALUES (2, 'c', 3)")
+
+	assert.Eventually(t, func() bool {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		return len(outMsgs) == 4
+	}, time.Minute*5, time.Millisecond*100)
+
+	require.NoError(t, streamOut.StopWithin(time.Second*10))
+
+	outMsgsMut.Lock()
+	defer outMsgsMut.Unlock()
+
+	assert.Equal(t, "insert", outMsgs[0].operation)
+	assert.Nil(t, outMsgs[0].before)
+
+	assert.Equal(t, "update", outMsgs[1].operation)
+	assert.JSONEq(t, `{"a":1,"b":"b"}`, outMsgs[1].body)
+	assert.Equal(t, map[string]any{"a": int32(1), "b": "a"}, outMsgs[1].before)
+
+	assert.Equal(t, "ddl", outMsgs[2].operation)
+	assert.JSONEq(t, `{
+  "statement": "ALTER TABLE foo ADD COLUMN c INT",
+  "columns": [{"name":"a","type":"int"},{"name":"b","type":"varchar(32)"},{"name":"c","type":"int"}],
+  "primary_key": ["a"]
+}`, outMsgs[2].body)
+
+	assert.Equal(t, "insert", outMsgs[3].operation)
+	assert.JSONEq(t, `{"a":2,"b":"c","c":3}`, outMsgs[3].body)
+}
+
+func TestIntegrationMySQLCDCIncrementalSnapshot(t *testing.T) {
+	dsn, db := setupTestWithMySQLVersion(t, "8.0")
+	db.Exec(`
+    CREATE TABLE IF NOT EXISTS foo (
+        a INT PRIMARY KEY
+    )
+`)
+	db.Exec(`
+    CREATE TABLE IF NOT EXISTS signals (
+        id VARCHAR(64) PRIMARY KEY,
+        type VARCHAR(32) NOT NULL,
+        data VARCHAR(2048)
+    )
+`)
+	for i := range 100 {
+		db.Exec("INSERT INTO foo VALUES (?)", i)
+	}
+
+	template := fmt.Sprintf(`
+label: foo_cdc
+mysql_cdc:
+  dsn: %s
+  stream_snapshot: false
+  snapshot_max_batch_size: 10
+  signal_table: signals
+  checkpoint_cache: foocache
+  tables:
+    - foo
+`, dsn)
+
+	cacheConf := fmt.Sprintf(`
+label: foocache
+file:
+  directory: %s`, t.TempDir())
+
+	streamOutBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+	require.NoError(t, streamOutBuilder.AddCacheYAML(cacheConf))
+	require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+	var reads, inserts atomic.Int64
+	require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, mb service.MessageBatch) error {
+		for _, msg := range mb {
+			switch operation, _ := msg.MetaGet("operation"); operation {
+			case "read":
+				reads.Add(1)
+			case "insert":
+				inserts.Add(1)
+			}
+		}
+		return nil
+	}))
+
+	streamOut, err := streamOutBuilder.Build()
+	require.NoError(t, err)
+	license.InjectTestService(streamOut.Resources())
+
+	go func() {
+		err = streamOut.Run(context.Background())
+		require.NoError(t, err)
+	}()
+
+	time.Sleep(time.Second * 5)
+	db.Exec(`INSERT INTO signals VALUES ('1', 'execute-snapshot', '{"data-collections": ["foo"]}')`)
+	db.Exec("INSERT INTO foo VALUES (100)")
+
+	assert.Eventually(t, func() bool {
+		return reads.Load() >= 100 && inserts.Load() == 1
+	}, time.Minute*5, time.Millisecond*100)
+
+	exe, err := bloblang.Parse(`root = cdc_snapshot("foo_cdc", ["foo"])`)
+	require.NoError(t, err)
+	_, err = exe.Query(nil)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return reads.Load() >= 201
+	}, time.Minute*5, time.Millisecond*100)
+
+	require.NoError(t, streamOut.StopWithin(time.Second*10))
+}
+
 func TestIntegrationMySQLSnapshotAndCDC(t *testing.T) {
 	dsn, db := setupTestWithMySQLVersion(t, "8.0")
 	// Create table
@@ -748,3 +1036,225 @@ file:
 	require.Equal(t, expected, ids)
 	batchMu.Unlock()
 }
+
+func TestIntegrationMySQLWatermarkSnapshotConsistency(t *testing.T) {
+	dsn, db := setupTestWithMySQLVersion(t, "8.0")
+	for _, table := range []string{"foo", "bar"} {
+		db.Exec(fmt.Sprintf(`
+    CREATE TABLE IF NOT EXISTS %s (
+        a INT AUTO_INCREMENT,
+        b INT NOT NULL,
+        PRIMARY KEY (a)
+    )
+`, table))
+		for i := 0; i < 1000; i++ {
+			db.Exec(fmt.Sprintf("INSERT INTO %s (b) VALUES (0)", table))
+		}
+	}
+	// The windows of the chunks are opened at low watermarks written to the signal table
+	db.Exec(`
+    CREATE TABLE IF NOT EXISTS signals (
+        id VARCHAR(64) PRIMARY KEY,
+        type VARCHAR(32) NOT NULL,
+        data VARCHAR(2048)
+    )
+`)
+
+	template := strings.NewReplacer("$DSN", dsn).Replace(`
+read_until:
+  # Stop when we're idle for 3 seconds, which means our writer stopped
+  idle_timeout: 3s
+  input:
+    mysql_cdc:
+      dsn: $DSN
+      stream_snapshot: true
+      snapshot_mode: watermark
+      snapshot_max_batch_size: 100
+      max_parallel_snapshot_tables: 2
+      checkpoint_cache: foocache
+      signal_table: signals
+      tables:
+        - foo
+        - bar
+`)
+
+	cacheConf := `
+label: foocache
+file:
+  directory: ` + t.TempDir()
+
+	streamOutBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+	require.NoError(t, streamOutBuilder.AddCacheYAML(cacheConf))
+	require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+	// The latest value of each row
+	rows := map[string]map[int64]int64{"foo": {}, "bar": {}}
+	var batchMu sync.Mutex
+	require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, batch service.MessageBatch) error {
+		batchMu.Lock()
+		defer batchMu.Unlock()
+		for _, msg := range batch {
+			table, _ := msg.MetaGet("table")
+			data, err := msg.AsStructured()
+			require.NoError(t, err)
+			a, err := bloblang.ValueAsInt64(data.(map[string]any)["a"])
+			require.NoError(t, err)
+			b, err := bloblang.ValueAsInt64(data.(map[string]any)["b"])
+			require.NoError(t, err)
+			rows[table][a] = b
+		}
+		return nil
+	}))
+
+	streamOut, err := streamOutBuilder.Build()
+	require.NoError(t, err)
+	license.InjectTestService(streamOut.Resources())
+
+	// Continuously update and insert rows while the snapshot is read
+	var count atomic.Int64
+	writer := asyncroutine.NewPeriodic(time.Microsecond, func() {
+		n := count.Add(1)
+		table := "foo"
+		if n%2 == 0 {
+			table = "bar"
+		}
+		db.Exec(fmt.Sprintf("UPDATE %s SET b = b + 1 WHERE a = ?", table), n%1000+1)
+		if n%10 == 0 {
+			db.Exec(fmt.Sprintf("INSERT INTO %s (b) VALUES (0)", table))
+		}
+	})
+	writer.Start()
+	t.Cleanup(writer.Stop)
+
+	streamStopped := make(chan any, 1)
+	go func() {
+		err = streamOut.Run(context.Background())
+		require.NoError(t, err)
+		streamStopped <- nil
+	}()
+
+	time.Sleep(time.Second * 3)
+
+	writer.Stop()
+
+	select {
+	case <-streamStopped:
+	case <-time.After(time.Minute):
+		require.Fail(t, "stream did not complete in time")
+	}
+	require.NoError(t, streamOut.StopWithin(time.Second*10))
+
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	for table, actual := range rows {
+		expected := map[int64]int64{}
+		res, err := db.Query(fmt.Sprintf("SELECT a, b FROM %s", table))
+		require.NoError(t, err)
+		for res.Next() {
+			var a, b int64
+			require.NoError(t, res.Scan(&a, &b))
+			expected[a] = b
+		}
+		require.NoError(t, res.Err())
+		require.NoError(t, res.Close())
+		assert.Equal(t, expected, actual, table)
+	}
+}
+
+func TestIntegrationMySQLSnapshotResume(t *testing.T) {
+	dsn, db := setupTestWithMySQLVersion(t, "8.0")
+	db.Exec(`
+    CREATE TABLE IF NOT EXISTS foo (
+        a INT PRIMARY KEY
+    )
+`)
+	for i := 0; i < 1000; i++ {
+		db.Exec("INSERT INTO foo VALUES (?)", i)
+	}
+
+	for _, mode := range []string{snapshotModeGlobalLock, snapshotModeWatermark} {
+		t.Run(mode, func(t *testing.T) {
+			var pos position
+			var binlogDoDB, binlogIgnoreDB, executedGtidSet any
+			require.NoError(t, db.QueryRow("SHOW MASTER STATUS").Scan(&pos.Name, &pos.Pos, &binlogDoDB, &binlogIgnoreDB, &executedGtidSet))
+
+			// Checkpoint of a snapshot interrupted after row 499 has been delivered
+			cacheDir := t.TempDir()
+			cp, err := checkpointToString(binlogCheckpoint{
+				Position: &pos,
+				Snapshot: map[string]snapshotProgress{"foo": {LastKey: []any{499}}},
+			})
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "mysql_binlog_position"), []byte(cp), 0o644))
+
+			template := fmt.Sprintf(`
+mysql_cdc:
+  dsn: %s
+  stream_snapshot: true
+  snapshot_mode: %s
+  snapshot_max_batch_size: 100
+  checkpoint_cache: foocache
+  tables:
+    - foo
+`, dsn, mode)
+
+			cacheConf := fmt.Sprintf(`
+label: foocache
+file:
+  directory: %s`, cacheDir)
+
+			streamOutBuilder := service.NewStreamBuilder()
+			require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+			require.NoError(t, streamOutBuilder.AddCacheYAML(cacheConf))
+			require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+			var ids []int64
+			var batchMu sync.Mutex
+			require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, batch service.MessageBatch) error {
+				batchMu.Lock()
+				defer batchMu.Unlock()
+				for _, msg := range batch {
+					data, err := msg.AsStructured()
+					require.NoError(t, err)
+					v, err := bloblang.ValueAsInt64(data.(map[string]any)["a"])
+					require.NoError(t, err)
+					ids = append(ids, v)
+				}
+				return nil
+			}))
+
+			streamOut, err := streamOutBuilder.Build()
+			require.NoError(t, err)
+			license.InjectTestService(streamOut.Resources())
+
+			go func() {
+				err = streamOut.Run(context.Background())
+				require.NoError(t, err)
+			}()
+
+			var expected []int64
+			for i := 500; i < 1000; i++ {
+				expected = append(expected, int64(i))
+			}
+			assert.Eventually(t, func() bool {
+				batchMu.Lock()
+				defer batchMu.Unlock()
+				return len(ids) == len(expected)
+			}, time.Minute, time.Millisecond*100)
+
+			require.NoError(t, streamOut.StopWithin(time.Second*10))
+
+			batchMu.Lock()
+			defer batchMu.Unlock()
+			assert.Equal(t, expected, ids)
+
+			// The progress of the snapshot is checkpointed as rows are delivered
+			b, err := os.ReadFile(filepath.Join(cacheDir, "mysql_binlog_position"))
+			require.NoError(t, err)
+			actual, err := parseCheckpoint(string(b))
+			require.NoError(t, err)
+			assert.Equal(t, map[string]snapshotProgress{"foo": {LastKey: []any{json.Number("999")}}}, actual.Snapshot)
+		})
+	}
+}
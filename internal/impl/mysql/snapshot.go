@@ -13,18 +13,31 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 )
 
+const (
+	snapshotModeGlobalLock = "global_lock"
+	snapshotModeWatermark  = "watermark"
+)
+
+// querier is implemented by both *sql.DB and *sql.Tx, snapshots taken with a global lock read within a consistent
+// snapshot transaction whereas lock-free snapshots read each chunk in its own implicit transaction.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
 // Snapshot represents a structure that prepares a transaction
 // and creates mysql consistent snapshot inside the transaction
 type Snapshot struct {
 	db *sql.DB
-	tx *sql.Tx
+	// txs are the consistent snapshot transactions, one for each table read in parallel
+	txs []*sql.Tx
 
-	lockConn     *sql.Conn
-	snapshotConn *sql.Conn
+	lockConn      *sql.Conn
+	snapshotConns []*sql.Conn
 
 	logger *service.Logger
 }
@@ -37,7 +50,9 @@ func NewSnapshot(logger *service.Logger, db *sql.DB) *Snapshot {
 	}
 }
 
-func (s *Snapshot) prepareSnapshot(ctx context.Context) (*position, error) {
+// prepareSnapshot starts `parallelism` transactions that share the same consistent snapshot of the database, and
+// returns the binlog position of the snapshot.
+func (s *Snapshot) prepareSnapshot(ctx context.Context, parallelism int) (*binlogCheckpoint, error) {
 	var err error
 	// Create a separate connection for FTWRL
 	s.lockConn, err = s.db.Conn(ctx)
@@ -45,20 +60,23 @@ func (s *Snapshot) prepareSnapshot(ctx context.Context) (*position, error) {
 		return nil, fmt.Errorf("failed to create lock connection: %v", err)
 	}
 
-	// Create another connection for the snapshot
-	s.snapshotConn, err = s.db.Conn(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create snapshot connection: %v", err)
-	}
-
-	// Start a consistent snapshot transaction
-	s.tx, err = s.snapshotConn.BeginTx(ctx, &sql.TxOptions{
-		ReadOnly:  true,
-		Isolation: sql.LevelRepeatableRead,
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	// Create another connection for each snapshot transaction
+	for range parallelism {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create snapshot connection: %v", err)
+		}
+		s.snapshotConns = append(s.snapshotConns, conn)
+
+		// Start a consistent snapshot transaction
+		tx, err := conn.BeginTx(ctx, &sql.TxOptions{
+			ReadOnly:  true,
+			Isolation: sql.LevelRepeatableRead,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to start transaction: %v", err)
+		}
+		s.txs = append(s.txs, tx)
 	}
 
 	/*
@@ -70,9 +88,6 @@ func (s *Snapshot) prepareSnapshot(ctx context.Context) (*position, error) {
 		to capture the binlog coordinates, then release immediately with UNLOCK TABLES.
 	*/
 	if _, err := s.lockConn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
-		if rErr := s.tx.Rollback(); rErr != nil {
-			return nil, rErr
-		}
 		return nil, fmt.Errorf("failed to acquire global read lock: %v", err)
 	}
 
@@ -86,58 +101,49 @@ func (s *Snapshot) prepareSnapshot(ctx context.Context) (*position, error) {
 
 		    It's important that we do this AFTER we acquire the READ LOCK and flushing the tables,
 		    otherwise other writes could sneak in between our transaction snapshot and acquiring the
-		    lock.
+		    lock. As no writes can happen while the lock is held, all the transactions see the same
+		    snapshot.
 	*/
 
-	// NOTE: this is a little sneaky because we're actually implicitly closing the transaction
-	// started with `BeginTx` above and replacing it with this one. We have to do this because
+	// NOTE: this is a little sneaky because we're actually implicitly closing the transactions
+	// started with `BeginTx` above and replacing them with these ones. We have to do this because
 	// the `database/sql` driver we're using does not support this WITH CONSISTENT SNAPSHOT.
-	if _, err := s.tx.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
-		// Make sure to release the lock if we fail
-		if _, eErr := s.lockConn.ExecContext(ctx, "UNLOCK TABLES"); eErr != nil {
-			return nil, eErr
-		}
-
-		if rErr := s.tx.Rollback(); rErr != nil {
-			return nil, rErr
+	for _, tx := range s.txs {
+		if _, err := tx.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			// Make sure to release the lock if we fail
+			if _, eErr := s.lockConn.ExecContext(ctx, "UNLOCK TABLES"); eErr != nil {
+				return nil, eErr
+			}
+			return nil, fmt.Errorf("failed to start consistent snapshot: %v", err)
 		}
-
-		return nil, fmt.Errorf("failed to start consistent snapshot: %v", err)
 	}
 
 	// Get binary log position (while locked)
-	pos, err := s.getCurrentBinlogPosition(ctx)
+	pos, gtidSet, err := s.getCurrentBinlogPosition(ctx)
 	if err != nil {
 		// Make sure to release the lock if we fail
 		if _, eErr := s.lockConn.ExecContext(ctx, "UNLOCK TABLES"); eErr != nil {
 			return nil, eErr
 		}
-
-		if rErr := s.tx.Rollback(); rErr != nil {
-			return nil, rErr
-		}
 		return nil, fmt.Errorf("failed to get binlog position: %v", err)
 	}
 
 	// Release the global read lock immediately after getting the binlog position
 	if _, err := s.lockConn.ExecContext(ctx, "UNLOCK TABLES"); err != nil {
-		if rErr := s.tx.Rollback(); rErr != nil {
-			return nil, rErr
-		}
 		return nil, fmt.Errorf("failed to release global read lock: %v", err)
 	}
 
-	return &pos, nil
+	return &binlogCheckpoint{Position: &pos, GTIDSet: gtidSet}, nil
 }
 
-func (s *Snapshot) getTablePrimaryKeys(ctx context.Context, table string) ([]string, error) {
+func (s *Snapshot) getTablePrimaryKeys(ctx context.Context, q querier, table string) ([]string, error) {
 	// Get primary key columns for the table
-	rows, err := s.tx.QueryContext(ctx, fmt.Sprintf(`
+	rows, err := q.QueryContext(ctx, `
 SELECT COLUMN_NAME
 FROM information_schema.KEY_COLUMN_USAGE
-WHERE TABLE_NAME = '%s' AND CONSTRAINT_NAME = 'PRIMARY'
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND CONSTRAINT_NAME = 'PRIMARY'
 ORDER BY ORDINAL_POSITION;
-  `, table))
+  `, table)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get primary key: %v", err)
 	}
@@ -164,33 +170,64 @@ ORDER BY ORDINAL_POSITION;
 	return pks, nil
 }
 
-func (s *Snapshot) querySnapshotTable(ctx context.Context, table string, pk []string, lastSeenPkVal *map[string]any, limit int) (*sql.Rows, error) {
+// querySnapshotChunk reads up to `limit` rows of the table ordered by primary key, starting after the primary key
+// values `lastKey`, or from the start of the table when it is empty.
+func (s *Snapshot) querySnapshotChunk(ctx context.Context, q querier, table string, pk []string, lastKey []any, limit int) ([]map[string]any, error) {
 	snapshotQueryParts := []string{
 		"SELECT * FROM " + table,
 	}
 
-	if lastSeenPkVal == nil {
-		snapshotQueryParts = append(snapshotQueryParts, s.buildOrderByClause(pk))
+	if len(lastKey) > 0 {
+		placeholders := make([]string, len(lastKey))
+		for i := range lastKey {
+			placeholders[i] = "?"
+		}
+		snapshotQueryParts = append(snapshotQueryParts, fmt.Sprintf("WHERE (%s) > (%s)", strings.Join(pk, ", "), strings.Join(placeholders, ", ")))
+	}
+	snapshotQueryParts = append(snapshotQueryParts, s.buildOrderByClause(pk))
+	snapshotQueryParts = append(snapshotQueryParts, fmt.Sprintf("LIMIT %d", limit))
+	query := strings.Join(snapshotQueryParts, " ")
+	s.logger.Infof("Querying snapshot: %s", query)
 
-		snapshotQueryParts = append(snapshotQueryParts, "LIMIT ?")
-		q := strings.Join(snapshotQueryParts, " ")
-		s.logger.Infof("Querying snapshot: %s", q)
-		return s.tx.QueryContext(ctx, strings.Join(snapshotQueryParts, " "), limit)
+	batchRows, err := q.QueryContext(ctx, query, lastKey...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute snapshot table query: %s", err)
 	}
+	defer batchRows.Close()
 
-	var lastSeenPkVals []any
-	var placeholders []string
-	for _, pkCol := range *lastSeenPkVal {
-		lastSeenPkVals = append(lastSeenPkVals, pkCol)
-		placeholders = append(placeholders, "?")
+	types, err := batchRows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch column types: %s", err)
 	}
 
-	snapshotQueryParts = append(snapshotQueryParts, fmt.Sprintf("WHERE (%s) > (%s)", strings.Join(pk, ", "), strings.Join(placeholders, ", ")))
-	snapshotQueryParts = append(snapshotQueryParts, s.buildOrderByClause(pk))
-	snapshotQueryParts = append(snapshotQueryParts, fmt.Sprintf("LIMIT %d", limit))
-	q := strings.Join(snapshotQueryParts, " ")
-	s.logger.Infof("Querying snapshot: %s", q)
-	return s.tx.QueryContext(ctx, q, lastSeenPkVals...)
+	values, mappers := prepSnapshotScannerAndMappers(types)
+
+	columns, err := batchRows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch columns: %s", err)
+	}
+
+	var rows []map[string]any
+	for batchRows.Next() {
+		if err := batchRows.Scan(values...); err != nil {
+			return nil, err
+		}
+
+		row := map[string]any{}
+		for idx, value := range values {
+			v, err := mappers[idx](value)
+			if err != nil {
+				return nil, err
+			}
+			row[columns[idx]] = v
+		}
+		rows = append(rows, row)
+	}
+
+	if err := batchRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate snapshot table: %s", err)
+	}
+	return rows, nil
 }
 
 func (s *Snapshot) buildOrderByClause(pk []string) string {
@@ -201,7 +238,7 @@ func (s *Snapshot) buildOrderByClause(pk []string) string {
 	return "ORDER BY " + strings.Join(pk, ", ")
 }
 
-func (s *Snapshot) getCurrentBinlogPosition(ctx context.Context) (position, error) {
+func (s *Snapshot) getCurrentBinlogPosition(ctx context.Context) (position, string, error) {
 	var (
 		offset uint32
 		file   string
@@ -209,40 +246,40 @@ func (s *Snapshot) getCurrentBinlogPosition(ctx context.Context) (position, erro
 		// required to scan response
 		binlogDoDB      any
 		binlogIgnoreDB  any
-		executedGtidSet any
+		executedGtidSet sql.NullString
 	)
 
-	row := s.snapshotConn.QueryRowContext(ctx, "SHOW MASTER STATUS")
+	row := s.lockConn.QueryRowContext(ctx, "SHOW MASTER STATUS")
 	if err := row.Scan(&file, &offset, &binlogDoDB, &binlogIgnoreDB, &executedGtidSet); err != nil {
-		return position{}, err
+		return position{}, "", err
 	}
 
 	return position{
 		Name: file,
 		Pos:  offset,
-	}, nil
+	}, executedGtidSet.String, nil
 }
 
 func (s *Snapshot) releaseSnapshot(_ context.Context) error {
-	if s.tx != nil {
-		if err := s.tx.Commit(); err != nil {
+	for _, tx := range s.txs {
+		if err := tx.Commit(); err != nil {
 			return fmt.Errorf("failed to commit transaction: %v", err)
 		}
 	}
 
-	// reset transaction
-	s.tx = nil
+	// reset transactions
+	s.txs = nil
 	return nil
 }
 
 func (s *Snapshot) close() error {
-	if s.tx != nil {
-		if err := s.tx.Rollback(); err != nil {
+	for _, tx := range s.txs {
+		if err := tx.Rollback(); err != nil {
 			return fmt.Errorf("unable to rollback transaction: %w", err)
 		}
-		s.tx = nil
 	}
-	for _, conn := range []*sql.Conn{s.lockConn, s.snapshotConn} {
+	s.txs = nil
+	for _, conn := range append([]*sql.Conn{s.lockConn}, s.snapshotConns...) {
 		if conn == nil {
 			continue
 		}
@@ -255,3 +292,40 @@ func (s *Snapshot) close() error {
 	}
 	return nil
 }
+
+// snapshotKeyValues returns the primary key values of a row in a form that can be stored in a checkpoint and used as
+// query arguments in order to resume a snapshot.
+func snapshotKeyValues(row map[string]any, pk []string) []any {
+	values := make([]any, len(pk))
+	for idx, col := range pk {
+		switch v := row[col].(type) {
+		case time.Time:
+			values[idx] = v.Format("2006-01-02 15:04:05.999999")
+		case []byte:
+			values[idx] = string(v)
+		default:
+			values[idx] = v
+		}
+	}
+	return values
+}
+
+// rowKey returns a comparable representation of the primary key values of a row, which is the same for rows read
+// from a snapshot and from the binlog.
+func rowKey(values []any) string {
+	var sb strings.Builder
+	for idx, v := range values {
+		if idx > 0 {
+			sb.WriteByte(0)
+		}
+		switch t := v.(type) {
+		case time.Time:
+			sb.WriteString(t.Format("2006-01-02 15:04:05.999999"))
+		case []byte:
+			sb.Write(t)
+		default:
+			fmt.Fprint(&sb, v)
+		}
+	}
+	return sb.String()
+}
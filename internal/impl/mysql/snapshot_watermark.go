@@ -0,0 +1,170 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package mysql
+
+import (
+	"slices"
+	"sync"
+)
+
+// signalTypeSnapshotWindowOpen is the type of the low watermarks written to the signal table.
+const signalTypeSnapshotWindowOpen = "snapshot-window-open"
+
+// chunkWindow is a chunk of a lock-free snapshot. The window opens before the chunk is queried (at the low watermark)
+// and closes at the binlog position read after the query (the high watermark). Rows changed by the binlog stream while
+// the window is open are dropped from the chunk, as the stream emits a version of them at least as recent, and the
+// remaining rows are emitted once the stream has reached the high watermark.
+type chunkWindow struct {
+	table string
+	// lowWatermark is the id of the low watermark the window waits for, it is empty once the window is open
+	lowWatermark string
+	high         *position
+	rows         []MessageEvent
+	keys         []string
+	changed      map[string]struct{}
+	opened       chan struct{}
+	done         chan struct{}
+}
+
+// watermarkTracker interleaves the chunks of lock-free snapshots with the binlog stream, in the style of DBLog.
+//
+// The window of a chunk is opened once the stream reads a low watermark, a row written to the signal table before the
+// chunk is queried. Transactions are committed in the order of the binlog (`binlog_order_commits`), so the changes
+// read by the stream before the low watermark are visible to the query whereas all later changes are recorded by the
+// window.
+//
+// Without a signal table windows are opened in lockstep with the stream instead, where a transaction that has been
+// written to the binlog but is not yet visible when a chunk is queried can be missed by both the chunk and the window.
+// The chunk then emits a stale version of a row after the stream, which requires the stream to read the transaction
+// before it has been committed to the storage engine and is unlikely but not impossible.
+type watermarkTracker struct {
+	mut     sync.Mutex
+	synced  binlogCheckpoint
+	windows []*chunkWindow
+}
+
+func newWatermarkTracker(start binlogCheckpoint) *watermarkTracker {
+	return &watermarkTracker{synced: start}
+}
+
+// open opens a window for a chunk of the table that is about to be queried.
+func (w *watermarkTracker) open(table string) *chunkWindow {
+	cw := w.openAt(table, "")
+	close(cw.opened)
+	return cw
+}
+
+// openAt adds a window for a chunk of the table which opens once the stream reads the low watermark with the id, the
+// `opened` channel of the window is closed then. The chunk must not be queried before.
+func (w *watermarkTracker) openAt(table, lowWatermark string) *chunkWindow {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	cw := &chunkWindow{
+		table:        table,
+		lowWatermark: lowWatermark,
+		changed:      map[string]struct{}{},
+		opened:       make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	w.windows = append(w.windows, cw)
+	return cw
+}
+
+// reachLowWatermark opens the window waiting for the low watermark with the id, which has been read by the stream.
+// Returns false if no window waits for it, e.g. when it has been written by another input sharing the signal table.
+func (w *watermarkTracker) reachLowWatermark(id string) bool {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	for _, cw := range w.windows {
+		if cw.lowWatermark != "" && cw.lowWatermark == id {
+			cw.lowWatermark = ""
+			close(cw.opened)
+			return true
+		}
+	}
+	return false
+}
+
+// close sets the high watermark and the rows of a window, which are emitted immediately if the stream has already
+// reached the high watermark. Otherwise the window remains open until sync is called with a position at or after the
+// high watermark. The `done` channel of the window is closed once its rows have been emitted.
+func (w *watermarkTracker) close(cw *chunkWindow, high position, rows []MessageEvent, keys []string, emit func(MessageEvent) error) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	cw.high = &high
+	cw.rows = rows
+	cw.keys = keys
+	if w.synced.Position != nil && w.synced.Position.Compare(high) >= 0 {
+		return w.flush(cw, emit)
+	}
+	return nil
+}
+
+// change records rows of the table changed by the stream, `keysFn` returns their keys as returned by rowKey and is
+// only called when a window of the table is open.
+func (w *watermarkTracker) change(table string, keysFn func() ([]string, error)) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	var keys []string
+	for _, cw := range w.windows {
+		if cw.table != table || cw.lowWatermark != "" {
+			continue
+		}
+		if keys == nil {
+			var err error
+			if keys, err = keysFn(); err != nil {
+				return err
+			}
+		}
+		for _, key := range keys {
+			cw.changed[key] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// sync updates the position of the stream at the end of a transaction and emits the rows of all windows that it has
+// reached.
+func (w *watermarkTracker) sync(cp binlogCheckpoint, emit func(MessageEvent) error) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	w.synced = cp
+	for _, cw := range slices.Clone(w.windows) {
+		if cw.high == nil || cp.Position == nil || cp.Position.Compare(*cw.high) < 0 {
+			continue
+		}
+		if err := w.flush(cw, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *watermarkTracker) flush(cw *chunkWindow, emit func(MessageEvent) error) error {
+	w.windows = slices.DeleteFunc(w.windows, func(o *chunkWindow) bool { return o == cw })
+	defer close(cw.done)
+
+	for idx, me := range cw.rows {
+		if _, changed := cw.changed[cw.keys[idx]]; changed {
+			continue
+		}
+		// Everything up to the synced position has been emitted before these rows, so the stream can resume from it
+		me.Position = w.synced.Position
+		me.GTIDSet = w.synced.GTIDSet
+		if err := emit(me); err != nil {
+			return err
+		}
+	}
+	return nil
+}
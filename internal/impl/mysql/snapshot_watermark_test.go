@@ -0,0 +1,128 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermarkTracker(t *testing.T) {
+	var emitted []MessageEvent
+	emit := func(me MessageEvent) error {
+		emitted = append(emitted, me)
+		return nil
+	}
+
+	chunk := func(table string, keys ...int) ([]MessageEvent, []string) {
+		var rows []MessageEvent
+		var rowKeys []string
+		for _, k := range keys {
+			rows = append(rows, MessageEvent{Table: table, Row: map[string]any{"a": k}, Operation: MessageOperationRead})
+			rowKeys = append(rowKeys, rowKey([]any{k}))
+		}
+		return rows, rowKeys
+	}
+	keysFn := func(keys ...any) func() ([]string, error) {
+		return func() ([]string, error) {
+			var out []string
+			for _, k := range keys {
+				out = append(out, rowKey([]any{k}))
+			}
+			return out, nil
+		}
+	}
+
+	w := newWatermarkTracker(binlogCheckpoint{Position: &position{Name: "log.000001", Pos: 100}})
+
+	// The stream has already reached the high watermark
+	cw := w.open("foo")
+	rows, keys := chunk("foo", 1, 2)
+	require.NoError(t, w.close(cw, position{Name: "log.000001", Pos: 100}, rows, keys, emit))
+	require.Len(t, emitted, 2)
+	assert.Equal(t, &position{Name: "log.000001", Pos: 100}, emitted[1].Position)
+	<-cw.done
+
+	// Rows changed by the stream until it reaches the high watermark are dropped
+	emitted = nil
+	fooWindow := w.open("foo")
+	barWindow := w.open("bar")
+	require.NoError(t, w.change("foo", keysFn(int32(3))))
+	rows, keys = chunk("foo", 3, 4, 5)
+	require.NoError(t, w.close(fooWindow, position{Name: "log.000001", Pos: 300}, rows, keys, emit))
+	require.NoError(t, w.change("foo", keysFn(int64(5))))
+	require.NoError(t, w.change("bar", keysFn(4)))
+	assert.Empty(t, emitted)
+
+	require.NoError(t, w.sync(binlogCheckpoint{Position: &position{Name: "log.000001", Pos: 200}}, emit))
+	assert.Empty(t, emitted)
+
+	require.NoError(t, w.sync(binlogCheckpoint{Position: &position{Name: "log.000002", Pos: 4}, GTIDSet: "foo"}, emit))
+	require.Len(t, emitted, 1)
+	assert.Equal(t, map[string]any{"a": 4}, emitted[0].Row)
+	assert.Equal(t, &position{Name: "log.000002", Pos: 4}, emitted[0].Position)
+	assert.Equal(t, "foo", emitted[0].GTIDSet)
+	<-fooWindow.done
+
+	// The window of bar has not been closed yet
+	select {
+	case <-barWindow.done:
+		t.Fatal("window should not be done")
+	default:
+	}
+	rows, keys = chunk("bar", 4, 6)
+	require.NoError(t, w.close(barWindow, position{Name: "log.000002", Pos: 4}, rows, keys, emit))
+	require.Len(t, emitted, 2)
+	assert.Equal(t, map[string]any{"a": 6}, emitted[1].Row)
+	<-barWindow.done
+}
+
+func TestWatermarkTrackerLowWatermark(t *testing.T) {
+	var emitted []MessageEvent
+	emit := func(me MessageEvent) error {
+		emitted = append(emitted, me)
+		return nil
+	}
+	keysFn := func(k int) func() ([]string, error) {
+		return func() ([]string, error) {
+			return []string{rowKey([]any{k})}, nil
+		}
+	}
+
+	w := newWatermarkTracker(binlogCheckpoint{Position: &position{Name: "log.000001", Pos: 100}})
+
+	cw := w.openAt("foo", "low")
+	select {
+	case <-cw.opened:
+		t.Fatal("window should not be open")
+	default:
+	}
+
+	// Changes read before the low watermark are visible to the chunk query
+	require.NoError(t, w.change("foo", keysFn(1)))
+
+	assert.False(t, w.reachLowWatermark("other"))
+	assert.True(t, w.reachLowWatermark("low"))
+	<-cw.opened
+	assert.False(t, w.reachLowWatermark("low"))
+
+	// Changes read after the low watermark are newer than the rows of the chunk
+	require.NoError(t, w.change("foo", keysFn(2)))
+
+	require.NoError(t, w.close(cw, position{Name: "log.000001", Pos: 200}, []MessageEvent{
+		{Table: "foo", Row: map[string]any{"a": 1}, Operation: MessageOperationRead},
+		{Table: "foo", Row: map[string]any{"a": 2}, Operation: MessageOperationRead},
+	}, []string{rowKey([]any{1}), rowKey([]any{2})}, emit))
+	require.NoError(t, w.sync(binlogCheckpoint{Position: &position{Name: "log.000001", Pos: 200}}, emit))
+	require.Len(t, emitted, 1)
+	assert.Equal(t, map[string]any{"a": 1}, emitted[0].Row)
+	<-cw.done
+}
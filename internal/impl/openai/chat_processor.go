@@ -11,6 +11,8 @@ package openai
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -39,6 +41,19 @@ const (
 	ocpFieldPresencePenalty  = "presence_penalty"
 	ocpFieldFrequencyPenalty = "frequency_penalty"
 	ocpFieldResponseFormat   = "response_format"
+	ocpFieldHistory          = "history"
+	ocpFieldMaxToolCalls     = "max_tool_calls"
+	// Tool options
+	ocpFieldTool                     = "tools"
+	ocpToolFieldName                 = "name"
+	ocpToolFieldDesc                 = "description"
+	ocpToolFieldParams               = "parameters"
+	ocpToolParamFieldRequired        = "required"
+	ocpToolParamFieldProps           = "properties"
+	ocpToolParamPropFieldType        = "type"
+	ocpToolParamPropFieldDescription = "description"
+	ocpToolParamPropFieldEnum        = "enum"
+	ocpToolFieldPipeline             = "processors"
 	// JSON schema fields
 	ocpFieldJSONSchema       = "json_schema"
 	ocpFieldJSONSchemaName   = "name"
@@ -88,6 +103,11 @@ To learn more about chat completion, see the https://platform.openai.com/docs/gu
 			service.NewInterpolatedStringField(ocpFieldSystemPrompt).
 				Description("The system prompt to submit along with the user prompt.").
 				Optional(),
+			service.NewBloblangField(ocpFieldHistory).
+				Description("A mapping that evaluates to an array of the prior messages of the conversation, which are sent between the system prompt and the user prompt. Each message must be an object with a `role` of `system`, `user` or `assistant`, and the `content` of the message.").
+				Version("4.47.0").
+				Example(`root = this.previous_turns.map_each(turn -> {"role": turn.author, "content": turn.text})`).
+				Optional(),
 			service.NewBloblangField(ocpFieldImage).
 				Description("An image to send along with the prompt. The mapping result must be a byte array.").
 				Version("4.38.0").
@@ -161,6 +181,31 @@ We generally recommend altering this or temperature but not both.`).
 				Optional().
 				Advanced().
 				Description("Up to 4 sequences where the API will stop generating further tokens."),
+			service.NewIntField(ocpFieldMaxToolCalls).
+				Default(3).
+				Advanced().
+				Version("4.47.0").
+				Description(`The maximum number of sequential tool calls.`).
+				LintRule(`root = if this <= 0 { ["field must be greater than zero"] }`),
+			service.NewObjectListField(
+				ocpFieldTool,
+				service.NewStringField(ocpToolFieldName).Description("The name of this tool."),
+				service.NewStringField(ocpToolFieldDesc).Description("A description of this tool, the LLM uses this to decide if the tool should be used."),
+				service.NewObjectField(
+					ocpToolFieldParams,
+					service.NewStringListField(ocpToolParamFieldRequired).Default([]string{}).Description("The required parameters for this pipeline."),
+					service.NewObjectMapField(
+						ocpToolParamFieldProps,
+						service.NewStringField(ocpToolParamPropFieldType).Description("The type of this parameter."),
+						service.NewStringField(ocpToolParamPropFieldDescription).Description("A description of this parameter."),
+						service.NewStringListField(ocpToolParamPropFieldEnum).Default([]string{}).Description("Specifies that this parameter is an enum and only these specific values should be used."),
+					).Description("The properties for the processor's input data"),
+				).Description("The parameters the LLM needs to provide to invoke this tool."),
+				service.NewProcessorListField(ocpToolFieldPipeline).Description("The pipeline to execute when the LLM uses this tool, the input of which is an object of the parameters provided by the LLM. The output of the pipeline is sent back to the LLM as the result of the tool call."),
+			).
+				Optional().
+				Version("4.47.0").
+				Description("The tools to allow the LLM to invoke. This allows building subpipelines that the LLM can choose to invoke to execute agentic-like actions."),
 		).LintRule(`
       root = match {
         this.exists("`+ocpFieldJSONSchema+`") && this.exists("`+ocpFieldSchemaRegistry+`") => ["cannot set both `+"`"+ocpFieldJSONSchema+"`"+` and `+"`"+ocpFieldSchemaRegistry+"`"+`"]
@@ -188,6 +233,40 @@ pipeline:
 output:
   stdout:
     codec: lines
+`).
+		Example(
+			"Use subpipelines as tool calls",
+			"This example allows GPT-4o to execute a subpipeline as a tool call to get more data.",
+			`
+input:
+  generate:
+    count: 1
+    mapping: |
+      root = "What is the weather like in Chicago?"
+pipeline:
+  processors:
+    - openai_chat_completion:
+        model: gpt-4o
+        api_key: TODO
+        prompt: "${!content().string()}"
+        tools:
+          - name: GetWeather
+            description: "Retrieve the weather for a specific city"
+            parameters:
+              required: ["city"]
+              properties:
+                city:
+                  type: string
+                  description: the city to lookup the weather for
+            processors:
+              - http:
+                  verb: GET
+                  url: 'https://wttr.in/${!this.city}?T'
+                  headers:
+                    # Spoof the curl user-agent to get a plaintext response
+                    User-Agent: curl/8.11.1
+output:
+  stdout: {}
 `)
 }
 
@@ -214,6 +293,13 @@ func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (serv
 			return nil, err
 		}
 	}
+	var h *bloblang.Executor
+	if conf.Contains(ocpFieldHistory) {
+		h, err = conf.FieldBloblang(ocpFieldHistory)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var i *bloblang.Executor
 	if conf.Contains(ocpFieldImage) {
 		i, err = conf.FieldBloblang(ocpFieldImage)
@@ -318,6 +404,16 @@ func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (serv
 	default:
 		return nil, fmt.Errorf("unknown %s: %q", ocpFieldResponseFormat, v)
 	}
+	maxToolCalls, err := conf.FieldInt(ocpFieldMaxToolCalls)
+	if err != nil {
+		return nil, err
+	}
+	var tools []tool
+	if conf.Contains(ocpFieldTool) {
+		if tools, err = toolsFromParsed(conf); err != nil {
+			return nil, err
+		}
+	}
 	return &chatProcessor{
 		b,
 		up,
@@ -333,9 +429,77 @@ func makeChatProcessor(conf *service.ParsedConfig, mgr *service.Resources) (serv
 		stop,
 		responseFormat,
 		schemaProvider,
+		h,
+		maxToolCalls,
+		tools,
 	}, nil
 }
 
+func toolsFromParsed(conf *service.ParsedConfig) ([]tool, error) {
+	toolConfs, err := conf.FieldObjectList(ocpFieldTool)
+	if err != nil {
+		return nil, err
+	}
+	var tools []tool
+	for _, toolConf := range toolConfs {
+		f := &oai.FunctionDefinition{}
+		f.Name, err = toolConf.FieldString(ocpToolFieldName)
+		if err != nil {
+			return nil, err
+		}
+		f.Description, err = toolConf.FieldString(ocpToolFieldDesc)
+		if err != nil {
+			return nil, err
+		}
+		paramsConf := toolConf.Namespace(ocpToolFieldParams)
+		required, err := paramsConf.FieldStringList(ocpToolParamFieldRequired)
+		if err != nil {
+			return nil, err
+		}
+		propsConf, err := paramsConf.FieldObjectMap(ocpToolParamFieldProps)
+		if err != nil {
+			return nil, err
+		}
+		type toolParam = struct {
+			Type        string   `json:"type"`
+			Description string   `json:"description"`
+			Enum        []string `json:"enum,omitempty"`
+		}
+		props := map[string]toolParam{}
+		for name, paramConf := range propsConf {
+			paramType, err := paramConf.FieldString(ocpToolParamPropFieldType)
+			if err != nil {
+				return nil, err
+			}
+			desc, err := paramConf.FieldString(ocpToolParamPropFieldDescription)
+			if err != nil {
+				return nil, err
+			}
+			enum, err := paramConf.FieldStringList(ocpToolParamPropFieldEnum)
+			if err != nil {
+				return nil, err
+			}
+			props[name] = toolParam{
+				Type:        paramType,
+				Description: desc,
+				Enum:        enum,
+			}
+		}
+		f.Parameters = map[string]any{
+			"type":       "object",
+			"required":   required,
+			"properties": props,
+		}
+
+		pipeline, err := toolConf.FieldProcessorList(ocpToolFieldPipeline)
+		if err != nil {
+			return nil, err
+		}
+		tools = append(tools, tool{oai.Tool{Type: oai.ToolTypeFunction, Function: f}, pipeline})
+	}
+	return tools, nil
+}
+
 func newFixedSchemaProvider(conf *service.ParsedConfig) (jsonSchemaProvider, error) {
 	name, err := conf.FieldString(ocpFieldJSONSchemaName)
 	if err != nil {
@@ -406,6 +570,14 @@ type chatProcessor struct {
 	stop             []string
 	responseFormat   oai.ChatCompletionResponseFormatType
 	schemaProvider   jsonSchemaProvider
+	history          *bloblang.Executor
+	maxToolCalls     int
+	tools            []tool
+}
+
+type tool struct {
+	spec     oai.Tool
+	pipeline []*service.OwnedProcessor
 }
 
 func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (service.MessageBatch, error) {
@@ -455,6 +627,21 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 			Content: s,
 		})
 	}
+	if p.history != nil {
+		h, err := msg.BloblangQuery(p.history)
+		if err != nil {
+			return nil, fmt.Errorf("%s execution error: %w", ocpFieldHistory, err)
+		}
+		v, err := h.AsStructured()
+		if err != nil {
+			return nil, fmt.Errorf("%s conversion error: %w", ocpFieldHistory, err)
+		}
+		history, err := historyMessages(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", ocpFieldHistory, err)
+		}
+		body.Messages = append(body.Messages, history...)
+	}
 	chatMsg := oai.ChatCompletionMessage{
 		Role: "user",
 	}
@@ -495,14 +682,132 @@ func (p *chatProcessor) Process(ctx context.Context, msg *service.Message) (serv
 			}},
 		})
 	}
-	resp, err := p.client.CreateChatCompletion(ctx, body)
+	for _, t := range p.tools {
+		body.Tools = append(body.Tools, t.spec)
+	}
+	// Allow up to N iterations of calling tools
+	for range p.maxToolCalls + 1 {
+		resp, err := p.client.CreateChatCompletion(ctx, body)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Choices) != 1 {
+			return nil, fmt.Errorf("invalid number of choices in response: %d", len(resp.Choices))
+		}
+		respMsg := resp.Choices[0].Message
+		if len(respMsg.ToolCalls) == 0 {
+			msg = msg.Copy()
+			msg.SetBytes([]byte(respMsg.Content))
+			return service.MessageBatch{msg}, nil
+		}
+		body.Messages = append(body.Messages, respMsg)
+		for _, toolCall := range respMsg.ToolCalls {
+			result, err := p.callTool(ctx, toolCall)
+			if err != nil {
+				return nil, err
+			}
+			body.Messages = append(body.Messages, oai.ChatCompletionMessage{
+				Role:       oai.ChatMessageRoleTool,
+				Content:    result,
+				ToolCallID: toolCall.ID,
+			})
+		}
+	}
+	return nil, fmt.Errorf("model did not finish after %d tool calls", p.maxToolCalls)
+}
+
+// historyMessages converts the result of the history mapping into the messages
+// of a conversation.
+func historyMessages(v any) ([]oai.ChatCompletionMessage, error) {
+	arr, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected an array of messages, got %T", v)
+	}
+	msgs := make([]oai.ChatCompletionMessage, 0, len(arr))
+	for i, e := range arr {
+		obj, ok := e.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("message %d: expected an object, got %T", i, e)
+		}
+		role, _ := obj["role"].(string)
+		switch role {
+		case oai.ChatMessageRoleSystem, oai.ChatMessageRoleUser, oai.ChatMessageRoleAssistant:
+		default:
+			return nil, fmt.Errorf("message %d: role must be one of system, user or assistant, got %v", i, obj["role"])
+		}
+		content, ok := obj["content"].(string)
+		if !ok {
+			return nil, fmt.Errorf("message %d: content must be a string, got %T", i, obj["content"])
+		}
+		msgs = append(msgs, oai.ChatCompletionMessage{Role: role, Content: content})
+	}
+	return msgs, nil
+}
+
+// callTool executes the pipeline of the tool requested by the LLM with the
+// arguments of the call, returning the output of the pipeline.
+func (p *chatProcessor) callTool(ctx context.Context, toolCall oai.ToolCall) (string, error) {
+	idx := slices.IndexFunc(p.tools, func(t tool) bool { return t.spec.Function.Name == toolCall.Function.Name })
+	if idx < 0 {
+		return "", fmt.Errorf("unknown tool call requested: %s", toolCall.Function.Name)
+	}
+	var args any = map[string]any{}
+	if toolCall.Function.Arguments != "" {
+		if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments for tool %s: %w", toolCall.Function.Name, err)
+		}
+	}
+	msg := service.NewMessage(nil)
+	msg.SetStructuredMut(args)
+	output, err := service.ExecuteProcessors(ctx, p.tools[idx].pipeline, service.MessageBatch{msg})
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("error calling tool %s: %w", toolCall.Function.Name, err)
+	}
+	result, err := combineToSingleMessage(output)
+	if err != nil {
+		return "", fmt.Errorf("error processing pipeline %s output: %w", toolCall.Function.Name, err)
 	}
-	if len(resp.Choices) != 1 {
-		return nil, fmt.Errorf("invalid number of choices in response: %d", len(resp.Choices))
+	return result, nil
+}
+
+func combineToSingleMessage(batches []service.MessageBatch) (string, error) {
+	msgs := []any{}
+	for _, batch := range batches {
+		for _, msg := range batch {
+			if err := msg.GetError(); err != nil {
+				return "", fmt.Errorf("pipeline resulted in message with error: %w", err)
+			}
+			if msg.HasStructured() {
+				v, err := msg.AsStructured()
+				if err != nil {
+					return "", fmt.Errorf("unable to extract JSON result: %w", err)
+				}
+				msgs = append(msgs, v)
+			} else {
+				b, err := msg.AsBytes()
+				if err != nil {
+					return "", fmt.Errorf("unable to extract raw bytes result: %w", err)
+				}
+				msgs = append(msgs, string(b))
+			}
+		}
+	}
+	if len(msgs) == 0 {
+		return "", errors.New("pipeline did not output any messages")
+	}
+	if len(msgs) == 1 {
+		return bloblang.ValueToString(msgs[0]), nil
+	}
+	return bloblang.ValueToString(msgs), nil
+}
+
+func (p *chatProcessor) Close(ctx context.Context) error {
+	for _, tool := range p.tools {
+		for _, processor := range tool.pipeline {
+			if err := processor.Close(ctx); err != nil {
+				return err
+			}
+		}
 	}
-	msg = msg.Copy()
-	msg.SetBytes([]byte(resp.Choices[0].Message.Content))
-	return service.MessageBatch{msg}, nil
+	return p.baseProcessor.Close(ctx)
 }
@@ -10,13 +10,20 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 
 	"github.com/go-faker/faker/v4"
+	_ "github.com/redpanda-data/benthos/v4/public/components/pure"
 	"github.com/redpanda-data/benthos/v4/public/service"
 	oai "github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/connect/v4/internal/license"
 )
 
 type mockChatClient struct {
@@ -66,3 +73,159 @@ func TestChatInterpolationError(t *testing.T) {
 	_, err = p.Process(context.Background(), input)
 	assert.Error(t, err)
 }
+
+func TestChatToolCallsAndHistory(t *testing.T) {
+	var reqsMut sync.Mutex
+	var reqs []oai.ChatCompletionRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body oai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reqsMut.Lock()
+		reqs = append(reqs, body)
+		reqsMut.Unlock()
+
+		var resp oai.ChatCompletionResponse
+		last := body.Messages[len(body.Messages)-1]
+		if last.Role == oai.ChatMessageRoleTool {
+			resp.Choices = []oai.ChatCompletionChoice{{
+				Message: oai.ChatCompletionMessage{
+					Role:    oai.ChatMessageRoleAssistant,
+					Content: "The weather is " + last.Content,
+				},
+			}}
+		} else {
+			resp.Choices = []oai.ChatCompletionChoice{{
+				Message: oai.ChatCompletionMessage{
+					Role: oai.ChatMessageRoleAssistant,
+					ToolCalls: []oai.ToolCall{{
+						ID:   "call_1",
+						Type: oai.ToolTypeFunction,
+						Function: oai.FunctionCall{
+							Name:      "GetWeather",
+							Arguments: `{"city":"Chicago"}`,
+						},
+					}},
+				},
+			}}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	conf, err := chatProcessorConfig().ParseYAML(`
+server_address: `+srv.URL+`
+api_key: foo
+model: gpt-4o
+system_prompt: You are a weather bot
+history: 'root = this.history'
+prompt: '${! this.question }'
+tools:
+  - name: GetWeather
+    description: Retrieve the weather for a specific city
+    parameters:
+      required: [ city ]
+      properties:
+        city:
+          type: string
+          description: The city to lookup the weather for
+    processors:
+      - mapping: 'root = "sunny in %s".format(this.city)'
+`, nil)
+	require.NoError(t, err)
+
+	mgr := service.MockResources()
+	license.InjectTestService(mgr)
+
+	p, err := makeChatProcessor(conf, mgr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, p.Close(context.Background()))
+	})
+
+	output, err := p.Process(context.Background(), service.NewMessage([]byte(`{
+  "history": [
+    {"role": "user", "content": "Hello"},
+    {"role": "assistant", "content": "Hi, how can I help?"}
+  ],
+  "question": "What is the weather like in Chicago?"
+}`)))
+	require.NoError(t, err)
+	require.Len(t, output, 1)
+	b, err := output[0].AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, "The weather is sunny in Chicago", string(b))
+
+	require.Len(t, reqs, 2)
+	require.Len(t, reqs[0].Tools, 1)
+	assert.Equal(t, "GetWeather", reqs[0].Tools[0].Function.Name)
+
+	var roles []string
+	for _, m := range reqs[1].Messages {
+		roles = append(roles, m.Role)
+	}
+	assert.Equal(t, []string{"system", "user", "assistant", "user", "assistant", "tool"}, roles)
+	assert.Equal(t, "Hello", reqs[1].Messages[1].Content)
+	assert.Equal(t, "What is the weather like in Chicago?", reqs[1].Messages[3].Content)
+	assert.Equal(t, "call_1", reqs[1].Messages[5].ToolCallID)
+	assert.Equal(t, "sunny in Chicago", reqs[1].Messages[5].Content)
+}
+
+func TestChatMaxToolCalls(t *testing.T) {
+	p := chatProcessor{
+		baseProcessor: &baseProcessor{
+			client: &mockToolCallClient{},
+			model:  "gpt-4o",
+		},
+		maxToolCalls: 2,
+		tools: []tool{{
+			spec: oai.Tool{Type: oai.ToolTypeFunction, Function: &oai.FunctionDefinition{Name: "Loop"}},
+		}},
+	}
+	_, err := p.Process(context.Background(), service.NewMessage([]byte(faker.Paragraph())))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model did not finish after 2 tool calls")
+}
+
+type mockToolCallClient struct {
+	stubClient
+}
+
+func (m *mockToolCallClient) CreateChatCompletion(ctx context.Context, body oai.ChatCompletionRequest) (resp oai.ChatCompletionResponse, err error) {
+	resp.Choices = []oai.ChatCompletionChoice{{
+		Message: oai.ChatCompletionMessage{
+			Role: "assistant",
+			ToolCalls: []oai.ToolCall{{
+				ID:       faker.UUIDHyphenated(),
+				Type:     oai.ToolTypeFunction,
+				Function: oai.FunctionCall{Name: "Loop"},
+			}},
+		},
+	}}
+	return
+}
+
+func TestChatHistoryMessages(t *testing.T) {
+	msgs, err := historyMessages([]any{
+		map[string]any{"role": "system", "content": "foo"},
+		map[string]any{"role": "assistant", "content": "bar"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []oai.ChatCompletionMessage{
+		{Role: "system", Content: "foo"},
+		{Role: "assistant", Content: "bar"},
+	}, msgs)
+
+	_, err = historyMessages(map[string]any{"role": "user", "content": "foo"})
+	require.Error(t, err)
+
+	_, err = historyMessages([]any{map[string]any{"role": "tool", "content": "foo"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "role must be one of system, user or assistant")
+
+	_, err = historyMessages([]any{map[string]any{"role": "user", "content": 5}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "content must be a string")
+}
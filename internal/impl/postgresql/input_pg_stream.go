@@ -12,6 +12,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/checkpoint"
@@ -21,6 +22,7 @@ import (
 	"github.com/redpanda-data/benthos/v4/public/service"
 
 	"github.com/redpanda-data/connect/v4/internal/asyncroutine"
+	"github.com/redpanda-data/connect/v4/internal/impl/cdc"
 	"github.com/redpanda-data/connect/v4/internal/impl/postgresql/pglogicalstream"
 	"github.com/redpanda-data/connect/v4/internal/license"
 )
@@ -41,6 +43,11 @@ const (
 	fieldBatching                  = "batching"
 	fieldMaxParallelSnapshotTables = "max_parallel_snapshot_tables"
 	fieldUnchangedToastValue       = "unchanged_toast_value"
+	fieldSignalTable               = "signal_table"
+	fieldTableDiscoveryInterval    = "table_discovery_interval"
+	fieldCleanupOnClose            = "cleanup_on_close"
+	fieldBinaryFormat              = "binary_format"
+	fieldStreamLargeTransactions   = "stream_large_transactions"
 
 	shutdownTimeout = 5 * time.Second
 )
@@ -63,8 +70,23 @@ Additionally, if ` + "`" + fieldStreamSnapshot + "`" + ` is set to true, then th
 
 This input adds the following metadata fields to each message:
 - table (Name of the table that the message originated from)
-- operation (Type of operation that generated the message: "read", "insert", "update", or "delete". "read" is from messages that are read in the initial snapshot phase. This will also be "begin" and "commit" if ` + "`" + fieldIncludeTxnMarkers + "`" + ` is enabled)
+- operation (Type of operation that generated the message: "read", "insert", "update", "delete", "truncate" or "message". "read" is from messages that are read in the initial snapshot phase. This will also be "begin" and "commit" if ` + "`" + fieldIncludeTxnMarkers + "`" + ` is enabled)
 - lsn (the log sequence number in postgres)
+- origin (the replication origin of the transaction, only set for changes that were replicated from another server, which can be used to avoid replication loops in bi-directional setups)
+
+== Truncates and logical decoding messages
+
+A ` + "`TRUNCATE`" + ` of a streamed table is emitted as a message with the operation "truncate" for each truncated table, with a payload containing the boolean fields ` + "`cascade` and `restart_identity`" + `.
+
+Messages written to the WAL with ` + "`pg_logical_emit_message`" + `, e.g. for the outbox pattern, are emitted with the operation "message" and a payload containing the fields ` + "`prefix`, `content` and `transactional`" + `. Logical decoding messages require Postgres 15 or newer.
+
+Columns of user-defined types are decoded using the definition of the type: enums as strings, composite types and hstores as objects and domains and arrays as their underlying types.
+
+== Incremental snapshots
+
+Snapshots of tables can be triggered while streaming, e.g. in order to backfill a sink, either by inserting a row into the table ` + "`" + fieldSignalTable + "`" + ` or with the xref:guides:bloblang/functions.adoc#cdc_snapshot[` + "`cdc_snapshot`" + ` Bloblang function] using the label of the input. The rows of incremental snapshots are read in chunks with the operation "read", interleaved with the changes of the replication stream such that a row is never emitted with a state older than the last change streamed for it. Incremental snapshots require Postgres 15 or newer and are not resumed when the input restarts.
+
+` + cdc.SignalTableDescription + `
 		`).
 		Field(service.NewStringField(fieldDSN).
 			Description("The Data Source Name for the PostgreSQL database in the form of `postgres://[user[:password]@][netloc][:port][/dbname][?param1=value1&...]`. Please note that Postgres enforces SSL by default, you can override this with the parameter `sslmode=disable` if required.").
@@ -85,12 +107,30 @@ This input adds the following metadata fields to each message:
 			Example(10000).
 			Default(0)).
 		Field(service.NewStringField(fieldSchema).
-			Description("The PostgreSQL schema from which to replicate data.").
-			Examples("public", `"MyCaseSensitiveSchemaNeedingQuotes"`),
+			Description("The PostgreSQL schema from which to replicate data, for tables that are specified without a schema.").
+			Examples("public", `"MyCaseSensitiveSchemaNeedingQuotes"`).
+			Default("public"),
 		).
 		Field(service.NewStringListField(fieldTables).
-			Description("A list of table names to include in the logical replication. Each table should be specified as a separate item.").
-			Example([]string{"my_table_1", `"MyCaseSensitiveTableNeedingQuotes"`})).
+			Description("A list of tables to include in the logical replication. Each table should be specified as a separate item, either as `table` within `" + fieldSchema + "` or as `schema.table`. The schema and table names can contain the glob wildcards `*`, `?` and `[...]`, in which case all existing tables that match are included, and matching tables created while streaming are added every `" + fieldTableDiscoveryInterval + "`.").
+			Example([]string{"my_table_1", `"MyCaseSensitiveTableNeedingQuotes"`}).
+			Example([]string{"tenant_*.orders", "public.*"})).
+		Field(service.NewDurationField(fieldTableDiscoveryInterval).
+			Description("How often to check for new tables matching wildcard patterns in `" + fieldTables + "`, which are added to the publication. If `" + fieldStreamSnapshot + "` is enabled, the existing rows of the new tables are read with an incremental snapshot. Set to `0s` to disable.").
+			Default("1m").
+			Advanced()).
+		Field(service.NewBoolField(fieldCleanupOnClose).
+			Description("When set to true, the replication slot and publication are dropped when the input is closed, if they were created by the input rather than existing before it was started. This releases the WAL retained by the slot, but the input can then not resume from where it left off.").
+			Default(false).
+			Advanced()).
+		Field(service.NewBoolField(fieldBinaryFormat).
+			Description("When set to true, column values are requested from Postgres in the binary format and decoded into faithful types: numerics are emitted as JSON numbers without loss of precision, arrays as arrays, ranges as objects with the fields `lower`, `upper`, `lower_inclusive` and `upper_inclusive` (or `empty`), hstores and json columns as objects and geometric types as objects or arrays of points with the fields `x` and `y`. Rows of snapshots are decoded into the same types. Values of types that can not be decoded from the binary format are emitted as raw bytes. Requires Postgres 14 or newer.").
+			Default(false).
+			Advanced()).
+		Field(service.NewBoolField(fieldStreamLargeTransactions).
+			Description("When set to true, Postgres streams the changes of transactions that exceed `logical_decoding_work_mem` while they are in progress, rather than spilling them to disk on the server until the transaction commits. The changes are spooled to temporary files by the input and emitted once the transaction commits, so changes of aborted transactions are never emitted. Requires Postgres 14 or newer.").
+			Default(false).
+			Advanced()).
 		Field(service.NewIntField(fieldCheckpointLimit).
 			Description("The maximum number of messages that can be processed at a given time. Increasing this limit enables parallel processing and batching at the output level. Any given LSN will not be acknowledged unless all messages under that offset are delivered in order to preserve at least once delivery guarantees.").
 			Default(1024)).
@@ -117,6 +157,11 @@ This input adds the following metadata fields to each message:
 			Default(nil).
 			Example("__redpanda_connect_unchanged_toast_value__").
 			Advanced()).
+		Field(service.NewStringField(fieldSignalTable).
+			Description("A table of the schema that is watched for signals, such as requests for incremental snapshots. The table is added to the publication of the input in addition to `" + fieldTables + "`.").
+			Example("connect_signals").
+			Optional().
+			Advanced()).
 		Field(service.NewAutoRetryNacksToggleField()).
 		Field(service.NewBatchPolicyField(fieldBatching))
 }
@@ -138,6 +183,11 @@ func newPgStreamInput(conf *service.ParsedConfig, mgr *service.Resources) (s ser
 		pgStandbyTimeout          time.Duration
 		batching                  service.BatchPolicy
 		unchangedToastValue       any
+		signalTable               string
+		tableDiscoveryInterval    time.Duration
+		cleanupOnClose            bool
+		binaryFormat              bool
+		streamLargeTransactions   bool
 	)
 
 	if err := license.CheckRunningEnterprise(mgr); err != nil {
@@ -217,6 +267,28 @@ func newPgStreamInput(conf *service.ParsedConfig, mgr *service.Resources) (s ser
 		return nil, err
 	}
 
+	if tableDiscoveryInterval, err = conf.FieldDuration(fieldTableDiscoveryInterval); err != nil {
+		return nil, err
+	}
+
+	if cleanupOnClose, err = conf.FieldBool(fieldCleanupOnClose); err != nil {
+		return nil, err
+	}
+
+	if binaryFormat, err = conf.FieldBool(fieldBinaryFormat); err != nil {
+		return nil, err
+	}
+
+	if streamLargeTransactions, err = conf.FieldBool(fieldStreamLargeTransactions); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains(fieldSignalTable) {
+		if signalTable, err = conf.FieldString(fieldSignalTable); err != nil {
+			return nil, err
+		}
+	}
+
 	pgConnConfig, err := pgconn.ParseConfigWithOptions(dsn, pgconn.ParseConfigOptions{
 		// Don't support dynamic reading of password
 		GetSSLPassword: func(context.Context) string { return "" },
@@ -238,6 +310,9 @@ func newPgStreamInput(conf *service.ParsedConfig, mgr *service.Resources) (s ser
 			DBSchema: schema,
 			DBTables: tables,
 
+			SignalTable:            signalTable,
+			TableDiscoveryInterval: tableDiscoveryInterval,
+
 			IncludeTxnMarkers:          includeTxnMarkers,
 			ReplicationSlotName:        "rs_" + dbSlotName,
 			BatchSize:                  snapshotBatchSize,
@@ -249,9 +324,13 @@ func newPgStreamInput(conf *service.ParsedConfig, mgr *service.Resources) (s ser
 			MaxParallelSnapshotTables:  maxParallelSnapshotTables,
 			Logger:                     mgr.Logger(),
 			UnchangedToastValue:        unchangedToastValue,
+
+			BinaryValues:                 binaryFormat,
+			StreamInProgressTransactions: streamLargeTransactions,
 		},
 		batching:        batching,
 		checkpointLimit: checkpointLimit,
+		cleanupOnClose:  cleanupOnClose,
 		msgChan:         make(chan asyncMessage),
 
 		mgr:             mgr,
@@ -268,6 +347,9 @@ func newPgStreamInput(conf *service.ParsedConfig, mgr *service.Resources) (s ser
 	if err != nil {
 		return nil, err
 	}
+	if i.deregisterTrigger, err = cdc.RegisterSnapshotTrigger(mgr.Label(), i.triggerSnapshot); err != nil {
+		return nil, err
+	}
 
 	return conf.WrapBatchInputExtractTracingSpanMapping("postgres_cdc", r)
 }
@@ -309,6 +391,14 @@ type pgStreamInput struct {
 	snapshotMetrics *service.MetricGauge
 	replicationLag  *service.MetricGauge
 	stopSig         *shutdown.Signaller
+
+	streamMut         sync.Mutex
+	stream            *pglogicalstream.Stream
+	deregisterTrigger func()
+
+	cleanupOnClose bool
+	// createdResources are the replication slot and publication created by the streams of the input
+	createdResources pglogicalstream.ReplicationResources
 }
 
 func (p *pgStreamInput) Connect(ctx context.Context) error {
@@ -322,10 +412,23 @@ func (p *pgStreamInput) Connect(ctx context.Context) error {
 	}
 	// Reset our stop signal
 	p.stopSig = shutdown.NewSignaller()
+	p.streamMut.Lock()
+	p.stream = pgStream
+	p.streamMut.Unlock()
 	go p.processStream(pgStream, batcher)
 	return err
 }
 
+// triggerSnapshot schedules an incremental snapshot of tables on the current replication stream.
+func (p *pgStreamInput) triggerSnapshot(tables []string) error {
+	p.streamMut.Lock()
+	defer p.streamMut.Unlock()
+	if p.stream == nil {
+		return service.ErrNotConnected
+	}
+	return p.stream.TriggerSnapshot(tables)
+}
+
 func (p *pgStreamInput) processStream(pgStream *pglogicalstream.Stream, batcher *service.Batcher) {
 	monitorLoop := asyncroutine.NewPeriodic(p.streamConfig.WalMonitorInterval, func() {
 		// Periodically collect stats
@@ -346,9 +449,19 @@ func (p *pgStreamInput) processStream(pgStream *pglogicalstream.Stream, batcher
 			p.logger.Errorf("unable to close batcher: %s", err)
 		}
 		// TODO(rockwood): We should wait for outstanding acks to be completed (best effort)
+		p.streamMut.Lock()
+		p.stream = nil
+		p.streamMut.Unlock()
 		if err := pgStream.Stop(ctx); err != nil {
 			p.logger.Errorf("unable to stop replication stream: %s", err)
 		}
+		created := pgStream.CreatedResources()
+		if created.SlotName != "" {
+			p.createdResources.SlotName = created.SlotName
+		}
+		if created.PublicationName != "" {
+			p.createdResources.PublicationName = created.PublicationName
+		}
 		p.stopSig.TriggerHasStopped()
 	}()
 
@@ -384,6 +497,9 @@ func (p *pgStreamInput) processStream(pgStream *pglogicalstream.Stream, batcher
 			if message.LSN != nil {
 				batchMsg.MetaSet("lsn", *message.LSN)
 			}
+			if message.Origin != "" {
+				batchMsg.MetaSet("origin", message.Origin)
+			}
 			if batcher.Add(batchMsg) {
 				nextTimedBatchChan = nil
 				flushedBatch, err := batcher.Flush(ctx)
@@ -466,6 +582,7 @@ func (p *pgStreamInput) ReadBatch(ctx context.Context) (service.MessageBatch, se
 }
 
 func (p *pgStreamInput) Close(ctx context.Context) error {
+	p.deregisterTrigger()
 	p.stopSig.TriggerSoftStop()
 	select {
 	case <-ctx.Done():
@@ -477,7 +594,19 @@ func (p *pgStreamInput) Close(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-time.After(shutdownTimeout):
+		if p.cleanupOnClose {
+			p.logger.Warn("unable to drop replication slot and publication as the replication stream did not stop in time")
+		}
+		return nil
 	case <-p.stopSig.HasStoppedChan():
 	}
+	if p.cleanupOnClose && p.createdResources != (pglogicalstream.ReplicationResources{}) {
+		cleanupCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+		if err := pglogicalstream.DropReplicationResources(cleanupCtx, p.streamConfig.DBRawDSN, p.createdResources); err != nil {
+			return err
+		}
+		p.logger.Infof("dropped replication resources %+v", p.createdResources)
+	}
 	return nil
 }
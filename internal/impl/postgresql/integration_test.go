@@ -21,6 +21,7 @@ import (
 
 	"github.com/go-faker/faker/v4"
 	_ "github.com/lib/pq"
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
 	_ "github.com/redpanda-data/benthos/v4/public/components/io"
 	_ "github.com/redpanda-data/benthos/v4/public/components/pure"
 	"github.com/redpanda-data/benthos/v4/public/service"
@@ -1062,3 +1063,397 @@ postgres_cdc:
 	require.NoError(t, streamOut.StopWithin(time.Second*10))
 
 }
+
+func TestIntegrationPostgresIncrementalSnapshot(t *testing.T) {
+	t.Parallel()
+	integration.CheckSkip(t)
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	var (
+		resource *dockertest.Resource
+		db       *sql.DB
+	)
+
+	resource, db, err = ResourceWithPostgreSQLVersion(t, pool, "16")
+	require.NoError(t, err)
+	require.NoError(t, resource.Expire(120))
+
+	hostAndPort := resource.GetHostPort("5432/tcp")
+	hostAndPortSplited := strings.Split(hostAndPort, ":")
+	password := "l]YLSc|4[i56%{gY"
+
+	_, err = db.Exec(`CREATE TABLE signals (id VARCHAR(64) PRIMARY KEY, type VARCHAR(32) NOT NULL, data VARCHAR(2048));`)
+	require.NoError(t, err)
+	for range 100 {
+		_, err = db.Exec("INSERT INTO seq DEFAULT VALUES")
+		require.NoError(t, err)
+	}
+
+	databaseURL := fmt.Sprintf("user=user_name password=%s dbname=dbname sslmode=disable host=%s port=%s", password, hostAndPortSplited[0], hostAndPortSplited[1])
+	template := fmt.Sprintf(`
+label: seq_cdc
+postgres_cdc:
+    dsn: %s
+    slot_name: test_slot_incremental
+    snapshot_batch_size: 10
+    signal_table: signals
+    schema: public
+    tables:
+      - seq
+`, databaseURL)
+
+	streamOutBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+	require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+	var reads, inserts atomic.Int64
+	require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, batch service.MessageBatch) error {
+		for _, msg := range batch {
+			switch operation, _ := msg.MetaGet("operation"); operation {
+			case "read":
+				reads.Add(1)
+			case "insert":
+				inserts.Add(1)
+			}
+		}
+		return nil
+	}))
+
+	streamOut, err := streamOutBuilder.Build()
+	require.NoError(t, err)
+	license.InjectTestService(streamOut.Resources())
+
+	go func() {
+		_ = streamOut.Run(context.Background())
+	}()
+
+	time.Sleep(5 * time.Second)
+	_, err = db.Exec(`INSERT INTO signals VALUES ('1', 'execute-snapshot', '{"data-collections": ["seq"]}');`)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO seq DEFAULT VALUES")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return reads.Load() >= 100 && inserts.Load() == 1
+	}, time.Second*25, time.Millisecond*100)
+
+	exe, err := bloblang.Parse(`root = cdc_snapshot("seq_cdc", ["seq"])`)
+	require.NoError(t, err)
+	_, err = exe.Query(nil)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return reads.Load() >= 201
+	}, time.Second*25, time.Millisecond*100)
+
+	require.NoError(t, streamOut.StopWithin(10*time.Second))
+}
+
+func TestIntegrationPostgresTruncateMessagesAndTypes(t *testing.T) {
+	t.Parallel()
+	integration.CheckSkip(t)
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	var (
+		resource *dockertest.Resource
+		db       *sql.DB
+	)
+
+	resource, db, err = ResourceWithPostgreSQLVersion(t, pool, "16")
+	require.NoError(t, err)
+	require.NoError(t, resource.Expire(120))
+
+	hostAndPort := resource.GetHostPort("5432/tcp")
+	hostAndPortSplited := strings.Split(hostAndPort, ":")
+	password := "l]YLSc|4[i56%{gY"
+
+	for _, stmt := range []string{
+		`CREATE TYPE mood AS ENUM ('sad', 'happy');`,
+		`CREATE TYPE point2d AS (x INT, y INT);`,
+		`CREATE TABLE custom_types (id INT PRIMARY KEY, m mood, p point2d);`,
+	} {
+		_, err = db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	databaseURL := fmt.Sprintf("user=user_name password=%s dbname=dbname sslmode=disable host=%s port=%s", password, hostAndPortSplited[0], hostAndPortSplited[1])
+	template := fmt.Sprintf(`
+postgres_cdc:
+    dsn: %s
+    slot_name: test_slot_truncate
+    schema: public
+    tables:
+      - custom_types
+`, databaseURL)
+
+	streamOutBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+	require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+	type result struct {
+		operation string
+		body      string
+	}
+	var outMsgs []result
+	var outMsgsMut sync.Mutex
+	require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, batch service.MessageBatch) error {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		for _, msg := range batch {
+			body, err := msg.AsBytes()
+			require.NoError(t, err)
+			operation, _ := msg.MetaGet("operation")
+			outMsgs = append(outMsgs, result{operation: operation, body: string(body)})
+		}
+		return nil
+	}))
+
+	streamOut, err := streamOutBuilder.Build()
+	require.NoError(t, err)
+	license.InjectTestService(streamOut.Resources())
+
+	go func() {
+		_ = streamOut.Run(context.Background())
+	}()
+
+	time.Sleep(5 * time.Second)
+	_, err = db.Exec(`INSERT INTO custom_types VALUES (1, 'happy', ROW(1, 2));`)
+	require.NoError(t, err)
+	_, err = db.Exec(`SELECT pg_logical_emit_message(false, 'outbox', 'hello');`)
+	require.NoError(t, err)
+	_, err = db.Exec(`TRUNCATE custom_types;`)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		return len(outMsgs) == 3
+	}, time.Second*25, time.Millisecond*100)
+
+	require.NoError(t, streamOut.StopWithin(10*time.Second))
+
+	outMsgsMut.Lock()
+	defer outMsgsMut.Unlock()
+	assert.Equal(t, "insert", outMsgs[0].operation)
+	assert.JSONEq(t, `{"id":1,"m":"happy","p":{"x":1,"y":2}}`, outMsgs[0].body)
+	assert.Equal(t, "message", outMsgs[1].operation)
+	assert.JSONEq(t, `{"prefix":"outbox","content":"hello","transactional":false}`, outMsgs[1].body)
+	assert.Equal(t, "truncate", outMsgs[2].operation)
+	assert.JSONEq(t, `{"cascade":false,"restart_identity":false}`, outMsgs[2].body)
+}
+
+func TestIntegrationPostgresTablePatternsAndCleanup(t *testing.T) {
+	t.Parallel()
+	integration.CheckSkip(t)
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	var (
+		resource *dockertest.Resource
+		db       *sql.DB
+	)
+
+	resource, db, err = ResourceWithPostgreSQLVersion(t, pool, "16")
+	require.NoError(t, err)
+	require.NoError(t, resource.Expire(120))
+
+	hostAndPort := resource.GetHostPort("5432/tcp")
+	hostAndPortSplited := strings.Split(hostAndPort, ":")
+	password := "l]YLSc|4[i56%{gY"
+
+	for _, stmt := range []string{
+		`CREATE SCHEMA tenant_a;`,
+		`CREATE SCHEMA tenant_b;`,
+		`CREATE TABLE tenant_a.orders (id INT PRIMARY KEY);`,
+		`CREATE TABLE tenant_a.items (id INT PRIMARY KEY);`,
+		`INSERT INTO tenant_a.orders VALUES (1);`,
+	} {
+		_, err = db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	databaseURL := fmt.Sprintf("user=user_name password=%s dbname=dbname sslmode=disable host=%s port=%s", password, hostAndPortSplited[0], hostAndPortSplited[1])
+	template := fmt.Sprintf(`
+postgres_cdc:
+    dsn: %s
+    slot_name: test_slot_patterns
+    stream_snapshot: true
+    table_discovery_interval: 1s
+    cleanup_on_close: true
+    tables:
+      - tenant_*.orders
+`, databaseURL)
+
+	streamOutBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+	require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+	var outMsgs []string
+	var outMsgsMut sync.Mutex
+	require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, batch service.MessageBatch) error {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		for _, msg := range batch {
+			table, _ := msg.MetaGet("table")
+			operation, _ := msg.MetaGet("operation")
+			outMsgs = append(outMsgs, table+":"+operation)
+		}
+		return nil
+	}))
+
+	streamOut, err := streamOutBuilder.Build()
+	require.NoError(t, err)
+	license.InjectTestService(streamOut.Resources())
+
+	go func() {
+		_ = streamOut.Run(context.Background())
+	}()
+
+	assert.Eventually(t, func() bool {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		return len(outMsgs) == 1
+	}, time.Second*25, time.Millisecond*100)
+
+	// A new tenant is picked up while streaming, including the rows written before it was discovered
+	_, err = db.Exec(`CREATE TABLE tenant_b.orders (id INT PRIMARY KEY);`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO tenant_b.orders VALUES (1);`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO tenant_a.items VALUES (1);`)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Second)
+	_, err = db.Exec(`INSERT INTO tenant_b.orders VALUES (2);`)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		return len(outMsgs) == 3
+	}, time.Second*25, time.Millisecond*100)
+
+	require.NoError(t, streamOut.StopWithin(10*time.Second))
+
+	outMsgsMut.Lock()
+	assert.Equal(t, []string{"orders:read", "orders:read", "orders:insert"}, outMsgs)
+	outMsgsMut.Unlock()
+
+	var slots, publications int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM pg_replication_slots WHERE slot_name = 'rs_test_slot_patterns'`).Scan(&slots))
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM pg_publication WHERE pubname = 'pglog_stream_rs_test_slot_patterns'`).Scan(&publications))
+	assert.Zero(t, slots)
+	assert.Zero(t, publications)
+}
+
+func TestIntegrationPostgresBinaryFormatAndStreaming(t *testing.T) {
+	t.Parallel()
+	integration.CheckSkip(t)
+	pool, err := dockertest.NewPool("")
+	require.NoError(t, err)
+
+	var (
+		resource *dockertest.Resource
+		db       *sql.DB
+	)
+
+	resource, db, err = ResourceWithPostgreSQLVersion(t, pool, "16")
+	require.NoError(t, err)
+	require.NoError(t, resource.Expire(120))
+
+	hostAndPort := resource.GetHostPort("5432/tcp")
+	hostAndPortSplited := strings.Split(hostAndPort, ":")
+	password := "l]YLSc|4[i56%{gY"
+
+	for _, stmt := range []string{
+		// Stream transactions as soon as possible
+		`ALTER SYSTEM SET logical_decoding_work_mem = '64kB';`,
+		`SELECT pg_reload_conf();`,
+		`CREATE TABLE rich_types (id INT PRIMARY KEY, amount NUMERIC, span NUMRANGE, location POINT, doc JSONB);`,
+		`INSERT INTO rich_types VALUES (1, 12345678901234567890.0123, '[1.5,2)', point(45.5, -122.6), '{"big": 9007199254740993}');`,
+	} {
+		_, err = db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	databaseURL := fmt.Sprintf("user=user_name password=%s dbname=dbname sslmode=disable host=%s port=%s", password, hostAndPortSplited[0], hostAndPortSplited[1])
+	template := fmt.Sprintf(`
+postgres_cdc:
+    dsn: %s
+    slot_name: test_slot_binary
+    stream_snapshot: true
+    binary_format: true
+    stream_large_transactions: true
+    tables:
+      - rich_types
+`, databaseURL)
+
+	streamOutBuilder := service.NewStreamBuilder()
+	require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: INFO`))
+	require.NoError(t, streamOutBuilder.AddInputYAML(template))
+
+	var outMsgs []string
+	var outMsgsMut sync.Mutex
+	require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, batch service.MessageBatch) error {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		for _, msg := range batch {
+			msgBytes, err := msg.AsBytes()
+			require.NoError(t, err)
+			outMsgs = append(outMsgs, string(msgBytes))
+		}
+		return nil
+	}))
+
+	streamOut, err := streamOutBuilder.Build()
+	require.NoError(t, err)
+	license.InjectTestService(streamOut.Resources())
+
+	go func() {
+		_ = streamOut.Run(context.Background())
+	}()
+
+	assert.Eventually(t, func() bool {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		return len(outMsgs) == 1
+	}, time.Second*25, time.Millisecond*100)
+
+	// A large transaction that is rolled back is never emitted
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec(`INSERT INTO rich_types (id, amount) SELECT i, i FROM generate_series(1000, 5999) AS i;`)
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	// A large transaction with a rolled back subtransaction only emits the changes of the committed transaction
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec(`INSERT INTO rich_types (id, amount) SELECT i, i FROM generate_series(2, 3001) AS i;`)
+	require.NoError(t, err)
+	_, err = tx.Exec(`SAVEPOINT discarded;`)
+	require.NoError(t, err)
+	_, err = tx.Exec(`INSERT INTO rich_types (id, amount) SELECT i, i FROM generate_series(10000, 12999) AS i;`)
+	require.NoError(t, err)
+	_, err = tx.Exec(`ROLLBACK TO SAVEPOINT discarded;`)
+	require.NoError(t, err)
+	_, err = tx.Exec(`UPDATE rich_types SET amount = 1.5, span = 'empty' WHERE id = 1;`)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	assert.Eventually(t, func() bool {
+		outMsgsMut.Lock()
+		defer outMsgsMut.Unlock()
+		return len(outMsgs) == 3002
+	}, time.Second*25, time.Millisecond*100)
+
+	require.NoError(t, streamOut.StopWithin(10*time.Second))
+
+	outMsgsMut.Lock()
+	defer outMsgsMut.Unlock()
+	require.Len(t, outMsgs, 3002)
+	assert.JSONEq(t, `{"id":1, "amount":12345678901234567890.0123, "span":{"lower":1.5, "upper":2, "lower_inclusive":true, "upper_inclusive":false}, "location":{"x":45.5, "y":-122.6}, "doc":{"big":9007199254740993}}`, outMsgs[0])
+	assert.JSONEq(t, `{"id":2, "amount":2, "span":null, "location":null, "doc":null}`, outMsgs[1])
+	assert.JSONEq(t, `{"id":1, "amount":1.5, "span":{"empty":true}, "location":{"x":45.5, "y":-122.6}, "doc":{"big":9007199254740993}}`, outMsgs[3001])
+}
@@ -22,8 +22,12 @@ type Config struct {
 	DBRawDSN string
 	// The DB schema to lookup tables in
 	DBSchema string
-	// DbTables is the tables to stream changes from
+	// DbTables is the tables to stream changes from, as `table` or `schema.table` patterns that may contain wildcards
 	DBTables []string
+	// TableDiscoveryInterval is how often tables matching wildcard patterns are discovered, zero disables discovery
+	TableDiscoveryInterval time.Duration
+	// SignalTable is the table watched for signals, such as requests for incremental snapshots
+	SignalTable string
 	// ReplicationSlotName is the name of the replication slot to use
 	//
 	// MUST BE SQL INJECTION FREE
@@ -46,4 +50,8 @@ type Config struct {
 	MaxParallelSnapshotTables int
 	// The value to use for unchanged toast columns
 	UnchangedToastValue any
+	// BinaryValues requests values in the binary format and decodes them into faithful types
+	BinaryValues bool
+	// StreamInProgressTransactions streams large transactions before they commit, spooling them to disk
+	StreamInProgressTransactions bool
 }
@@ -0,0 +1,115 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// registerCustomType looks up a user-defined type announced by a TypeMessage and registers a codec for it, so that
+// enums are decoded as strings, composite types and hstores as objects and domains and arrays as their underlying
+// types. Types without a suitable codec are left unregistered and decoded as strings. Types are looked up again when
+// announced again, as their definition may have changed.
+func registerCustomType(ctx context.Context, db *sql.DB, typeMap *pgtype.Map, oid uint32) error {
+	var (
+		name                       string
+		typType, typCategory       string
+		typRelID, typElem, typBase uint32
+	)
+	row := db.QueryRowContext(ctx, `SELECT typname, typtype, typcategory, typrelid, typelem, typbasetype FROM pg_type WHERE oid = $1`, oid)
+	if err := row.Scan(&name, &typType, &typCategory, &typRelID, &typElem, &typBase); err != nil {
+		return fmt.Errorf("unable to look up type %d: %w", oid, err)
+	}
+
+	var codec pgtype.Codec
+	switch {
+	case typType == "e":
+		codec = &pgtype.EnumCodec{}
+	case typType == "b" && name == "hstore":
+		codec = pgtype.HstoreCodec{}
+	case typType == "c":
+		fields, err := compositeTypeFields(ctx, db, typeMap, typRelID)
+		if err != nil {
+			return fmt.Errorf("unable to look up fields of type %s: %w", name, err)
+		}
+		codec = &pgtype.CompositeCodec{Fields: fields}
+	case typType == "d":
+		if err := ensureType(ctx, db, typeMap, typBase); err != nil {
+			return err
+		}
+		base, ok := typeMap.TypeForOID(typBase)
+		if !ok {
+			return nil
+		}
+		codec = base.Codec
+	case typCategory == "A" && typElem != 0:
+		if err := ensureType(ctx, db, typeMap, typElem); err != nil {
+			return err
+		}
+		elem, ok := typeMap.TypeForOID(typElem)
+		if !ok {
+			return nil
+		}
+		codec = &pgtype.ArrayCodec{ElementType: elem}
+	default:
+		return nil
+	}
+	typeMap.RegisterType(&pgtype.Type{Name: name, OID: oid, Codec: codec})
+	return nil
+}
+
+// ensureType registers a type that a user-defined type depends on, unless it is already known.
+func ensureType(ctx context.Context, db *sql.DB, typeMap *pgtype.Map, oid uint32) error {
+	if _, ok := typeMap.TypeForOID(oid); ok {
+		return nil
+	}
+	return registerCustomType(ctx, db, typeMap, oid)
+}
+
+func compositeTypeFields(ctx context.Context, db *sql.DB, typeMap *pgtype.Map, relID uint32) ([]pgtype.CompositeCodecField, error) {
+	rows, err := db.QueryContext(ctx, `SELECT attname, atttypid FROM pg_attribute WHERE attrelid = $1 AND attnum > 0 AND NOT attisdropped ORDER BY attnum`, relID)
+	if err != nil {
+		return nil, err
+	}
+	type field struct {
+		name string
+		oid  uint32
+	}
+	var attrs []field
+	for rows.Next() {
+		var f field
+		if err := rows.Scan(&f.name, &f.oid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		attrs = append(attrs, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fields := make([]pgtype.CompositeCodecField, len(attrs))
+	for i, f := range attrs {
+		if err := ensureType(ctx, db, typeMap, f.oid); err != nil {
+			return nil, err
+		}
+		t, ok := typeMap.TypeForOID(f.oid)
+		if !ok {
+			// Fields without a codec are decoded as text
+			t, _ = typeMap.TypeForOID(pgtype.TextOID)
+		}
+		fields[i] = pgtype.CompositeCodecField{Name: f.name, Type: t}
+	}
+	return fields, nil
+}
@@ -0,0 +1,518 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/cdc"
+	"github.com/redpanda-data/connect/v4/internal/impl/postgresql/pglogicalstream/sanitize"
+)
+
+// watermarkPrefix is the prefix of the logical decoding messages that mark the end of a chunk of an incremental
+// snapshot in the WAL.
+const watermarkPrefix = "redpanda_connect_watermark"
+
+const defaultIncrementalSnapshotBatchSize = 1024
+
+// chunkWindow is a chunk of an incremental snapshot. The window opens before the chunk is queried and closes when the
+// stream decodes the watermark message emitted after the query. Rows changed by the stream while the window is open
+// are dropped from the chunk, as the stream emits a version of them at least as recent, and the remaining rows are
+// emitted once the watermark is reached.
+type chunkWindow struct {
+	id        string
+	schema    string
+	table     string
+	pkColumns []string
+	rows      []StreamMessage
+	keys      []string
+	changed   map[string]struct{}
+	done      chan struct{}
+}
+
+// incrementalSnapshots tracks the snapshots triggered while streaming and the windows of their chunks.
+type incrementalSnapshots struct {
+	mut       sync.Mutex
+	requests  []TableFQN
+	requested chan struct{}
+	windows   []*chunkWindow
+}
+
+func newIncrementalSnapshots() *incrementalSnapshots {
+	return &incrementalSnapshots{requested: make(chan struct{}, 1)}
+}
+
+// TriggerSnapshot schedules an incremental snapshot of tables, given as `table` or `schema.table` patterns, which are
+// read in chunks interleaved with the replication stream. Incremental snapshots are not resumed when the stream is
+// restarted.
+func (s *Stream) TriggerSnapshot(tables []string) error {
+	if s.incremental == nil {
+		return errors.New("incremental snapshots require Postgres 15 or newer")
+	}
+	streamed := s.streamedTables()
+	var fqns []TableFQN
+	for _, table := range tables {
+		p, err := parseTablePattern(s.schema, table)
+		if err != nil {
+			return err
+		}
+		matched := false
+		for _, t := range streamed {
+			if p.matchesFQN(t) {
+				fqns = append(fqns, t)
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("table %s is not streamed by this input", table)
+		}
+	}
+	s.incremental.enqueue(fqns)
+	return nil
+}
+
+// enqueue schedules an incremental snapshot of tables.
+func (i *incrementalSnapshots) enqueue(tables []TableFQN) {
+	i.mut.Lock()
+	for _, table := range tables {
+		if !slices.Contains(i.requests, table) {
+			i.requests = append(i.requests, table)
+		}
+	}
+	i.mut.Unlock()
+
+	select {
+	case i.requested <- struct{}{}:
+	default:
+	}
+}
+
+// runIncrementalSnapshots reads incremental snapshots as they are triggered until the stream is stopped.
+func (s *Stream) runIncrementalSnapshots(dsn string) {
+	ctx, done := s.shutSig.SoftStopCtx(context.Background())
+	defer done()
+
+	for {
+		select {
+		case <-s.incremental.requested:
+		case <-ctx.Done():
+			return
+		}
+
+		s.incremental.mut.Lock()
+		tables := s.incremental.requests
+		s.incremental.requests = nil
+		s.incremental.mut.Unlock()
+
+		s.logger.Infof("Starting incremental snapshot of tables %v", tables)
+		if err := s.readIncrementalSnapshot(ctx, dsn, tables); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case s.errors <- fmt.Errorf("failed to process incremental snapshot: %w", err):
+			case <-ctx.Done():
+			}
+			return
+		}
+		s.logger.Infof("Finished incremental snapshot of tables %v", tables)
+	}
+}
+
+func (s *Stream) readIncrementalSnapshot(ctx context.Context, dsn string, tables []TableFQN) error {
+	db, err := openPgConnectionFromConfig(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	snapshotter := &Snapshotter{pool: db, logger: s.logger, typedValues: s.binaryValues}
+
+	batchSize := s.snapshotBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultIncrementalSnapshotBatchSize
+	}
+
+	for _, table := range tables {
+		pkColumns, err := queryPrimaryKeyColumns(ctx, db, table)
+		if err != nil {
+			return fmt.Errorf("failed to get primary key column for table %v: %w", table, err)
+		}
+
+		var lastKey []any
+		for {
+			rowsCount, err := s.readIncrementalChunk(ctx, snapshotter, table, pkColumns, &lastKey, batchSize)
+			if err != nil {
+				return fmt.Errorf("failed to read snapshot chunk of table %v: %w", table, err)
+			}
+			if rowsCount < batchSize {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// readIncrementalChunk reads a chunk of rows after `lastKey` between a window and a watermark, and waits until the
+// stream has emitted them.
+func (s *Stream) readIncrementalChunk(ctx context.Context, snapshotter *Snapshotter, table TableFQN, pkColumns []string, lastKey *[]any, limit int) (int, error) {
+	unquotedTable, err := sanitize.UnquotePostgresIdentifier(table.Table)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected failure to unquote table name: %w", err)
+	}
+	unquotedSchema, err := sanitize.UnquotePostgresIdentifier(table.Schema)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected failure to unquote schema name: %w", err)
+	}
+	unquotedPkColumns := make([]string, len(pkColumns))
+	for i, col := range pkColumns {
+		if unquotedPkColumns[i], err = sanitize.UnquotePostgresIdentifier(col); err != nil {
+			return 0, fmt.Errorf("unexpected failure to unquote column name: %w", err)
+		}
+	}
+
+	cw := s.incremental.open(unquotedSchema, unquotedTable, unquotedPkColumns)
+
+	// The primary key columns are prepended as text, which is how pgoutput encodes the columns of changes
+	textColumns := make([]string, len(pkColumns))
+	for i, col := range pkColumns {
+		textColumns[i] = col + "::text"
+	}
+	query := fmt.Sprintf("SELECT %s, * FROM %s", strings.Join(textColumns, ", "), table.String())
+	var args []any
+	if len(*lastKey) > 0 {
+		placeholders := make([]string, len(pkColumns))
+		for i := range pkColumns {
+			placeholders[i] = "$" + strconv.Itoa(i+1)
+		}
+		query += fmt.Sprintf(" WHERE (%s) > (%s)", strings.Join(pkColumns, ", "), strings.Join(placeholders, ", "))
+		args = *lastKey
+	}
+	// NOTE: All strings passed into here have been validated or derived from the code/database, therefore not prone to SQL injection.
+	sq, err := sanitize.SQLQuery(fmt.Sprintf("%s ORDER BY %s LIMIT %d;", query, strings.Join(pkColumns, ", "), limit), args...)
+	if err != nil {
+		s.incremental.discard(cw)
+		return 0, err
+	}
+
+	rows, keys, err := s.queryIncrementalChunk(ctx, snapshotter, sq, unquotedSchema, unquotedTable, unquotedPkColumns, lastKey)
+	if err != nil {
+		s.incremental.discard(cw)
+		return 0, err
+	}
+	if len(rows) == 0 {
+		s.incremental.discard(cw)
+		return 0, nil
+	}
+	s.incremental.setRows(cw, rows, keys)
+
+	// The watermark is transactional so that it is decoded after all transactions committed before it, including
+	// those that were not visible to the chunk query.
+	wq, err := sanitize.SQLQuery("SELECT pg_logical_emit_message(true, $1, $2);", watermarkPrefix, cw.id)
+	if err != nil {
+		s.incremental.discard(cw)
+		return 0, err
+	}
+	if _, err := snapshotter.pool.ExecContext(ctx, wq); err != nil {
+		s.incremental.discard(cw)
+		return 0, fmt.Errorf("failed to emit watermark: %w", err)
+	}
+
+	select {
+	case <-cw.done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	return len(rows), nil
+}
+
+func (s *Stream) queryIncrementalChunk(ctx context.Context, snapshotter *Snapshotter, sq, schema, table string, pkColumns []string, lastKey *[]any) ([]StreamMessage, []string, error) {
+	chunkRows, err := snapshotter.pool.QueryContext(ctx, sq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer chunkRows.Close()
+
+	columnTypes, err := chunkRows.ColumnTypes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get column types: %w", err)
+	}
+	columnNames, err := chunkRows.Columns()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get column names: %w", err)
+	}
+
+	// The types of the primary key columns, which are selected again after their text
+	numPkColumns := len(pkColumns)
+	keyTypes := make([]string, numPkColumns)
+	for i, pk := range pkColumns {
+		if idx := slices.Index(columnNames[numPkColumns:], pk); idx >= 0 {
+			keyTypes[i] = columnTypes[numPkColumns+idx].DatabaseTypeName()
+		}
+	}
+	typeMap := pgtype.NewMap()
+
+	var (
+		messages []StreamMessage
+		keys     []string
+	)
+	for chunkRows.Next() {
+		keyArgs := make([]sql.NullString, numPkColumns)
+		scanArgs, valueGetters := snapshotter.prepareScannersAndGetters(columnTypes[numPkColumns:])
+		dest := make([]any, 0, len(columnTypes))
+		for i := range keyArgs {
+			dest = append(dest, &keyArgs[i])
+		}
+		if err := chunkRows.Scan(append(dest, scanArgs...)...); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		keyValues := make([]string, numPkColumns)
+		key := make([]any, numPkColumns)
+		for i, v := range keyArgs {
+			keyValues[i] = chunkKeyText(typeMap, keyTypes[i], v.String)
+			key[i] = v.String
+		}
+		*lastKey = key
+
+		data := make(map[string]any, len(valueGetters))
+		for i, getter := range valueGetters {
+			col := columnNames[numPkColumns+i]
+			if data[col], err = getter(scanArgs[i]); err != nil {
+				return nil, nil, fmt.Errorf("unable to decode column %s: %w", col, err)
+			}
+		}
+		messages = append(messages, StreamMessage{
+			Operation: ReadOpType,
+			Schema:    schema,
+			Table:     table,
+			Data:      data,
+		})
+		keys = append(keys, chunkRowKey(keyValues))
+	}
+	if err := chunkRows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to iterate chunk: %w", err)
+	}
+	return messages, keys, nil
+}
+
+// open opens a window for a chunk of the table that is about to be queried.
+func (i *incrementalSnapshots) open(schema, table string, pkColumns []string) *chunkWindow {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	cw := &chunkWindow{
+		// Random so that the watermarks of previous runs of the stream, which may be replayed, are not mistaken for it
+		id:        gonanoid.Must(),
+		schema:    schema,
+		table:     table,
+		pkColumns: pkColumns,
+		changed:   map[string]struct{}{},
+		done:      make(chan struct{}),
+	}
+	i.windows = append(i.windows, cw)
+	return cw
+}
+
+func (i *incrementalSnapshots) setRows(cw *chunkWindow, rows []StreamMessage, keys []string) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	cw.rows = rows
+	cw.keys = keys
+}
+
+func (i *incrementalSnapshots) discard(cw *chunkWindow) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+	i.windows = slices.DeleteFunc(i.windows, func(o *chunkWindow) bool { return o == cw })
+}
+
+// change records the rows of a change to a table, given as the key and old tuples of the change, in the open windows
+// of the table.
+func (i *incrementalSnapshots) change(typeMap *pgtype.Map, rel *RelationMessage, tuples ...*TupleData) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	for _, cw := range i.windows {
+		// Tables of different schemas may share a name when streaming multiple schemas
+		if cw.schema != rel.Namespace || cw.table != rel.RelationName {
+			continue
+		}
+		for _, tuple := range tuples {
+			if tuple == nil {
+				continue
+			}
+			if key, ok := tupleRowKey(typeMap, rel, tuple, cw.pkColumns); ok {
+				cw.changed[key] = struct{}{}
+			}
+		}
+	}
+}
+
+// watermark closes the window of a watermark message and returns the rows of its chunk that have not been changed.
+func (i *incrementalSnapshots) watermark(id string) (rows []StreamMessage, cw *chunkWindow) {
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	idx := slices.IndexFunc(i.windows, func(cw *chunkWindow) bool { return cw.id == id })
+	if idx < 0 {
+		return nil, nil
+	}
+	cw = i.windows[idx]
+	i.windows = slices.Delete(i.windows, idx, idx+1)
+	for idx, row := range cw.rows {
+		if _, changed := cw.changed[cw.keys[idx]]; !changed {
+			rows = append(rows, row)
+		}
+	}
+	return rows, cw
+}
+
+// tupleRowKey returns the key of a row of a change, which is only available if the tuple contains all of the primary
+// key columns.
+func tupleRowKey(typeMap *pgtype.Map, rel *RelationMessage, tuple *TupleData, pkColumns []string) (string, bool) {
+	values := make([]string, len(pkColumns))
+	for i, pk := range pkColumns {
+		idx := slices.IndexFunc(rel.Columns, func(c *RelationMessageColumn) bool { return c.Name == pk })
+		if idx < 0 || idx >= len(tuple.Columns) {
+			return "", false
+		}
+		switch col := tuple.Columns[idx]; col.DataType {
+		case TupleDataTypeText:
+			values[i] = string(col.Data)
+			if text, ok := keyText(typeMap, rel.Columns[idx].DataType, pgtype.TextFormatCode, col.Data); ok {
+				values[i] = text
+			}
+		case TupleDataTypeBinary:
+			text, ok := keyText(typeMap, rel.Columns[idx].DataType, pgtype.BinaryFormatCode, col.Data)
+			if !ok {
+				return "", false
+			}
+			values[i] = text
+		default:
+			return "", false
+		}
+	}
+	return chunkRowKey(values), true
+}
+
+// chunkKeyText normalizes the text of a primary key value of a chunk, as selected in the text format of Postgres, the
+// same way as the values of changes so that they are comparable. Values of unknown types are compared as is.
+func chunkKeyText(typeMap *pgtype.Map, typeName, value string) string {
+	dt, ok := typeMap.TypeForName(strings.ToLower(typeName))
+	if !ok {
+		return value
+	}
+	if text, ok := keyText(typeMap, dt.OID, pgtype.TextFormatCode, []byte(value)); ok {
+		return text
+	}
+	return value
+}
+
+func chunkRowKey(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+// processSignal handles rows inserted into the signal table, invalid signals are logged rather than stopping the
+// stream.
+func (s *Stream) processSignal(message *StreamMessage) {
+	if message.Operation != InsertOpType {
+		return
+	}
+	row, _ := message.Data.(map[string]any)
+	signalType, _ := row["type"].(string)
+	data, _ := row["data"].(string)
+	tables, err := cdc.ParseSnapshotSignal(signalType, data)
+	if err == nil {
+		err = s.TriggerSnapshot(tables)
+	}
+	if err != nil {
+		s.logger.Warnf("Ignoring signal %v: %s", row["id"], err)
+	}
+}
+
+func queryPrimaryKeyColumns(ctx context.Context, db *sql.DB, table TableFQN) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+        SELECT a.attname
+        FROM   pg_index i
+        JOIN   pg_attribute a ON a.attrelid = i.indrelid
+            AND a.attnum = ANY(i.indkey)
+        WHERE  i.indrelid = $1::regclass
+        AND    i.indisprimary
+        ORDER BY array_position(i.indkey, a.attnum);
+    `, table.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query primary key: %w", err)
+	}
+	defer rows.Close()
+
+	var pkColumns []string
+	for rows.Next() {
+		var col string
+		if err := rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		// Postgres gives us back normalized identifiers here - we need to quote them.
+		pkColumns = append(pkColumns, sanitize.QuotePostgresIdentifier(col))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(pkColumns) == 0 {
+		return nil, fmt.Errorf("no primary key found for table %s", table)
+	}
+	return pkColumns, nil
+}
+
+// processIncrementalSnapshot records the changes of the stream in the open windows of incremental snapshots, and emits
+// the rows of a chunk when its watermark is decoded, in which case it returns true.
+func (s *Stream) processIncrementalSnapshot(ctx context.Context, logicalMsg Message, relations map[uint32]*RelationMessage, typeMap *pgtype.Map) (bool, error) {
+	switch logicalMsg := logicalMsg.(type) {
+	case *InsertMessage:
+		if rel, ok := relations[logicalMsg.RelationID]; ok {
+			s.incremental.change(typeMap, rel, logicalMsg.Tuple)
+		}
+	case *UpdateMessage:
+		if rel, ok := relations[logicalMsg.RelationID]; ok {
+			s.incremental.change(typeMap, rel, logicalMsg.OldTuple, logicalMsg.NewTuple)
+		}
+	case *DeleteMessage:
+		if rel, ok := relations[logicalMsg.RelationID]; ok {
+			s.incremental.change(typeMap, rel, logicalMsg.OldTuple)
+		}
+	case *LogicalDecodingMessage:
+		if logicalMsg.Prefix != watermarkPrefix {
+			return false, nil
+		}
+		rows, cw := s.incremental.watermark(string(logicalMsg.Content))
+		if cw == nil {
+			// The watermark of a previous run of the stream
+			return true, nil
+		}
+		defer close(cw.done)
+		for _, row := range rows {
+			select {
+			case s.messages <- row:
+			case <-ctx.Done():
+				return true, ctx.Err()
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
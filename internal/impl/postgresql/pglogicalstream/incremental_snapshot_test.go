@@ -0,0 +1,136 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalSnapshotWindows(t *testing.T) {
+	rel := &RelationMessage{
+		Namespace:    "public",
+		RelationName: "foo",
+		Columns: []*RelationMessageColumn{
+			{Name: "b"},
+			{Name: "a", Flags: 1},
+		},
+	}
+	tuple := func(b, a string) *TupleData {
+		return &TupleData{Columns: []*TupleDataColumn{
+			{DataType: 't', Data: []byte(b)},
+			{DataType: 't', Data: []byte(a)},
+		}}
+	}
+	rows := func(keys ...string) ([]StreamMessage, []string) {
+		var msgs []StreamMessage
+		var rowKeys []string
+		for _, k := range keys {
+			msgs = append(msgs, StreamMessage{Operation: ReadOpType, Table: "foo", Data: map[string]any{"a": k}})
+			rowKeys = append(rowKeys, chunkRowKey([]string{k}))
+		}
+		return msgs, rowKeys
+	}
+
+	i := newIncrementalSnapshots()
+	fooWindow := i.open("public", "foo", []string{"a"})
+	barWindow := i.open("public", "bar", []string{"a"})
+	require.NotEqual(t, fooWindow.id, barWindow.id)
+
+	i.change(pgtype.NewMap(), rel, tuple("x", "1"))
+	i.change(pgtype.NewMap(), rel, nil, tuple("y", "3"))
+	fooRows, fooKeys := rows("1", "2", "3")
+	i.setRows(fooWindow, fooRows, fooKeys)
+	barRows, barKeys := rows("1")
+	i.setRows(barWindow, barRows, barKeys)
+
+	emitted, cw := i.watermark(fooWindow.id)
+	assert.Same(t, fooWindow, cw)
+	require.Len(t, emitted, 1)
+	assert.Equal(t, map[string]any{"a": "2"}, emitted[0].Data)
+
+	// Windows are only closed once
+	_, cw = i.watermark(fooWindow.id)
+	assert.Nil(t, cw)
+
+	// Changes without the key columns are not recorded
+	i.change(pgtype.NewMap(), &RelationMessage{Namespace: "public", RelationName: "bar", Columns: rel.Columns}, &TupleData{Columns: []*TupleDataColumn{
+		{DataType: 't', Data: []byte("1")},
+		{DataType: 'u'},
+	}})
+	emitted, cw = i.watermark(barWindow.id)
+	assert.Same(t, barWindow, cw)
+	assert.Len(t, emitted, 1)
+}
+
+func TestIncrementalSnapshotWindowsSchemas(t *testing.T) {
+	columns := []*RelationMessageColumn{{Name: "id", Flags: 1}}
+	tuple := func(id string) *TupleData {
+		return &TupleData{Columns: []*TupleDataColumn{{DataType: 't', Data: []byte(id)}}}
+	}
+
+	i := newIncrementalSnapshots()
+	aWindow := i.open("tenant_a", "orders", []string{"id"})
+	bWindow := i.open("tenant_b", "orders", []string{"id"})
+
+	keys := []string{chunkRowKey([]string{"1"})}
+	i.setRows(aWindow, []StreamMessage{{Operation: ReadOpType, Schema: "tenant_a", Table: "orders", Data: map[string]any{"id": "1"}}}, keys)
+	i.setRows(bWindow, []StreamMessage{{Operation: ReadOpType, Schema: "tenant_b", Table: "orders", Data: map[string]any{"id": "1"}}}, keys)
+
+	// A change to a table only drops rows from the windows of that table, and not
+	// those of tables with the same name in other schemas
+	i.change(pgtype.NewMap(), &RelationMessage{Namespace: "tenant_a", RelationName: "orders", Columns: columns}, tuple("1"))
+
+	emitted, _ := i.watermark(aWindow.id)
+	assert.Empty(t, emitted)
+
+	emitted, _ = i.watermark(bWindow.id)
+	require.Len(t, emitted, 1)
+	assert.Equal(t, "tenant_b", emitted[0].Schema)
+}
+
+func TestIncrementalSnapshotBinaryTimestamptzKey(t *testing.T) {
+	typeMap := pgtype.NewMap()
+	data, err := typeMap.Encode(pgtype.TimestamptzOID, pgtype.BinaryFormatCode, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), nil)
+	require.NoError(t, err)
+
+	rel := &RelationMessage{
+		Namespace:    "public",
+		RelationName: "events",
+		Columns:      []*RelationMessageColumn{{Name: "ts", Flags: 1, DataType: pgtype.TimestamptzOID}},
+	}
+	binaryKey, ok := tupleRowKey(typeMap, rel, &TupleData{Columns: []*TupleDataColumn{
+		{DataType: TupleDataTypeBinary, Data: data},
+	}}, []string{"ts"})
+	require.True(t, ok)
+	textKey, ok := tupleRowKey(typeMap, rel, &TupleData{Columns: []*TupleDataColumn{
+		{DataType: TupleDataTypeText, Data: []byte("2024-01-01 00:00:00+00")},
+	}}, []string{"ts"})
+	require.True(t, ok)
+
+	// Chunk keys are selected as the text of Postgres, which differs from that of
+	// pgx for timestamptz values
+	chunkKey := chunkRowKey([]string{chunkKeyText(typeMap, "TIMESTAMPTZ", "2024-01-01 00:00:00+00")})
+	assert.Equal(t, chunkKey, binaryKey)
+	assert.Equal(t, chunkKey, textKey)
+
+	i := newIncrementalSnapshots()
+	cw := i.open("public", "events", []string{"ts"})
+	i.setRows(cw, []StreamMessage{{Operation: ReadOpType, Schema: "public", Table: "events"}}, []string{chunkKey})
+	i.change(typeMap, rel, &TupleData{Columns: []*TupleDataColumn{
+		{DataType: TupleDataTypeBinary, Data: data},
+	}})
+	emitted, _ := i.watermark(cw.id)
+	assert.Empty(t, emitted)
+}
@@ -13,7 +13,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/Jeffail/shutdown"
@@ -53,6 +55,28 @@ type Stream struct {
 	monitor                    *Monitor
 	maxParallelSnapshotTables  int
 	unchangedToastValue        any
+	// signalTable is the table watched for signals, if any
+	signalTable *TableFQN
+	// incremental is nil unless the server supports the watermark messages of incremental snapshots
+	incremental *incrementalSnapshots
+	// db is used to query the database while streaming, as the replication connection can't be queried
+	db *sql.DB
+
+	// schema is the unquoted schema of tables given without one
+	schema          string
+	tablesMut       sync.RWMutex
+	tablePatterns   []tablePattern
+	publicationName string
+	// createdSlot and createdPublication are set when the slot and publication didn't exist before the stream
+	createdSlot        atomic.Bool
+	createdPublication atomic.Bool
+	// origin is the replication origin of the transaction being streamed, if any
+	origin string
+	// binaryValues is set when values are requested in the binary format and decoded into faithful types, snapshot
+	// values are decoded into the same types
+	binaryValues bool
+	// streamed is nil unless in-progress transactions are streamed
+	streamed *streamedTransactions
 }
 
 // NewPgStream creates a new instance of the Stream struct
@@ -84,18 +108,55 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 		return nil, err
 	}
 
-	schema, err := sanitize.NormalizePostgresIdentifier(config.DBSchema)
+	normalizedSchema, err := sanitize.NormalizePostgresIdentifier(config.DBSchema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema name %q: %w", config.DBSchema, err)
+	}
+	schema, err := sanitize.UnquotePostgresIdentifier(normalizedSchema)
 	if err != nil {
 		return nil, fmt.Errorf("invalid schema name %q: %w", config.DBSchema, err)
 	}
 
-	tables := []TableFQN{}
+	var patterns []tablePattern
 	for _, table := range config.DBTables {
-		normalized, err := sanitize.NormalizePostgresIdentifier(table)
+		p, err := parseTablePattern(schema, table)
 		if err != nil {
-			return nil, fmt.Errorf("invalid table name %q: %w", table, err)
+			return nil, err
 		}
-		tables = append(tables, TableFQN{Schema: schema, Table: normalized})
+		patterns = append(patterns, p)
+	}
+
+	db, err := openPgConnectionFromConfig(config.DBRawDSN)
+	if err != nil {
+		return nil, err
+	}
+	cleanups = append(cleanups, func() {
+		if err := db.Close(); err != nil {
+			config.Logger.Warnf("unable to properly cleanup db connection on stream creation failure: %s", err)
+		}
+	})
+
+	tables, err := resolveTables(ctx, db, patterns)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no tables matching %v found", config.DBTables)
+	}
+	publishedTables := tables
+	var signalTable *TableFQN
+	if config.SignalTable != "" {
+		p, err := parseTablePattern(schema, config.SignalTable)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signal table: %w", err)
+		}
+		if p.isGlob() {
+			return nil, fmt.Errorf("signal table %q must not contain wildcards", config.SignalTable)
+		}
+		fqn := p.fqn()
+		signalTable = &fqn
+		tables = slices.DeleteFunc(tables, func(t TableFQN) bool { return t == fqn })
+		publishedTables = append(slices.Clone(tables), fqn)
 	}
 	stream := &Stream{
 		pgConn:                     dbConn,
@@ -105,12 +166,20 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 		snapshotMemorySafetyFactor: config.SnapshotMemorySafetyFactor,
 		snapshotBatchSize:          config.BatchSize,
 		tables:                     tables,
+		schema:                     schema,
+		tablePatterns:              patterns,
+		db:                         db,
 		maxParallelSnapshotTables:  config.MaxParallelSnapshotTables,
 		logger:                     config.Logger,
 		shutSig:                    shutdown.NewSignaller(),
 		includeTxnMarkers:          config.IncludeTxnMarkers,
 		standbyMessageTimeout:      config.PgStandbyTimeout,
 		unchangedToastValue:        config.UnchangedToastValue,
+		signalTable:                signalTable,
+		binaryValues:               config.BinaryValues,
+	}
+	if config.StreamInProgressTransactions {
+		stream.streamed = newStreamedTransactions()
 	}
 
 	monitor, err := NewMonitor(ctx, config.DBRawDSN, stream.logger, tables, stream.slotName, config.WalMonitorInterval)
@@ -130,26 +199,54 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 		return nil, err
 	}
 
+	if version < 14 && (config.BinaryValues || config.StreamInProgressTransactions) {
+		return nil, errors.New("the binary format and streaming of in-progress transactions require Postgres 14 or newer")
+	}
+
+	protoVersion := "1"
+	if config.StreamInProgressTransactions {
+		protoVersion = "2"
+	}
 	pluginArguments := []string{
-		"proto_version '1'",
+		fmt.Sprintf("proto_version '%s'", protoVersion),
 		// Sprintf is safe because we validate ReplicationSlotName is alphanumeric in the config
 		fmt.Sprintf("publication_names 'pglog_stream_%s'", config.ReplicationSlotName),
 	}
+	if config.BinaryValues {
+		pluginArguments = append(pluginArguments, "binary 'true'")
+	}
+	if config.StreamInProgressTransactions {
+		pluginArguments = append(pluginArguments, "streaming 'on'")
+	}
 
 	if version > 14 {
 		pluginArguments = append(pluginArguments, "messages 'true'")
+		stream.incremental = newIncrementalSnapshots()
+	} else if signalTable != nil {
+		return nil, errors.New("signal tables require Postgres 15 or newer")
 	}
 
 	stream.decodingPluginArguments = pluginArguments
 
 	pubName := "pglog_stream_" + config.ReplicationSlotName
-	stream.logger.Infof("Creating publication %s for tables: %s", pubName, tables)
-	if err = CreatePublication(ctx, stream.pgConn, pubName, tables); err != nil {
+	stream.publicationName = pubName
+	var pubExists bool
+	if err = db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_publication WHERE pubname = $1)", pubName).Scan(&pubExists); err != nil {
+		return nil, fmt.Errorf("failed to check publication existence: %w", err)
+	}
+	stream.logger.Infof("Creating publication %s for tables: %s", pubName, publishedTables)
+	if err = CreatePublication(ctx, stream.pgConn, pubName, publishedTables); err != nil {
 		return nil, err
 	}
-	cleanups = append(cleanups, func() {
-		// TODO: Drop publication if it was created (meaning it's not existing state we might want to keep).
-	})
+	if !pubExists {
+		stream.createdPublication.Store(true)
+		cleanups = append(cleanups, func() {
+			// Drop the publication as it was created by us rather than being existing state we might want to keep.
+			if _, err := db.ExecContext(context.Background(), "DROP PUBLICATION IF EXISTS "+pubName); err != nil {
+				config.Logger.Warnf("unable to drop publication on stream creation failure: %s", err)
+			}
+		})
+	}
 
 	s, err := sanitize.SQLQuery("SELECT confirmed_flush_lsn, plugin FROM pg_replication_slots WHERE slot_name = $1", config.ReplicationSlotName)
 	if err != nil {
@@ -184,6 +281,7 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 				stream.errors <- fmt.Errorf("logical replication stream error: %w", err)
 			}
 		}()
+		stream.startBackgroundTasks(config)
 		cleanups = nil
 		return stream, nil
 	}
@@ -210,6 +308,7 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 		if err != nil {
 			return nil, err
 		}
+		snapshotter.typedValues = config.BinaryValues
 	}
 
 	go func() {
@@ -236,6 +335,7 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 				config.TemporaryReplicationSlot,
 			)
 			if err == nil {
+				stream.createdSlot.Store(!config.TemporaryReplicationSlot)
 				// Drop our temporary name, we don't need it anymore.
 				err = DropReplicationSlot(
 					ctx,
@@ -263,6 +363,7 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 				stream.errors <- fmt.Errorf("failed to create replication slot: %w", err)
 				return
 			}
+			stream.createdSlot.Store(!config.TemporaryReplicationSlot)
 		}
 		if err := stream.startLr(ctx, startLSN); err != nil {
 			stream.errors <- fmt.Errorf("failed to start logical replication: %w", err)
@@ -273,6 +374,8 @@ func NewPgStream(ctx context.Context, config *Config) (*Stream, error) {
 		}
 	}()
 
+	stream.startBackgroundTasks(config)
+
 	// Success! No need to cleanup
 	cleanups = nil
 	return stream, nil
@@ -370,6 +473,20 @@ func (s *Stream) streamMessages(currentLSN LSN) error {
 			s.logger.Errorf("unable to acknowledge LSN on stream shutdown: %v", err)
 		}
 	}()
+	// See the explaination above about lastEmittedCommitLSN but if this is a commit message, we want to
+	// only remap the commit of the last message in a transaction, so only update the remapped value if
+	// it was a suppressed commit, otherwise we just provide a noop mapping of commit LSN
+	trackResult := func(result processChangeResult, msgLSN LSN) {
+		if result == changeResultSuppressedCommitMessage {
+			lastEmittedCommitLSN = msgLSN
+		} else if result == changeResultEmittedMessage {
+			lastEmittedLSN = msgLSN
+			lastEmittedCommitLSN = msgLSN
+		}
+	}
+	if s.streamed != nil {
+		defer s.streamed.close()
+	}
 
 	nextStandbyMessageDeadline := time.Now().Add(s.standbyMessageTimeout)
 	ctx, done := s.shutSig.SoftStopCtx(context.Background())
@@ -423,19 +540,9 @@ func (s *Stream) streamMessages(currentLSN LSN) error {
 				return fmt.Errorf("failed to parse XLogData: %w", err)
 			}
 			msgLSN := xld.WALStart + LSN(len(xld.WALData))
-			result, err := s.processChange(ctx, msgLSN, xld, relations, typeMap)
-			if err != nil {
+			if err := s.processChange(ctx, msgLSN, xld, relations, typeMap, trackResult); err != nil {
 				return fmt.Errorf("decoding postgres changes failed: %w", err)
 			}
-			// See the explaination above about lastEmittedCommitLSN but if this is a commit message, we want to
-			// only remap the commit of the last message in a transaction, so only update the remapped value if
-			// it was a suppressed commit, otherwise we just provide a noop mapping of commit LSN
-			if result == changeResultSuppressedCommitMessage {
-				lastEmittedCommitLSN = msgLSN
-			} else if result == changeResultEmittedMessage {
-				lastEmittedLSN = msgLSN
-				lastEmittedCommitLSN = msgLSN
-			}
 		default:
 			return fmt.Errorf("unknown message type: %c", msg.Data[0])
 		}
@@ -452,16 +559,139 @@ const (
 	changeResultEmittedMessage          = 2
 )
 
-// Handle handles the pgoutput output
-func (s *Stream) processChange(ctx context.Context, msgLSN LSN, xld XLogData, relations map[uint32]*RelationMessage, typeMap *pgtype.Map) (processChangeResult, error) {
+// Handle handles the pgoutput output, calling track with the result of each processed message
+func (s *Stream) processChange(ctx context.Context, msgLSN LSN, xld XLogData, relations map[uint32]*RelationMessage, typeMap *pgtype.Map, track func(processChangeResult, LSN)) error {
+	if s.streamed != nil && s.streamed.inBlock() {
+		switch MessageType(xld.WALData[0]) {
+		case MessageTypeStreamStart, MessageTypeStreamStop, MessageTypeStreamCommit, MessageTypeStreamAbort:
+		default:
+			// Changes of in-progress transactions are processed once the transaction commits
+			return s.streamed.append(msgLSN, xld.WALData)
+		}
+	}
+	logicalMsg, err := Parse(xld.WALData)
+	if err != nil {
+		return err
+	}
+	switch logicalMsg := logicalMsg.(type) {
+	case *StreamStartMessage, *StreamStopMessage, *StreamAbortMessage, *StreamCommitMessage:
+		if s.streamed == nil {
+			return fmt.Errorf("unexpected %s message, streaming of in-progress transactions is disabled", logicalMsg.Type())
+		}
+	}
+	switch logicalMsg := logicalMsg.(type) {
+	case *StreamStartMessage:
+		return s.streamed.start(logicalMsg.Xid)
+	case *StreamStopMessage:
+		return s.streamed.stop()
+	case *StreamAbortMessage:
+		s.streamed.abort(logicalMsg.Xid, logicalMsg.SubXid)
+		return nil
+	case *StreamCommitMessage:
+		return s.commitStreamedTransaction(ctx, msgLSN, logicalMsg, relations, typeMap, track)
+	}
+	result, err := s.processMessage(ctx, msgLSN, logicalMsg, relations, typeMap)
+	if err != nil {
+		return err
+	}
+	track(result, msgLSN)
+	return nil
+}
+
+// commitStreamedTransaction processes the spooled changes of a streamed transaction once it commits, in the same way
+// as a transaction that is sent when it commits. The changes keep the LSNs they were received at, which precede the
+// LSN of the commit, so that acknowledging some of them doesn't acknowledge the whole transaction.
+func (s *Stream) commitStreamedTransaction(ctx context.Context, msgLSN LSN, commit *StreamCommitMessage, relations map[uint32]*RelationMessage, typeMap *pgtype.Map, track func(processChangeResult, LSN)) error {
+	begin := &BeginMessage{FinalLSN: commit.CommitLSN, CommitTime: commit.CommitTime, Xid: commit.Xid}
+	begin.SetType(MessageTypeBegin)
+	begun := false
+	processBegin := func(lsn LSN) error {
+		begun = true
+		result, err := s.processMessage(ctx, lsn, begin, relations, typeMap)
+		if err != nil {
+			return err
+		}
+		track(result, lsn)
+		return nil
+	}
+	err := s.streamed.commit(commit.Xid, func(lsn LSN, msg []byte) error {
+		if !begun {
+			if err := processBegin(lsn); err != nil {
+				return err
+			}
+		}
+		logicalMsg, err := Parse(msg)
+		if err != nil {
+			return err
+		}
+		result, err := s.processMessage(ctx, lsn, logicalMsg, relations, typeMap)
+		if err != nil {
+			return err
+		}
+		track(result, lsn)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !begun {
+		if err := processBegin(msgLSN); err != nil {
+			return err
+		}
+	}
+
+	commitMsg := &CommitMessage{
+		Flags:             commit.Flags,
+		CommitLSN:         commit.CommitLSN,
+		TransactionEndLSN: commit.TransactionEndLSN,
+		CommitTime:        commit.CommitTime,
+	}
+	commitMsg.SetType(MessageTypeCommit)
+	result, err := s.processMessage(ctx, msgLSN, commitMsg, relations, typeMap)
+	if err != nil {
+		return err
+	}
+	track(result, msgLSN)
+	return nil
+}
+
+// processMessage processes a parsed message of a transaction
+func (s *Stream) processMessage(ctx context.Context, msgLSN LSN, logicalMsg Message, relations map[uint32]*RelationMessage, typeMap *pgtype.Map) (processChangeResult, error) {
+	switch logicalMsg := logicalMsg.(type) {
+	case *BeginMessage:
+		s.origin = ""
+	case *OriginMessage:
+		s.origin = logicalMsg.Name
+	case *TypeMessage:
+		// User-defined types are announced before the first relation that uses them
+		if err := registerCustomType(ctx, s.db, typeMap, logicalMsg.DataType); err != nil {
+			return changeResultNoMessage, err
+		}
+	case *TruncateMessage:
+		messages, err := decodeTruncate(logicalMsg, relations)
+		if err != nil {
+			return changeResultNoMessage, err
+		}
+		return s.emitChanges(ctx, msgLSN, messages)
+	}
 	// parse changes inside the transaction
-	message, err := decodePgOutput(xld.WALData, relations, typeMap, s.unchangedToastValue)
+	message, err := decodePgOutputMessage(logicalMsg, relations, typeMap, s.unchangedToastValue)
 	if err != nil {
 		return changeResultNoMessage, err
 	}
+	if s.incremental != nil {
+		if done, err := s.processIncrementalSnapshot(ctx, logicalMsg, relations, typeMap); done || err != nil {
+			return changeResultNoMessage, err
+		}
+	}
 	if message == nil {
 		return changeResultNoMessage, nil
 	}
+	if s.signalTable != nil && message.Table != "" && sanitize.QuotePostgresIdentifier(message.Table) == s.signalTable.Table &&
+		sanitize.QuotePostgresIdentifier(message.Schema) == s.signalTable.Schema {
+		s.processSignal(message)
+		return changeResultNoMessage, nil
+	}
 
 	if !s.includeTxnMarkers {
 		switch message.Operation {
@@ -472,14 +702,24 @@ func (s *Stream) processChange(ctx context.Context, msgLSN LSN, xld XLogData, re
 		}
 	}
 
+	return s.emitChanges(ctx, msgLSN, []StreamMessage{*message})
+}
+
+func (s *Stream) emitChanges(ctx context.Context, msgLSN LSN, messages []StreamMessage) (processChangeResult, error) {
+	if len(messages) == 0 {
+		return changeResultNoMessage, nil
+	}
 	lsn := msgLSN.String()
-	message.LSN = &lsn
-	select {
-	case s.messages <- *message:
-		return changeResultEmittedMessage, nil
-	case <-ctx.Done():
-		return changeResultNoMessage, ctx.Err()
+	for _, message := range messages {
+		message.LSN = &lsn
+		message.Origin = s.origin
+		select {
+		case s.messages <- message:
+		case <-ctx.Done():
+			return changeResultNoMessage, ctx.Err()
+		}
 	}
+	return changeResultEmittedMessage, nil
 }
 
 func (s *Stream) processSnapshot(ctx context.Context, snapshotter *Snapshotter) error {
@@ -500,7 +740,7 @@ func (s *Stream) processSnapshot(ctx context.Context, snapshotter *Snapshotter)
 	var wg errgroup.Group
 	wg.SetLimit(s.maxParallelSnapshotTables)
 
-	for _, table := range s.tables {
+	for _, table := range s.streamedTables() {
 		tableName := table
 		wg.Go(func() (err error) {
 			s.logger.Debugf("Processing snapshot for table: %v", table)
@@ -705,6 +945,9 @@ func (s *Stream) Stop(ctx context.Context) error {
 	wg.Go(func() error {
 		return s.monitor.Stop()
 	})
+	wg.Go(func() error {
+		return s.db.Close()
+	})
 	select {
 	case <-ctx.Done():
 	case <-s.shutSig.HasStoppedChan():
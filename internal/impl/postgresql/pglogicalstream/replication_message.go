@@ -679,6 +679,100 @@ func (m *LogicalDecodingMessage) Decode(src []byte) (err error) {
 	return nil
 }
 
+// StreamStartMessage starts a block of changes of an in-progress transaction, sent when streaming of large
+// transactions is enabled.
+type StreamStartMessage struct {
+	baseMessage
+	// Xid of the transaction.
+	Xid uint32
+	// FirstSegment is set for the first block of changes of the transaction.
+	FirstSegment bool
+}
+
+// Decode decodes the message from src.
+func (m *StreamStartMessage) Decode(src []byte) error {
+	if len(src) < 5 {
+		return m.lengthError("StreamStartMessage", 5, len(src))
+	}
+	m.Xid, _ = m.decodeUint32(src)
+	m.FirstSegment = src[4] == 1
+
+	m.SetType(MessageTypeStreamStart)
+
+	return nil
+}
+
+// StreamStopMessage ends a block of changes of an in-progress transaction.
+type StreamStopMessage struct {
+	baseMessage
+}
+
+// Decode decodes the message from src.
+func (m *StreamStopMessage) Decode(_ []byte) error {
+	m.SetType(MessageTypeStreamStop)
+	return nil
+}
+
+// StreamCommitMessage commits a streamed transaction.
+type StreamCommitMessage struct {
+	baseMessage
+	// Xid of the transaction.
+	Xid uint32
+	// Flags currently unused (must be 0).
+	Flags uint8
+	// CommitLSN is the LSN of the commit.
+	CommitLSN LSN
+	// TransactionEndLSN is the end LSN of the transaction.
+	TransactionEndLSN LSN
+	// CommitTime is the commit timestamp of the transaction
+	CommitTime time.Time
+}
+
+// Decode decodes the message from src.
+func (m *StreamCommitMessage) Decode(src []byte) error {
+	if len(src) < 29 {
+		return m.lengthError("StreamCommitMessage", 29, len(src))
+	}
+	var low, used int
+	m.Xid, used = m.decodeUint32(src)
+	low += used
+	m.Flags = src[low]
+	low++
+	m.CommitLSN, used = m.decodeLSN(src[low:])
+	low += used
+	m.TransactionEndLSN, used = m.decodeLSN(src[low:])
+	low += used
+	m.CommitTime, _ = m.decodeTime(src[low:])
+
+	m.SetType(MessageTypeStreamCommit)
+
+	return nil
+}
+
+// StreamAbortMessage aborts a streamed transaction or one of its subtransactions.
+type StreamAbortMessage struct {
+	baseMessage
+	// Xid of the transaction.
+	Xid uint32
+	// SubXid of the aborted subtransaction, which is the same as Xid when the whole transaction is aborted.
+	SubXid uint32
+}
+
+// Decode decodes the message from src.
+func (m *StreamAbortMessage) Decode(src []byte) error {
+	if len(src) < 8 {
+		return m.lengthError("StreamAbortMessage", 8, len(src))
+	}
+	var low, used int
+	m.Xid, used = m.decodeUint32(src)
+	low += used
+	m.SubXid, _ = m.decodeUint32(src[low:])
+
+	m.SetType(MessageTypeStreamAbort)
+
+	return nil
+}
+
 // Parse parse a logical replication message.
 func Parse(data []byte) (m Message, err error) {
 	var decoder MessageDecoder
@@ -722,6 +816,14 @@ func getCommonDecoder(msgType MessageType) MessageDecoder {
 		decoder = new(CommitMessage)
 	case MessageTypeOrigin:
 		decoder = new(OriginMessage)
+	case MessageTypeStreamStart:
+		decoder = new(StreamStartMessage)
+	case MessageTypeStreamStop:
+		decoder = new(StreamStopMessage)
+	case MessageTypeStreamCommit:
+		decoder = new(StreamCommitMessage)
+	case MessageTypeStreamAbort:
+		decoder = new(StreamAbortMessage)
 	}
 
 	return decoder
@@ -47,11 +47,15 @@ func isCommitMessage(WALData []byte) (bool, *CommitMessage, error) {
 // before the change message.
 func decodePgOutput(WALData []byte, relations map[uint32]*RelationMessage, typeMap *pgtype.Map, unchangedToastValue any) (*StreamMessage, error) {
 	logicalMsg, err := Parse(WALData)
-	message := &StreamMessage{}
-
 	if err != nil {
 		return nil, err
 	}
+	return decodePgOutputMessage(logicalMsg, relations, typeMap, unchangedToastValue)
+}
+
+// decodePgOutputMessage is decodePgOutput for a message that has already been parsed.
+func decodePgOutputMessage(logicalMsg Message, relations map[uint32]*RelationMessage, typeMap *pgtype.Map, unchangedToastValue any) (*StreamMessage, error) {
+	message := &StreamMessage{}
 	switch logicalMsg := logicalMsg.(type) {
 	case *RelationMessage:
 		relations[logicalMsg.RelationID] = logicalMsg
@@ -78,8 +82,8 @@ func decodePgOutput(WALData []byte, relations map[uint32]*RelationMessage, typeM
 				values[colName] = nil
 			case 'u': // unchanged toast
 				values[colName] = unchangedToastValue
-			case 't': //text
-				val, err := decodeTextColumnData(typeMap, col.Data, rel.Columns[idx].DataType)
+			case 't', 'b': // text or binary
+				val, err := decodeColumnData(typeMap, col, rel.Columns[idx].DataType)
 				if err != nil {
 					return nil, fmt.Errorf("unable to decode column data: %w", err)
 				}
@@ -115,8 +119,8 @@ func decodePgOutput(WALData []byte, relations map[uint32]*RelationMessage, typeM
 						values[colName] = nil
 					case 'u': // unchanged toast
 						values[colName] = unchangedToastValue
-					case 't':
-						val, err := decodeTextColumnData(typeMap, col.Data, rel.Columns[idx].DataType)
+					case 't', 'b':
+						val, err := decodeColumnData(typeMap, col, rel.Columns[idx].DataType)
 						if err != nil {
 							return nil, fmt.Errorf("unable to decode column data: %w", err)
 						}
@@ -125,8 +129,8 @@ func decodePgOutput(WALData []byte, relations map[uint32]*RelationMessage, typeM
 						return nil, fmt.Errorf("unable to decode column data, unknown data type: %d", col.DataType)
 					}
 				}
-			case 't': //text
-				val, err := decodeTextColumnData(typeMap, col.Data, rel.Columns[idx].DataType)
+			case 't', 'b': // text or binary
+				val, err := decodeColumnData(typeMap, col, rel.Columns[idx].DataType)
 				if err != nil {
 					return nil, fmt.Errorf("unable to decode column data: %w", err)
 				}
@@ -152,8 +156,8 @@ func decodePgOutput(WALData []byte, relations map[uint32]*RelationMessage, typeM
 				values[colName] = nil
 			case 'u': // unchanged toast
 				values[colName] = unchangedToastValue
-			case 't': //text
-				val, err := decodeTextColumnData(typeMap, col.Data, rel.Columns[idx].DataType)
+			case 't', 'b': // text or binary
+				val, err := decodeColumnData(typeMap, col, rel.Columns[idx].DataType)
 				if err != nil {
 					return nil, fmt.Errorf("unable to decode column data: %w", err)
 				}
@@ -162,11 +166,13 @@ func decodePgOutput(WALData []byte, relations map[uint32]*RelationMessage, typeM
 			}
 		}
 		message.Data = values
-	case *TruncateMessage:
-	case *TypeMessage:
-	case *OriginMessage:
 	case *LogicalDecodingMessage:
-		return nil, nil
+		message.Operation = MessageOpType
+		message.Data = map[string]any{
+			"prefix":        logicalMsg.Prefix,
+			"content":       string(logicalMsg.Content),
+			"transactional": logicalMsg.Transactional,
+		}
 	default:
 		return nil, nil
 	}
@@ -174,6 +180,27 @@ func decodePgOutput(WALData []byte, relations map[uint32]*RelationMessage, typeM
 	return message, nil
 }
 
+// decodeTruncate decodes a truncate message into a message for each truncated relation.
+func decodeTruncate(logicalMsg *TruncateMessage, relations map[uint32]*RelationMessage) ([]StreamMessage, error) {
+	messages := make([]StreamMessage, 0, len(logicalMsg.RelationIDs))
+	for _, id := range logicalMsg.RelationIDs {
+		rel, ok := relations[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown relation ID %d", id)
+		}
+		messages = append(messages, StreamMessage{
+			Operation: TruncateOpType,
+			Schema:    rel.Namespace,
+			Table:     rel.RelationName,
+			Data: map[string]any{
+				"cascade":          logicalMsg.Option&TruncateOptionCascade != 0,
+				"restart_identity": logicalMsg.Option&TruncateOptionRestartIdentity != 0,
+			},
+		})
+	}
+	return messages, nil
+}
+
 func decodeTextColumnData(mi *pgtype.Map, data []byte, dataType uint32) (any, error) {
 	if data == nil {
 		return nil, nil
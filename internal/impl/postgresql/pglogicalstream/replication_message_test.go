@@ -811,6 +811,21 @@ func (s *truncateMessageSuite) Test() {
 	s.True(ok)
 
 	s.Equal(expected, truncateMsg)
+
+	relations := map[uint32]*RelationMessage{
+		expected.RelationIDs[0]: {Namespace: "public", RelationName: "foo"},
+		expected.RelationIDs[1]: {Namespace: "public", RelationName: "bar"},
+	}
+	messages, err := decodeTruncate(truncateMsg, relations)
+	s.NoError(err)
+	data := map[string]any{"cascade": true, "restart_identity": true}
+	s.Equal([]StreamMessage{
+		{Operation: TruncateOpType, Schema: "public", Table: "foo", Data: data},
+		{Operation: TruncateOpType, Schema: "public", Table: "bar", Data: data},
+	}, messages)
+
+	_, err = decodeTruncate(truncateMsg, map[uint32]*RelationMessage{})
+	s.Error(err)
 }
 
 func TestLogicalDecodingMessageSuite(t *testing.T) {
@@ -835,4 +850,15 @@ func (s *logicalDecodingMessageSuite) Test() {
 	s.True(ok)
 
 	s.Equal(expected, logicalDecodingMsg)
+
+	streamMsg, err := decodePgOutput(msg, map[uint32]*RelationMessage{}, nil, nil)
+	s.NoError(err)
+	s.Equal(&StreamMessage{
+		Operation: MessageOpType,
+		Data: map[string]any{
+			"prefix":        "test",
+			"content":       "hello",
+			"transactional": true,
+		},
+	}, streamMsg)
 }
@@ -16,6 +16,7 @@ import (
 	"strings"
 
 	"github.com/jackc/pgtype"
+	pgxtype "github.com/jackc/pgx/v5/pgtype"
 
 	"errors"
 
@@ -41,6 +42,8 @@ type Snapshotter struct {
 	snapshotName string
 	// The TXN for the snapshot phase
 	readerTxn *sql.Tx
+	// typedValues decodes values into the same types as values in the binary format
+	typedValues bool
 }
 
 // NewSnapshotter creates a new Snapshotter instance
@@ -94,6 +97,22 @@ func (s *Snapshotter) prepareScannersAndGetters(columnTypes []*sql.ColumnType) (
 	scanArgs := make([]any, len(columnTypes))
 	valueGetters := make([]func(any) (any, error), len(columnTypes))
 
+	if s.typedValues {
+		typeMap := pgxtype.NewMap()
+		for i, v := range columnTypes {
+			typeName := v.DatabaseTypeName()
+			scanArgs[i] = new(sql.NullString)
+			valueGetters[i] = func(v any) (any, error) {
+				val := v.(*sql.NullString)
+				if !val.Valid {
+					return nil, nil
+				}
+				return decodeTypedTextValue(typeMap, typeName, val.String)
+			}
+		}
+		return scanArgs, valueGetters
+	}
+
 	for i, v := range columnTypes {
 		switch v.DatabaseTypeName() {
 		case "VARCHAR", "TEXT", "UUID", "TIMESTAMP":
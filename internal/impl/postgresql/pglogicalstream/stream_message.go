@@ -34,6 +34,10 @@ const (
 	BeginOpType OpType = "begin"
 	// CommitOpType is a database transaction commit
 	CommitOpType OpType = "commit"
+	// TruncateOpType is a database truncate
+	TruncateOpType OpType = "truncate"
+	// MessageOpType is a logical decoding message emitted with pg_logical_emit_message
+	MessageOpType OpType = "message"
 )
 
 // StreamMessage represents a single change from the database
@@ -44,4 +48,6 @@ type StreamMessage struct {
 	Table     string  `json:"table"`
 	// For deleted messages - there will be old changes if replica identity set to full or empty changes
 	Data any `json:"data"`
+	// Origin is the replication origin of the transaction, which is set for changes replicated from another server
+	Origin string `json:"origin,omitempty"`
 }
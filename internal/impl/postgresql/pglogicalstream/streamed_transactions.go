@@ -0,0 +1,178 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// streamedTransactions spools the changes of in-progress transactions streamed by pgoutput to temporary files until
+// they commit, as they must not be emitted if the transaction aborts, and a large transaction would otherwise have
+// to be held in memory. Blocks of changes of different transactions can be interleaved.
+type streamedTransactions struct {
+	txns map[uint32]*streamedTransaction
+	// current is the transaction of the block of changes being received, if any
+	current *streamedTransaction
+}
+
+type streamedTransaction struct {
+	file *os.File
+	w    *bufio.Writer
+	// abortedSubXids are the subtransactions whose changes are discarded on commit
+	abortedSubXids map[uint32]struct{}
+}
+
+func newStreamedTransactions() *streamedTransactions {
+	return &streamedTransactions{txns: map[uint32]*streamedTransaction{}}
+}
+
+// start starts a block of changes of a transaction.
+func (t *streamedTransactions) start(xid uint32) error {
+	txn, ok := t.txns[xid]
+	if !ok {
+		file, err := os.CreateTemp("", "postgres_cdc_txn_*")
+		if err != nil {
+			return fmt.Errorf("unable to create file for streamed transaction %d: %w", xid, err)
+		}
+		txn = &streamedTransaction{
+			file:           file,
+			w:              bufio.NewWriter(file),
+			abortedSubXids: map[uint32]struct{}{},
+		}
+		t.txns[xid] = txn
+	}
+	t.current = txn
+	return nil
+}
+
+// stop ends the current block of changes.
+func (t *streamedTransactions) stop() error {
+	if t.current == nil {
+		return nil
+	}
+	err := t.current.w.Flush()
+	t.current = nil
+	return err
+}
+
+// inBlock returns whether a block of changes is being received.
+func (t *streamedTransactions) inBlock() bool {
+	return t.current != nil
+}
+
+// streamedMessageHasXid returns whether a message of a block of changes is prefixed with the xid of its
+// (sub)transaction.
+func streamedMessageHasXid(msgType MessageType) bool {
+	switch msgType {
+	case MessageTypeRelation, MessageTypeType, MessageTypeInsert, MessageTypeUpdate, MessageTypeDelete,
+		MessageTypeTruncate, MessageTypeMessage:
+		return true
+	}
+	return false
+}
+
+// append spools a message of the current block of changes, received at lsn. The xid prefix is removed so that the
+// message can be parsed as any other message once the transaction commits.
+func (t *streamedTransactions) append(lsn LSN, walData []byte) error {
+	if t.current == nil {
+		return errors.New("received streamed change outside of a stream block")
+	}
+	var subXid uint32
+	msg := walData
+	if streamedMessageHasXid(MessageType(walData[0])) {
+		if len(walData) < 5 {
+			return fmt.Errorf("streamed %s message is too short", MessageType(walData[0]))
+		}
+		subXid = binary.BigEndian.Uint32(walData[1:])
+		// The last byte of the xid is replaced by the message type
+		msg = walData[4:]
+		msg[0] = walData[0]
+	}
+	var header [16]byte
+	binary.BigEndian.PutUint32(header[0:], subXid)
+	binary.BigEndian.PutUint64(header[4:], uint64(lsn))
+	binary.BigEndian.PutUint32(header[12:], uint32(len(msg)))
+	if _, err := t.current.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := t.current.w.Write(msg)
+	return err
+}
+
+// abort discards the changes of a subtransaction, or of the whole transaction when subXid is the xid.
+func (t *streamedTransactions) abort(xid, subXid uint32) {
+	txn, ok := t.txns[xid]
+	if !ok {
+		return
+	}
+	if xid != subXid {
+		txn.abortedSubXids[subXid] = struct{}{}
+		return
+	}
+	delete(t.txns, xid)
+	txn.discard()
+}
+
+// commit calls fn with each spooled change of a transaction that was not aborted, in order, and then discards them.
+func (t *streamedTransactions) commit(xid uint32, fn func(lsn LSN, msg []byte) error) error {
+	txn, ok := t.txns[xid]
+	if !ok {
+		return nil
+	}
+	delete(t.txns, xid)
+	defer txn.discard()
+
+	if err := txn.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := txn.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(txn.file)
+	var header [16]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("unable to read streamed transaction %d: %w", xid, err)
+		}
+		subXid := binary.BigEndian.Uint32(header[0:])
+		lsn := LSN(binary.BigEndian.Uint64(header[4:]))
+		msg := make([]byte, binary.BigEndian.Uint32(header[12:]))
+		if _, err := io.ReadFull(r, msg); err != nil {
+			return fmt.Errorf("unable to read streamed transaction %d: %w", xid, err)
+		}
+		if _, aborted := txn.abortedSubXids[subXid]; aborted {
+			continue
+		}
+		if err := fn(lsn, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// close discards the changes of all transactions.
+func (t *streamedTransactions) close() {
+	for xid, txn := range t.txns {
+		delete(t.txns, xid)
+		txn.discard()
+	}
+	t.current = nil
+}
+
+func (t *streamedTransaction) discard() {
+	_ = t.file.Close()
+	_ = os.Remove(t.file.Name())
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamedTransactions(t *testing.T) {
+	streamedInsert := func(xid uint32, payload string) []byte {
+		msg := []byte{byte(MessageTypeInsert), 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(msg[1:], xid)
+		return append(msg, payload...)
+	}
+	type change struct {
+		lsn LSN
+		msg string
+	}
+	commit := func(txns *streamedTransactions, xid uint32) []change {
+		var changes []change
+		require.NoError(t, txns.commit(xid, func(lsn LSN, msg []byte) error {
+			changes = append(changes, change{lsn: lsn, msg: string(msg)})
+			return nil
+		}))
+		return changes
+	}
+
+	txns := newStreamedTransactions()
+	defer txns.close()
+
+	// Interleaved blocks of two transactions, the first with an aborted subtransaction
+	require.NoError(t, txns.start(1))
+	assert.True(t, txns.inBlock())
+	require.NoError(t, txns.append(10, streamedInsert(1, "a")))
+	require.NoError(t, txns.append(11, streamedInsert(5, "b")))
+	require.NoError(t, txns.stop())
+	assert.False(t, txns.inBlock())
+
+	require.NoError(t, txns.start(2))
+	require.NoError(t, txns.append(12, streamedInsert(2, "c")))
+	require.NoError(t, txns.stop())
+
+	require.NoError(t, txns.start(1))
+	require.NoError(t, txns.append(13, []byte{byte(MessageTypeOrigin), 'o'}))
+	require.NoError(t, txns.append(14, streamedInsert(1, "d")))
+	require.NoError(t, txns.stop())
+
+	txns.abort(1, 5)
+	assert.Equal(t, []change{
+		{lsn: 10, msg: "Ia"},
+		{lsn: 13, msg: "Oo"},
+		{lsn: 14, msg: "Id"},
+	}, commit(txns, 1))
+	assert.Empty(t, commit(txns, 1))
+
+	txns.abort(2, 2)
+	assert.Empty(t, commit(txns, 2))
+
+	require.Error(t, txns.append(15, streamedInsert(3, "e")))
+}
@@ -0,0 +1,291 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/postgresql/pglogicalstream/sanitize"
+)
+
+// tablePattern selects tables by schema and table name, either of which can contain glob patterns such as `*`. Unquoted
+// names are matched case-insensitively as they are folded to lower case by postgres, quoted names are matched as is.
+type tablePattern struct {
+	schema string
+	table  string
+}
+
+// parseTablePattern parses a pattern of the form `table` or `schema.table`, where the schema defaults to
+// `defaultSchema`.
+func parseTablePattern(defaultSchema, pattern string) (tablePattern, error) {
+	schemaPart, tablePart := defaultSchema, pattern
+	inQuotes := false
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case '.':
+			if !inQuotes {
+				schemaPart, tablePart = pattern[:i], pattern[i+1:]
+				i = len(pattern)
+			}
+		}
+	}
+
+	var (
+		p   tablePattern
+		err error
+	)
+	if p.schema, err = unquotePatternPart(schemaPart); err != nil {
+		return p, fmt.Errorf("invalid schema name %q: %w", schemaPart, err)
+	}
+	if p.table, err = unquotePatternPart(tablePart); err != nil {
+		return p, fmt.Errorf("invalid table name %q: %w", tablePart, err)
+	}
+	return p, nil
+}
+
+func unquotePatternPart(part string) (string, error) {
+	if strings.ContainsAny(part, "*?[") {
+		if strings.HasPrefix(part, `"`) {
+			return sanitize.UnquotePostgresIdentifier(part)
+		}
+		// Validate the pattern with a placeholder for the wildcards
+		if _, err := path.Match(part, ""); err != nil {
+			return "", err
+		}
+		return strings.ToLower(part), nil
+	}
+	normalized, err := sanitize.NormalizePostgresIdentifier(part)
+	if err != nil {
+		return "", err
+	}
+	return sanitize.UnquotePostgresIdentifier(normalized)
+}
+
+// isGlob returns whether the pattern matches more than a single table.
+func (p tablePattern) isGlob() bool {
+	return strings.ContainsAny(p.schema, "*?[") || strings.ContainsAny(p.table, "*?[")
+}
+
+// matches returns whether the pattern matches a table, given by its unquoted schema and table name.
+func (p tablePattern) matches(schema, table string) bool {
+	schemaMatch, _ := path.Match(p.schema, schema)
+	tableMatch, _ := path.Match(p.table, table)
+	return schemaMatch && tableMatch
+}
+
+// matchesFQN returns whether the pattern matches a table.
+func (p tablePattern) matchesFQN(table TableFQN) bool {
+	schema, err := sanitize.UnquotePostgresIdentifier(table.Schema)
+	if err != nil {
+		return false
+	}
+	name, err := sanitize.UnquotePostgresIdentifier(table.Table)
+	if err != nil {
+		return false
+	}
+	return p.matches(schema, name)
+}
+
+func (p tablePattern) fqn() TableFQN {
+	return TableFQN{
+		Schema: sanitize.QuotePostgresIdentifier(p.schema),
+		Table:  sanitize.QuotePostgresIdentifier(p.table),
+	}
+}
+
+// resolveTables returns the tables selected by the patterns. Patterns without wildcards select a table whether or
+// not it exists, whereas patterns with wildcards select the existing tables that match them.
+func resolveTables(ctx context.Context, db *sql.DB, patterns []tablePattern) ([]TableFQN, error) {
+	var (
+		tables []TableFQN
+		globs  []tablePattern
+	)
+	seen := map[TableFQN]struct{}{}
+	add := func(t TableFQN) {
+		if _, exists := seen[t]; !exists {
+			seen[t] = struct{}{}
+			tables = append(tables, t)
+		}
+	}
+	for _, p := range patterns {
+		if p.isGlob() {
+			globs = append(globs, p)
+		} else {
+			add(p.fqn())
+		}
+	}
+	if len(globs) == 0 {
+		return tables, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+        SELECT n.nspname, c.relname
+        FROM   pg_class c
+        JOIN   pg_namespace n ON n.oid = c.relnamespace
+        WHERE  c.relkind IN ('r', 'p')
+        AND    NOT c.relispartition
+        AND    n.nspname NOT IN ('pg_catalog', 'information_schema')
+        AND    n.nspname NOT LIKE 'pg_toast%'
+        ORDER BY n.nspname, c.relname;
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, err
+		}
+		for _, p := range globs {
+			if p.matches(schema, table) {
+				add(TableFQN{Schema: sanitize.QuotePostgresIdentifier(schema), Table: sanitize.QuotePostgresIdentifier(table)})
+				break
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	return tables, nil
+}
+
+// streamedTables returns the tables of the publication, excluding the signal table.
+func (s *Stream) streamedTables() []TableFQN {
+	s.tablesMut.RLock()
+	defer s.tablesMut.RUnlock()
+	return slices.Clone(s.tables)
+}
+
+func (s *Stream) startBackgroundTasks(config *Config) {
+	if s.incremental != nil {
+		go s.runIncrementalSnapshots(config.DBRawDSN)
+	}
+	if config.TableDiscoveryInterval > 0 && slices.ContainsFunc(s.tablePatterns, tablePattern.isGlob) {
+		go s.runTableDiscovery(config.TableDiscoveryInterval, config.StreamOldData)
+	}
+}
+
+// runTableDiscovery periodically adds new tables that match the wildcard patterns to the publication until the
+// stream is stopped. When `snapshot` is set the new tables are snapshotted incrementally.
+func (s *Stream) runTableDiscovery(interval time.Duration, snapshot bool) {
+	ctx, done := s.shutSig.SoftStopCtx(context.Background())
+	defer done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		added, err := s.discoverTables(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.Warnf("Failed to discover new tables: %s", err)
+		}
+		if len(added) == 0 {
+			continue
+		}
+		s.logger.Infof("Added new tables to publication %s: %v", s.publicationName, added)
+		if snapshot {
+			if s.incremental == nil {
+				s.logger.Warnf("Unable to snapshot new tables %v, incremental snapshots require Postgres 15 or newer", added)
+				continue
+			}
+			s.incremental.enqueue(added)
+		}
+	}
+}
+
+// discoverTables adds the tables matching the patterns that are not streamed yet to the publication.
+func (s *Stream) discoverTables(ctx context.Context) ([]TableFQN, error) {
+	tables, err := resolveTables(ctx, s.db, s.tablePatterns)
+	if err != nil {
+		return nil, err
+	}
+	streamed := s.streamedTables()
+
+	var added []TableFQN
+	for _, table := range tables {
+		if slices.Contains(streamed, table) || (s.signalTable != nil && *s.signalTable == table) {
+			continue
+		}
+		// NOTE: The publication name is derived from the validated slot name and the table is quoted.
+		if _, err := s.db.ExecContext(ctx, fmt.Sprintf("ALTER PUBLICATION %s ADD TABLE %s", s.publicationName, table)); err != nil {
+			return added, fmt.Errorf("failed to add table %s to publication: %w", table, err)
+		}
+		s.tablesMut.Lock()
+		s.tables = append(s.tables, table)
+		s.tablesMut.Unlock()
+		added = append(added, table)
+	}
+	return added, nil
+}
+
+// ReplicationResources are the replication slot and publication of a stream.
+type ReplicationResources struct {
+	SlotName        string
+	PublicationName string
+}
+
+// CreatedResources returns the replication slot and publication that were created by the stream, rather than
+// existing before it was started, with an empty name for those that were not.
+func (s *Stream) CreatedResources() ReplicationResources {
+	var res ReplicationResources
+	if s.createdSlot.Load() {
+		res.SlotName = s.slotName
+	}
+	if s.createdPublication.Load() {
+		res.PublicationName = s.publicationName
+	}
+	return res
+}
+
+// DropReplicationResources drops a replication slot and publication once the stream using them has been stopped.
+func DropReplicationResources(ctx context.Context, dsn string, res ReplicationResources) error {
+	db, err := openPgConnectionFromConfig(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if res.SlotName != "" {
+		// The slot remains active until the server notices that the replication connection has been closed
+		for {
+			_, err = db.ExecContext(ctx, "SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = $1", res.SlotName)
+			if err == nil {
+				break
+			}
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				return fmt.Errorf("failed to drop replication slot %s: %w", res.SlotName, err)
+			}
+		}
+	}
+	if res.PublicationName != "" {
+		// NOTE: The publication name is derived from the validated slot name.
+		if _, err := db.ExecContext(ctx, "DROP PUBLICATION IF EXISTS "+res.PublicationName); err != nil {
+			return fmt.Errorf("failed to drop publication %s: %w", res.PublicationName, err)
+		}
+	}
+	return nil
+}
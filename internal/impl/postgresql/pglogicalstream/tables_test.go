@@ -0,0 +1,57 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTablePattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected tablePattern
+		glob     bool
+	}{
+		{pattern: "foo", expected: tablePattern{schema: "public", table: "foo"}},
+		{pattern: "Foo", expected: tablePattern{schema: "public", table: "foo"}},
+		{pattern: `"Foo"`, expected: tablePattern{schema: "public", table: "Foo"}},
+		{pattern: "tenant.foo", expected: tablePattern{schema: "tenant", table: "foo"}},
+		{pattern: `"My.Schema"."Foo.Bar"`, expected: tablePattern{schema: "My.Schema", table: "Foo.Bar"}},
+		{pattern: "tenant_*.Orders", expected: tablePattern{schema: "tenant_*", table: "orders"}, glob: true},
+		{pattern: `"Tenant_?".*`, expected: tablePattern{schema: "Tenant_?", table: "*"}, glob: true},
+	}
+	for _, test := range tests {
+		p, err := parseTablePattern("public", test.pattern)
+		require.NoError(t, err, test.pattern)
+		assert.Equal(t, test.expected, p, test.pattern)
+		assert.Equal(t, test.glob, p.isGlob(), test.pattern)
+	}
+
+	for _, pattern := range []string{"", "foo.", "1foo", "foo[.bar", `"foo`} {
+		_, err := parseTablePattern("public", pattern)
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestTablePatternMatches(t *testing.T) {
+	p, err := parseTablePattern("public", "tenant_*.orders")
+	require.NoError(t, err)
+	assert.True(t, p.matches("tenant_1", "orders"))
+	assert.False(t, p.matches("tenant_1", "Orders"))
+	assert.False(t, p.matches("public", "orders"))
+	assert.True(t, p.matchesFQN(TableFQN{Schema: `"tenant_abc"`, Table: `"orders"`}))
+	assert.False(t, p.matchesFQN(TableFQN{Schema: `"tenant_abc"`, Table: `"items"`}))
+
+	p, err = parseTablePattern("public", "foo")
+	require.NoError(t, err)
+	assert.Equal(t, TableFQN{Schema: `"public"`, Table: `"foo"`}, p.fqn())
+}
@@ -0,0 +1,233 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// decodeColumnData decodes the value of a tuple column, which is in the binary format when it was requested from
+// pgoutput and the type supports it, and in the text format otherwise.
+func decodeColumnData(typeMap *pgtype.Map, col *TupleDataColumn, dataType uint32) (any, error) {
+	if col.DataType == TupleDataTypeBinary {
+		return decodeBinaryColumnData(typeMap, col.Data, dataType)
+	}
+	return decodeTextColumnData(typeMap, col.Data, dataType)
+}
+
+// decodeBinaryColumnData decodes a value in the binary format into the values described by mapValue. Values of types
+// without a known codec are returned as raw bytes.
+func decodeBinaryColumnData(typeMap *pgtype.Map, data []byte, dataType uint32) (any, error) {
+	if data == nil {
+		return nil, nil
+	}
+	dt, ok := typeMap.TypeForOID(dataType)
+	if !ok {
+		return data, nil
+	}
+	switch dt.OID {
+	case pgtype.JSONOID:
+		return unmarshalJSON(data)
+	case pgtype.JSONBOID:
+		if len(data) == 0 || data[0] != 1 {
+			return nil, errors.New("unknown jsonb version number")
+		}
+		return unmarshalJSON(data[1:])
+	}
+	val, err := dt.Codec.DecodeValue(typeMap, dataType, pgtype.BinaryFormatCode, data)
+	if err != nil {
+		return nil, err
+	}
+	return mapValue(val), nil
+}
+
+// keyText converts a value in the given format to the text format of its type as encoded by pgx. The text encoding
+// of pgx differs from that of Postgres for types such as timestamptz, and so values are normalized this way whether
+// they were received in the binary or the text format in order to compare them.
+func keyText(typeMap *pgtype.Map, dataType uint32, format int16, data []byte) (string, bool) {
+	dt, ok := typeMap.TypeForOID(dataType)
+	if !ok {
+		return "", false
+	}
+	val, err := dt.Codec.DecodeValue(typeMap, dataType, format, data)
+	if err != nil {
+		return "", false
+	}
+	text, err := typeMap.Encode(dataType, pgtype.TextFormatCode, val, nil)
+	if err != nil {
+		return "", false
+	}
+	return string(text), true
+}
+
+// decodeTypedTextValue decodes a value in the text format of the type with the given name into the values described
+// by mapValue, so that snapshots match the values decoded from the binary format. Values of unknown types are
+// returned as strings.
+func decodeTypedTextValue(typeMap *pgtype.Map, typeName, data string) (any, error) {
+	dt, ok := typeMap.TypeForName(strings.ToLower(typeName))
+	if !ok {
+		return data, nil
+	}
+	switch dt.OID {
+	case pgtype.JSONOID, pgtype.JSONBOID:
+		return unmarshalJSON([]byte(data))
+	}
+	val, err := dt.Codec.DecodeValue(typeMap, dt.OID, pgtype.TextFormatCode, []byte(data))
+	if err != nil {
+		return nil, err
+	}
+	return mapValue(val), nil
+}
+
+// unmarshalJSON unmarshals a JSON document keeping numbers as json.Number, so that integers beyond the precision of
+// a float are not altered.
+func unmarshalJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("unable to decode json: %w", err)
+	}
+	return v, nil
+}
+
+// mapValue maps the values decoded by pgtype codecs to values that have a faithful JSON representation: numerics are
+// json.Number (or a string for NaN and infinities), uuids are strings, arrays are slices, ranges are objects with
+// `lower`, `upper`, `lower_inclusive` and `upper_inclusive` fields (or `empty` for empty ranges), hstores are objects
+// and geometric types are objects or slices of points with `x` and `y` fields.
+func mapValue(v any) any {
+	switch v := v.(type) {
+	case pgtype.Numeric:
+		if !v.Valid {
+			return nil
+		}
+		text, err := v.Value()
+		if err != nil {
+			return nil
+		}
+		if v.NaN || v.InfinityModifier != pgtype.Finite {
+			return text
+		}
+		return json.Number(text.(string))
+	case [16]byte:
+		return uuid.UUID(v).String()
+	case []any:
+		values := make([]any, len(v))
+		for i, elem := range v {
+			values[i] = mapValue(elem)
+		}
+		return values
+	case pgtype.Range[any]:
+		return mapRange(v)
+	case pgtype.Multirange[pgtype.Range[any]]:
+		ranges := make([]any, len(v))
+		for i, r := range v {
+			ranges[i] = mapRange(r)
+		}
+		return ranges
+	case pgtype.Hstore:
+		values := make(map[string]any, len(v))
+		for key, value := range v {
+			if value == nil {
+				values[key] = nil
+			} else {
+				values[key] = *value
+			}
+		}
+		return values
+	case pgtype.Point:
+		if !v.Valid {
+			return nil
+		}
+		return mapPoint(v.P)
+	case pgtype.Lseg:
+		if !v.Valid {
+			return nil
+		}
+		return mapPoints(v.P[:])
+	case pgtype.Box:
+		if !v.Valid {
+			return nil
+		}
+		return mapPoints(v.P[:])
+	case pgtype.Path:
+		if !v.Valid {
+			return nil
+		}
+		return map[string]any{"points": mapPoints(v.P), "closed": v.Closed}
+	case pgtype.Polygon:
+		if !v.Valid {
+			return nil
+		}
+		return mapPoints(v.P)
+	case pgtype.Circle:
+		if !v.Valid {
+			return nil
+		}
+		return map[string]any{"center": mapPoint(v.P), "radius": v.R}
+	case pgtype.Line:
+		if !v.Valid {
+			return nil
+		}
+		return map[string]any{"a": v.A, "b": v.B, "c": v.C}
+	case netip.Prefix:
+		return v.String()
+	case driver.Valuer:
+		// Types such as intervals and times of day are represented by their text format
+		text, err := v.Value()
+		if err != nil {
+			return nil
+		}
+		return text
+	}
+	return v
+}
+
+func mapRange(r pgtype.Range[any]) any {
+	if !r.Valid {
+		return nil
+	}
+	if r.LowerType == pgtype.Empty {
+		return map[string]any{"empty": true}
+	}
+	value := map[string]any{
+		"lower":           nil,
+		"upper":           nil,
+		"lower_inclusive": r.LowerType == pgtype.Inclusive,
+		"upper_inclusive": r.UpperType == pgtype.Inclusive,
+	}
+	if r.LowerType != pgtype.Unbounded {
+		value["lower"] = mapValue(r.Lower)
+	}
+	if r.UpperType != pgtype.Unbounded {
+		value["upper"] = mapValue(r.Upper)
+	}
+	return value
+}
+
+func mapPoint(p pgtype.Vec2) map[string]any {
+	return map[string]any{"x": p.X, "y": p.Y}
+}
+
+func mapPoints(points []pgtype.Vec2) []any {
+	values := make([]any, len(points))
+	for i, p := range points {
+		values[i] = mapPoint(p)
+	}
+	return values
+}
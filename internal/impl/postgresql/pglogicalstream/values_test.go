@@ -0,0 +1,103 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/v4/blob/main/licenses/rcl.md
+
+package pglogicalstream
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeBinaryColumnData(t *testing.T) {
+	typeMap := pgtype.NewMap()
+	encode := func(oid uint32, v any) []byte {
+		data, err := typeMap.Encode(oid, pgtype.BinaryFormatCode, v, nil)
+		require.NoError(t, err)
+		return data
+	}
+	numeric := func(s string) pgtype.Numeric {
+		var n pgtype.Numeric
+		require.NoError(t, n.Scan(s))
+		return n
+	}
+
+	tests := []struct {
+		name     string
+		oid      uint32
+		data     []byte
+		expected any
+	}{
+		{name: "int", oid: pgtype.Int8OID, data: encode(pgtype.Int8OID, int64(42)), expected: int64(42)},
+		{name: "numeric", oid: pgtype.NumericOID, data: encode(pgtype.NumericOID, numeric("12345678901234567890.0123")), expected: json.Number("12345678901234567890.0123")},
+		{name: "numeric nan", oid: pgtype.NumericOID, data: encode(pgtype.NumericOID, numeric("NaN")), expected: "NaN"},
+		{name: "jsonb", oid: pgtype.JSONBOID, data: encode(pgtype.JSONBOID, `{"a":[1,9007199254740993]}`), expected: map[string]any{"a": []any{json.Number("1"), json.Number("9007199254740993")}}},
+		{name: "uuid", oid: pgtype.UUIDOID, data: encode(pgtype.UUIDOID, uuid.MustParse("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11")), expected: "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{name: "array", oid: pgtype.NumericArrayOID, data: encode(pgtype.NumericArrayOID, []pgtype.Numeric{numeric("1.5"), numeric("2")}), expected: []any{json.Number("1.5"), json.Number("2")}},
+		{name: "point", oid: pgtype.PointOID, data: encode(pgtype.PointOID, pgtype.Point{P: pgtype.Vec2{X: 45.5, Y: -122.6}, Valid: true}), expected: map[string]any{"x": 45.5, "y": -122.6}},
+		{
+			name: "range",
+			oid:  pgtype.Int4rangeOID,
+			data: encode(pgtype.Int4rangeOID, pgtype.Range[int32]{Lower: 1, LowerType: pgtype.Inclusive, UpperType: pgtype.Unbounded, Valid: true}),
+			expected: map[string]any{
+				"lower":           int32(1),
+				"upper":           nil,
+				"lower_inclusive": true,
+				"upper_inclusive": false,
+			},
+		},
+		{name: "empty range", oid: pgtype.Int4rangeOID, data: encode(pgtype.Int4rangeOID, pgtype.Range[int32]{LowerType: pgtype.Empty, UpperType: pgtype.Empty, Valid: true}), expected: map[string]any{"empty": true}},
+		{name: "unknown type", oid: 123456, data: []byte{1, 2}, expected: []byte{1, 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := decodeBinaryColumnData(typeMap, test.data, test.oid)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestDecodeTypedTextValue(t *testing.T) {
+	typeMap := pgtype.NewMap()
+
+	v, err := decodeTypedTextValue(typeMap, "NUMERIC", "1.50")
+	require.NoError(t, err)
+	assert.Equal(t, json.Number("1.50"), v)
+
+	v, err = decodeTypedTextValue(typeMap, "NUMRANGE", "[1.5,2)")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"lower":           json.Number("1.5"),
+		"upper":           json.Number("2"),
+		"lower_inclusive": true,
+		"upper_inclusive": false,
+	}, v)
+
+	v, err = decodeTypedTextValue(typeMap, "", "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", v)
+}
+
+func TestKeyText(t *testing.T) {
+	typeMap := pgtype.NewMap()
+	data, err := typeMap.Encode(pgtype.Int4OID, pgtype.BinaryFormatCode, int32(7), nil)
+	require.NoError(t, err)
+
+	text, ok := keyText(typeMap, pgtype.Int4OID, pgtype.BinaryFormatCode, data)
+	require.True(t, ok)
+	assert.Equal(t, "7", text)
+
+	text, ok = keyText(typeMap, pgtype.Int4OID, pgtype.TextFormatCode, []byte("7"))
+	require.True(t, ok)
+	assert.Equal(t, "7", text)
+}
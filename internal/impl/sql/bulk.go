@@ -0,0 +1,49 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/lib/pq"
+)
+
+// bulkInsertQuery returns a statement that loads rows of the columns into a
+// table through the native bulk interface of the driver when prepared within a
+// transaction, where each execution of the statement adds a row. Returns false
+// if the driver does not have a bulk interface.
+//
+// When flush is true the statement must be executed without arguments once all
+// rows have been added in order to send them.
+func bulkInsertQuery(driver, table string, columns []string) (query string, flush, ok bool) {
+	switch driver {
+	case "postgres":
+		// COPY FROM STDIN, identifiers are quoted and therefore case sensitive
+		if schema, name, found := strings.Cut(table, "."); found {
+			return pq.CopyInSchema(schema, name, columns...), true, true
+		}
+		return pq.CopyIn(table, columns...), true, true
+	case "mssql":
+		// Bulk copy via INSERT BULK
+		return mssql.CopyIn(table, mssql.BulkOptions{}, columns...), true, true
+	case "clickhouse":
+		// Inserts prepared within a transaction are sent as a native columnar
+		// batch once the transaction is committed
+		return fmt.Sprintf("INSERT INTO %v (%v)", table, strings.Join(columns, ", ")), false, true
+	}
+	return "", false, false
+}
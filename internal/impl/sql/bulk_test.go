@@ -0,0 +1,73 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"testing"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkInsertQuery(t *testing.T) {
+	tests := []struct {
+		driver   string
+		table    string
+		expected string
+		flush    bool
+		ok       bool
+	}{
+		{
+			driver:   "postgres",
+			table:    "foo",
+			expected: `COPY "foo" ("id", "name") FROM STDIN`,
+			flush:    true,
+			ok:       true,
+		},
+		{
+			driver:   "postgres",
+			table:    "public.foo",
+			expected: `COPY "public"."foo" ("id", "name") FROM STDIN`,
+			flush:    true,
+			ok:       true,
+		},
+		{
+			driver:   "mssql",
+			table:    "foo",
+			expected: mssql.CopyIn("foo", mssql.BulkOptions{}, "id", "name"),
+			flush:    true,
+			ok:       true,
+		},
+		{
+			driver:   "clickhouse",
+			table:    "foo",
+			expected: `INSERT INTO foo (id, name)`,
+			ok:       true,
+		},
+		{
+			driver: "mysql",
+			table:  "foo",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.driver+"_"+test.table, func(t *testing.T) {
+			query, flush, ok := bulkInsertQuery(test.driver, test.table, []string{"id", "name"})
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.flush, flush)
+			assert.Equal(t, test.expected, query)
+		})
+	}
+}
@@ -61,6 +61,10 @@ This will behave as an ` + "`add`" + ` operation by default, and so ideally need
 == Add
 
 The ` + "`add`" + ` operation is performed with a traditional ` + "`insert`" + ` statement.
+
+== Timeplus
+
+Streams of Timeplus are append-only by default, where a ` + "`get`" + ` would return any of the values ever set for a key and a ` + "`delete`" + ` would fail. Therefore, when using the ` + "`timeplus`" + ` driver the table must be a mutable stream with the key column as its primary key, e.g. ` + "`CREATE MUTABLE STREAM foo (foo string, bar string) PRIMARY KEY foo`" + `. Since an insert into a mutable stream replaces the row of an existing key a ` + "`set_suffix`" + ` isn't needed, but an ` + "`add`" + ` operation also overwrites existing keys.
 `).
 		Field(driverField).
 		Field(dsnField).
@@ -145,12 +149,12 @@ func newSQLCacheFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (
 		return nil, err
 	}
 
-	s.selectBuilder = squirrel.Select(valueColumn).From(tableStr)
+	s.selectBuilder = squirrel.Select(valueColumn).From(selectTable(s.driver, tableStr))
 	s.insertBuilder = squirrel.Insert(tableStr).Columns(s.keyColumn, valueColumn)
 	s.upsertBuilder = squirrel.Insert(tableStr).Columns(s.keyColumn, valueColumn)
 	s.deleteBuilder = squirrel.Delete(tableStr)
 
-	if s.driver == "postgres" || s.driver == "clickhouse" {
+	if s.driver == "postgres" || s.driver == "clickhouse" || s.driver == "timeplus" {
 		s.selectBuilder = s.selectBuilder.PlaceholderFormat(squirrel.Dollar)
 		s.insertBuilder = s.insertBuilder.PlaceholderFormat(squirrel.Dollar)
 		s.upsertBuilder = s.upsertBuilder.PlaceholderFormat(squirrel.Dollar)
@@ -19,9 +19,11 @@ import (
 	"database/sql"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/Masterminds/squirrel"
 
+	"github.com/Jeffail/checkpoint"
 	"github.com/Jeffail/shutdown"
 
 	"github.com/redpanda-data/benthos/v4/public/bloblang"
@@ -33,7 +35,17 @@ func sqlSelectInputConfig() *service.ConfigSpec {
 		Beta().
 		Categories("Services").
 		Summary("Executes a select query and creates a message for each row received.").
-		Description(`Once the rows from the query are exhausted this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a xref:components:inputs/sequence.adoc[sequence] to execute).`).
+		Description(`Once the rows from the query are exhausted this input shuts down, allowing the pipeline to gracefully terminate (or the next input in a xref:components:inputs/sequence.adoc[sequence] to execute), unless ` + "`polling`" + ` is configured.
+
+== Polling
+
+When ` + "`polling`" + ` is configured the query is executed repeatedly in order to extract new and modified rows incrementally, where each poll selects the rows that are positioned after the last row that has been read according to one or two tracking columns:
+
+- An ` + "`incrementing_column`" + `, such as an auto-increment ID, detects new rows.
+- A ` + "`timestamp_column`" + `, such as an ` + "`updated_at`" + ` column, detects new and modified rows.
+- Both columns together detect new and modified rows, where rows that share a timestamp are told apart by their incrementing column.
+
+Rows are selected in the order of their tracking columns and the values of the tracking columns of the last row that has been delivered are stored in the ` + "`checkpoint_cache`" + `, where the checkpoint is only advanced once all preceding rows have been acknowledged, so that polling resumes from it upon restart with at-least-once semantics. When ` + "`auto_retry_nacks`" + ` is disabled a rejected row is not dropped, instead polling resumes from the checkpoint and the rows that follow it are read again. When only a timestamp column is specified the checkpoint only advances past a timestamp once all rows of the poll that share it have been acknowledged, since the checkpoint cannot distinguish between them. The field ` + "`where`" + ` can be used to filter rows further, but the field ` + "`suffix`" + ` must not contain an ORDER BY clause.`).
 		Field(driverField).
 		Field(dsnField).
 		Field(service.NewStringField("table").
@@ -44,7 +56,7 @@ func sqlSelectInputConfig() *service.ConfigSpec {
 			Example([]string{"*"}).
 			Example([]string{"foo", "bar", "baz"})).
 		Field(service.NewStringField("where").
-			Description("An optional where clause to add. Placeholder arguments are populated with the `args_mapping` field. Placeholders should always be question marks, and will automatically be converted to dollar syntax when the postgres or clickhouse drivers are used.").
+			Description("An optional where clause to add. Placeholder arguments are populated with the `args_mapping` field. Placeholders should always be question marks, and will automatically be converted to dollar syntax when the postgres, clickhouse or timeplus drivers are used.").
 			Example("type = ? and created_at > ?").
 			Example("user_id = ?").
 			Optional()).
@@ -60,6 +72,7 @@ func sqlSelectInputConfig() *service.ConfigSpec {
 			Description("An optional suffix to append to the select query.").
 			Optional().
 			Advanced()).
+		Field(sqlSelectPollingField()).
 		Field(service.NewAutoRetryNacksToggleField())
 
 	for _, f := range connFields() {
@@ -83,6 +96,28 @@ input:
       root = [
         now().ts_unix() - 3600
       ]
+`,
+		).
+		Example("Poll a Table for Changes (MySQL)",
+			`
+Here we define a pipeline that polls a table every ten seconds for new and modified rows, which are detected by the columns "id" and "updated_at" and checkpointed in a file cache so that polling resumes from the last delivered row upon restart:`,
+			`
+input:
+  sql_select:
+    driver: mysql
+    dsn: foouser:foopassword@tcp(localhost:3306)/foodb?parseTime=true
+    table: footable
+    columns: [ '*' ]
+    polling:
+      interval: 10s
+      incrementing_column: id
+      timestamp_column: updated_at
+      checkpoint_cache: checkpoints
+
+cache_resources:
+  - label: checkpoints
+    file:
+      directory: ./checkpoints
 `,
 		)
 	return spec
@@ -118,12 +153,28 @@ type sqlSelectInput struct {
 
 	connSettings *connSettings
 
+	// polling is nil unless the table is polled for new and modified rows
+	polling   *sqlSelectPolling
+	cp        *checkpoint.Capped[*sqlPollPosition]
+	cpMut     sync.Mutex
+	readPos   *sqlPollPosition
+	peeked    map[string]any
+	peekedPos *sqlPollPosition
+	nextPoll  time.Time
+
+	// committedPos is the last checkpoint persisted to the cache, polling is
+	// rewound to it when a row is rejected.
+	committedPos *sqlPollPosition
+	rewind       bool
+
+	mgr     *service.Resources
 	logger  *service.Logger
 	shutSig *shutdown.Signaller
 }
 
 func newSQLSelectInputFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*sqlSelectInput, error) {
 	s := &sqlSelectInput{
+		mgr:     mgr,
 		logger:  mgr.Logger(),
 		shutSig: shutdown.NewSignaller(),
 	}
@@ -160,8 +211,8 @@ func newSQLSelectInputFromConfig(conf *service.ParsedConfig, mgr *service.Resour
 		}
 	}
 
-	s.builder = squirrel.Select(columns...).From(tableStr)
-	if s.driver == "postgres" || s.driver == "clickhouse" {
+	s.builder = squirrel.Select(columns...).From(selectTable(s.driver, tableStr))
+	if s.driver == "postgres" || s.driver == "clickhouse" || s.driver == "timeplus" {
 		s.builder = s.builder.PlaceholderFormat(squirrel.Dollar)
 	} else if s.driver == "oracle" || s.driver == "gocosmos" {
 		s.builder = s.builder.PlaceholderFormat(squirrel.Colon)
@@ -183,6 +234,12 @@ func newSQLSelectInputFromConfig(conf *service.ParsedConfig, mgr *service.Resour
 		s.builder = s.builder.Suffix(suffixStr)
 	}
 
+	if conf.Contains(ssiFieldPolling) {
+		if s.polling, err = sqlSelectPollingFromParsed(conf); err != nil {
+			return nil, err
+		}
+	}
+
 	if s.connSettings, err = connSettingsFromParsed(conf, mgr); err != nil {
 		return nil, err
 	}
@@ -209,33 +266,31 @@ func (s *sqlSelectInput) Connect(ctx context.Context) (err error) {
 
 	s.connSettings.apply(ctx, db, s.logger)
 
-	var args []any
-	if s.argsMapping != nil {
-		var iargs any
-		if iargs, err = s.argsMapping.Query(nil); err != nil {
+	if s.polling != nil {
+		// Rows are queried on the first read, polling starts from the last
+		// delivered row.
+		if s.readPos, err = s.getCachedPosition(ctx); err != nil {
 			return
 		}
-
-		var ok bool
-		if args, ok = iargs.([]any); !ok {
-			err = fmt.Errorf("mapping returned non-array result: %T", iargs)
+		s.cpMut.Lock()
+		s.committedPos, s.rewind = s.readPos, false
+		s.cp = checkpoint.NewCapped[*sqlPollPosition](int64(s.polling.checkpointLimit))
+		s.cpMut.Unlock()
+	} else {
+		var queryBuilder squirrel.SelectBuilder
+		if queryBuilder, err = s.queryBuilder(); err != nil {
 			return
 		}
-	}
-
-	queryBuilder := s.builder
-	if s.where != "" {
-		queryBuilder = queryBuilder.Where(s.where, args...)
-	}
-	var rows *sql.Rows
-	if rows, err = queryBuilder.RunWith(db).Query(); err != nil {
-		return
-	} else if err = rows.Err(); err != nil {
-		s.logger.With("err", err).Warn("unexpected error while execute raw select")
+		var rows *sql.Rows
+		if rows, err = queryBuilder.RunWith(db).Query(); err != nil {
+			return
+		} else if err = rows.Err(); err != nil {
+			s.logger.With("err", err).Warn("unexpected error while execute raw select")
+		}
+		s.rows = rows
 	}
 
 	s.db = db
-	s.rows = rows
 
 	go func() {
 		<-s.shutSig.HardStopChan()
@@ -255,7 +310,34 @@ func (s *sqlSelectInput) Connect(ctx context.Context) (err error) {
 	return nil
 }
 
+// queryBuilder returns the select query with the where clause, if any, and its
+// arguments.
+func (s *sqlSelectInput) queryBuilder() (squirrel.SelectBuilder, error) {
+	var args []any
+	if s.argsMapping != nil {
+		iargs, err := s.argsMapping.Query(nil)
+		if err != nil {
+			return s.builder, err
+		}
+
+		var ok bool
+		if args, ok = iargs.([]any); !ok {
+			return s.builder, fmt.Errorf("mapping returned non-array result: %T", iargs)
+		}
+	}
+
+	queryBuilder := s.builder
+	if s.where != "" {
+		queryBuilder = queryBuilder.Where(s.where, args...)
+	}
+	return queryBuilder, nil
+}
+
 func (s *sqlSelectInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	if s.polling != nil {
+		return s.readPolling(ctx)
+	}
+
 	s.dbMut.Lock()
 	defer s.dbMut.Unlock()
 
@@ -293,6 +375,157 @@ func (s *sqlSelectInput) Read(ctx context.Context) (*service.Message, service.Ac
 	}, nil
 }
 
+func (s *sqlSelectInput) readPolling(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	for {
+		s.dbMut.Lock()
+		if s.db == nil {
+			s.dbMut.Unlock()
+			return nil, nil, service.ErrNotConnected
+		}
+		s.rewindLocked()
+
+		if s.peeked == nil {
+			if wait := time.Until(s.nextPoll); wait > 0 {
+				s.dbMut.Unlock()
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, nil, ctx.Err()
+				}
+				continue
+			}
+			if err := s.pollLocked(); err != nil {
+				s.nextPoll = time.Now().Add(s.polling.interval)
+				s.dbMut.Unlock()
+				return nil, nil, err
+			}
+			if s.peeked == nil {
+				s.dbMut.Unlock()
+				continue
+			}
+		}
+
+		msg, ackFn, err := s.readPolledLocked(ctx)
+		s.dbMut.Unlock()
+		return msg, ackFn, err
+	}
+}
+
+// rewindLocked discards the rows that have been read since the last persisted
+// checkpoint after a row has been rejected, so that the next poll reads them
+// again.
+func (s *sqlSelectInput) rewindLocked() {
+	s.cpMut.Lock()
+	defer s.cpMut.Unlock()
+
+	if !s.rewind {
+		return
+	}
+	s.rewind = false
+
+	if s.rows != nil {
+		_ = s.rows.Close()
+		s.rows = nil
+	}
+	s.peeked, s.peekedPos = nil, nil
+	s.readPos = s.committedPos
+	s.nextPoll = time.Time{}
+
+	// Rows that are still in flight are tracked by the previous checkpointer
+	// and are therefore never persisted.
+	s.cp = checkpoint.NewCapped[*sqlPollPosition](int64(s.polling.checkpointLimit))
+}
+
+// pollLocked executes the query for the rows positioned after the last row that
+// has been read and reads the first row.
+func (s *sqlSelectInput) pollLocked() error {
+	queryBuilder, err := s.queryBuilder()
+	if err != nil {
+		return err
+	}
+	queryBuilder = s.polling.pollQuery(queryBuilder, s.readPos, time.Now())
+
+	if s.rows, err = queryBuilder.RunWith(s.db).Query(); err != nil {
+		return err
+	}
+	return s.prefetchLocked()
+}
+
+// prefetchLocked reads the next row of a poll ahead of time, which determines
+// whether the current row is the last of its timestamp.
+func (s *sqlSelectInput) prefetchLocked() error {
+	s.peeked, s.peekedPos = nil, nil
+
+	endPoll := func(err error) error {
+		_ = s.rows.Close()
+		s.rows = nil
+		s.nextPoll = time.Now().Add(s.polling.interval)
+		return err
+	}
+
+	if !s.rows.Next() {
+		return endPoll(s.rows.Err())
+	}
+	row, err := sqlRowToMap(s.rows)
+	if err != nil {
+		return endPoll(err)
+	}
+	pos, err := s.polling.positionOf(row)
+	if err != nil {
+		return endPoll(err)
+	}
+	s.peeked, s.peekedPos = row, pos
+	return nil
+}
+
+func (s *sqlSelectInput) readPolledLocked(ctx context.Context) (*service.Message, service.AckFunc, error) {
+	row, pos := s.peeked, s.peekedPos
+
+	// The position of a row is only checkpointed when no row that follows it
+	// shares the same position, otherwise we leave the checkpoint where it is.
+	if err := s.prefetchLocked(); err != nil {
+		s.logger.Errorf("Failed to read row, polling will resume from the last complete row: %v", err)
+		pos = nil
+	} else if s.peeked != nil && s.polling.sameTimestamp(pos, s.peekedPos) {
+		pos = nil
+	}
+	if pos != nil {
+		s.readPos = pos
+	}
+
+	cp := s.cp
+	resolveFn, err := cp.Track(ctx, pos, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg := service.NewMessage(nil)
+	msg.SetStructuredMut(row)
+	return msg, func(ctx context.Context, err error) error {
+		s.cpMut.Lock()
+		defer s.cpMut.Unlock()
+
+		// Nacks are retried by AutoRetryNacks unless disabled, in which case
+		// the checkpoint must not move past the row. Instead we read it again
+		// from the last persisted checkpoint.
+		highest := resolveFn()
+		if err != nil {
+			s.rewind = true
+			return nil
+		}
+		if s.rewind || cp != s.cp || highest == nil || *highest == nil {
+			return nil
+		}
+		if err := s.setCachedPosition(ctx, *highest); err != nil {
+			return err
+		}
+		s.committedPos = *highest
+		return nil
+	}, nil
+}
+
 func (s *sqlSelectInput) Close(ctx context.Context) error {
 	s.shutSig.TriggerHardStop()
 	s.dbMut.Lock()
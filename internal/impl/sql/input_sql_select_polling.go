@@ -0,0 +1,319 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	ssiFieldPolling                   = "polling"
+	ssiFieldPollingInterval           = "interval"
+	ssiFieldPollingIncrementingColumn = "incrementing_column"
+	ssiFieldPollingTimestampColumn    = "timestamp_column"
+	ssiFieldPollingTimestampDelay     = "timestamp_delay"
+	ssiFieldPollingCheckpointCache    = "checkpoint_cache"
+	ssiFieldPollingCheckpointKey      = "checkpoint_key"
+	ssiFieldPollingCheckpointLimit    = "checkpoint_limit"
+)
+
+func sqlSelectPollingField() *service.ConfigField {
+	return service.NewObjectField(ssiFieldPolling,
+		service.NewDurationField(ssiFieldPollingInterval).
+			Description("The period to wait between polls once all rows of a poll have been read.").
+			Default("5s"),
+		service.NewStringField(ssiFieldPollingIncrementingColumn).
+			Description("A strictly increasing integer column, such as an auto-increment ID, used to detect new rows.").
+			Example("id").
+			Optional(),
+		service.NewStringField(ssiFieldPollingTimestampColumn).
+			Description("A timestamp column that is updated whenever a row is inserted or modified, used to detect new and modified rows.").
+			Example("updated_at").
+			Optional(),
+		service.NewDurationField(ssiFieldPollingTimestampDelay).
+			Description("An optional delay before rows are read after their timestamp, which gives transactions with earlier timestamps time to commit before the rows with later timestamps are read. This requires the clocks of Redpanda Connect and the database to be in sync.").
+			Example("10s").
+			Optional().
+			Advanced(),
+		service.NewStringField(ssiFieldPollingCheckpointCache).
+			Description("A xref:components:caches/about.adoc[cache resource] to store the value of the tracking columns of the last row that has been delivered in, which allows polling to resume from that row upon restart."),
+		service.NewStringField(ssiFieldPollingCheckpointKey).
+			Description("The key to store the checkpoint with in `checkpoint_cache`. An alternative key can be provided if multiple inputs share the same cache.").
+			Default("sql_select_checkpoint").
+			Advanced(),
+		service.NewIntField(ssiFieldPollingCheckpointLimit).
+			Description("The maximum number of rows that can be processed at a given time. Increasing this limit enables parallel processing and batching at the output level.").
+			Default(1024).
+			Advanced(),
+	).
+		Description("Poll the table for new and modified rows rather than executing the query once, which requires at least one of `incrementing_column` and `timestamp_column`.").
+		Optional().
+		Version("4.47.0")
+}
+
+type sqlSelectPolling struct {
+	interval           time.Duration
+	incrementingColumn string
+	timestampColumn    string
+	timestampDelay     time.Duration
+	checkpointCache    string
+	checkpointKey      string
+	checkpointLimit    int
+}
+
+func sqlSelectPollingFromParsed(conf *service.ParsedConfig) (*sqlSelectPolling, error) {
+	conf = conf.Namespace(ssiFieldPolling)
+
+	p := &sqlSelectPolling{}
+	var err error
+	if p.interval, err = conf.FieldDuration(ssiFieldPollingInterval); err != nil {
+		return nil, err
+	}
+	if conf.Contains(ssiFieldPollingIncrementingColumn) {
+		if p.incrementingColumn, err = conf.FieldString(ssiFieldPollingIncrementingColumn); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains(ssiFieldPollingTimestampColumn) {
+		if p.timestampColumn, err = conf.FieldString(ssiFieldPollingTimestampColumn); err != nil {
+			return nil, err
+		}
+	}
+	if p.incrementingColumn == "" && p.timestampColumn == "" {
+		return nil, fmt.Errorf("at least one of %v and %v must be specified", ssiFieldPollingIncrementingColumn, ssiFieldPollingTimestampColumn)
+	}
+	if conf.Contains(ssiFieldPollingTimestampDelay) {
+		if p.timestampColumn == "" {
+			return nil, fmt.Errorf("%v requires %v to be specified", ssiFieldPollingTimestampDelay, ssiFieldPollingTimestampColumn)
+		}
+		if p.timestampDelay, err = conf.FieldDuration(ssiFieldPollingTimestampDelay); err != nil {
+			return nil, err
+		}
+	}
+	if p.checkpointCache, err = conf.FieldString(ssiFieldPollingCheckpointCache); err != nil {
+		return nil, err
+	}
+	if p.checkpointKey, err = conf.FieldString(ssiFieldPollingCheckpointKey); err != nil {
+		return nil, err
+	}
+	if p.checkpointLimit, err = conf.FieldInt(ssiFieldPollingCheckpointLimit); err != nil {
+		return nil, err
+	}
+	if p.checkpointLimit <= 0 {
+		return nil, fmt.Errorf("%v must be greater than zero", ssiFieldPollingCheckpointLimit)
+	}
+	return p, nil
+}
+
+// sqlPollPosition is the value of the tracking columns of a row, new and
+// modified rows are those that are positioned after it.
+type sqlPollPosition struct {
+	Incrementing *int64     `json:"incrementing,omitempty"`
+	Timestamp    *time.Time `json:"timestamp,omitempty"`
+}
+
+// positionOf returns the position of a row from its tracking columns.
+func (p *sqlSelectPolling) positionOf(row map[string]any) (*sqlPollPosition, error) {
+	var pos sqlPollPosition
+	if p.incrementingColumn != "" {
+		v, err := trackingColumnValue(row, p.incrementingColumn)
+		if err != nil {
+			return nil, err
+		}
+		i, err := pollIncrementingValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("incrementing column %v: %w", p.incrementingColumn, err)
+		}
+		pos.Incrementing = &i
+	}
+	if p.timestampColumn != "" {
+		v, err := trackingColumnValue(row, p.timestampColumn)
+		if err != nil {
+			return nil, err
+		}
+		t, err := pollTimestampValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp column %v: %w", p.timestampColumn, err)
+		}
+		pos.Timestamp = &t
+	}
+	return &pos, nil
+}
+
+func trackingColumnValue(row map[string]any, column string) (any, error) {
+	v, exists := row[strings.Trim(column, "\"`[]")]
+	if !exists {
+		return nil, fmt.Errorf("tracking column %v was not selected", column)
+	}
+	if v == nil {
+		return nil, fmt.Errorf("tracking column %v must not be null", column)
+	}
+	return v, nil
+}
+
+func pollIncrementingValue(v any) (int64, error) {
+	switch t := v.(type) {
+	case int64:
+		return t, nil
+	case int:
+		return int64(t), nil
+	case int32:
+		return int64(t), nil
+	case int16:
+		return int64(t), nil
+	case int8:
+		return int64(t), nil
+	case uint64:
+		if t > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", t)
+		}
+		return int64(t), nil
+	case uint32:
+		return int64(t), nil
+	case uint16:
+		return int64(t), nil
+	case uint8:
+		return int64(t), nil
+	case string:
+		return strconv.ParseInt(t, 10, 64)
+	}
+	return 0, fmt.Errorf("expected an integer value, got %T", v)
+}
+
+var pollTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+func pollTimestampValue(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range pollTimestampLayouts {
+			if ts, err := time.Parse(layout, t); err == nil {
+				return ts, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unable to parse %q as a timestamp", t)
+	}
+	return time.Time{}, fmt.Errorf("expected a timestamp value, got %T", v)
+}
+
+// sameTimestamp returns whether two rows share a timestamp, in which case a
+// checkpoint of only the timestamp of the first row would skip the second.
+func (p *sqlSelectPolling) sameTimestamp(a, b *sqlPollPosition) bool {
+	return p.incrementingColumn == "" && a.Timestamp.Equal(*b.Timestamp)
+}
+
+// pollQuery returns a query for the rows positioned after a position, which are
+// ordered by their tracking columns. Rows sharing a timestamp are tracked by
+// their incrementing column when both columns are specified.
+func (p *sqlSelectPolling) pollQuery(builder squirrel.SelectBuilder, pos *sqlPollPosition, now time.Time) squirrel.SelectBuilder {
+	inc, ts := p.incrementingColumn, p.timestampColumn
+
+	if pos != nil {
+		switch {
+		case inc != "" && ts != "":
+			builder = builder.Where(squirrel.Or{
+				squirrel.Gt{ts: *pos.Timestamp},
+				squirrel.And{
+					squirrel.Eq{ts: *pos.Timestamp},
+					squirrel.Gt{inc: *pos.Incrementing},
+				},
+			})
+		case inc != "":
+			builder = builder.Where(squirrel.Gt{inc: *pos.Incrementing})
+		default:
+			builder = builder.Where(squirrel.Gt{ts: *pos.Timestamp})
+		}
+	}
+	if ts != "" && p.timestampDelay > 0 {
+		builder = builder.Where(squirrel.Lt{ts: now.Add(-p.timestampDelay)})
+	}
+
+	if ts != "" {
+		builder = builder.OrderBy(ts)
+	}
+	if inc != "" {
+		builder = builder.OrderBy(inc)
+	}
+	return builder
+}
+
+// ---- cache methods start ----
+
+func (s *sqlSelectInput) getCachedPosition(ctx context.Context) (*sqlPollPosition, error) {
+	var (
+		cacheVal []byte
+		cErr     error
+	)
+	if err := s.mgr.AccessCache(ctx, s.polling.checkpointCache, func(c service.Cache) {
+		cacheVal, cErr = c.Get(ctx, s.polling.checkpointKey)
+	}); err != nil {
+		return nil, fmt.Errorf("unable to access cache for reading: %w", err)
+	}
+	if errors.Is(cErr, service.ErrKeyNotFound) {
+		return nil, nil
+	} else if cErr != nil {
+		return nil, fmt.Errorf("unable read checkpoint from cache: %w", cErr)
+	} else if cacheVal == nil {
+		return nil, nil
+	}
+
+	var pos sqlPollPosition
+	if err := json.Unmarshal(cacheVal, &pos); err != nil {
+		return nil, fmt.Errorf("unable to parse checkpoint: %w", err)
+	}
+	if (s.polling.incrementingColumn != "") != (pos.Incrementing != nil) ||
+		(s.polling.timestampColumn != "") != (pos.Timestamp != nil) {
+		return nil, errors.New("the cached checkpoint does not match the configured tracking columns")
+	}
+	return &pos, nil
+}
+
+func (s *sqlSelectInput) setCachedPosition(ctx context.Context, pos *sqlPollPosition) error {
+	posBytes, err := json.Marshal(pos)
+	if err != nil {
+		return fmt.Errorf("unable to serialize checkpoint: %w", err)
+	}
+
+	var cErr error
+	if err := s.mgr.AccessCache(ctx, s.polling.checkpointCache, func(c service.Cache) {
+		cErr = c.Set(ctx, s.polling.checkpointKey, posBytes, nil)
+	}); err != nil {
+		return fmt.Errorf("unable to access cache for writing: %w", err)
+	}
+	if cErr != nil {
+		return fmt.Errorf("unable persist checkpoint to cache: %w", cErr)
+	}
+	return nil
+}
+
+// ---- cache methods end ----
@@ -16,8 +16,13 @@ package sql
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
@@ -43,3 +48,246 @@ args_mapping: 'root = [ this.id ]'
 	require.NoError(t, err)
 	require.NoError(t, selectInput.Close(context.Background()))
 }
+
+func TestSQLSelectInputPollingConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        string
+		errContains string
+	}{
+		{
+			name: "no tracking columns",
+			conf: `
+polling:
+  checkpoint_cache: foo
+`,
+			errContains: "at least one of incrementing_column and timestamp_column must be specified",
+		},
+		{
+			name: "timestamp delay without timestamp column",
+			conf: `
+polling:
+  incrementing_column: id
+  timestamp_delay: 1s
+  checkpoint_cache: foo
+`,
+			errContains: "timestamp_delay requires timestamp_column to be specified",
+		},
+		{
+			name: "incrementing and timestamp columns",
+			conf: `
+polling:
+  incrementing_column: id
+  timestamp_column: updated_at
+  checkpoint_cache: foo
+`,
+		},
+	}
+
+	spec := sqlSelectInputConfig()
+	env := service.NewEnvironment()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selectConfig, err := spec.ParseYAML(`
+driver: sqlite
+dsn: woof
+table: quack
+columns: [ '*' ]
+`+test.conf, env)
+			require.NoError(t, err)
+
+			selectInput, err := newSQLSelectInputFromConfig(selectConfig, service.MockResources())
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, selectInput.Close(context.Background()))
+		})
+	}
+}
+
+func newSQLSelectPollingTestInput(t *testing.T, dsn, polling string, res *service.Resources) *sqlSelectInput {
+	t.Helper()
+
+	selectConfig, err := sqlSelectInputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: things
+columns: [ id, name, updated_at ]
+polling:
+  interval: 10ms
+  checkpoint_cache: foo
+`+polling, service.NewEnvironment())
+	require.NoError(t, err)
+
+	selectInput, err := newSQLSelectInputFromConfig(selectConfig, res)
+	require.NoError(t, err)
+	require.NoError(t, selectInput.Connect(context.Background()))
+	t.Cleanup(func() {
+		_ = selectInput.Close(context.Background())
+	})
+	return selectInput
+}
+
+func readSQLSelectNames(t *testing.T, i *sqlSelectInput, n int) (names []string, ackFns []service.AckFunc) {
+	t.Helper()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+	defer done()
+
+	for range n {
+		msg, ackFn, err := i.Read(ctx)
+		require.NoError(t, err)
+		v, err := msg.AsStructured()
+		require.NoError(t, err)
+		names = append(names, v.(map[string]any)["name"].(string))
+		ackFns = append(ackFns, ackFn)
+	}
+	return
+}
+
+func sqlSelectCachedCheckpoint(t *testing.T, res *service.Resources) string {
+	t.Helper()
+
+	var v []byte
+	var cErr error
+	require.NoError(t, res.AccessCache(context.Background(), "foo", func(c service.Cache) {
+		v, cErr = c.Get(context.Background(), "sql_select_checkpoint")
+	}))
+	if errors.Is(cErr, service.ErrKeyNotFound) {
+		return ""
+	}
+	require.NoError(t, cErr)
+	return string(v)
+}
+
+func TestSQLSelectInputPollingIncrementingTimestamp(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	_, err = db.Exec(`CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT, updated_at DATETIME)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO things VALUES (1, 'a', ?), (2, 'b', ?), (3, 'c', ?)`, t1, t1, t2)
+	require.NoError(t, err)
+
+	res := service.MockResources(service.MockResourcesOptAddCache("foo"))
+	polling := `
+  incrementing_column: id
+  timestamp_column: updated_at
+`
+	selectInput := newSQLSelectPollingTestInput(t, dsn, polling, res)
+
+	names, ackFns := readSQLSelectNames(t, selectInput, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	// The checkpoint only advances once all preceding rows are acknowledged
+	require.NoError(t, ackFns[2](context.Background(), nil))
+	assert.Empty(t, sqlSelectCachedCheckpoint(t, res))
+	require.NoError(t, ackFns[0](context.Background(), nil))
+	require.NoError(t, ackFns[1](context.Background(), nil))
+	assert.JSONEq(t, `{"incrementing":3,"timestamp":"2024-01-01T01:00:00Z"}`, sqlSelectCachedCheckpoint(t, res))
+
+	// A new row that shares the timestamp of the last row and a modified row
+	_, err = db.Exec(`INSERT INTO things VALUES (4, 'd', ?)`, t2)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE things SET updated_at = ? WHERE id = 1`, t2.Add(time.Hour))
+	require.NoError(t, err)
+
+	names, ackFns = readSQLSelectNames(t, selectInput, 2)
+	assert.Equal(t, []string{"d", "a"}, names)
+	for _, ackFn := range ackFns {
+		require.NoError(t, ackFn(context.Background(), nil))
+	}
+	require.NoError(t, selectInput.Close(context.Background()))
+
+	// Polling resumes from the checkpoint
+	_, err = db.Exec(`INSERT INTO things VALUES (5, 'e', ?)`, t2.Add(time.Hour))
+	require.NoError(t, err)
+
+	selectInput = newSQLSelectPollingTestInput(t, dsn, polling, res)
+	names, _ = readSQLSelectNames(t, selectInput, 1)
+	assert.Equal(t, []string{"e"}, names)
+}
+
+func TestSQLSelectInputPollingTimestampTies(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	_, err = db.Exec(`CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT, updated_at DATETIME)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO things VALUES (1, 'a', ?), (2, 'b', ?), (3, 'c', ?)`, t1, t1, t2)
+	require.NoError(t, err)
+
+	res := service.MockResources(service.MockResourcesOptAddCache("foo"))
+	polling := `
+  timestamp_column: updated_at
+`
+	selectInput := newSQLSelectPollingTestInput(t, dsn, polling, res)
+
+	names, ackFns := readSQLSelectNames(t, selectInput, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	// The first row shares its timestamp with the second and is therefore not
+	// checkpointed
+	require.NoError(t, ackFns[0](context.Background(), nil))
+	assert.Empty(t, sqlSelectCachedCheckpoint(t, res))
+	require.NoError(t, ackFns[1](context.Background(), nil))
+	assert.JSONEq(t, `{"timestamp":"2024-01-01T00:00:00Z"}`, sqlSelectCachedCheckpoint(t, res))
+	require.NoError(t, selectInput.Close(context.Background()))
+
+	// The unacknowledged row is read again upon restart
+	selectInput = newSQLSelectPollingTestInput(t, dsn, polling, res)
+	names, _ = readSQLSelectNames(t, selectInput, 1)
+	assert.Equal(t, []string{"c"}, names)
+}
+
+func TestSQLSelectInputPollingNackWithoutAutoRetry(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT, updated_at DATETIME)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO things (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c')`)
+	require.NoError(t, err)
+
+	res := service.MockResources(service.MockResourcesOptAddCache("foo"))
+
+	// Without auto_retry_nacks the input receives the nacks itself
+	selectInput := newSQLSelectPollingTestInput(t, dsn, `
+  incrementing_column: id
+`, res)
+
+	names, ackFns := readSQLSelectNames(t, selectInput, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+
+	require.NoError(t, ackFns[0](context.Background(), nil))
+	assert.JSONEq(t, `{"incrementing":1}`, sqlSelectCachedCheckpoint(t, res))
+
+	// The checkpoint does not move past a rejected row, even once the rows that
+	// follow it are acknowledged
+	require.NoError(t, ackFns[1](context.Background(), errors.New("nope")))
+	require.NoError(t, ackFns[2](context.Background(), nil))
+	assert.JSONEq(t, `{"incrementing":1}`, sqlSelectCachedCheckpoint(t, res))
+
+	// The rejected row and the rows that follow it are read again
+	names, ackFns = readSQLSelectNames(t, selectInput, 2)
+	assert.Equal(t, []string{"b", "c"}, names)
+	for _, ackFn := range ackFns {
+		require.NoError(t, ackFn(context.Background(), nil))
+	}
+	assert.JSONEq(t, `{"incrementing":3}`, sqlSelectCachedCheckpoint(t, res))
+}
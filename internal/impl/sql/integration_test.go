@@ -18,6 +18,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -390,29 +391,36 @@ var testRawProcessorsTransactional = testRawTransactionalProcessors("raw_txn", r
 
 var testDeprecatedProcessorsBasic = testRawDeprecatedProcessors("deprecated", rawProcessorTest)
 
-func testBatchInputOutputBatch(t *testing.T, driver, dsn, table string) {
-	colList := `[ "foo", "bar", "baz" ]`
-	if driver == "oracle" {
-		colList = `[ "\"foo\"", "\"bar\"", "\"baz\"" ]`
-	}
-	t.Run("batch_input_output", func(t *testing.T) {
-		confReplacer := strings.NewReplacer(
-			"$driver", driver,
-			"$dsn", dsn,
-			"$table", table,
-			"$columnlist", colList,
-		)
+var testBatchInputOutputBatch = testBatchInputOutput("batch_input_output", false)
 
-		outputConf := confReplacer.Replace(`
+var testBatchInputOutputBulk = testBatchInputOutput("batch_input_output_bulk", true)
+
+func testBatchInputOutput(name string, bulk bool) testFn {
+	return func(t *testing.T, driver, dsn, table string) {
+		colList := `[ "foo", "bar", "baz" ]`
+		if driver == "oracle" {
+			colList = `[ "\"foo\"", "\"bar\"", "\"baz\"" ]`
+		}
+		t.Run(name, func(t *testing.T) {
+			confReplacer := strings.NewReplacer(
+				"$driver", driver,
+				"$dsn", dsn,
+				"$table", table,
+				"$columnlist", colList,
+				"$bulk", strconv.FormatBool(bulk),
+			)
+
+			outputConf := confReplacer.Replace(`
 sql_insert:
   driver: $driver
   dsn: $dsn
   table: $table
   columns: $columnlist
+  bulk: $bulk
   args_mapping: 'root = [ this.foo, this.bar.floor(), this.baz ]'
 `)
 
-		inputConf := confReplacer.Replace(`
+			inputConf := confReplacer.Replace(`
 sql_select:
   driver: $driver
   dsn: $dsn
@@ -426,61 +434,62 @@ processors:
       root.bar = this.bar.number()
 `)
 
-		streamInBuilder := service.NewStreamBuilder()
-		require.NoError(t, streamInBuilder.SetLoggerYAML(`level: OFF`))
-		require.NoError(t, streamInBuilder.AddOutputYAML(outputConf))
+			streamInBuilder := service.NewStreamBuilder()
+			require.NoError(t, streamInBuilder.SetLoggerYAML(`level: OFF`))
+			require.NoError(t, streamInBuilder.AddOutputYAML(outputConf))
 
-		inFn, err := streamInBuilder.AddBatchProducerFunc()
-		require.NoError(t, err)
+			inFn, err := streamInBuilder.AddBatchProducerFunc()
+			require.NoError(t, err)
 
-		streamIn, err := streamInBuilder.Build()
-		require.NoError(t, err)
+			streamIn, err := streamInBuilder.Build()
+			require.NoError(t, err)
 
-		go func() {
-			assert.NoError(t, streamIn.Run(context.Background()))
-		}()
+			go func() {
+				assert.NoError(t, streamIn.Run(context.Background()))
+			}()
 
-		streamOutBuilder := service.NewStreamBuilder()
-		require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: OFF`))
-		require.NoError(t, streamOutBuilder.AddInputYAML(inputConf))
+			streamOutBuilder := service.NewStreamBuilder()
+			require.NoError(t, streamOutBuilder.SetLoggerYAML(`level: OFF`))
+			require.NoError(t, streamOutBuilder.AddInputYAML(inputConf))
 
-		var outBatches []string
-		require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, mb service.MessageBatch) error {
-			msgBytes, err := mb[0].AsBytes()
-			require.NoError(t, err)
-			outBatches = append(outBatches, string(msgBytes))
-			return nil
-		}))
+			var outBatches []string
+			require.NoError(t, streamOutBuilder.AddBatchConsumerFunc(func(c context.Context, mb service.MessageBatch) error {
+				msgBytes, err := mb[0].AsBytes()
+				require.NoError(t, err)
+				outBatches = append(outBatches, string(msgBytes))
+				return nil
+			}))
 
-		streamOut, err := streamOutBuilder.Build()
-		require.NoError(t, err)
+			streamOut, err := streamOutBuilder.Build()
+			require.NoError(t, err)
 
-		var insertBatch service.MessageBatch
-		for i := 0; i < 10; i++ {
-			insertBatch = append(insertBatch, service.NewMessage([]byte(fmt.Sprintf(`{
+			var insertBatch service.MessageBatch
+			for i := 0; i < 10; i++ {
+				insertBatch = append(insertBatch, service.NewMessage([]byte(fmt.Sprintf(`{
 	"foo": "doc-%d",
 	"bar": %d,
 	"baz": "and this"
 }`, i, i))))
-		}
-		require.NoError(t, inFn(context.Background(), insertBatch))
-		require.NoError(t, streamIn.StopWithin(15*time.Second))
-
-		require.NoError(t, streamOut.Run(context.Background()))
-
-		assert.Equal(t, []string{
-			"{\"bar\":0,\"baz\":\"and this\",\"foo\":\"doc-0\"}",
-			"{\"bar\":1,\"baz\":\"and this\",\"foo\":\"doc-1\"}",
-			"{\"bar\":2,\"baz\":\"and this\",\"foo\":\"doc-2\"}",
-			"{\"bar\":3,\"baz\":\"and this\",\"foo\":\"doc-3\"}",
-			"{\"bar\":4,\"baz\":\"and this\",\"foo\":\"doc-4\"}",
-			"{\"bar\":5,\"baz\":\"and this\",\"foo\":\"doc-5\"}",
-			"{\"bar\":6,\"baz\":\"and this\",\"foo\":\"doc-6\"}",
-			"{\"bar\":7,\"baz\":\"and this\",\"foo\":\"doc-7\"}",
-			"{\"bar\":8,\"baz\":\"and this\",\"foo\":\"doc-8\"}",
-			"{\"bar\":9,\"baz\":\"and this\",\"foo\":\"doc-9\"}",
-		}, outBatches)
-	})
+			}
+			require.NoError(t, inFn(context.Background(), insertBatch))
+			require.NoError(t, streamIn.StopWithin(15*time.Second))
+
+			require.NoError(t, streamOut.Run(context.Background()))
+
+			assert.Equal(t, []string{
+				"{\"bar\":0,\"baz\":\"and this\",\"foo\":\"doc-0\"}",
+				"{\"bar\":1,\"baz\":\"and this\",\"foo\":\"doc-1\"}",
+				"{\"bar\":2,\"baz\":\"and this\",\"foo\":\"doc-2\"}",
+				"{\"bar\":3,\"baz\":\"and this\",\"foo\":\"doc-3\"}",
+				"{\"bar\":4,\"baz\":\"and this\",\"foo\":\"doc-4\"}",
+				"{\"bar\":5,\"baz\":\"and this\",\"foo\":\"doc-5\"}",
+				"{\"bar\":6,\"baz\":\"and this\",\"foo\":\"doc-6\"}",
+				"{\"bar\":7,\"baz\":\"and this\",\"foo\":\"doc-7\"}",
+				"{\"bar\":8,\"baz\":\"and this\",\"foo\":\"doc-8\"}",
+				"{\"bar\":9,\"baz\":\"and this\",\"foo\":\"doc-9\"}",
+			}, outBatches)
+		})
+	}
 }
 
 func testBatchInputOutputRaw(t *testing.T, driver, dsn, table string) {
@@ -609,6 +618,7 @@ func testSuite(t *testing.T, driver, dsn string, createTableFn func(string) (str
 		testBatchProcessorBasic,
 		testBatchProcessorParallel,
 		testBatchInputOutputBatch,
+		testBatchInputOutputBulk,
 		testBatchInputOutputRaw,
 		testRawProcessorsBasic,
 		testRawProcessorsTransactional,
@@ -734,6 +744,76 @@ func TestIntegrationOldClickhouse(t *testing.T) {
 	testSuite(t, "clickhouse", dsn, createTable)
 }
 
+func TestIntegrationTimeplus(t *testing.T) {
+	integration.CheckSkip(t)
+	t.Parallel()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Could not connect to docker: %s", err)
+	}
+	pool.MaxWait = 3 * time.Minute
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository:   "ghcr.io/timeplus-io/proton",
+		Tag:          "latest",
+		ExposedPorts: []string{"8463/tcp"},
+	})
+	require.NoError(t, err)
+
+	var db *sql.DB
+	t.Cleanup(func() {
+		if err = pool.Purge(resource); err != nil {
+			t.Logf("Failed to clean up docker resource: %s", err)
+		}
+		if db != nil {
+			db.Close()
+		}
+	})
+
+	createTable := func(name string) (string, error) {
+		_, err := db.Exec(fmt.Sprintf(`create stream %s (
+  "foo" string,
+  "bar" int64,
+  "baz" string
+		);`, name))
+		return name, err
+	}
+
+	dsn := fmt.Sprintf("proton://localhost:%s/default", resource.GetPort("8463/tcp"))
+	require.NoError(t, pool.Retry(func() error {
+		db, err = sql.Open("proton", dsn)
+		if err != nil {
+			return err
+		}
+		if err = db.Ping(); err != nil {
+			db.Close()
+			db = nil
+			return err
+		}
+		if _, err := createTable("footable"); err != nil {
+			return err
+		}
+		return nil
+	}))
+
+	// Raw queries against a stream never terminate, so only the components
+	// which query the stream via `table()` are covered.
+	for _, fn := range []testFn{
+		testBatchProcessorBasic,
+		testBatchProcessorParallel,
+		testBatchInputOutputBatch,
+	} {
+		tableName, err := gonanoid.Generate("abcdefghijklmnopqrstuvwxyz", 40)
+		require.NoError(t, err)
+
+		tableName, err = createTable(tableName)
+		require.NoError(t, err)
+
+		fn(t, "timeplus", dsn, tableName)
+	}
+}
+
 func TestIntegrationPostgres(t *testing.T) {
 	integration.CheckSkip(t)
 	t.Parallel()
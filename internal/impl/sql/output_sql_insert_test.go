@@ -16,8 +16,12 @@ package sql
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
@@ -42,3 +46,300 @@ args_mapping: 'root = [ this.id ]'
 	require.NoError(t, err)
 	require.NoError(t, insertOutput.Close(context.Background()))
 }
+
+func TestSQLInsertOutputUpsertConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        string
+		errContains string
+	}{
+		{
+			name: "key column not a column",
+			conf: `
+driver: postgres
+key_columns: [ bar ]
+`,
+			errContains: "key column bar is not one of the columns",
+		},
+		{
+			name: "with suffix",
+			conf: `
+driver: postgres
+key_columns: [ foo ]
+suffix: ON CONFLICT DO NOTHING
+`,
+			errContains: "key_columns cannot be combined with suffix or options",
+		},
+		{
+			name: "unsupported driver",
+			conf: `
+driver: clickhouse
+key_columns: [ foo ]
+`,
+			errContains: "upserts are not supported by the clickhouse driver",
+		},
+		{
+			name: "bulk with key columns",
+			conf: `
+driver: postgres
+key_columns: [ foo ]
+bulk: true
+`,
+			errContains: "bulk cannot be combined with key_columns, prefix, suffix or options",
+		},
+	}
+
+	spec := sqlInsertOutputConfig()
+	env := service.NewEnvironment()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			insertConfig, err := spec.ParseYAML(`
+dsn: woof
+table: quack
+columns: [ foo ]
+args_mapping: 'root = [ this.id ]'
+`+test.conf, env)
+			require.NoError(t, err)
+
+			_, err = newSQLInsertOutputFromConfig(insertConfig, service.MockResources())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errContains)
+		})
+	}
+}
+
+func TestSQLInsertOutputUpsertAndDelete(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO things VALUES (1, 'a'), (2, 'b')`)
+	require.NoError(t, err)
+
+	insertConfig, err := sqlInsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: things
+columns: [ id, name ]
+key_columns: [ id ]
+args_mapping: 'root = [ this.id, this.name ]'
+`, service.NewEnvironment())
+	require.NoError(t, err)
+
+	insertOutput, err := newSQLInsertOutputFromConfig(insertConfig, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, insertOutput.Connect(context.Background()))
+	t.Cleanup(func() {
+		_ = insertOutput.Close(context.Background())
+	})
+
+	newMsg := func(operation, content string) *service.Message {
+		msg := service.NewMessage([]byte(content))
+		msg.MetaSetMut("operation", operation)
+		return msg
+	}
+
+	require.NoError(t, insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		newMsg("update", `{"id":1,"name":"c"}`),
+		newMsg("delete", `{"id":2,"name":null}`),
+		newMsg("insert", `{"id":3,"name":"d"}`),
+		newMsg("insert", `{"id":4,"name":"e"}`),
+		newMsg("delete", `{"id":4,"name":null}`),
+	}))
+
+	// A failing statement rolls back the whole batch
+	require.Error(t, insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		newMsg("insert", `{"id":5,"name":"f"}`),
+		newMsg("insert", `{"id":"nope","name":"g"}`),
+	}))
+
+	// Operations other than row changes are rejected rather than upserted
+	err = insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		newMsg("insert", `{"id":6,"name":"h"}`),
+		newMsg("ddl", `{"id":null,"name":null}`),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported operation 'ddl' of message 1")
+
+	// Messages without an operation are upserted
+	require.NoError(t, insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":7,"name":"i"}`)),
+		newMsg("read", `{"id":8,"name":"j"}`),
+	}))
+
+	rows, err := db.Query(`SELECT id, name FROM things ORDER BY id`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var actual []string
+	for rows.Next() {
+		var id int
+		var name string
+		require.NoError(t, rows.Scan(&id, &name))
+		actual = append(actual, fmt.Sprintf("%v:%v", id, name))
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"1:c", "3:d", "7:i", "8:j"}, actual)
+}
+
+func TestSQLInsertOutputBulkFallback(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE things (id INTEGER PRIMARY KEY, name TEXT)`)
+	require.NoError(t, err)
+
+	insertConfig, err := sqlInsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: things
+columns: [ id, name ]
+bulk: true
+args_mapping: 'root = [ this.id, this.name ]'
+`, service.NewEnvironment())
+	require.NoError(t, err)
+
+	insertOutput, err := newSQLInsertOutputFromConfig(insertConfig, service.MockResources())
+	require.NoError(t, err)
+	assert.Empty(t, insertOutput.bulkQuery)
+	require.NoError(t, insertOutput.Connect(context.Background()))
+	t.Cleanup(func() {
+		_ = insertOutput.Close(context.Background())
+	})
+
+	require.NoError(t, insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":1,"name":"a"}`)),
+		service.NewMessage([]byte(`{"id":2,"name":"b"}`)),
+	}))
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM things`).Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestSQLInsertOutputSchemaEvolutionConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        string
+		errContains string
+	}{
+		{
+			name: "without columns",
+			conf: `
+driver: sqlite
+`,
+			errContains: "columns and args_mapping must be specified unless schema_evolution is enabled",
+		},
+		{
+			name: "with columns",
+			conf: `
+driver: sqlite
+columns: [ foo ]
+args_mapping: 'root = [ this.id ]'
+schema_evolution:
+  enabled: true
+`,
+			errContains: "schema_evolution cannot be combined with columns or args_mapping",
+		},
+		{
+			name: "with key columns",
+			conf: `
+driver: sqlite
+key_columns: [ foo ]
+schema_evolution:
+  enabled: true
+`,
+			errContains: "schema_evolution cannot be combined with key_columns",
+		},
+		{
+			name: "unsupported driver",
+			conf: `
+driver: oracle
+schema_evolution:
+  enabled: true
+`,
+			errContains: "schema evolution is not supported by the oracle driver",
+		},
+	}
+
+	spec := sqlInsertOutputConfig()
+	env := service.NewEnvironment()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			insertConfig, err := spec.ParseYAML(`
+dsn: woof
+table: quack
+`+test.conf, env)
+			require.NoError(t, err)
+
+			_, err = newSQLInsertOutputFromConfig(insertConfig, service.MockResources())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.errContains)
+		})
+	}
+}
+
+func TestSQLInsertOutputSchemaEvolution(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "foo.db")
+	db, err := sql.Open("sqlite", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	insertConfig, err := sqlInsertOutputConfig().ParseYAML(`
+driver: sqlite
+dsn: `+dsn+`
+table: things
+schema_evolution:
+  enabled: true
+`, service.NewEnvironment())
+	require.NoError(t, err)
+
+	insertOutput, err := newSQLInsertOutputFromConfig(insertConfig, service.MockResources())
+	require.NoError(t, err)
+	require.NoError(t, insertOutput.Connect(context.Background()))
+	t.Cleanup(func() {
+		_ = insertOutput.Close(context.Background())
+	})
+
+	require.NoError(t, insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":1,"name":"a","extra":null}`)),
+		service.NewMessage([]byte(`{"id":2,"name":"b"}`)),
+	}))
+	require.NoError(t, insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":3,"name":"c","score":1.5,"tags":["x","y"]}`)),
+		service.NewMessage([]byte(`{"id":4,"extra":"d"}`)),
+	}))
+
+	// Columns that are added by others are picked up rather than added again
+	_, err = db.Exec(`ALTER TABLE things ADD COLUMN note TEXT`)
+	require.NoError(t, err)
+	require.NoError(t, insertOutput.WriteBatch(context.Background(), service.MessageBatch{
+		service.NewMessage([]byte(`{"id":5,"NOTE":"e"}`)),
+	}))
+
+	rows, err := db.Query(`SELECT id, name, extra, score, tags, note FROM things ORDER BY id`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var actual []string
+	for rows.Next() {
+		var id int
+		var name, extra, tags, note sql.NullString
+		var score sql.NullFloat64
+		require.NoError(t, rows.Scan(&id, &name, &extra, &score, &tags, &note))
+		actual = append(actual, fmt.Sprintf("%v:%v:%v:%v:%v:%v", id, name.String, extra.String, score.Float64, tags.String, note.String))
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{
+		"1:a::0::",
+		"2:b::0::",
+		`3:c::1.5:["x","y"]:`,
+		"4::d:0::",
+		"5:::0::e",
+	}, actual)
+}
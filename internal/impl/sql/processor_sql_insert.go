@@ -129,6 +129,7 @@ func NewSQLInsertProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Re
 	}
 	if _, in := map[string]struct{}{
 		"clickhouse": {},
+		"timeplus":   {},
 		"oracle":     {},
 	}[driverStr]; in {
 		s.useTxStmt = true
@@ -156,7 +157,7 @@ func NewSQLInsertProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Re
 	}
 
 	s.builder = squirrel.Insert(tableStr).Columns(columns...)
-	if driverStr == "postgres" || driverStr == "clickhouse" {
+	if driverStr == "postgres" || driverStr == "clickhouse" || driverStr == "timeplus" {
 		s.builder = s.builder.PlaceholderFormat(squirrel.Dollar)
 	} else if driverStr == "oracle" || driverStr == "gocosmos" {
 		s.builder = s.builder.PlaceholderFormat(squirrel.Colon)
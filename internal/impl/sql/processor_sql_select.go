@@ -46,7 +46,7 @@ If the query fails to execute then the message will remain unchanged and the err
 			Example([]string{"*"}).
 			Example([]string{"foo", "bar", "baz"})).
 		Field(service.NewStringField("where").
-			Description("An optional where clause to add. Placeholder arguments are populated with the `args_mapping` field. Placeholders should always be question marks, and will automatically be converted to dollar syntax when the postgres or clickhouse drivers are used.").
+			Description("An optional where clause to add. Placeholder arguments are populated with the `args_mapping` field. Placeholders should always be question marks, and will automatically be converted to dollar syntax when the postgres, clickhouse or timeplus drivers are used.").
 			Example("meow = ? and woof = ?").
 			Example("user_id = ?").
 			Optional()).
@@ -157,8 +157,8 @@ func NewSQLSelectProcessorFromConfig(conf *service.ParsedConfig, mgr *service.Re
 		}
 	}
 
-	s.builder = squirrel.Select(columns...).From(tableStr)
-	if driverStr == "postgres" || driverStr == "clickhouse" {
+	s.builder = squirrel.Select(columns...).From(selectTable(driverStr, tableStr))
+	if driverStr == "postgres" || driverStr == "clickhouse" || driverStr == "timeplus" {
 		s.builder = s.builder.PlaceholderFormat(squirrel.Dollar)
 	} else if driverStr == "oracle" || driverStr == "gocosmos" {
 		s.builder = s.builder.PlaceholderFormat(squirrel.Colon)
@@ -0,0 +1,417 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	sioFieldSchemaEvolution                     = "schema_evolution"
+	sioFieldSchemaEvolutionEnabled              = "enabled"
+	sioFieldSchemaEvolutionNewColumnTypeMapping = "new_column_type_mapping"
+)
+
+func sqlInsertSchemaEvolutionField() *service.ConfigField {
+	return service.NewObjectField(sioFieldSchemaEvolution,
+		service.NewBoolField(sioFieldSchemaEvolutionEnabled).
+			Description("Whether schema evolution is enabled.").
+			Default(false),
+		service.NewBloblangField(sioFieldSchemaEvolutionNewColumnTypeMapping).
+			Description("An optional mapping from the value of a new field to the type of its column, which should result in the `root` variable being assigned a string with the column type. The input to this mapping is an object with the value and the name of the field, the original message and the table being written to, for example: `{\"value\": 42.3, \"name\": \"new_field\", \"message\": {\"existing_field\": 42, \"new_field\": 42.3}, \"table\": \"foo\"}`. The metadata is unchanged from the original message. When omitted the column type is inferred from the type of the value in the dialect of the driver.").
+			Example(`root = match this.value.type() {
+  this == "number" => "NUMERIC(38, 9)"
+  _ => "TEXT"
+}`).
+			Optional().
+			Advanced(),
+	).
+		Description("Create the table from the fields of the messages rather than inserting the configured `columns`, and add columns to the table when messages contain new fields. This is supported by the `postgres`, `mysql`, `clickhouse`, `sqlite` and `mssql` drivers, and cannot be combined with the fields `columns`, `args_mapping`, `key_columns` and `bulk`.").
+		Optional().
+		Version("4.47.0")
+}
+
+var (
+	evolvingColumnNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// Column types may contain parameters such as VARCHAR(255) or
+	// Nullable(DateTime64(6)), but must not contain quotes or other characters
+	// that would allow the statement to be altered. Commas are further checked
+	// by validColumnType to only appear within parentheses.
+	evolvingColumnTypeRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_ (),]*$`)
+)
+
+// defaultColumnTypes maps the kinds of values to the types of the columns they
+// are stored in for each driver that supports schema evolution.
+var defaultColumnTypes = map[string]map[string]string{
+	"postgres": {
+		"string":    "TEXT",
+		"bytes":     "BYTEA",
+		"integer":   "BIGINT",
+		"number":    "DOUBLE PRECISION",
+		"bool":      "BOOLEAN",
+		"timestamp": "TIMESTAMPTZ",
+		"object":    "JSONB",
+	},
+	"mysql": {
+		"string":    "TEXT",
+		"bytes":     "BLOB",
+		"integer":   "BIGINT",
+		"number":    "DOUBLE",
+		"bool":      "BOOLEAN",
+		"timestamp": "DATETIME(6)",
+		"object":    "JSON",
+	},
+	"clickhouse": {
+		"string":    "Nullable(String)",
+		"bytes":     "Nullable(String)",
+		"integer":   "Nullable(Int64)",
+		"number":    "Nullable(Float64)",
+		"bool":      "Nullable(Bool)",
+		"timestamp": "Nullable(DateTime64(6))",
+		"object":    "Nullable(String)",
+	},
+	"sqlite": {
+		"string":    "TEXT",
+		"bytes":     "BLOB",
+		"integer":   "INTEGER",
+		"number":    "REAL",
+		"bool":      "BOOLEAN",
+		"timestamp": "DATETIME",
+		"object":    "TEXT",
+	},
+	"mssql": {
+		"string":    "NVARCHAR(MAX)",
+		"bytes":     "VARBINARY(MAX)",
+		"integer":   "BIGINT",
+		"number":    "FLOAT",
+		"bool":      "BIT",
+		"timestamp": "DATETIME2",
+		"object":    "NVARCHAR(MAX)",
+	},
+}
+
+// sqlSchemaEvolver creates a table from the fields of messages and adds the
+// columns of new fields to it.
+type sqlSchemaEvolver struct {
+	driver      string
+	table       string
+	typeMapping *bloblang.Executor
+
+	mut sync.Mutex
+	// columns contains the lower case names of the columns of the table, and is
+	// nil until the table has been created.
+	columns map[string]struct{}
+}
+
+func sqlSchemaEvolverFromParsed(conf *service.ParsedConfig, driver, table string) (*sqlSchemaEvolver, error) {
+	if !conf.Contains(sioFieldSchemaEvolution, sioFieldSchemaEvolutionEnabled) {
+		return nil, nil
+	}
+	conf = conf.Namespace(sioFieldSchemaEvolution)
+
+	enabled, err := conf.FieldBool(sioFieldSchemaEvolutionEnabled)
+	if err != nil || !enabled {
+		return nil, err
+	}
+	if _, ok := defaultColumnTypes[driver]; !ok {
+		return nil, fmt.Errorf("schema evolution is not supported by the %v driver", driver)
+	}
+
+	e := &sqlSchemaEvolver{driver: driver, table: table}
+	if conf.Contains(sioFieldSchemaEvolutionNewColumnTypeMapping) {
+		if e.typeMapping, err = conf.FieldBloblang(sioFieldSchemaEvolutionNewColumnTypeMapping); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// batchRows returns the fields of the messages of a batch, which must be
+// objects.
+func batchRows(batch service.MessageBatch) ([]map[string]any, error) {
+	rows := make([]map[string]any, len(batch))
+	for i, msg := range batch {
+		v, err := msg.AsStructured()
+		if err != nil {
+			return nil, err
+		}
+		row, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("schema evolution requires messages to be objects, got %T", v)
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// evolve creates the table if it has not been created yet and adds the columns
+// of fields of the rows that the table does not have, returning the columns to
+// insert. Fields that are null in every row are only added once they have a
+// value, as their type cannot be inferred.
+func (e *sqlSchemaEvolver) evolve(ctx context.Context, db *sql.DB, batch service.MessageBatch, rows []map[string]any) ([]string, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	var fields []string
+	// The index of the first row with a non-null value of each field, or -1
+	firstValues := map[string]int{}
+	for i, row := range rows {
+		for _, k := range sortedKeys(row) {
+			first, seen := firstValues[k]
+			if !seen {
+				if !evolvingColumnNameRegexp.MatchString(k) {
+					return nil, fmt.Errorf("field name '%v' is not a valid column name", k)
+				}
+				fields = append(fields, k)
+				first = -1
+			}
+			if first == -1 && row[k] != nil {
+				first = i
+			}
+			firstValues[k] = first
+		}
+	}
+
+	if e.columns == nil {
+		var defs []string
+		for _, f := range fields {
+			if i := firstValues[f]; i >= 0 {
+				t, err := e.columnType(batch[i], rows[i], f)
+				if err != nil {
+					return nil, err
+				}
+				defs = append(defs, f+" "+t)
+			}
+		}
+		if len(defs) == 0 {
+			return nil, errors.New("unable to create a table from messages without non-null fields")
+		}
+		if _, err := db.ExecContext(ctx, e.createTableQuery(defs)); err != nil {
+			return nil, fmt.Errorf("failed to create table: %w", err)
+		}
+		if err := e.loadColumns(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+
+	var columns []string
+	for _, f := range fields {
+		if _, exists := e.columns[strings.ToLower(f)]; exists {
+			columns = append(columns, f)
+			continue
+		}
+		i := firstValues[f]
+		if i == -1 {
+			continue
+		}
+		t, err := e.columnType(batch[i], rows[i], f)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := db.ExecContext(ctx, e.addColumnQuery(f, t)); err != nil {
+			// The column may have been added by another writer in the meantime
+			if lErr := e.loadColumns(ctx, db); lErr != nil {
+				return nil, lErr
+			}
+			if _, exists := e.columns[strings.ToLower(f)]; !exists {
+				return nil, fmt.Errorf("failed to add column %v: %w", f, err)
+			}
+		}
+		e.columns[strings.ToLower(f)] = struct{}{}
+		columns = append(columns, f)
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("messages do not contain any non-null fields to insert")
+	}
+	return columns, nil
+}
+
+// reset discards the columns of the table, which are loaded again before the
+// next batch is written.
+func (e *sqlSchemaEvolver) reset() {
+	e.mut.Lock()
+	e.columns = nil
+	e.mut.Unlock()
+}
+
+func (e *sqlSchemaEvolver) loadColumns(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %v WHERE 1 = 0", e.table))
+	if err != nil {
+		return fmt.Errorf("failed to read the columns of the table: %w", err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to read the columns of the table: %w", err)
+	}
+	e.columns = make(map[string]struct{}, len(names))
+	for _, n := range names {
+		e.columns[strings.ToLower(n)] = struct{}{}
+	}
+	return nil
+}
+
+// columnType returns the type of the column of a field, either from the type
+// mapping or inferred from the value of the field.
+func (e *sqlSchemaEvolver) columnType(msg *service.Message, row map[string]any, field string) (string, error) {
+	value := row[field]
+
+	var t string
+	if e.typeMapping == nil {
+		kind, ok := valueKind(value)
+		if !ok {
+			return "", fmt.Errorf("unable to infer the column type of field '%v' from a value of type %T", field, value)
+		}
+		t = defaultColumnTypes[e.driver][kind]
+	} else {
+		in := msg.Copy()
+		in.SetStructuredMut(map[string]any{
+			"value":   value,
+			"name":    field,
+			"message": row,
+			"table":   e.table,
+		})
+		out, err := in.BloblangQuery(e.typeMapping)
+		if err != nil {
+			return "", fmt.Errorf("failed to map the column type of field '%v': %w", field, err)
+		}
+		if out == nil {
+			return "", fmt.Errorf("the column type mapping of field '%v' deleted the message", field)
+		}
+		b, err := out.AsBytes()
+		if err != nil {
+			return "", err
+		}
+		t = strings.TrimSpace(string(b))
+	}
+	if !validColumnType(t) {
+		return "", fmt.Errorf("invalid column type '%v' for field '%v'", t, field)
+	}
+	return t, nil
+}
+
+// validColumnType returns whether a column type only consists of a name and
+// balanced parameters, where commas outside of parentheses would otherwise
+// allow further columns to be added to the statement.
+func validColumnType(t string) bool {
+	if !evolvingColumnTypeRegexp.MatchString(t) {
+		return false
+	}
+	depth := 0
+	for _, c := range t {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return false
+			}
+		case ',':
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+func (e *sqlSchemaEvolver) createTableQuery(defs []string) string {
+	columns := strings.Join(defs, ", ")
+	switch e.driver {
+	case "clickhouse":
+		return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (%v) ENGINE = MergeTree() ORDER BY tuple()", e.table, columns)
+	case "mssql":
+		return fmt.Sprintf("IF OBJECT_ID(N'%v', N'U') IS NULL CREATE TABLE %v (%v)", strings.ReplaceAll(e.table, "'", "''"), e.table, columns)
+	}
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (%v)", e.table, columns)
+}
+
+func (e *sqlSchemaEvolver) addColumnQuery(column, columnType string) string {
+	switch e.driver {
+	case "postgres", "clickhouse":
+		return fmt.Sprintf("ALTER TABLE %v ADD COLUMN IF NOT EXISTS %v %v", e.table, column, columnType)
+	case "mssql":
+		return fmt.Sprintf("ALTER TABLE %v ADD %v %v", e.table, column, columnType)
+	}
+	return fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v %v", e.table, column, columnType)
+}
+
+// valueKind returns the kind of a structured value that determines the default
+// type of its column.
+func valueKind(v any) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return "string", true
+	case []byte:
+		return "bytes", true
+	case bool:
+		return "bool", true
+	case json.Number:
+		if _, err := t.Int64(); err == nil {
+			return "integer", true
+		}
+		return "number", true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer", true
+	case float32, float64:
+		return "number", true
+	case time.Time:
+		return "timestamp", true
+	case map[string]any, []any:
+		return "object", true
+	}
+	return "", false
+}
+
+// evolvingColumnValue returns the argument of the value of a field, where
+// objects and arrays are stored as JSON.
+func evolvingColumnValue(v any) (any, error) {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i, nil
+		}
+		return t.Float64()
+	case map[string]any, []any:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return v, nil
+}
+
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
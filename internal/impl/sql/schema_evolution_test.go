@@ -0,0 +1,152 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestSchemaEvolutionQueries(t *testing.T) {
+	tests := []struct {
+		driver         string
+		expectedCreate string
+		expectedAdd    string
+	}{
+		{
+			driver:         "postgres",
+			expectedCreate: `CREATE TABLE IF NOT EXISTS foo (id BIGINT, name TEXT)`,
+			expectedAdd:    `ALTER TABLE foo ADD COLUMN IF NOT EXISTS age BIGINT`,
+		},
+		{
+			driver:         "mysql",
+			expectedCreate: `CREATE TABLE IF NOT EXISTS foo (id BIGINT, name TEXT)`,
+			expectedAdd:    `ALTER TABLE foo ADD COLUMN age BIGINT`,
+		},
+		{
+			driver:         "clickhouse",
+			expectedCreate: `CREATE TABLE IF NOT EXISTS foo (id BIGINT, name TEXT) ENGINE = MergeTree() ORDER BY tuple()`,
+			expectedAdd:    `ALTER TABLE foo ADD COLUMN IF NOT EXISTS age BIGINT`,
+		},
+		{
+			driver:         "sqlite",
+			expectedCreate: `CREATE TABLE IF NOT EXISTS foo (id BIGINT, name TEXT)`,
+			expectedAdd:    `ALTER TABLE foo ADD COLUMN age BIGINT`,
+		},
+		{
+			driver:         "mssql",
+			expectedCreate: `IF OBJECT_ID(N'foo', N'U') IS NULL CREATE TABLE foo (id BIGINT, name TEXT)`,
+			expectedAdd:    `ALTER TABLE foo ADD age BIGINT`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			e := &sqlSchemaEvolver{driver: test.driver, table: "foo"}
+			assert.Equal(t, test.expectedCreate, e.createTableQuery([]string{"id BIGINT", "name TEXT"}))
+			assert.Equal(t, test.expectedAdd, e.addColumnQuery("age", "BIGINT"))
+		})
+	}
+}
+
+func TestSchemaEvolutionColumnType(t *testing.T) {
+	row := map[string]any{
+		"a": "foo",
+		"b": json.Number("10"),
+		"c": json.Number("10.5"),
+		"d": true,
+		"e": map[string]any{"f": "g"},
+		"h": []any{"i"},
+		"j": nil,
+	}
+	msg := service.NewMessage(nil)
+	msg.SetStructured(row)
+
+	e := &sqlSchemaEvolver{driver: "postgres", table: "foo"}
+	for field, expected := range map[string]string{
+		"a": "TEXT",
+		"b": "BIGINT",
+		"c": "DOUBLE PRECISION",
+		"d": "BOOLEAN",
+		"e": "JSONB",
+		"h": "JSONB",
+	} {
+		actual, err := e.columnType(msg, row, field)
+		require.NoError(t, err, field)
+		assert.Equal(t, expected, actual, field)
+	}
+
+	_, err := e.columnType(msg, row, "j")
+	require.Error(t, err)
+
+	e.typeMapping, err = bloblang.Parse(`root = if this.name == "a" { "VARCHAR(%v)".format(this.value.length()) } else { "TEXT; DROP TABLE foo" }`)
+	require.NoError(t, err)
+
+	actual, err := e.columnType(msg, row, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "VARCHAR(3)", actual)
+
+	_, err = e.columnType(msg, row, "b")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid column type")
+}
+
+func TestSchemaEvolutionValidColumnType(t *testing.T) {
+	for _, valid := range []string{
+		"TEXT",
+		"VARCHAR(255)",
+		"NUMERIC(38, 9)",
+		"Nullable(DateTime64(6))",
+		"Map(String, Nullable(Decimal(10, 2)))",
+	} {
+		assert.True(t, validColumnType(valid), valid)
+	}
+
+	for _, invalid := range []string{
+		"TEXT, other INT",
+		"NUMERIC(38, 9), other INT",
+		"TEXT), other INT, (x",
+		"VARCHAR(255",
+		"TEXT; DROP TABLE foo",
+		"TEXT 'foo'",
+		"",
+	} {
+		assert.False(t, validColumnType(invalid), invalid)
+	}
+}
+
+func TestSchemaEvolutionColumnValue(t *testing.T) {
+	for _, test := range []struct {
+		input    any
+		expected any
+	}{
+		{input: json.Number("10"), expected: int64(10)},
+		{input: json.Number("10.5"), expected: 10.5},
+		{input: map[string]any{"a": "b"}, expected: `{"a":"b"}`},
+		{input: []any{json.Number("1"), "c"}, expected: `[1,"c"]`},
+		{input: "foo", expected: "foo"},
+		{input: nil, expected: nil},
+	} {
+		actual, err := evolvingColumnValue(test.input)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, actual)
+	}
+}
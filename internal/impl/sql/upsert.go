@@ -0,0 +1,117 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// placeholderFormat returns the placeholder format expected by a driver.
+func placeholderFormat(driver string) squirrel.PlaceholderFormat {
+	switch driver {
+	case "postgres", "clickhouse", "timeplus":
+		return squirrel.Dollar
+	case "oracle", "gocosmos":
+		return squirrel.Colon
+	}
+	return squirrel.Question
+}
+
+// upsertQuery returns a statement that inserts a row of the columns, or updates
+// the non-key columns of the row with the same key columns if it already
+// exists, in the dialect of the driver. The values of the columns are expected
+// as arguments in the order of the columns.
+func upsertQuery(driver, table string, columns, keyColumns []string) (string, error) {
+	var updateColumns []string
+	for _, c := range columns {
+		if !slices.Contains(keyColumns, c) {
+			updateColumns = append(updateColumns, c)
+		}
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	insertInto := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", table, strings.Join(columns, ", "), placeholders)
+
+	var query string
+	switch driver {
+	case "postgres", "sqlite":
+		if len(updateColumns) == 0 {
+			query = fmt.Sprintf("%v ON CONFLICT (%v) DO NOTHING", insertInto, strings.Join(keyColumns, ", "))
+			break
+		}
+		query = fmt.Sprintf("%v ON CONFLICT (%v) DO UPDATE SET %v", insertInto, strings.Join(keyColumns, ", "),
+			joinColumns(updateColumns, ", ", func(c string) string { return c + " = EXCLUDED." + c }))
+	case "mysql":
+		if len(updateColumns) == 0 {
+			// Updating a key column to its own value is a no-op
+			updateColumns = keyColumns[:1]
+		}
+		query = fmt.Sprintf("%v ON DUPLICATE KEY UPDATE %v", insertInto,
+			joinColumns(updateColumns, ", ", func(c string) string { return c + " = VALUES(" + c + ")" }))
+	case "spanner":
+		query = "INSERT OR UPDATE" + strings.TrimPrefix(insertInto, "INSERT")
+	case "mssql", "trino":
+		query = mergeQuery(table, fmt.Sprintf("(VALUES (%v)) AS source (%v)", placeholders, strings.Join(columns, ", ")), columns, keyColumns, updateColumns)
+		if driver == "mssql" {
+			// MERGE statements must be terminated by a semicolon in SQL Server
+			query += ";"
+		}
+	case "oracle":
+		query = mergeQuery(table, fmt.Sprintf("(SELECT %v FROM dual) source", joinColumns(columns, ", ", func(c string) string { return "? AS " + c })), columns, keyColumns, updateColumns)
+	case "snowflake":
+		query = mergeQuery(table, fmt.Sprintf("(SELECT %v) AS source", joinColumns(columns, ", ", func(c string) string { return "? AS " + c })), columns, keyColumns, updateColumns)
+	default:
+		return "", fmt.Errorf("upserts are not supported by the %v driver", driver)
+	}
+	return placeholderFormat(driver).ReplacePlaceholders(query)
+}
+
+// mergeQuery returns a MERGE statement of the rows of a source.
+func mergeQuery(table, source string, columns, keyColumns, updateColumns []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "MERGE INTO %v target USING %v ON (%v)", table, source,
+		joinColumns(keyColumns, " AND ", func(c string) string { return "target." + c + " = source." + c }))
+	if len(updateColumns) > 0 {
+		fmt.Fprintf(&b, " WHEN MATCHED THEN UPDATE SET %v",
+			joinColumns(updateColumns, ", ", func(c string) string { return c + " = source." + c }))
+	}
+	fmt.Fprintf(&b, " WHEN NOT MATCHED THEN INSERT (%v) VALUES (%v)", strings.Join(columns, ", "),
+		joinColumns(columns, ", ", func(c string) string { return "source." + c }))
+	return b.String()
+}
+
+// deleteQuery returns a statement that deletes the row with the key columns,
+// the values of which are expected as arguments in the order of the key
+// columns.
+func deleteQuery(driver, table string, keyColumns []string) (string, error) {
+	if driver == "clickhouse" || driver == "timeplus" || driver == "gocosmos" {
+		return "", fmt.Errorf("deletes are not supported by the %v driver", driver)
+	}
+	query := fmt.Sprintf("DELETE FROM %v WHERE %v", table,
+		joinColumns(keyColumns, " AND ", func(c string) string { return c + " = ?" }))
+	return placeholderFormat(driver).ReplacePlaceholders(query)
+}
+
+func joinColumns(columns []string, sep string, fn func(c string) string) string {
+	parts := make([]string, 0, len(columns))
+	for _, c := range columns {
+		parts = append(parts, fn(c))
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertQuery(t *testing.T) {
+	tests := []struct {
+		driver     string
+		columns    []string
+		keyColumns []string
+		expected   string
+	}{
+		{
+			driver:     "postgres",
+			columns:    []string{"id", "name", "age"},
+			keyColumns: []string{"id"},
+			expected:   "INSERT INTO foo (id, name, age) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, age = EXCLUDED.age",
+		},
+		{
+			driver:     "sqlite",
+			columns:    []string{"id"},
+			keyColumns: []string{"id"},
+			expected:   "INSERT INTO foo (id) VALUES (?) ON CONFLICT (id) DO NOTHING",
+		},
+		{
+			driver:     "mysql",
+			columns:    []string{"id", "name"},
+			keyColumns: []string{"id"},
+			expected:   "INSERT INTO foo (id, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name)",
+		},
+		{
+			driver:     "mysql",
+			columns:    []string{"id"},
+			keyColumns: []string{"id"},
+			expected:   "INSERT INTO foo (id) VALUES (?) ON DUPLICATE KEY UPDATE id = VALUES(id)",
+		},
+		{
+			driver:     "spanner",
+			columns:    []string{"id", "name"},
+			keyColumns: []string{"id"},
+			expected:   "INSERT OR UPDATE INTO foo (id, name) VALUES (?, ?)",
+		},
+		{
+			driver:     "mssql",
+			columns:    []string{"id", "region", "name"},
+			keyColumns: []string{"id", "region"},
+			expected:   "MERGE INTO foo target USING (VALUES (?, ?, ?)) AS source (id, region, name) ON (target.id = source.id AND target.region = source.region) WHEN MATCHED THEN UPDATE SET name = source.name WHEN NOT MATCHED THEN INSERT (id, region, name) VALUES (source.id, source.region, source.name);",
+		},
+		{
+			driver:     "oracle",
+			columns:    []string{"id", "name"},
+			keyColumns: []string{"id"},
+			expected:   "MERGE INTO foo target USING (SELECT :1 AS id, :2 AS name FROM dual) source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET name = source.name WHEN NOT MATCHED THEN INSERT (id, name) VALUES (source.id, source.name)",
+		},
+		{
+			driver:     "snowflake",
+			columns:    []string{"id"},
+			keyColumns: []string{"id"},
+			expected:   "MERGE INTO foo target USING (SELECT ? AS id) AS source ON (target.id = source.id) WHEN NOT MATCHED THEN INSERT (id) VALUES (source.id)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.driver, func(t *testing.T) {
+			query, err := upsertQuery(test.driver, "foo", test.columns, test.keyColumns)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, query)
+		})
+	}
+
+	_, err := upsertQuery("clickhouse", "foo", []string{"id"}, []string{"id"})
+	require.Error(t, err)
+}
+
+func TestDeleteQuery(t *testing.T) {
+	query, err := deleteQuery("postgres", "foo", []string{"id", "region"})
+	require.NoError(t, err)
+	assert.Equal(t, "DELETE FROM foo WHERE id = $1 AND region = $2", query)
+
+	_, err = deleteQuery("gocosmos", "foo", []string{"id"})
+	require.Error(t, err)
+}
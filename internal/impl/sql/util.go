@@ -17,6 +17,7 @@ package sql
 import (
 	"database/sql"
 	"slices"
+	"strings"
 
 	"github.com/pgvector/pgvector-go"
 )
@@ -118,3 +119,14 @@ func bloblValuesToPgSQLValues(v []any) []any {
 	}
 	return o
 }
+
+// selectTable returns the source of a select query against the table. A query
+// against a Timeplus stream is a streaming query which never terminates, so the
+// historical data of the stream is queried via `table()` instead, unless the
+// table is already a table function.
+func selectTable(driver, table string) string {
+	if driver == "timeplus" && !strings.Contains(table, "(") {
+		return "table(" + table + ")"
+	}
+	return table
+}
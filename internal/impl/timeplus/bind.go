@@ -0,0 +1,132 @@
+package timeplus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var literalReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// bindArgs replaces the `$1`, `$2`, ... placeholders of the query with the SQL literals of `args`. The query API
+// does not support parameters, so the arguments are bound on the client side for both HTTP and TCP. Placeholders
+// within quoted string literals and identifiers are left as is.
+func bindArgs(sql string, args []any) (string, error) {
+	if len(args) == 0 {
+		return sql, nil
+	}
+
+	literals := make([]string, len(args))
+	for i, arg := range args {
+		literal, err := sqlLiteral(arg)
+		if err != nil {
+			return "", fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		literals[i] = literal
+	}
+
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			b.WriteByte(c)
+			if c == '\\' && i+1 < len(sql) {
+				// Escaped characters, including quotes, do not end the literal
+				i++
+				b.WriteByte(sql[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			end := i + 1
+			for end < len(sql) && isDigit(sql[end]) {
+				end++
+			}
+			placeholder := sql[i:end]
+			n, _ := strconv.Atoi(placeholder[1:])
+			if n < 1 || n > len(literals) {
+				return "", fmt.Errorf("no argument for placeholder %s", placeholder)
+			}
+			b.WriteString(literals[n-1])
+			i = end - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func sqlLiteral(v any) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return "'" + literalReplacer.Replace(t) + "'", nil
+	case []byte:
+		return "'" + literalReplacer.Replace(string(t)) + "'", nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case int:
+		return strconv.Itoa(t), nil
+	case int32:
+		return strconv.FormatInt(int64(t), 10), nil
+	case int64:
+		return strconv.FormatInt(t, 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(t), 10), nil
+	case uint64:
+		return strconv.FormatUint(t, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(t), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(t, 'g', -1, 64), nil
+	case json.Number:
+		if _, err := t.Float64(); err != nil {
+			return "", err
+		}
+		return t.String(), nil
+	case time.Time:
+		return "to_datetime64('" + t.UTC().Format("2006-01-02 15:04:05.999999999") + "', 9, 'UTC')", nil
+	case []any:
+		elems := make([]string, len(t))
+		for i, e := range t {
+			literal, err := sqlLiteral(e)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = literal
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		elems := make([]string, 0, 2*len(t))
+		for _, k := range keys {
+			literal, err := sqlLiteral(t[k])
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, "'"+literalReplacer.Replace(k)+"'", literal)
+		}
+		return "map(" + strings.Join(elems, ", ") + ")", nil
+	}
+	return "", fmt.Errorf("unsupported type %T", v)
+}
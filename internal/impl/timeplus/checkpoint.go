@@ -0,0 +1,184 @@
+package timeplus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	columnSequenceNumber = "_tp_sn"
+	columnEventTime      = "_tp_time"
+
+	seekTimeLayout = "2006-01-02 15:04:05.000"
+)
+
+var seekToRe = regexp.MustCompile(`(?i)\bseek_to\s*=\s*('[^']*'|[^\s,]+)`)
+
+// position is the resume position of a row, it holds the value of the `seek_to` query setting which starts the
+// query right after (sequence number) or at (event time) the row.
+type position struct {
+	seekTo string
+}
+
+// positionOf derives the position of a row from its `_tp_sn` column, or `_tp_time` column if the query does not
+// select the sequence number. Returns nil if the row contains neither.
+func positionOf(event map[string]any) (*position, error) {
+	if v, exists := event[columnSequenceNumber]; exists && v != nil {
+		sn, err := toInt64(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", columnSequenceNumber, err)
+		}
+		return &position{seekTo: strconv.FormatInt(sn+1, 10)}, nil
+	}
+
+	if v, exists := event[columnEventTime]; exists && v != nil {
+		switch t := v.(type) {
+		case time.Time:
+			return &position{seekTo: t.UTC().Format(seekTimeLayout)}, nil
+		case string:
+			return &position{seekTo: t}, nil
+		default:
+			return nil, fmt.Errorf("invalid %s: unexpected type %T", columnEventTime, v)
+		}
+	}
+
+	return nil, nil
+}
+
+func toInt64(v any) (int64, error) {
+	switch t := v.(type) {
+	case int64:
+		return t, nil
+	case int32:
+		return int64(t), nil
+	case uint64:
+		if t > math.MaxInt64 {
+			return 0, fmt.Errorf("value %d overflows int64", t)
+		}
+		return int64(t), nil
+	case float64:
+		return int64(t), nil
+	case json.Number:
+		return t.Int64()
+	case string:
+		return strconv.ParseInt(t, 10, 64)
+	}
+	return 0, fmt.Errorf("unexpected type %T", v)
+}
+
+// withSeekTo rewrites the query so that it starts from `seekTo`. An existing `seek_to` setting of the query is
+// replaced, settings of subqueries are left as is.
+func withSeekTo(sql, seekTo string) string {
+	setting := "seek_to='" + strings.ReplaceAll(seekTo, "'", "\\'") + "'"
+
+	sql = strings.TrimRight(strings.TrimSpace(sql), ";")
+	i := settingsClause(sql)
+	if i < 0 {
+		return sql + " SETTINGS " + setting
+	}
+
+	if clause := sql[i:]; seekToRe.MatchString(clause) {
+		return sql[:i] + seekToRe.ReplaceAllLiteralString(clause, setting)
+	}
+	return sql + ", " + setting
+}
+
+// settingsClause returns the index of the `SETTINGS` clause of the query, or -1 if there is none. Keywords within
+// parentheses, quotes and comments belong to subqueries, CTEs or literals and are skipped.
+func settingsClause(sql string) int {
+	index := -1
+	depth := 0
+	var quote byte
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if quote != 0 {
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sql)
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case isIdentifierChar(c):
+			end := i
+			for end < len(sql) && isIdentifierChar(sql[end]) {
+				end++
+			}
+			if depth == 0 && strings.EqualFold(sql[i:end], "settings") {
+				index = i
+			}
+			i = end - 1
+		}
+	}
+	return index
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// ---- cache methods start ----
+
+func (p *timeplusInput) getCachedPosition(ctx context.Context) (*position, error) {
+	var (
+		cacheVal []byte
+		cErr     error
+	)
+	if err := p.res.AccessCache(ctx, p.checkpointCache, func(c service.Cache) {
+		cacheVal, cErr = c.Get(ctx, p.checkpointCacheKey)
+	}); err != nil {
+		return nil, fmt.Errorf("unable to access cache for reading: %w", err)
+	}
+	if errors.Is(cErr, service.ErrKeyNotFound) {
+		return nil, nil
+	} else if cErr != nil {
+		return nil, fmt.Errorf("unable read checkpoint from cache: %w", cErr)
+	} else if cacheVal == nil {
+		return nil, nil
+	}
+	return &position{seekTo: string(cacheVal)}, nil
+}
+
+func (p *timeplusInput) setCachedPosition(ctx context.Context, pos position) error {
+	var cErr error
+	if err := p.res.AccessCache(ctx, p.checkpointCache, func(c service.Cache) {
+		cErr = c.Set(ctx, p.checkpointCacheKey, []byte(pos.seekTo), nil)
+	}); err != nil {
+		return fmt.Errorf("unable to access cache for writing: %w", err)
+	}
+	if cErr != nil {
+		return fmt.Errorf("unable persist checkpoint to cache: %w", cErr)
+	}
+	return nil
+}
+
+// ---- cache methods end ----
@@ -0,0 +1,214 @@
+package timeplus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/checkpoint"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+type fakeReader struct {
+	sqls    []string
+	batches [][]map[string]any
+
+	// runErr is returned by Run, and readErr by Read once all batches are consumed
+	runErr  error
+	readErr error
+}
+
+func (r *fakeReader) Run(sql string) error {
+	r.sqls = append(r.sqls, sql)
+	return r.runErr
+}
+
+func (r *fakeReader) Read(context.Context) ([]map[string]any, error) {
+	if len(r.batches) == 0 {
+		if r.readErr != nil {
+			return nil, r.readErr
+		}
+		return nil, io.EOF
+	}
+	batch := r.batches[0]
+	r.batches = r.batches[1:]
+	return batch, nil
+}
+
+func (*fakeReader) ColumnTypes() map[string]string {
+	return map[string]string{"_tp_sn": "int64"}
+}
+
+func (*fakeReader) Close(context.Context) error {
+	return nil
+}
+
+func TestWithSeekTo(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			sql:      "select * from iot",
+			expected: "select * from iot SETTINGS seek_to='42'",
+		},
+		{
+			sql:      "select * from iot;",
+			expected: "select * from iot SETTINGS seek_to='42'",
+		},
+		{
+			sql:      "select * from iot settings enable_optimize_predicate_expression=0",
+			expected: "select * from iot settings enable_optimize_predicate_expression=0, seek_to='42'",
+		},
+		{
+			sql:      "select * from iot SETTINGS seek_to='earliest', enable_optimize_predicate_expression=0",
+			expected: "select * from iot SETTINGS seek_to='42', enable_optimize_predicate_expression=0",
+		},
+		{
+			sql:      "select * from iot SETTINGS seek_to=earliest",
+			expected: "select * from iot SETTINGS seek_to='42'",
+		},
+		{
+			sql:      "select * from (select * from iot settings seek_to='earliest') where a > 1",
+			expected: "select * from (select * from iot settings seek_to='earliest') where a > 1 SETTINGS seek_to='42'",
+		},
+		{
+			sql:      "select * from (select * from iot SETTINGS seek_to='earliest') SETTINGS max_threads=1",
+			expected: "select * from (select * from iot SETTINGS seek_to='earliest') SETTINGS max_threads=1, seek_to='42'",
+		},
+		{
+			sql:      "with x as (select * from iot settings max_threads=1) select * from x",
+			expected: "with x as (select * from iot settings max_threads=1) select * from x SETTINGS seek_to='42'",
+		},
+		{
+			sql:      "select * from iot where note = 'no settings; seek_to=1' and `settings` > 1",
+			expected: "select * from iot where note = 'no settings; seek_to=1' and `settings` > 1 SETTINGS seek_to='42'",
+		},
+		{
+			sql:      "select my_settings from iot /* settings */",
+			expected: "select my_settings from iot /* settings */ SETTINGS seek_to='42'",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, withSeekTo(test.sql, "42"), test.sql)
+	}
+}
+
+func TestPositionOf(t *testing.T) {
+	pos, err := positionOf(map[string]any{"_tp_sn": int64(41), "_tp_time": "2024-01-01 00:00:00.000"})
+	require.NoError(t, err)
+	assert.Equal(t, &position{seekTo: "42"}, pos)
+
+	pos, err = positionOf(map[string]any{"_tp_sn": json.Number("41")})
+	require.NoError(t, err)
+	assert.Equal(t, &position{seekTo: "42"}, pos)
+
+	pos, err = positionOf(map[string]any{"_tp_time": time.Date(2024, 1, 1, 0, 0, 1, 5_000_000, time.UTC)})
+	require.NoError(t, err)
+	assert.Equal(t, &position{seekTo: "2024-01-01 00:00:01.005"}, pos)
+
+	pos, err = positionOf(map[string]any{"a": 1})
+	require.NoError(t, err)
+	assert.Nil(t, pos)
+
+	_, err = positionOf(map[string]any{"_tp_sn": "nope"})
+	require.Error(t, err)
+}
+
+func TestInputCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	res := service.MockResources(service.MockResourcesOptAddCache("cp"))
+
+	reader := &fakeReader{
+		batches: [][]map[string]any{
+			{{"_tp_sn": int64(1)}},
+			{{"_tp_sn": int64(2)}, {"_tp_sn": int64(3)}},
+		},
+	}
+	input := &timeplusInput{
+		log:                res.Logger(),
+		res:                res,
+		reader:             reader,
+		sql:                "select _tp_sn from iot",
+		checkpointCache:    "cp",
+		checkpointCacheKey: "key",
+		cp:                 checkpoint.NewCapped[*position](10),
+		backoff:            backoff.NewExponentialBackOff(),
+	}
+
+	require.NoError(t, input.Connect(ctx))
+
+	_, ack1, err := input.ReadBatch(ctx)
+	require.NoError(t, err)
+	batch, ack2, err := input.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+
+	columnTypes, ok := batch[0].MetaGetMut("column_types")
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{"_tp_sn": "int64"}, columnTypes)
+
+	// Acknowledging the second batch first must not move the checkpoint past the first batch
+	require.NoError(t, ack2(ctx, nil))
+	pos, err := input.getCachedPosition(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, pos)
+
+	require.NoError(t, ack1(ctx, nil))
+	pos, err = input.getCachedPosition(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &position{seekTo: "4"}, pos)
+
+	_, _, err = input.ReadBatch(ctx)
+	require.ErrorIs(t, err, service.ErrNotConnected)
+
+	require.NoError(t, input.Connect(ctx))
+	assert.Equal(t, []string{
+		"select _tp_sn from iot",
+		"select _tp_sn from iot SETTINGS seek_to='4'",
+	}, reader.sqls)
+}
+
+func TestInputCheckpointBatchWithoutPosition(t *testing.T) {
+	ctx := context.Background()
+	res := service.MockResources(service.MockResourcesOptAddCache("cp"))
+
+	reader := &fakeReader{
+		batches: [][]map[string]any{
+			{{"_tp_sn": int64(1)}},
+			{{"value": "no position"}},
+		},
+	}
+	input := &timeplusInput{
+		log:                res.Logger(),
+		res:                res,
+		reader:             reader,
+		sql:                "select * from iot",
+		checkpointCache:    "cp",
+		checkpointCacheKey: "key",
+		cp:                 checkpoint.NewCapped[*position](10),
+		backoff:            backoff.NewExponentialBackOff(),
+	}
+
+	require.NoError(t, input.Connect(ctx))
+
+	_, ack1, err := input.ReadBatch(ctx)
+	require.NoError(t, err)
+	_, ack2, err := input.ReadBatch(ctx)
+	require.NoError(t, err)
+
+	// The batch without a position resolves last and must not hide the position
+	// of the first batch
+	require.NoError(t, ack2(ctx, nil))
+	require.NoError(t, ack1(ctx, nil))
+	pos, err := input.getCachedPosition(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &position{seekTo: "2"}, pos)
+}
@@ -0,0 +1,89 @@
+package timeplus
+
+import (
+	"crypto/tls"
+	"net/url"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/driver"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/http"
+)
+
+const (
+	fieldTLS          = "tls"
+	fieldTimeout      = "timeout"
+	fieldProxyURL     = "proxy_url"
+	fieldMaxIdleConns = "max_idle_conns"
+	fieldMaxOpenConns = "max_open_conns"
+	fieldIdleTimeout  = "idle_conn_timeout"
+)
+
+// connFields returns the fields which configure the connections to Timeplus, shared by all components.
+func connFields() []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewTLSToggledField(fieldTLS).Description("Custom TLS settings can be used to override system defaults, e.g. to trust an internal CA or to authenticate via mutual TLS. This applies to both HTTP and `tcp` connections."),
+		service.NewDurationField(fieldTimeout).Description("The timeout of HTTP requests and of establishing `tcp` connections. Streaming queries via HTTP are not limited by this timeout once the response headers are received.").Default("10s").Advanced(),
+		service.NewURLField(fieldProxyURL).Description("An optional HTTP proxy URL. Only applies to HTTP connections.").Optional().Advanced(),
+		service.NewIntField(fieldMaxIdleConns).Description("The maximum number of idle connections which are kept open.").Default(10).Advanced(),
+		service.NewIntField(fieldMaxOpenConns).Description("The maximum number of open connections. If `value <= 0`, then there is no limit on the number of open connections.").Default(0).Advanced(),
+		service.NewDurationField(fieldIdleTimeout).Description("The maximum amount of time an idle connection is kept open. If `value <= 0`, idle connections are not closed due to their idle time.").Default("90s").Advanced(),
+	}
+}
+
+type connConfig struct {
+	tls          *tls.Config
+	timeout      time.Duration
+	proxyURL     *url.URL
+	maxIdleConns int
+	maxOpenConns int
+	idleTimeout  time.Duration
+}
+
+func connConfigFromParsed(conf *service.ParsedConfig) (c connConfig, err error) {
+	var tlsEnabled bool
+	if c.tls, tlsEnabled, err = conf.FieldTLSToggled(fieldTLS); err != nil {
+		return
+	}
+	if !tlsEnabled {
+		c.tls = nil
+	}
+	if c.timeout, err = conf.FieldDuration(fieldTimeout); err != nil {
+		return
+	}
+	if conf.Contains(fieldProxyURL) {
+		if c.proxyURL, err = conf.FieldURL(fieldProxyURL); err != nil {
+			return
+		}
+	}
+	if c.maxIdleConns, err = conf.FieldInt(fieldMaxIdleConns); err != nil {
+		return
+	}
+	if c.maxOpenConns, err = conf.FieldInt(fieldMaxOpenConns); err != nil {
+		return
+	}
+	c.idleTimeout, err = conf.FieldDuration(fieldIdleTimeout)
+	return
+}
+
+func (c connConfig) httpOptions() http.Options {
+	return http.Options{
+		TLS:             c.tls,
+		Timeout:         c.timeout,
+		ProxyURL:        c.proxyURL,
+		MaxIdleConns:    c.maxIdleConns,
+		MaxOpenConns:    c.maxOpenConns,
+		IdleConnTimeout: c.idleTimeout,
+	}
+}
+
+func (c connConfig) driverOptions() driver.Options {
+	return driver.Options{
+		TLS:             c.tls,
+		DialTimeout:     c.timeout,
+		MaxIdleConns:    c.maxIdleConns,
+		MaxOpenConns:    c.maxOpenConns,
+		ConnMaxIdleTime: c.idleTimeout,
+	}
+}
@@ -5,40 +5,34 @@ import (
 	"database/sql"
 	"errors"
 	"io"
-	"regexp"
-	"strconv"
-	"strings"
-	"time"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 	protonDriver "github.com/timeplus-io/proton-go-driver/v2"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
 )
 
+// maxBatchRows is the maximum number of rows returned by a single `Read`.
+const maxBatchRows = 4096
+
 type driver struct {
 	logger      *service.Logger
 	conn        *sql.DB
 	rows        *sql.Rows
 	columnTypes []*sql.ColumnType
 
+	// rowCH is fed by a background goroutine so that all rows of a block, which are decoded at once, can be
+	// drained together
+	rowCH   chan map[string]any
+	readErr error
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
-var (
-	codeRe = *regexp.MustCompile(`code: (.+[0-9])`)
-	msgRe  = *regexp.MustCompile(`message: (.*)`)
-)
-
 // NewDriver creates a new proton driver.
-func NewDriver(logger *service.Logger, addr, username, password string) *driver {
-	conn := protonDriver.OpenDB(&protonDriver.Options{
-		Addr: []string{addr},
-		Auth: protonDriver.Auth{
-			Username: username,
-			Password: password,
-		},
-		DialTimeout: 5 * time.Second,
-	})
+func NewDriver(logger *service.Logger, addr, username, password string, opts Options) *driver {
+	conn := opts.openDB(addr, username, password)
 
 	return &driver{
 		logger: logger,
@@ -46,116 +40,157 @@ func NewDriver(logger *service.Logger, addr, username, password string) *driver
 	}
 }
 
-// Run starts a query.
+// Run starts a query, terminating the query that is already running, if any.
 func (d *driver) Run(sql string) error {
-	d.ctx, d.cancel = context.WithCancel(context.Background())
-	ckCtx := protonDriver.Context(d.ctx)
+	if err := d.stop(); err != nil {
+		d.logger.With("error", err).Warn("failed to terminate the previous query")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ckCtx := protonDriver.Context(ctx)
 
 	rows, err := d.conn.QueryContext(ckCtx, sql)
 	if err != nil {
-		return err
+		cancel()
+		return errclass.Classify(err)
 	}
 
 	if err := rows.Err(); err != nil {
-		return err
+		_ = rows.Close()
+		cancel()
+		return errclass.Classify(err)
 	}
 
 	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
+		_ = rows.Close()
+		cancel()
 		return err
 	}
 
+	d.ctx, d.cancel = ctx, cancel
 	d.rows = rows
 	d.columnTypes = columnTypes
+	d.rowCH = make(chan map[string]any, maxBatchRows)
+	d.readErr = nil
+
+	go d.scanRows(d.ctx, rows, columnTypes, d.rowCH)
 
 	return nil
 }
 
-// Read reads one row.
-func (d *driver) Read(ctx context.Context) (map[string]any, error) {
-	for { // retry loop
-		if d.rows.Next() {
-			count := len(d.columnTypes)
-
-			values := make([]any, count)
-			valuePtrs := make([]any, count)
-
-			for i := range d.columnTypes {
-				valuePtrs[i] = &values[i]
-			}
+// stop terminates the running query, if any, and waits for the rows which are still being scanned to be dropped.
+func (d *driver) stop() error {
+	if d.cancel == nil {
+		return nil
+	}
 
-			if err := d.rows.Scan(valuePtrs...); err != nil {
-				return nil, err
-			}
+	d.cancel()
+	for range d.rowCH {
+	}
 
-			event := make(map[string]any)
-			for i, col := range d.columnTypes {
-				event[col.Name()] = values[i]
-			}
+	rows := d.rows
+	d.ctx, d.cancel, d.rows, d.rowCH = nil, nil, nil, nil
 
-			return event, nil
+	if err := rows.Close(); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			return err
 		}
+	}
 
-		if err := d.rows.Err(); err != nil {
-			if isQueryCancelErr(err) {
-				// Most likely timeplusd got restarted. Since we are going to re-connect to timeplusd once it recovered, we do not log it as error for now.
-				d.logger.With("reason", err).Info("query cancelled")
-				return nil, io.EOF
-			}
-			if errors.Is(err, context.Canceled) {
-				return nil, err
-			}
-
-			d.logger.With("error", err).Errorf("query failed: %s", err.Error())
-			// this happens when the SQL is updated, i.e. a new MV is created, the previous checkpoint is on longer available.
-			if strings.Contains(err.Error(), "code: 2003") {
-				continue // retry
-			}
-			return nil, err
+	if err := rows.Err(); err != nil {
+		if !errors.Is(err, context.Canceled) {
+			return err
 		}
+	}
 
-		return nil, io.EOF
+	return nil
+}
+
+// ColumnTypes returns the types of the columns of the running query.
+func (d *driver) ColumnTypes() map[string]string {
+	types := make(map[string]string, len(d.columnTypes))
+	for _, col := range d.columnTypes {
+		types[col.Name()] = col.DatabaseTypeName()
 	}
+	return types
 }
 
-// Close terminates the running query.
-func (d *driver) Close(context.Context) error {
-	d.cancel()
+// Read reads all rows which are available, blocking until there is at least one row.
+func (d *driver) Read(ctx context.Context) ([]map[string]any, error) {
+	var events []map[string]any
 
-	if err := d.rows.Close(); err != nil {
-		if !errors.Is(err, context.Canceled) {
-			return err
+	select {
+	case event, ok := <-d.rowCH:
+		if !ok {
+			return nil, d.readErr
 		}
+		events = append(events, event)
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 
-	if err := d.rows.Err(); err != nil {
-		if !errors.Is(err, context.Canceled) {
-			return err
+	for len(events) < maxBatchRows {
+		select {
+		case event, ok := <-d.rowCH:
+			if !ok {
+				return events, nil
+			}
+			events = append(events, event)
+		default:
+			return events, nil
 		}
 	}
 
-	return d.conn.Close()
+	return events, nil
 }
 
-func isQueryCancelErr(err error) bool {
-	code, msg := parse(err)
-	return code == 394 && strings.Contains(msg, "Query was cancelled")
-}
+func (d *driver) scanRows(ctx context.Context, rows *sql.Rows, columnTypes []*sql.ColumnType, rowCH chan<- map[string]any) {
+	defer close(rowCH)
 
-func parse(err error) (int, string) {
-	var code int
-	var msg string
+	for rows.Next() {
+		count := len(columnTypes)
+
+		values := make([]any, count)
+		valuePtrs := make([]any, count)
+
+		for i := range columnTypes {
+			valuePtrs[i] = &values[i]
+		}
 
-	errStr := err.Error()
-	codeMatches := codeRe.FindStringSubmatch(errStr)
-	if len(codeMatches) == 2 {
-		code, _ = strconv.Atoi(codeMatches[1])
+		if err := rows.Scan(valuePtrs...); err != nil {
+			d.readErr = errclass.Classify(err)
+			return
+		}
+
+		event := make(map[string]any)
+		for i, col := range columnTypes {
+			event[col.Name()] = values[i]
+		}
+
+		select {
+		case rowCH <- event:
+		case <-ctx.Done():
+			d.readErr = ctx.Err()
+			return
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		// The caller decides whether to run the query again based on the class of the error, e.g. the query got
+		// cancelled because timeplusd is restarting or the checkpoint of the query is no longer available.
+		d.readErr = errclass.Classify(err)
+		return
 	}
 
-	msgMatches := msgRe.FindStringSubmatch(errStr)
-	if len(msgMatches) == 2 {
-		msg = msgMatches[1]
+	d.readErr = io.EOF
+}
+
+// Close terminates the running query.
+func (d *driver) Close(context.Context) error {
+	if err := d.stop(); err != nil {
+		return err
 	}
 
-	return code, msg
+	return d.conn.Close()
 }
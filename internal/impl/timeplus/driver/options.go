@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"time"
+
+	protonDriver "github.com/timeplus-io/proton-go-driver/v2"
+)
+
+// Options configures the connections of the native driver.
+type Options struct {
+	// TLS enables TLS when not nil.
+	TLS         *tls.Config
+	DialTimeout time.Duration
+	// MaxIdleConns and MaxOpenConns are left to the driver defaults when <= 0.
+	MaxIdleConns int
+	MaxOpenConns int
+	// ConnMaxIdleTime only applies to database/sql connection pools, idle connections are not closed when <= 0.
+	ConnMaxIdleTime time.Duration
+}
+
+func (o Options) protonOptions(addr, username, password string) *protonDriver.Options {
+	return &protonDriver.Options{
+		Addr: []string{addr},
+		Auth: protonDriver.Auth{
+			Username: username,
+			Password: password,
+		},
+		TLS:         o.TLS,
+		DialTimeout: o.DialTimeout,
+	}
+}
+
+// openDB opens a database/sql connection pool. The pool settings MUST be applied to the returned `sql.DB` since
+// `OpenDB` rejects them as options.
+func (o Options) openDB(addr, username, password string) *sql.DB {
+	db := protonDriver.OpenDB(o.protonOptions(addr, username, password))
+	if o.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+	}
+	return db
+}
+
+// open opens a native connection pool.
+func (o Options) open(addr, username, password string) (protonDriver.Conn, error) {
+	opts := o.protonOptions(addr, username, password)
+	opts.MaxIdleConns = o.MaxIdleConns
+	opts.MaxOpenConns = o.MaxOpenConns
+	return protonDriver.Open(opts)
+}
@@ -0,0 +1,80 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	protonDriver "github.com/timeplus-io/proton-go-driver/v2"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
+)
+
+type querier struct {
+	logger *service.Logger
+	conn   *sql.DB
+}
+
+// NewQuerier creates a new proton querier which runs terminating queries, the connections are pooled and shared by
+// concurrent queries.
+func NewQuerier(logger *service.Logger, addr, username, password string, opts Options) *querier {
+	conn := opts.openDB(addr, username, password)
+
+	logger.With("host", addr).Info("timeplus native querier created")
+
+	return &querier{
+		logger: logger,
+		conn:   conn,
+	}
+}
+
+// Query runs `sql` and returns all rows. It blocks until the query terminates, so it MUST NOT be used for streaming
+// queries.
+func (q *querier) Query(ctx context.Context, sql string) ([]map[string]any, error) {
+	rows, err := q.conn.QueryContext(protonDriver.Context(ctx), sql)
+	if err != nil {
+		return nil, errclass.Classify(err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []map[string]any
+	for rows.Next() {
+		values := make([]any, len(columnTypes))
+		valuePtrs := make([]any, len(columnTypes))
+		for i := range columnTypes {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		event := make(map[string]any, len(columnTypes))
+		for i, col := range columnTypes {
+			event[col.Name()] = values[i]
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errclass.Classify(err)
+	}
+
+	return events, nil
+}
+
+// Exec runs `sql` and discards its result, e.g. DDL statements.
+func (q *querier) Exec(ctx context.Context, sql string) error {
+	_, err := q.conn.ExecContext(protonDriver.Context(ctx), sql)
+	return errclass.Classify(err)
+}
+
+// Close closes all connections of the pool.
+func (q *querier) Close(context.Context) error {
+	return q.conn.Close()
+}
@@ -0,0 +1,124 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	protonDriver "github.com/timeplus-io/proton-go-driver/v2"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/schema"
+)
+
+type writer struct {
+	logger *service.Logger
+	conn   protonDriver.Conn
+	stream string
+}
+
+// NewWriter creates a new proton writer which inserts rows into `stream` via native columnar batches.
+func NewWriter(logger *service.Logger, addr, username, password, stream string, opts Options) (*writer, error) {
+	conn, err := opts.open(addr, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.With("host", addr).With("stream", stream).Info("timeplus native writer created")
+
+	return &writer{
+		logger: logger,
+		conn:   conn,
+		stream: stream,
+	}, nil
+}
+
+// Write inserts all rows in a single native batch. Each row MUST contain the values of `cols` in the same order.
+func (w *writer) Write(ctx context.Context, cols []string, rows [][]any) error {
+	batch, err := w.conn.PrepareBatch(ctx, insertSQL(w.stream, cols))
+	if err != nil {
+		return errclass.Classify(err)
+	}
+
+	for i, row := range rows {
+		if err := batch.Append(row...); err != nil {
+			_ = batch.Abort()
+			// The row cannot be converted into the column types, sending it again fails as well
+			return &errclass.Error{Class: errclass.Fatal, Err: fmt.Errorf("failed to append row %d: %w", i, err)}
+		}
+	}
+
+	return errclass.Classify(batch.Send())
+}
+
+// Describe fetches the columns of the stream via `DESCRIBE`.
+func (w *writer) Describe(ctx context.Context) ([]schema.Column, error) {
+	rows, err := w.conn.Query(ctx, "DESCRIBE "+quoteIdentifier(w.stream))
+	if err != nil {
+		if errclass.CodeOf(err) == errclass.CodeUnknownTable {
+			return nil, fmt.Errorf("stream %s: %w", w.stream, schema.ErrStreamNotFound)
+		}
+		return nil, errclass.Classify(err)
+	}
+	defer rows.Close()
+
+	var cols []schema.Column
+	for rows.Next() {
+		// name, type, default_type, default_expression, comment, codec_expression, ttl_expression
+		values := make([]string, len(rows.Columns()))
+		valuePtrs := make([]any, len(values))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		if len(values) < 4 {
+			return nil, fmt.Errorf("unexpected DESCRIBE result with %d columns", len(values))
+		}
+
+		cols = append(cols, schema.NewColumn(values[0], values[1], values[2], values[3]))
+	}
+
+	return cols, rows.Err()
+}
+
+// CreateStream creates the stream with `cols` if it does not exist yet.
+func (w *writer) CreateStream(ctx context.Context, cols []schema.Column) error {
+	defs := make([]string, len(cols))
+	for i, col := range cols {
+		defs[i] = quoteIdentifier(col.Name) + " " + col.Type
+	}
+
+	return errclass.Classify(w.conn.Exec(ctx, fmt.Sprintf("CREATE STREAM IF NOT EXISTS %s (%s)", quoteIdentifier(w.stream), strings.Join(defs, ", "))))
+}
+
+// AddColumns adds `cols` to the stream, columns which already exist are ignored.
+func (w *writer) AddColumns(ctx context.Context, cols []schema.Column) error {
+	defs := make([]string, len(cols))
+	for i, col := range cols {
+		defs[i] = "ADD COLUMN IF NOT EXISTS " + quoteIdentifier(col.Name) + " " + col.Type
+	}
+
+	return errclass.Classify(w.conn.Exec(ctx, fmt.Sprintf("ALTER STREAM %s %s", quoteIdentifier(w.stream), strings.Join(defs, ", "))))
+}
+
+// Close closes all connections of the writer.
+func (w *writer) Close(context.Context) error {
+	return w.conn.Close()
+}
+
+func insertSQL(stream string, cols []string) string {
+	quoted := make([]string, len(cols))
+	for i, col := range cols {
+		quoted[i] = quoteIdentifier(col)
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES", quoteIdentifier(stream), strings.Join(quoted, ", "))
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "\\`") + "`"
+}
@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	protonlib "github.com/timeplus-io/proton-go-driver/v2/lib/driver"
+	"github.com/timeplus-io/proton-go-driver/v2/lib/proto"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/schema"
+)
+
+type fakeBatch struct {
+	protonlib.Batch
+
+	rows      [][]any
+	appendErr error
+	sendErr   error
+	aborted   bool
+	sent      bool
+}
+
+func (b *fakeBatch) Append(v ...any) error {
+	if b.appendErr != nil && len(b.rows) == 1 {
+		return b.appendErr
+	}
+	b.rows = append(b.rows, v)
+	return nil
+}
+
+func (b *fakeBatch) Abort() error {
+	b.aborted = true
+	return nil
+}
+
+func (b *fakeBatch) Send() error {
+	b.sent = true
+	return b.sendErr
+}
+
+type fakeConn struct {
+	protonlib.Conn
+
+	queries    []string
+	batch      *fakeBatch
+	prepareErr error
+}
+
+func (c *fakeConn) PrepareBatch(_ context.Context, query string) (protonlib.Batch, error) {
+	c.queries = append(c.queries, query)
+	if c.prepareErr != nil {
+		return nil, c.prepareErr
+	}
+	return c.batch, nil
+}
+
+func (c *fakeConn) Exec(_ context.Context, query string, _ ...any) error {
+	c.queries = append(c.queries, query)
+	return nil
+}
+
+func newFakeWriter(conn *fakeConn) *writer {
+	return &writer{
+		logger: service.MockResources().Logger(),
+		conn:   conn,
+		stream: "my`stream",
+	}
+}
+
+func TestInsertSQL(t *testing.T) {
+	assert.Equal(t, "INSERT INTO `mystream` (`a`, `b c`) VALUES", insertSQL("mystream", []string{"a", "b c"}))
+	assert.Equal(t, "INSERT INTO `my\\`stream` (`a\\`b`) VALUES", insertSQL("my`stream", []string{"a`b"}))
+}
+
+func TestWriterWrite(t *testing.T) {
+	ctx := context.Background()
+	rows := [][]any{{"a", int64(1)}, {"b", int64(2)}, {"c", int64(3)}}
+
+	t.Run("rows are sent in a single batch", func(t *testing.T) {
+		conn := &fakeConn{batch: &fakeBatch{}}
+
+		require.NoError(t, newFakeWriter(conn).Write(ctx, []string{"name", "value"}, rows))
+
+		assert.Equal(t, []string{"INSERT INTO `my\\`stream` (`name`, `value`) VALUES"}, conn.queries)
+		assert.Equal(t, rows, conn.batch.rows)
+		assert.True(t, conn.batch.sent)
+		assert.False(t, conn.batch.aborted)
+	})
+
+	t.Run("batch is aborted when a row cannot be appended", func(t *testing.T) {
+		conn := &fakeConn{batch: &fakeBatch{appendErr: errors.New("cannot convert")}}
+
+		err := newFakeWriter(conn).Write(ctx, []string{"name", "value"}, rows)
+		require.Error(t, err)
+		assert.Equal(t, errclass.Fatal, errclass.ClassOf(err))
+		assert.Contains(t, err.Error(), "failed to append row 1: cannot convert")
+
+		assert.True(t, conn.batch.aborted)
+		assert.False(t, conn.batch.sent)
+	})
+
+	t.Run("errors of the server are classified", func(t *testing.T) {
+		conn := &fakeConn{prepareErr: &proto.Exception{Code: errclass.CodeUnknownTable, Message: "unknown stream"}}
+
+		err := newFakeWriter(conn).Write(ctx, []string{"name"}, [][]any{{"a"}})
+		require.Error(t, err)
+		assert.Equal(t, errclass.Fatal, errclass.ClassOf(err))
+
+		conn = &fakeConn{batch: &fakeBatch{sendErr: &proto.Exception{Code: errclass.CodeQueryWasCancelled, Message: "cancelled"}}}
+
+		err = newFakeWriter(conn).Write(ctx, []string{"name"}, [][]any{{"a"}})
+		require.Error(t, err)
+		assert.Equal(t, errclass.QueryCancelled, errclass.ClassOf(err))
+	})
+}
+
+func TestWriterSchemaStatements(t *testing.T) {
+	ctx := context.Background()
+	conn := &fakeConn{}
+	w := newFakeWriter(conn)
+
+	cols := []schema.Column{
+		schema.NewColumn("a", "string", "", ""),
+		schema.NewColumn("b", "nullable(float64)", "", ""),
+	}
+	require.NoError(t, w.CreateStream(ctx, cols))
+	require.NoError(t, w.AddColumns(ctx, cols[1:]))
+
+	assert.Equal(t, []string{
+		"CREATE STREAM IF NOT EXISTS `my\\`stream` (`a` string, `b` nullable(float64))",
+		"ALTER STREAM `my\\`stream` ADD COLUMN IF NOT EXISTS `b` nullable(float64)",
+	}, conn.queries)
+}
@@ -0,0 +1,209 @@
+// Package errclass classifies the errors returned by Timeplus, either via the native protocol or via HTTP, so that
+// the components can decide whether to retry, reconnect or give up.
+package errclass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"syscall"
+
+	"github.com/timeplus-io/proton-go-driver/v2/lib/proto"
+)
+
+// Class is the class of a Timeplus error.
+type Class int
+
+const (
+	// Retriable errors are transient, e.g. network errors or an overloaded server. The operation should be retried.
+	Retriable Class = iota
+	// Fatal errors are caused by the request itself, e.g. a syntax error or an unknown stream. Retrying the same
+	// request fails again.
+	Fatal
+	// QueryCancelled errors are returned when the server cancels a running query, most likely because it is
+	// restarting. The query should be run again once the server recovers.
+	QueryCancelled
+	// CheckpointInvalidated errors are returned when the checkpoint of a query is no longer available, e.g. after a
+	// materialized view has been re-created. The query should be run again.
+	CheckpointInvalidated
+	// Auth errors are caused by invalid credentials or missing permissions.
+	Auth
+)
+
+// String returns the name of the class, which is also used as the value of the `class` metric label.
+func (c Class) String() string {
+	switch c {
+	case Retriable:
+		return "retriable"
+	case Fatal:
+		return "fatal"
+	case QueryCancelled:
+		return "query_cancelled"
+	case CheckpointInvalidated:
+		return "checkpoint_invalidated"
+	case Auth:
+		return "auth"
+	}
+	return "unknown"
+}
+
+// Server error codes, see https://github.com/timeplus-io/proton/blob/develop/src/Common/ErrorCodes.cpp
+const (
+	CodeCannotParseText       = 6
+	CodeNoSuchColumnInTable   = 16
+	CodeCannotParseInput      = 27
+	CodeBadArguments          = 36
+	CodeIllegalTypeOfArgument = 43
+	CodeIllegalColumn         = 44
+	CodeUnknownFunction       = 46
+	CodeUnknownIdentifier     = 47
+	CodeTypeMismatch          = 53
+	CodeUnknownTable          = 60
+	CodeSyntaxError           = 62
+	CodeUnknownDatabase       = 81
+	CodeUnknownUser           = 192
+	CodeWrongPassword         = 193
+	CodeRequiredPassword      = 194
+	CodeQueryWasCancelled     = 394
+	CodeAccessDenied          = 497
+	CodeAuthenticationFailed  = 516
+	CodeCheckpointInvalidated = 2003
+)
+
+var codeClasses = map[int]Class{
+	CodeCannotParseText:       Fatal,
+	CodeNoSuchColumnInTable:   Fatal,
+	CodeCannotParseInput:      Fatal,
+	CodeBadArguments:          Fatal,
+	CodeIllegalTypeOfArgument: Fatal,
+	CodeIllegalColumn:         Fatal,
+	CodeUnknownFunction:       Fatal,
+	CodeUnknownIdentifier:     Fatal,
+	CodeTypeMismatch:          Fatal,
+	CodeUnknownTable:          Fatal,
+	CodeSyntaxError:           Fatal,
+	CodeUnknownDatabase:       Fatal,
+	CodeUnknownUser:           Auth,
+	CodeWrongPassword:         Auth,
+	CodeRequiredPassword:      Auth,
+	CodeAccessDenied:          Auth,
+	CodeAuthenticationFailed:  Auth,
+	CodeQueryWasCancelled:     QueryCancelled,
+	CodeCheckpointInvalidated: CheckpointInvalidated,
+}
+
+// Error is a classified Timeplus error.
+type Error struct {
+	Class Class
+	// Code is the server error code, or 0 if the error did not originate from the server.
+	Code int
+	// StatusCode is the HTTP status code, or 0 if the error did not originate from an HTTP response.
+	StatusCode int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var codeRe = regexp.MustCompile(`"?code"?\s*:\s*([0-9]+)`)
+
+// Classify wraps `err` into an `*Error` according to its server error code, or its cause if it did not originate
+// from the server. Errors which are already classified are returned as they are, and nil, `io.EOF` and context
+// errors are not classified at all.
+func Classify(err error) error {
+	if err == nil || errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	var cErr *Error
+	if errors.As(err, &cErr) {
+		return err
+	}
+
+	if code := serverCode(err); code != 0 {
+		return &Error{Class: classOfCode(code), Code: code, Err: err}
+	}
+
+	return &Error{Class: Retriable, Err: err}
+}
+
+// FromResponse creates an error from the status code and the body of a failed HTTP response. `op` describes the
+// operation which failed, e.g. "failed to ingest".
+func FromResponse(op string, statusCode int, body []byte) error {
+	err := &Error{
+		StatusCode: statusCode,
+		Err:        fmt.Errorf("%s, got status code %d, error %s", op, statusCode, body),
+	}
+
+	if code := serverCode(err.Err); code != 0 {
+		err.Code = code
+		err.Class = classOfCode(code)
+		return err
+	}
+
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		err.Class = Auth
+	case statusCode == http.StatusTooManyRequests || statusCode == http.StatusRequestTimeout || statusCode >= 500:
+		err.Class = Retriable
+	default:
+		err.Class = Fatal
+	}
+	return err
+}
+
+// ClassOf returns the class of `err`. Unclassified errors are considered retriable.
+func ClassOf(err error) Class {
+	var cErr *Error
+	if errors.As(err, &cErr) {
+		return cErr.Class
+	}
+	return Retriable
+}
+
+// CodeOf returns the server error code of `err`, or 0 if it has none.
+func CodeOf(err error) int {
+	var cErr *Error
+	if errors.As(err, &cErr) && cErr.Code != 0 {
+		return cErr.Code
+	}
+	return serverCode(err)
+}
+
+// IsConnectionError returns true if `err` is caused by an unreachable server.
+func IsConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, os.ErrDeadlineExceeded) || errors.As(err, &netErr)
+}
+
+func serverCode(err error) int {
+	var exception *proto.Exception
+	if errors.As(err, &exception) {
+		return int(exception.Code)
+	}
+
+	// Errors returned via database/sql or HTTP only contain the code in their message, e.g. `code: 60` or `"code":60`
+	if matches := codeRe.FindStringSubmatch(err.Error()); len(matches) == 2 {
+		code, _ := strconv.Atoi(matches[1])
+		return code
+	}
+	return 0
+}
+
+func classOfCode(code int) Class {
+	if class, exists := codeClasses[code]; exists {
+		return class
+	}
+	return Retriable
+}
@@ -0,0 +1,129 @@
+package errclass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/timeplus-io/proton-go-driver/v2/lib/proto"
+)
+
+func TestClassify(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		err   error
+		class Class
+		code  int
+	}{
+		{
+			name:  "exception",
+			err:   &proto.Exception{Code: 62, Message: "Syntax error"},
+			class: Fatal,
+			code:  CodeSyntaxError,
+		},
+		{
+			name:  "wrapped exception",
+			err:   fmt.Errorf("failed: %w", &proto.Exception{Code: 516, Message: "Authentication failed"}),
+			class: Auth,
+			code:  CodeAuthenticationFailed,
+		},
+		{
+			name:  "query cancelled",
+			err:   errors.New("code: 394, message: Query was cancelled"),
+			class: QueryCancelled,
+			code:  CodeQueryWasCancelled,
+		},
+		{
+			name:  "checkpoint invalidated",
+			err:   errors.New("code: 2003, message: checkpoint is no longer available"),
+			class: CheckpointInvalidated,
+			code:  CodeCheckpointInvalidated,
+		},
+		{
+			name:  "unknown code",
+			err:   errors.New("code: 241, message: Memory limit exceeded"),
+			class: Retriable,
+			code:  241,
+		},
+		{
+			name:  "connection refused",
+			err:   fmt.Errorf("dial: %w", syscall.ECONNREFUSED),
+			class: Retriable,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := Classify(test.err)
+
+			var cErr *Error
+			require.ErrorAs(t, err, &cErr)
+			assert.Equal(t, test.class, cErr.Class)
+			assert.Equal(t, test.code, cErr.Code)
+			assert.Equal(t, test.class, ClassOf(err))
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+
+	for _, err := range []error{nil, io.EOF, context.Canceled} {
+		assert.Equal(t, err, Classify(err))
+	}
+
+	classified := Classify(errors.New("code: 62"))
+	assert.Same(t, classified, Classify(classified))
+}
+
+func TestFromResponse(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status int
+		body   string
+		class  Class
+		code   int
+	}{
+		{
+			name:   "unauthorized",
+			status: 401,
+			body:   "unauthorized",
+			class:  Auth,
+		},
+		{
+			name:   "server error",
+			status: 503,
+			body:   "unavailable",
+			class:  Retriable,
+		},
+		{
+			name:   "too many requests",
+			status: 429,
+			class:  Retriable,
+		},
+		{
+			name:   "bad request",
+			status: 400,
+			body:   "invalid payload",
+			class:  Fatal,
+		},
+		{
+			name:   "server code",
+			status: 500,
+			body:   `{"code":60,"error_msg":"Stream default.foo doesn't exist"}`,
+			class:  Fatal,
+			code:   CodeUnknownTable,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := FromResponse("failed to ingest", test.status, []byte(test.body))
+
+			var cErr *Error
+			require.ErrorAs(t, err, &cErr)
+			assert.Equal(t, test.class, cErr.Class)
+			assert.Equal(t, test.code, cErr.Code)
+			assert.Equal(t, test.status, cErr.StatusCode)
+			assert.EqualError(t, err, fmt.Sprintf("failed to ingest, got status code %d, error %s", test.status, test.body))
+		})
+	}
+}
@@ -0,0 +1,5 @@
+package timeplus
+
+// metricErrors counts the errors returned by Timeplus, labelled by their `class`, i.e. `retriable`, `fatal`,
+// `query_cancelled`, `checkpoint_invalidated` or `auth`.
+const metricErrors = "timeplus_errors"
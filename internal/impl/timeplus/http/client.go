@@ -2,17 +2,21 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"reflect"
 	"time"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/schema"
 )
 
 const (
@@ -24,14 +28,34 @@ const (
 
 	// TargetTimeplusd is the `target` option that represents timeplusd (or proton)
 	TargetTimeplusd string = "timeplusd"
+
+	ingestTimeLayout = "2006-01-02 15:04:05.999999999"
 )
 
 // Client is the Timeplus Enterprise HTTP client. Always use `NewClient` to create it.
 type Client struct {
-	logger    *service.Logger
-	ingestURL *url.URL
-	header    http.Header
-	client    *http.Client
+	logger     *service.Logger
+	stream     string
+	ingestURL  *url.URL
+	streamsURL *url.URL
+	header     http.Header
+	client     *http.Client
+	gzip       bool
+}
+
+type tpStreams struct {
+	Data []tpStream `json:"data"`
+}
+
+type tpStream struct {
+	Name    string     `json:"name"`
+	Columns []tpColumn `json:"columns"`
+}
+
+type tpColumn struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default,omitempty"`
 }
 
 type tpIngest struct {
@@ -40,13 +64,16 @@ type tpIngest struct {
 }
 
 // NewClient creates a new Timeplus Enterprise HTTP client
-func NewClient(logger *service.Logger, target string, baseURL *url.URL, workspace, stream, apikey, username, password string) *Client {
+func NewClient(logger *service.Logger, target string, baseURL *url.URL, workspace, stream, apikey, username, password string, opts Options) *Client {
 	ingestURL, _ := url.Parse(baseURL.String())
+	streamsURL, _ := url.Parse(baseURL.String())
 
 	if target == TargetTimeplus {
 		ingestURL.Path = path.Join(ingestURL.Path, workspace, "api", timeplusAPIVersion, "streams", stream, "ingest")
+		streamsURL.Path = path.Join(streamsURL.Path, workspace, "api", timeplusAPIVersion, "streams")
 	} else if target == TargetTimeplusd {
 		ingestURL.Path = path.Join(ingestURL.Path, "timeplusd", timeplusdDAPIVersion, "ingest", "streams", stream)
+		streamsURL.Path = path.Join(streamsURL.Path, "timeplusd", timeplusdDAPIVersion, "ddl", "streams")
 	}
 
 	logger = logger.With("target", TargetTimeplusd).With("host", ingestURL.Host).With("ingest_url", ingestURL.RequestURI())
@@ -54,27 +81,22 @@ func NewClient(logger *service.Logger, target string, baseURL *url.URL, workspac
 
 	return &Client{
 		logger,
+		stream,
 		ingestURL,
+		streamsURL,
 		NewHeader(apikey, username, password),
-		newDefaultClient(),
+		newClient(opts, false),
+		opts.Gzip,
 	}
 }
 
-// We may want to allow the user to configure this in the future. But for now, the default option should be fine.
-func newDefaultClient() *http.Client {
-	// We may want to allow the user to configure this in the future. But for now, the default option should be fine.
-	return &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: 10 * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout: 10 * time.Second,
-		},
+func (c *Client) Write(ctx context.Context, cols []string, rows [][]any) error {
+	for _, row := range rows {
+		for i, v := range row {
+			row[i] = jsonValue(v)
+		}
 	}
-}
 
-func (c *Client) Write(ctx context.Context, cols []string, rows [][]any) error {
 	payload := tpIngest{
 		Columns: cols,
 		Data:    rows,
@@ -85,26 +107,188 @@ func (c *Client) Write(ctx context.Context, cols []string, rows [][]any) error {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.ingestURL.String(), bytes.NewBuffer(payloadBytes))
+	body := bytes.NewBuffer(payloadBytes)
+	if c.gzip {
+		body = new(bytes.Buffer)
+		zw := gzip.NewWriter(body)
+		if _, err := zw.Write(payloadBytes); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.ingestURL.String(), body)
 	if err != nil {
 		return err
 	}
 	req.Header = c.header
+	if c.gzip {
+		req.Header = c.header.Clone()
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return errclass.Classify(err)
 	}
 
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		errorBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to ingest, got status code %d", resp.StatusCode)
+		errorBody, _ := io.ReadAll(resp.Body)
+		return errclass.FromResponse("failed to ingest", resp.StatusCode, errorBody)
+	}
+
+	return nil
+}
+
+// Describe fetches the columns of the stream via the stream API.
+func (c *Client) Describe(ctx context.Context) ([]schema.Column, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.streamsURL.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = c.header
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errclass.Classify(err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, errclass.FromResponse("failed to list streams", resp.StatusCode, body)
+	}
+
+	// Timeplus Enterprise returns the list of streams directly while timeplusd wraps it in `data`
+	var streams []tpStream
+	if err := json.Unmarshal(body, &streams); err != nil {
+		var wrapped tpStreams
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to decode streams: %w", err)
 		}
+		streams = wrapped.Data
+	}
 
-		return fmt.Errorf("failed to ingest, got status code %d, error %s", resp.StatusCode, errorBody)
+	for _, stream := range streams {
+		if stream.Name != c.stream {
+			continue
+		}
+
+		cols := make([]schema.Column, 0, len(stream.Columns))
+		for _, col := range stream.Columns {
+			var defaultKind string
+			if len(col.Default) > 0 {
+				defaultKind = schema.DefaultKindDefault
+			}
+			cols = append(cols, schema.NewColumn(col.Name, col.Type, defaultKind, col.Default))
+		}
+		return cols, nil
 	}
 
+	return nil, fmt.Errorf("stream %s: %w", c.stream, schema.ErrStreamNotFound)
+}
+
+// CreateStream creates the stream with `cols` via the stream API.
+func (c *Client) CreateStream(ctx context.Context, cols []schema.Column) error {
+	payload := tpStream{
+		Name:    c.stream,
+		Columns: make([]tpColumn, len(cols)),
+	}
+	for i, col := range cols {
+		payload.Columns[i] = tpColumn{Name: col.Name, Type: col.Type}
+	}
+
+	return c.post(ctx, c.streamsURL, payload)
+}
+
+// AddColumns adds `cols` to the stream via the stream API.
+func (c *Client) AddColumns(ctx context.Context, cols []schema.Column) error {
+	columnsURL := *c.streamsURL
+	columnsURL.Path = path.Join(columnsURL.Path, c.stream, "columns")
+
+	for _, col := range cols {
+		if err := c.post(ctx, &columnsURL, tpColumn{Name: col.Name, Type: col.Type}); err != nil {
+			return fmt.Errorf("failed to add column %s: %w", col.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, u *url.URL, payload any) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewBuffer(payloadBytes))
+	if err != nil {
+		return err
+	}
+	req.Header = c.header
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errclass.Classify(err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errorBody, _ := io.ReadAll(resp.Body)
+		return errclass.FromResponse("request failed", resp.StatusCode, errorBody)
+	}
+
+	return nil
+}
+
+// Close releases idle connections held by the underlying HTTP client.
+func (c *Client) Close(context.Context) error {
+	c.client.CloseIdleConnections()
 	return nil
 }
+
+// jsonValue converts timestamps, which are produced by schema coercion, into the format accepted by the ingest API.
+func jsonValue(v any) any {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC().Format(ingestTimeLayout)
+	case *time.Time:
+		if t == nil {
+			return nil
+		}
+		return t.UTC().Format(ingestTimeLayout)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = jsonValue(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		out := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = jsonValue(iter.Value().Interface())
+		}
+		return out
+	}
+
+	return v
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Options configures the HTTP clients.
+type Options struct {
+	// TLS overrides the default TLS settings when not nil.
+	TLS *tls.Config
+	// Timeout limits requests, streaming queries are only limited until the response headers are received.
+	Timeout  time.Duration
+	ProxyURL *url.URL
+	// Gzip compresses the request bodies of ingest requests.
+	Gzip            bool
+	MaxIdleConns    int
+	MaxOpenConns    int
+	IdleConnTimeout time.Duration
+}
+
+// newClient creates an HTTP client. The timeout of `streaming` clients only applies to the dial, the TLS handshake
+// and waiting for the response headers, otherwise long running queries would be aborted.
+func newClient(opts Options, streaming bool) *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: opts.Timeout,
+		}).DialContext,
+		TLSClientConfig:     opts.TLS,
+		TLSHandshakeTimeout: opts.Timeout,
+		MaxIdleConns:        opts.MaxIdleConns,
+		MaxIdleConnsPerHost: opts.MaxIdleConns,
+		MaxConnsPerHost:     opts.MaxOpenConns,
+		IdleConnTimeout:     opts.IdleConnTimeout,
+		ForceAttemptHTTP2:   true,
+	}
+	if opts.ProxyURL != nil {
+		transport.Proxy = http.ProxyURL(opts.ProxyURL)
+	}
+
+	client := &http.Client{Transport: transport}
+	if streaming {
+		transport.ResponseHeaderTimeout = opts.Timeout
+	} else {
+		client.Timeout = opts.Timeout
+	}
+	return client
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Querier runs terminating queries via the Timeplus Enterprise query API. Always use `NewQuerier` to create it.
+type Querier struct {
+	logger   *service.Logger
+	header   http.Header
+	queryURL *url.URL
+	client   *http.Client
+}
+
+// NewQuerier creates a Timeplus Enterprise querier. Each query is consumed via SSE until the server closes the stream.
+func NewQuerier(logger *service.Logger, baseURL *url.URL, workspace, apikey, username, password string, opts Options) *Querier {
+	queryURL, _ := url.Parse(baseURL.String())
+
+	queryURL.Path = path.Join(queryURL.Path, workspace, "api", timeplusAPIVersion, "queries")
+
+	logger.With("host", queryURL.Host).With("query_url", queryURL.RequestURI()).Debug("new http querier created")
+
+	return &Querier{
+		logger:   logger,
+		header:   NewHeader(apikey, username, password),
+		queryURL: queryURL,
+		client:   newClient(opts, true),
+	}
+}
+
+// Query runs `sql` and returns all rows. It blocks until the query terminates, so it MUST NOT be used for streaming
+// queries.
+func (q *Querier) Query(ctx context.Context, sql string) ([]map[string]any, error) {
+	c := q.newSSEClient()
+	if err := c.run(ctx, sql); err != nil {
+		return nil, err
+	}
+	defer c.Close(ctx)
+
+	var events []map[string]any
+	for {
+		rows, err := c.Read(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return events, nil
+			}
+			return nil, err
+		}
+		events = append(events, rows...)
+	}
+}
+
+// Exec runs `sql` and discards its result, e.g. DDL statements.
+func (q *Querier) Exec(ctx context.Context, sql string) error {
+	_, err := q.Query(ctx, sql)
+	return err
+}
+
+// Close releases idle connections held by the underlying HTTP client.
+func (q *Querier) Close(context.Context) error {
+	q.client.CloseIdleConnections()
+	return nil
+}
+
+func (q *Querier) newSSEClient() *sseClient {
+	return &sseClient{
+		header:   q.header,
+		queryURL: q.queryURL,
+		client:   q.client,
+		logger:   q.logger,
+	}
+}
@@ -12,19 +12,19 @@ import (
 	"path"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
 )
 
 type sseClient struct {
 	header   http.Header
 	queryURL *url.URL
-	reader   *eventStreamReader
 	cols     []col
-	eventCH  chan []any
+	eventCH  chan [][]any
 	readErr  error
 	client   *http.Client
 	logger   *service.Logger
 
-	ctx    context.Context
 	cancel context.CancelFunc
 }
 
@@ -42,8 +42,8 @@ type col struct {
 }
 
 // NewSSEClient creates a Timeplus Enterprise SSE client.
-// Since each SSE event could contain multiple messages, we should implement this as a BatchInput in the future.
-func NewSSEClient(logger *service.Logger, baseURL *url.URL, workspace, apikey, username, password string) *sseClient {
+// Each SSE event could contain multiple rows, which are returned together by `Read`.
+func NewSSEClient(logger *service.Logger, baseURL *url.URL, workspace, apikey, username, password string, opts Options) *sseClient {
 	queryURL, _ := url.Parse(baseURL.String())
 
 	queryURL.Path = path.Join(queryURL.Path, workspace, "api", timeplusAPIVersion, "queries")
@@ -53,13 +53,22 @@ func NewSSEClient(logger *service.Logger, baseURL *url.URL, workspace, apikey, u
 	return &sseClient{
 		header:   NewHeader(apikey, username, password),
 		queryURL: queryURL,
-		eventCH:  make(chan []any),
-		client:   newDefaultClient(),
+		eventCH:  make(chan [][]any),
+		client:   newClient(opts, true),
 		logger:   logger,
 	}
 }
 
+// Run starts a query which is consumed until the client is closed, regardless of the context of its caller.
 func (c *sseClient) Run(sql string) error {
+	return c.run(context.Background(), sql)
+}
+
+// run starts a query which is cancelled once either the client is closed or ctx is done. The query that is already
+// running, if any, is terminated first.
+func (c *sseClient) run(ctx context.Context, sql string) (err error) {
+	c.stop()
+
 	payload := map[string]string{
 		"sql": sql,
 	}
@@ -69,9 +78,14 @@ func (c *sseClient) Run(sql string) error {
 		return err
 	}
 
-	c.ctx, c.cancel = context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
 
-	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.queryURL.String(), body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.queryURL.String(), body)
 	if err != nil {
 		return err
 	}
@@ -80,36 +94,42 @@ func (c *sseClient) Run(sql string) error {
 	//nolint
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return err
+		return errclass.Classify(err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errorBody, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return fmt.Errorf("failed to run query, got status code %d", resp.StatusCode)
+		return errclass.FromResponse("failed to run query", resp.StatusCode, errorBody)
 	}
 
-	c.reader = newEventStreamReader(resp.Body, 1024*1024)
-	cols, err := c.readQueryMeta()
+	reader := newEventStreamReader(resp.Body, 1024*1024)
+	cols, err := readQueryMeta(reader)
 	if err != nil {
 		resp.Body.Close()
 		return err
 	}
+
+	eventCH := make(chan [][]any)
+	c.cancel = cancel
 	c.cols = cols
+	c.eventCH = eventCH
+	c.readErr = nil
 
 	go func() {
 		defer func() {
 			resp.Body.Close()
-			close(c.eventCH)
+			close(eventCH)
 		}()
 
 		for {
-			ev, err := c.reader.ReadEvent()
+			ev, err := reader.ReadEvent()
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					return
 				}
 
-				c.readErr = err
+				c.readErr = errclass.Classify(err)
 				return
 			}
 
@@ -121,8 +141,14 @@ func (c *sseClient) Run(sql string) error {
 					return
 				}
 
-				for _, ev := range events {
-					c.eventCH <- ev
+				if len(events) == 0 {
+					continue
+				}
+
+				select {
+				case eventCH <- events:
+				case <-ctx.Done():
+					return
 				}
 			default:
 				continue
@@ -133,42 +159,66 @@ func (c *sseClient) Run(sql string) error {
 	return nil
 }
 
-func (c *sseClient) Read(ctx context.Context) (map[string]any, error) {
-	if c.readErr != nil {
-		return nil, c.readErr
+// ColumnTypes returns the types of the columns of the running query.
+func (c *sseClient) ColumnTypes() map[string]string {
+	types := make(map[string]string, len(c.cols))
+	for _, col := range c.cols {
+		types[col.Name] = col.Type
 	}
+	return types
+}
 
+// Read reads all rows of the next SSE event.
+func (c *sseClient) Read(ctx context.Context) ([]map[string]any, error) {
 	select {
-	case event, ok := <-c.eventCH:
+	case events, ok := <-c.eventCH:
 		if !ok {
-			return nil, nil
+			if c.readErr != nil {
+				return nil, c.readErr
+			}
+			return nil, io.EOF
 		}
 
-		if len(event) != len(c.cols) {
-			return nil, fmt.Errorf("rows in cols %d doesn't match cols in header %d", len(event), len(c.cols))
-		}
-		msg := map[string]any{}
+		msgs := make([]map[string]any, 0, len(events))
+		for _, event := range events {
+			if len(event) != len(c.cols) {
+				return nil, fmt.Errorf("rows in cols %d doesn't match cols in header %d", len(event), len(c.cols))
+			}
 
-		for i := range event {
-			msg[c.cols[i].Name] = event[i]
+			msg := map[string]any{}
+			for i := range event {
+				msg[c.cols[i].Name] = event[i]
+			}
+			msgs = append(msgs, msg)
 		}
 
-		return msg, nil
+		return msgs, nil
 	case <-ctx.Done():
-		return nil, nil
-	default:
-		return nil, c.readErr
+		return nil, ctx.Err()
 	}
 }
 
 func (c *sseClient) Close(context.Context) error {
-	c.cancel()
+	c.stop()
 
 	return nil
 }
 
-func (c *sseClient) readQueryMeta() ([]col, error) {
-	ev, err := c.reader.ReadEvent()
+// stop terminates the running query, if any, and waits for the events which are still being read to be dropped.
+func (c *sseClient) stop() {
+	if c.cancel == nil {
+		return
+	}
+
+	c.cancel()
+	for range c.eventCH {
+	}
+
+	c.cancel = nil
+}
+
+func readQueryMeta(reader *eventStreamReader) ([]col, error) {
+	ev, err := reader.ReadEvent()
 	if err != nil {
 		return nil, err
 	}
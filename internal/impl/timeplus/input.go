@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"syscall"
+	"sync"
+	"time"
 
+	"github.com/Jeffail/checkpoint"
+	"github.com/cenkalti/backoff/v4"
 	"github.com/redpanda-data/benthos/v4/public/service"
 
 	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/driver"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
 	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/http"
+	"github.com/redpanda-data/connect/v4/internal/retries"
 )
 
 var inputConfigSpec *service.ConfigSpec
@@ -24,7 +28,27 @@ func init() {
 This input can execute a query on Timeplus Enterprise Cloud, Timeplus Enterprise (self-hosted) or Timeplusd. A structured message will be created
 from each row received.
 
-If it is a streaming query, this input will keep running until the query is terminated. If it is a table query, this input will shut down once the rows from the query are exhausted.`).
+If it is a streaming query, this input will keep running until the query is terminated. If it is a table query, this input will shut down once the rows from the query are exhausted.
+
+The rows which are received together, i.e. the rows of one SSE event or of one native block, are emitted as a single batch. A `+"`batching`"+` policy can be configured to combine them into larger batches.
+
+== Metadata
+
+This input adds the following metadata fields to each message:
+
+- column_types: An object mapping the name of each column to its Timeplus data type, e.g. `+"`@column_types.temperature`"+`.
+
+== Resuming streaming queries
+
+If `+"`checkpoint_cache`"+` is set, the position of the latest row that has been successfully delivered is stored in the cache resource. When the input restarts (or reconnects), the query is rewritten with the `+"`seek_to`"+` setting so that it resumes right after this row with at-least-once semantics. The position is derived from the `+"`_tp_sn`"+` column of the rows if the query selects it, otherwise from the `+"`_tp_time`"+` column. Rows that contain neither of them are not checkpointed.
+
+Sequence numbers are only meaningful for streams with a single shard. For streams with multiple shards, select `+"`_tp_time`"+` instead, in which case rows sharing the event time of the checkpoint are delivered again.
+
+== Error handling
+
+Errors returned by Timeplus are classified as `+"`retriable`"+` (e.g. network errors), `+"`fatal`"+` (e.g. syntax errors or unknown streams), `+"`query_cancelled`"+` (e.g. timeplusd is restarting), `+"`checkpoint_invalidated`"+` (e.g. a materialized view has been re-created) or `+"`auth`"+`. The query is run again after a delay controlled by the `+"`backoff`"+` fields, and the errors are counted by the `+"`timeplus_errors`"+` metric with the class as the `+"`class`"+` label.
+
+By default the query is retried forever. Set `+"`max_retries`"+` or `+"`backoff.max_elapsed_time`"+` to shut down the input once the retries are exhausted, and `+"`shutdown_on_fatal_error`"+` to shut it down on fatal and authentication errors right away. The pipeline stops once all of its inputs are shut down.`).
 		Example(
 			"From Timeplus Enterprise Cloud via HTTP",
 			"You will need to create API Key on Timeplus Enterprise Cloud Web console first and then set the `apikey` field.",
@@ -55,7 +79,21 @@ input:
     url: tcp://localhost:8463
     query: select * from iot
     username: timeplus
-    password: timeplus`)
+    password: timeplus`).
+		Example(
+			"Resume a streaming query after restarts",
+			"The sequence number of the latest delivered row is stored in the `timeplus_checkpoint` cache resource, so the query resumes from there when the input restarts.",
+			`
+input:
+  timeplus:
+    url: tcp://localhost:8463
+    query: select _tp_sn, * from iot
+    checkpoint_cache: timeplus_checkpoint
+
+cache_resources:
+  - label: timeplus_checkpoint
+    file:
+      directory: /tmp/timeplus_checkpoint`)
 
 	inputConfigSpec.
 		Field(service.NewStringField("query").Description("The query to run").Examples("select * from iot", "select count(*) from table(iot)")).
@@ -63,15 +101,22 @@ input:
 		Field(service.NewStringField("workspace").Optional().Description("ID of the workspace. Required when reads from Timeplus Enterprise.")).
 		Field(service.NewStringField("apikey").Secret().Optional().Description("The API key. Required when reads from Timeplus Enterprise Cloud")).
 		Field(service.NewStringField("username").Optional().Description("The username. Required when reads from Timeplus Enterprise (self-hosted) or Timeplusd")).
-		Field(service.NewStringField("password").Secret().Optional().Description("The password. Required when reads from Timeplus Enterprise (self-hosted) or Timeplusd"))
-	err := service.RegisterInput(
+		Field(service.NewStringField("password").Secret().Optional().Description("The password. Required when reads from Timeplus Enterprise (self-hosted) or Timeplusd")).
+		Fields(connFields()...).
+		Field(service.NewStringField("checkpoint_cache").Optional().Description("A https://www.docs.redpanda.com/redpanda-connect/components/caches/about[cache resource^] to use for storing the position of the latest row that has been successfully delivered, this allows Redpanda Connect to resume a streaming query from that position upon restart.")).
+		Field(service.NewStringField("checkpoint_key").Description("The key to use to store the position in `checkpoint_cache`. An alternative key can be provided if multiple inputs share the same cache.").Default("timeplus_position").Advanced()).
+		Field(service.NewIntField("checkpoint_limit").Description("The maximum number of messages that can be processed at a given time. Increasing this limit enables parallel processing and batching at the output level. Any given position will not be acknowledged unless all messages before it are delivered in order to preserve at least once delivery guarantees.").Default(1024).Advanced()).
+		Field(service.NewBoolField("shutdown_on_fatal_error").Description("Whether to shut down the input on fatal and authentication errors, such as syntax errors, unknown streams or invalid credentials, instead of retrying them.").Default(false).Advanced()).
+		Fields(retries.CommonRetryBackOffFields(0, "1s", "30s", "0s")...).
+		Field(service.NewBatchPolicyField("batching"))
+	err := service.RegisterBatchInput(
 		"timeplus", inputConfigSpec, newTimeplusInput)
 	if err != nil {
 		panic(err)
 	}
 }
 
-func newTimeplusInput(conf *service.ParsedConfig, mgr *service.Resources) (service.Input, error) {
+func newTimeplusInput(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
 	logger := mgr.Logger()
 	sql, err := conf.FieldString("query")
 	if err != nil {
@@ -107,47 +152,136 @@ func newTimeplusInput(conf *service.ParsedConfig, mgr *service.Resources) (servi
 		}
 	}
 
+	connConf, err := connConfigFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+
 	var reader Reader
 
 	if addr.Scheme == "tcp" {
-		reader = driver.NewDriver(logger, addr.Host, username, password)
+		reader = driver.NewDriver(logger, addr.Host, username, password, connConf.driverOptions())
 	} else {
 		workspace, err := conf.FieldString("workspace")
 		if err != nil {
 			return nil, err
 		}
 
-		reader = http.NewSSEClient(logger, addr, workspace, apikey, username, password)
+		reader = http.NewSSEClient(logger, addr, workspace, apikey, username, password, connConf.httpOptions())
+	}
+
+	input := &timeplusInput{
+		log:     logger,
+		res:     mgr,
+		reader:  reader,
+		sql:     sql,
+		mErrors: mgr.Metrics().NewCounter(metricErrors, "class"),
+	}
+
+	if input.shutdownOnFatal, err = conf.FieldBool("shutdown_on_fatal_error"); err != nil {
+		return nil, err
+	}
+	backoffCtor, err := retries.CommonRetryBackOffCtorFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	input.backoff = backoffCtor()
+	input.backoff.Reset()
+
+	if conf.Contains("checkpoint_cache") {
+		if input.checkpointCache, err = conf.FieldString("checkpoint_cache"); err != nil {
+			return nil, err
+		}
+		if !mgr.HasCache(input.checkpointCache) {
+			return nil, fmt.Errorf("unknown cache resource: %s", input.checkpointCache)
+		}
+		if input.checkpointCacheKey, err = conf.FieldString("checkpoint_key"); err != nil {
+			return nil, err
+		}
+
+		checkpointLimit, err := conf.FieldInt("checkpoint_limit")
+		if err != nil {
+			return nil, err
+		}
+		input.cp = checkpoint.NewCapped[*position](int64(checkpointLimit))
+	}
+
+	batching, err := conf.FieldBatchPolicy("batching")
+	if err != nil {
+		return nil, err
+	}
+	if !batching.IsNoop() {
+		if input.batcher, err = batching.NewBatcher(mgr); err != nil {
+			return nil, err
+		}
+		input.batchPeriod = batching.Period != ""
 	}
 
-	return service.AutoRetryNacks(
-		&timeplusInput{
-			log:    logger,
-			reader: reader,
-			sql:    sql,
-		}), nil
+	return service.AutoRetryNacksBatched(input), nil
 }
 
 type timeplusInput struct {
 	log *service.Logger
+	res *service.Resources
 
 	reader Reader
 	sql    string
+
+	batcher     *service.Batcher
+	batchPeriod bool
+	// columnTypes is the value of the column_types metadata of every message of
+	// the query, which is shared between them and therefore read-only.
+	columnTypes map[string]any
+
+	backoff         backoff.BackOff
+	shutdownOnFatal bool
+	mErrors         *service.MetricCounter
+
+	checkpointCache    string
+	checkpointCacheKey string
+	cp                 *checkpoint.Capped[*position]
+	cacheMut           sync.Mutex
+	// lastPos is the position of the last batch with a position, which batches without one are tracked with so that
+	// they cannot hide the position of an earlier batch once resolved. Only accessed by ReadBatch.
+	lastPos *position
 }
 
 func (p *timeplusInput) Connect(ctx context.Context) error {
-	logger := p.log.With("sql", p.sql)
+	sql := p.sql
+	if p.cp != nil {
+		pos, err := p.getCachedPosition(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to get cached position: %w", err)
+		}
+		if pos != nil {
+			sql = withSeekTo(sql, pos.seekTo)
+		}
+	}
+
+	logger := p.log.With("sql", sql)
 
 	// We don't pass the `ctx` to `Run` method intentionally because
 	// "The provided context remains open only for the duration of the connecting
 	// phase, and should not be used to establish the lifetime of the connection
 	// itself."
-	if err := p.reader.Run(p.sql); err != nil {
-		if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, os.ErrDeadlineExceeded) {
-			return errors.New("failed to connect to driver")
+	if err := p.reader.Run(sql); err != nil {
+		if errclass.IsConnectionError(err) {
+			err = fmt.Errorf("failed to connect to driver: %w", err)
+		} else {
+			err = fmt.Errorf("failed to run query: %w", err)
 		}
 
-		return fmt.Errorf("failed to run query: %w", err)
+		wait, retry := p.onError(err)
+		if !retry {
+			return service.ErrEndOfInput
+		}
+		return service.NewErrBackOff(err, wait)
+	}
+
+	columnTypes := p.reader.ColumnTypes()
+	p.columnTypes = make(map[string]any, len(columnTypes))
+	for name, typ := range columnTypes {
+		p.columnTypes[name] = typ
 	}
 
 	logger.Info("timeplusd connected, query is running")
@@ -155,28 +289,199 @@ func (p *timeplusInput) Connect(ctx context.Context) error {
 	return nil
 }
 
-func (p *timeplusInput) Read(ctx context.Context) (*service.Message, service.AckFunc, error) {
-	event, err := p.reader.Read(ctx)
+func (p *timeplusInput) ReadBatch(ctx context.Context) (service.MessageBatch, service.AckFunc, error) {
+	batch, pos, err := p.readBatch(ctx)
 	if err != nil {
-		// Query got cancelled from server side
+		// The query is exhausted
 		if errors.Is(err, io.EOF) {
 			return nil, nil, service.ErrNotConnected
 		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil, err
+		}
+
+		wait, retry := p.onError(err)
+		if !retry {
+			return nil, nil, service.ErrEndOfInput
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, service.ErrNotConnected
+	}
+	p.backoff.Reset()
+
+	if p.cp == nil {
+		ack := func(ctx context.Context, err error) error {
+			// Nacks are retried automatically when we use service.AutoRetryNacksBatched
+			return nil
+		}
+		return batch, ack, nil
+	}
+
+	if pos == nil {
+		pos = p.lastPos
+	} else {
+		p.lastPos = pos
+	}
+
+	// Blocks once `checkpoint_limit` messages are pending
+	resolveFn, err := p.cp.Track(ctx, pos, int64(len(batch)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to track checkpoint for batch: %w", err)
+	}
+
+	ack := func(ctx context.Context, err error) error {
+		// Nacks are retried automatically when we use service.AutoRetryNacksBatched, so this is only called once the
+		// batch has been delivered.
+		p.cacheMut.Lock()
+		defer p.cacheMut.Unlock()
+
+		maxPos := resolveFn()
+		// Nothing to commit, this wasn't the latest batch
+		if maxPos == nil {
+			return nil
+		}
+		// The rows have no position
+		if *maxPos == nil {
+			return nil
+		}
+		return p.setCachedPosition(ctx, **maxPos)
+	}
+
+	return batch, ack, nil
+}
+
+// readBatch reads the next batch of messages, together with the position of its latest row.
+func (p *timeplusInput) readBatch(ctx context.Context) (service.MessageBatch, *position, error) {
+	if p.batcher == nil {
+		events, err := p.reader.Read(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
 
+		batch := make(service.MessageBatch, 0, len(events))
+		var pos *position
+		for _, event := range events {
+			msg, eventPos, err := p.newMessage(event)
+			if err != nil {
+				return nil, nil, err
+			}
+			if eventPos != nil {
+				pos = eventPos
+			}
+			batch = append(batch, msg)
+		}
+		return batch, pos, nil
+	}
+
+	var (
+		pending int
+		pos     *position
+	)
+	for {
+		readCtx, cancel := ctx, context.CancelFunc(func() {})
+		if d, ok := p.batcher.UntilNext(); ok {
+			readCtx, cancel = context.WithTimeout(ctx, d)
+		} else if pending > 0 && p.batchPeriod {
+			// The period has already elapsed
+			break
+		}
+
+		events, err := p.reader.Read(readCtx)
+		cancel()
+		if err != nil {
+			periodElapsed := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+			if pending > 0 && (periodElapsed || errors.Is(err, io.EOF)) {
+				// Flush the pending rows, EOF is returned again by the next read
+				break
+			}
+			if periodElapsed {
+				continue
+			}
+			return nil, nil, err
+		}
+
+		flush := false
+		for _, event := range events {
+			msg, eventPos, err := p.newMessage(event)
+			if err != nil {
+				return nil, nil, err
+			}
+			if eventPos != nil {
+				pos = eventPos
+			}
+			pending++
+			if p.batcher.Add(msg) {
+				flush = true
+			}
+		}
+		if flush {
+			break
+		}
+	}
+
+	batch, err := p.batcher.Flush(ctx)
+	if err != nil {
 		return nil, nil, err
 	}
+	return batch, pos, nil
+}
 
+// onError records the error and returns how long to wait before the query is run again, or false if the input
+// should shut down instead.
+func (p *timeplusInput) onError(err error) (time.Duration, bool) {
+	class := errclass.ClassOf(err)
+	p.mErrors.Incr(1, class.String())
+
+	switch class {
+	case errclass.QueryCancelled:
+		// Most likely timeplusd got restarted, the query is run again once it recovered
+		p.log.With("reason", err).Info("query cancelled")
+	case errclass.CheckpointInvalidated:
+		// This happens when the SQL is updated, i.e. a new MV is created, the previous checkpoint is no longer available
+		p.log.With("reason", err).Warn("query checkpoint invalidated")
+	case errclass.Fatal, errclass.Auth:
+		if p.shutdownOnFatal {
+			p.log.With("error", err).Errorf("shutting down after %s error", class)
+			return 0, false
+		}
+		p.log.With("error", err).Errorf("query failed with %s error", class)
+	default:
+		p.log.With("error", err).Errorf("query failed: %s", err.Error())
+	}
+
+	wait := p.backoff.NextBackOff()
+	if wait == backoff.Stop {
+		p.log.With("error", err).Error("shutting down after the retries are exhausted")
+		return 0, false
+	}
+	return wait, true
+}
+
+func (p *timeplusInput) newMessage(event map[string]any) (*service.Message, *position, error) {
 	msg := service.NewMessage(nil)
 	msg.SetStructured(event)
+	msg.MetaSetMut("column_types", p.columnTypes)
 
-	ack := func(ctx context.Context, err error) error {
-		// Nacks are retried automatically when we use service.AutoRetryNacks
-		return nil
+	if p.cp == nil {
+		return msg, nil, nil
 	}
 
-	return msg, ack, nil
+	pos, err := positionOf(event)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, pos, nil
 }
 
 func (p *timeplusInput) Close(ctx context.Context) error {
+	if p.batcher != nil {
+		if err := p.batcher.Close(ctx); err != nil {
+			return err
+		}
+	}
 	return p.reader.Close(ctx)
 }
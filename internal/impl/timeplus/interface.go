@@ -1,15 +1,35 @@
 package timeplus
 
-import "context"
+import (
+	"context"
 
-// Writer is the interface. Currently only http writer is implemented. Caller needs to make sure all writes contain the same `cols`
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/schema"
+)
+
+// Writer is the interface. It is implemented by the http writer and the native (tcp) writer. Caller needs to make sure all writes contain the same `cols`
 type Writer interface {
+	Describe(ctx context.Context) ([]schema.Column, error)
+	CreateStream(ctx context.Context, cols []schema.Column) error
+	AddColumns(ctx context.Context, cols []schema.Column) error
 	Write(ctx context.Context, cols []string, rows [][]any) error
+	Close(ctx context.Context) error
 }
 
-// Reader is the interface. Called MUST guarantee that the `Run` method is called before `Read` or `Close`
+// Reader is the interface. Called MUST guarantee that the `Run` method is called before `Read`, `ColumnTypes` or `Close`.
+// `Read` blocks until rows are available and returns the rows which arrived together, i.e. the rows of one SSE event or
+// one native block.
 type Reader interface {
 	Run(sql string) error
-	Read(ctx context.Context) (map[string]any, error)
+	Read(ctx context.Context) ([]map[string]any, error)
+	ColumnTypes() map[string]string
+	Close(ctx context.Context) error
+}
+
+// Querier is the interface. It is implemented by the http querier and the native (tcp) querier. It runs queries which
+// terminate, e.g. lookups against `table(...)` or mutable streams and DDL statements. `Query` returns all rows of the
+// query.
+type Querier interface {
+	Query(ctx context.Context, sql string) ([]map[string]any, error)
+	Exec(ctx context.Context, sql string) error
 	Close(ctx context.Context) error
 }
@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sort"
+	"strings"
+	"sync"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/driver"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
 	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/http"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/schema"
 )
 
 var outputConfigSpec *service.ConfigSpec
@@ -21,8 +25,17 @@ func init() {
 		Description(`
 This output can send message to Timeplus Enterprise Cloud, Timeplus Enterprise (self-hosted) or directly to timeplusd.
 
-This output accepts structured message only. It also expects all message contains the same keys and matches the schema of the destination stream. If the upstream source or pipeline returns
-unstructured message such as string, please refer to the "Unstructured message" example.`).
+If the schema of `+"`url`"+` is `+"`tcp`"+`, this output writes to timeplusd via the native protocol with columnar batch inserts, which avoids the JSON encoding of the HTTP ingest API and is recommended for high-volume pipelines. In this case the `+"`target`"+` and `+"`workspace`"+` fields are ignored.
+
+This output accepts structured message only. The schema of the destination stream is fetched when the output connects, and each message is projected onto the columns of the stream:
+
+- Fields which are not columns of the stream are dropped.
+- Columns missing from a message are filled with their default values (or NULL) by the server. The same applies to null fields of columns which aren't `+"`nullable`"+`.
+- Values are converted into the types of their columns, e.g. strings or unix timestamps into `+"`datetime64`"+`, numbers or strings into `+"`decimal`"+`, strings into `+"`uuid`"+`, as well as arrays and maps of these types.
+
+Messages which cannot be converted are rejected individually, the rest of the batch is still written. Such messages can be routed to a dead letter queue with a `+"xref:components:outputs/fallback.adoc[`fallback`]"+` output or handled with `+"xref:components:outputs/reject_errored.adoc[`reject_errored`]"+`.
+
+If the upstream source or pipeline returns unstructured message such as string, please refer to the "Unstructured message" example.`).
 		Example(
 			"To Timeplus Enterprise Cloud",
 			"You will need to create API Key on Timeplus Enterprise Cloud Web console first and then set the `apikey` field.",
@@ -53,6 +66,34 @@ output:
     stream: mystream
     username: username
     password: pw`).
+		Example(
+			"To Timeplusd via TCP",
+			"Make sure the schema of url is tcp and specify the native TCP port of the Timeplusd.",
+			`
+output:
+  timeplus:
+    url: tcp://localhost:8463
+    stream: mystream
+    username: timeplus
+    password: timeplus`).
+		Example(
+			"Create the stream and evolve its schema",
+			"The stream is created from the first batch if it does not exist, and new columns are added when messages contain new fields. Here timestamps are stored as `datetime64(3)` columns and numbers as `float64` columns.",
+			`
+output:
+  timeplus:
+    url: tcp://localhost:8463
+    stream: mystream
+    username: timeplus
+    password: timeplus
+    schema_evolution:
+      enabled: true
+      new_column_type_mapping: |
+        root = match this.value.type() {
+          this == "number" => "float64"
+          this == "timestamp" => "datetime64(3)"
+          _ => "string"
+        }`).
 		Example(
 			"Unstructured message",
 			"If the upstream source or pipeline returns unstructured message such as string, you can leverage the output processors to wrap it into a stucture message and then pass it to the output. This example create a strcutre mesasge with `raw` field and store the original string content into this field. You can modify the name of this `raw` field to whatever you want. Please make sure the destiation stream contains such field",
@@ -69,12 +110,22 @@ output:
         root.raw = content().string()`)
 	outputConfigSpec.
 		Field(service.NewStringEnumField("target", http.TargetTimeplus, http.TargetTimeplusd).Default(http.TargetTimeplus).Description("The destination type, either Timeplus Enterprise or timeplusd")).
-		Field(service.NewURLField("url").Description("The url should always include schema and host.").Default("https://us-west-2.timeplus.cloud").Examples("http://localhost:8000", "http://127.0.0.1:3218")).
+		Field(service.NewURLField("url").Description("The url should always include schema and host.").Default("https://us-west-2.timeplus.cloud").Examples("http://localhost:8000", "http://127.0.0.1:3218", "tcp://localhost:8463")).
 		Field(service.NewStringField("workspace").Optional().Description("ID of the workspace. Required if target is `timeplus`.")).
 		Field(service.NewStringField("stream").Description("The name of the stream. Make sure the schema of the stream matches the input")).
 		Field(service.NewStringField("apikey").Secret().Optional().Description("The API key. Required if you are sending message to Timeplus Enterprise Cloud")).
 		Field(service.NewStringField("username").Optional().Description("The username. Required if you are sending message to Timeplus Enterprise (self-hosted) or timeplusd")).
 		Field(service.NewStringField("password").Secret().Optional().Description("The password. Required if you are sending message to Timeplus Enterprise (self-hosted) or timeplusd")).
+		Fields(connFields()...).
+		Field(service.NewStringEnumField("compression", "none", "gzip").Description("The compression of the request bodies of ingest requests via HTTP. Data sent via `tcp` is not affected.").Default("none").Advanced()).
+		Field(service.NewObjectField("schema_evolution",
+			service.NewBoolField("enabled").Description("Whether schema evolution is enabled. If enabled, a new column is added to the stream via `ALTER STREAM` when a message contains a field which is not a column of the stream yet.").Default(false),
+			service.NewBoolField("create_stream").Description("Whether to create the stream from the first batch if it does not exist. The columns and their types are determined from the fields of the messages via `new_column_type_mapping`.").Default(true),
+			service.NewBloblangField("new_column_type_mapping").Description(`
+The mapping function from Redpanda Connect type to column type in Timeplus. Overriding this can allow for customization of the datatype if there is specific information that you know about the data types in use. This mapping should result in the `+"`root`"+` variable being assigned a string with the data type for the new column in Timeplus.
+
+The input to this mapping is an object with the value and the name of the new column, the original message and the stream being written to. The metadata is unchanged from the original message that caused the schema to change. For example: `+"`"+`{"value": 42.3, "name":"new_data_field", "message": {"existing_data_field": 42, "new_data_field": "foo"}, "stream": "mystream"}`+"`"+`. Fields with null values are ignored until a message carries a value for them.`).Default(defaultNewColumnTypeMapping).Advanced(),
+		).Description("Options to create the destination stream and evolve its schema as new fields are added to the messages. Schema evolution is only supported for `timeplusd`, either via `tcp` or HTTP.").Optional()).
 		Field(service.NewOutputMaxInFlightField()).
 		Field(service.NewBatchPolicyField("batching"))
 
@@ -84,61 +135,189 @@ output:
 }
 
 type timeplus struct {
-	logger *service.Logger
-	client Writer
+	logger  *service.Logger
+	client  Writer
+	evolver *schemaEvolver
+
+	mErrors *service.MetricCounter
+
+	columnsMut sync.RWMutex
+	// columns are the columns of the destination stream in the order of the stream schema
+	columns      []schema.Column
+	streamExists bool
+}
+
+// insertGroup collects the rows of all messages which carry the same set of columns. Columns omitted by the
+// messages are left out of the insert so that the server fills in their defaults (or NULLs).
+type insertGroup struct {
+	cols    []string
+	rows    [][]any
+	indexes []int
 }
 
 // Close implements service.Output
-func (t *timeplus) Close(context.Context) error {
-	return nil
+func (t *timeplus) Close(ctx context.Context) error {
+	if t.client == nil {
+		return nil
+	}
+
+	return t.client.Close(ctx)
 }
 
 // Connect implements service.Output
-func (t *timeplus) Connect(context.Context) error {
+func (t *timeplus) Connect(ctx context.Context) error {
 	if t.client == nil {
 		return errors.New("client not initialized")
 	}
 
+	columns, err := t.client.Describe(ctx)
+	if err != nil {
+		if errors.Is(err, schema.ErrStreamNotFound) && t.evolver != nil && t.evolver.createStream {
+			// The stream is created from the first batch
+			t.setColumns(nil, false)
+			return nil
+		}
+		t.mErrors.Incr(1, errclass.ClassOf(err).String())
+		return fmt.Errorf("failed to fetch the schema of the stream: %w", err)
+	}
+
+	t.setColumns(columns, true)
+
 	return nil
 }
 
+func (t *timeplus) setColumns(columns []schema.Column, streamExists bool) {
+	t.columnsMut.Lock()
+	t.columns, t.streamExists = columns, streamExists
+	t.columnsMut.Unlock()
+}
+
+// evolveSchema runs the schema evolution if the batch requires it and returns the up-to-date columns of the stream.
+func (t *timeplus) evolveSchema(ctx context.Context, b service.MessageBatch) ([]schema.Column, error) {
+	t.columnsMut.RLock()
+	columns, streamExists := t.columns, t.streamExists
+	t.columnsMut.RUnlock()
+
+	if t.evolver == nil || !needsEvolution(columns, streamExists, b) {
+		return columns, nil
+	}
+
+	t.columnsMut.Lock()
+	defer t.columnsMut.Unlock()
+
+	// Another batch may have already migrated the stream while we were waiting for the lock
+	if !needsEvolution(t.columns, t.streamExists, b) {
+		return t.columns, nil
+	}
+
+	columns, err := t.evolver.Evolve(ctx, t.columns, t.streamExists, b)
+	if err != nil {
+		return nil, err
+	}
+
+	t.columns, t.streamExists = columns, true
+	return columns, nil
+}
+
 func (t *timeplus) WriteBatch(ctx context.Context, b service.MessageBatch) error {
 	if len(b) == 0 {
 		return nil
 	}
 
-	cols := []string{}
-	rows := [][]any{}
+	columns, err := t.evolveSchema(ctx, b)
+	if err != nil {
+		return fmt.Errorf("schema evolution failed: %w", err)
+	}
+
+	var batchErr *service.BatchError
+	fail := func(i int, err error) {
+		if batchErr == nil {
+			batchErr = service.NewBatchError(b, err)
+		}
+		batchErr.Failed(i, err)
+	}
 
-	// Here we assume all messages have the same structure, same keys
-	for _, msg := range b {
-		keys := []string{}
-		data := []any{}
+	groups := map[string]*insertGroup{}
+	var ordered []*insertGroup
 
-		msgStructure, err := msg.AsStructured()
+	for i, msg := range b {
+		cols, row, err := project(columns, msg)
 		if err != nil {
-			return fmt.Errorf("failed to get structured message %w, skipping this message", err)
+			t.logger.With("error", err).Debug("message does not conform to the stream schema")
+			fail(i, err)
+			continue
 		}
 
-		msgJSON, OK := msgStructure.(map[string]any)
-		if !OK {
-			return fmt.Errorf("expect map[string]any, got %T, skipping this message", msgJSON)
+		key := strings.Join(cols, "\x00")
+		group, exists := groups[key]
+		if !exists {
+			group = &insertGroup{cols: cols}
+			groups[key] = group
+			ordered = append(ordered, group)
 		}
+		group.rows = append(group.rows, row)
+		group.indexes = append(group.indexes, i)
+	}
+
+	for _, group := range ordered {
+		if err := t.client.Write(ctx, group.cols, group.rows); err != nil {
+			t.mErrors.Incr(1, errclass.ClassOf(err).String())
+			if batchErr == nil && len(ordered) == 1 {
+				if errclass.IsConnectionError(err) {
+					t.logger.With("error", err).Warn("lost connection to timeplus")
+					return service.ErrNotConnected
+				}
+				return err
+			}
+			for _, i := range group.indexes {
+				fail(i, err)
+			}
+		}
+	}
+
+	if batchErr != nil {
+		return batchErr
+	}
+	return nil
+}
+
+// project maps a message onto the columns of the stream. Fields which are not part of the stream are dropped and
+// values are coerced into the types of their columns. Null values of columns which aren't nullable are omitted, so
+// that the server fills them in with the default of the column.
+func project(columns []schema.Column, msg *service.Message) ([]string, []any, error) {
+	msgStructure, err := msg.AsStructured()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get structured message: %w", err)
+	}
+
+	msgJSON, ok := msgStructure.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("expect map[string]any, got %T", msgStructure)
+	}
+
+	cols := make([]string, 0, len(msgJSON))
+	row := make([]any, 0, len(msgJSON))
 
-		for key := range msgJSON {
-			keys = append(keys, key)
+	for _, col := range columns {
+		v, exists := msgJSON[col.Name]
+		if !exists || !col.Writable() || (v == nil && !col.Nullable()) {
+			continue
 		}
-		sort.Strings(keys)
 
-		for _, key := range keys {
-			data = append(data, msgJSON[key])
+		coerced, err := col.Coerce(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert field %s to %s: %w", col.Name, col.Type, err)
 		}
 
-		rows = append(rows, data)
-		cols = keys
+		cols = append(cols, col.Name)
+		row = append(row, coerced)
 	}
 
-	return t.client.Write(ctx, cols, rows)
+	if len(cols) == 0 {
+		return nil, nil, errors.New("message does not contain any column of the stream")
+	}
+
+	return cols, row, nil
 }
 
 func newTimeplusOutput(conf *service.ParsedConfig, mgr *service.Resources) (out service.BatchOutput, batchPolicy service.BatchPolicy, maxInFlight int, err error) {
@@ -183,29 +362,82 @@ func newTimeplusOutput(conf *service.ParsedConfig, mgr *service.Resources) (out
 		}
 	}
 
-	var workspace string
+	if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
+		return
+	}
+	if maxInFlight, err = conf.FieldMaxInFlight(); err != nil {
+		return
+	}
 
-	if target == http.TargetTimeplus {
-		workspace, err = conf.FieldString("workspace")
-		if err != nil {
+	var connConf connConfig
+	if connConf, err = connConfigFromParsed(conf); err != nil {
+		return
+	}
+
+	var evolve bool
+	if conf.Contains("schema_evolution") {
+		if evolve, err = conf.FieldBool("schema_evolution", "enabled"); err != nil {
 			return
 		}
-		if len(workspace) == 0 {
-			err = errors.New("workspace is required for `timeplus` target")
+		// Streams are only created and altered via the DDL API of timeplusd or the native protocol
+		if evolve && baseURL.Scheme != "tcp" && target != http.TargetTimeplusd {
+			err = errors.New("schema_evolution is only supported for the `timeplusd` target or a `tcp` url")
 			return
 		}
 	}
 
-	if batchPolicy, err = conf.FieldBatchPolicy("batching"); err != nil {
-		return
+	var client Writer
+
+	if baseURL.Scheme == "tcp" {
+		if client, err = driver.NewWriter(logger, baseURL.Host, username, password, stream, connConf.driverOptions()); err != nil {
+			return
+		}
+	} else {
+		var workspace string
+
+		if target == http.TargetTimeplus {
+			workspace, err = conf.FieldString("workspace")
+			if err != nil {
+				return
+			}
+			if len(workspace) == 0 {
+				err = errors.New("workspace is required for `timeplus` target")
+				return
+			}
+		}
+
+		var compression string
+		if compression, err = conf.FieldString("compression"); err != nil {
+			return
+		}
+
+		httpOpts := connConf.httpOptions()
+		httpOpts.Gzip = compression == "gzip"
+		client = http.NewClient(logger, target, baseURL, workspace, stream, apikey, username, password, httpOpts)
 	}
-	if maxInFlight, err = conf.FieldMaxInFlight(); err != nil {
-		return
+
+	var evolver *schemaEvolver
+	if evolve {
+		seConf := conf.Namespace("schema_evolution")
+
+		evolver = &schemaEvolver{
+			logger: logger,
+			client: client,
+			stream: stream,
+		}
+		if evolver.createStream, err = seConf.FieldBool("create_stream"); err != nil {
+			return
+		}
+		if evolver.mapping, err = seConf.FieldBloblang("new_column_type_mapping"); err != nil {
+			return
+		}
 	}
 
 	out = &timeplus{
-		logger: logger,
-		client: http.NewClient(logger, target, baseURL, workspace, stream, apikey, username, password),
+		logger:  logger,
+		client:  client,
+		evolver: evolver,
+		mErrors: mgr.Metrics().NewCounter(metricErrors, "class"),
 	}
 
 	return
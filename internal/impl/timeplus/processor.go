@@ -0,0 +1,236 @@
+package timeplus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/driver"
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/http"
+)
+
+var processorConfigSpec *service.ConfigSpec
+
+func init() {
+	processorConfigSpec = service.NewConfigSpec().
+		Categories("Services").
+		Version("4.47.0").
+		Summary("Runs a query on Timeplus Enterprise or Timeplusd for each message and (optionally) returns the result as an array of objects, one for each row returned.").
+		Description(`
+This processor can run lookups against Timeplus streams, e.g. `+"`table(...)`"+` queries or queries against mutable streams, in order to enrich messages, as well as DDL statements from within a pipeline.
+
+The query MUST terminate, streaming queries are not supported. Placeholders `+"`$1`"+`, `+"`$2`"+`, ... in the query are populated with the values returned by `+"`args_mapping`"+`. The values are escaped and bound on the client side since the query API does not support parameters.
+
+If the query fails to execute then the message will remain unchanged and the error can be caught using xref:configuration:error_handling.adoc[error handling methods].`).
+		Example(
+			"Enrich messages via a lookup",
+			"Here we look up the device of each message in the mutable stream `devices` via TCP. A `"+"xref:components:processors/branch.adoc[`branch` processor]"+"` is used in order to insert the resulting row into the original message at the path `device`.",
+			`
+pipeline:
+  processors:
+    - branch:
+        processors:
+          - timeplus_sql:
+              url: tcp://localhost:8463
+              query: select name, location from table(devices) where id = $1
+              args_mapping: root = [ this.device_id ]
+        result_map: root.device = this.index(0)`).
+		Example(
+			"Create streams dynamically",
+			"Here we create a stream for each tenant before the messages are written to it. The stream name is taken from the metadata, so make sure it cannot be used for SQL injection.",
+			`
+pipeline:
+  processors:
+    - timeplus_sql:
+        url: tcp://localhost:8463
+        unsafe_dynamic_query: true
+        query: create stream if not exists `+"`events_${! @tenant }`"+` (id string, payload string)
+        exec_only: true`)
+
+	processorConfigSpec.
+		Field(service.NewStringField("query").Description("The query to run. It MUST terminate, e.g. a query against `table(...)` or a DDL statement.").Examples("select * from table(devices) where id = $1", "create stream if not exists iot (id string, temperature float64)")).
+		Field(service.NewBoolField("unsafe_dynamic_query").Description("Whether to enable xref:configuration:interpolation.adoc#bloblang-queries[interpolation functions] in the query. Great care should be made to ensure your queries are defended against injection attacks.").Default(false).Advanced()).
+		Field(service.NewBloblangField("args_mapping").Description("An optional xref:guides:bloblang/about.adoc[Bloblang mapping] which should evaluate to an array of values matching in size to the number of placeholder arguments in the field `query`.").Examples("root = [ this.cat.meow, this.doc.woofs[0] ]", `root = [ meta("user.id") ]`).Optional()).
+		Field(service.NewBoolField("exec_only").Description("Whether the query result should be discarded. When set to `true` the message contents will remain unchanged, which is useful in cases where you are executing DDL statements.").Default(false)).
+		Field(service.NewURLField("url").Description("The url should always include schema and host.").Default("tcp://localhost:8463")).
+		Field(service.NewStringField("workspace").Optional().Description("ID of the workspace. Required when queries Timeplus Enterprise.")).
+		Field(service.NewStringField("apikey").Secret().Optional().Description("The API key. Required when queries Timeplus Enterprise Cloud")).
+		Field(service.NewStringField("username").Optional().Description("The username. Required when queries Timeplus Enterprise (self-hosted) or Timeplusd")).
+		Field(service.NewStringField("password").Secret().Optional().Description("The password. Required when queries Timeplus Enterprise (self-hosted) or Timeplusd")).
+		Fields(connFields()...)
+
+	if err := service.RegisterBatchProcessor("timeplus_sql", processorConfigSpec, newTimeplusProcessor); err != nil {
+		panic(err)
+	}
+}
+
+type timeplusProcessor struct {
+	logger  *service.Logger
+	querier Querier
+
+	query       string
+	dynamic     *service.InterpolatedString
+	argsMapping *bloblang.Executor
+	execOnly    bool
+}
+
+func newTimeplusProcessor(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+	logger := mgr.Logger()
+
+	p := &timeplusProcessor{logger: logger}
+
+	unsafeDyn, err := conf.FieldBool("unsafe_dynamic_query")
+	if err != nil {
+		return nil, err
+	}
+	if unsafeDyn {
+		if p.dynamic, err = conf.FieldInterpolatedString("query"); err != nil {
+			return nil, err
+		}
+	} else if p.query, err = conf.FieldString("query"); err != nil {
+		return nil, err
+	}
+
+	if conf.Contains("args_mapping") {
+		if p.argsMapping, err = conf.FieldBloblang("args_mapping"); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.execOnly, err = conf.FieldBool("exec_only"); err != nil {
+		return nil, err
+	}
+
+	addr, err := conf.FieldURL("url")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		apikey   string
+		username string
+		password string
+	)
+	if conf.Contains("apikey") {
+		apikey, err = conf.FieldString("apikey")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains("username") {
+		username, err = conf.FieldString("username")
+		if err != nil {
+			return nil, err
+		}
+	}
+	if conf.Contains("password") {
+		password, err = conf.FieldString("password")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	connConf, err := connConfigFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	if addr.Scheme == "tcp" {
+		p.querier = driver.NewQuerier(logger, addr.Host, username, password, connConf.driverOptions())
+	} else {
+		workspace, err := conf.FieldString("workspace")
+		if err != nil {
+			return nil, err
+		}
+
+		p.querier = http.NewQuerier(logger, addr, workspace, apikey, username, password, connConf.httpOptions())
+	}
+
+	return p, nil
+}
+
+func (p *timeplusProcessor) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	var argsExec *service.MessageBatchBloblangExecutor
+	if p.argsMapping != nil {
+		argsExec = batch.BloblangExecutor(p.argsMapping)
+	}
+	var dynQuery *service.MessageBatchInterpolationExecutor
+	if p.dynamic != nil {
+		dynQuery = batch.InterpolationExecutor(p.dynamic)
+	}
+
+	batch = batch.Copy()
+
+	for i, msg := range batch {
+		if err := p.processMessage(ctx, msg, i, argsExec, dynQuery); err != nil {
+			p.logger.Debugf("%v", err)
+			msg.SetError(err)
+		}
+	}
+
+	return []service.MessageBatch{batch}, nil
+}
+
+func (p *timeplusProcessor) processMessage(
+	ctx context.Context,
+	msg *service.Message,
+	i int,
+	argsExec *service.MessageBatchBloblangExecutor,
+	dynQuery *service.MessageBatchInterpolationExecutor,
+) error {
+	var args []any
+	if argsExec != nil {
+		resMsg, err := argsExec.Query(i)
+		if err != nil {
+			return fmt.Errorf("arguments mapping failed: %v", err)
+		}
+
+		iargs, err := resMsg.AsStructured()
+		if err != nil {
+			return fmt.Errorf("mapping returned non-structured result: %w", err)
+		}
+
+		var ok bool
+		if args, ok = iargs.([]any); !ok {
+			return fmt.Errorf("mapping returned non-array result: %T", iargs)
+		}
+	}
+
+	query := p.query
+	if dynQuery != nil {
+		var err error
+		if query, err = dynQuery.TryString(i); err != nil {
+			return fmt.Errorf("query interpolation error: %w", err)
+		}
+	}
+
+	query, err := bindArgs(query, args)
+	if err != nil {
+		return fmt.Errorf("failed to bind arguments: %w", err)
+	}
+
+	if p.execOnly {
+		if err := p.querier.Exec(ctx, query); err != nil {
+			return fmt.Errorf("failed to run query: %w", err)
+		}
+		return nil
+	}
+
+	rows, err := p.querier.Query(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to run query: %w", err)
+	}
+
+	jArray := make([]any, len(rows))
+	for i, row := range rows {
+		jArray[i] = row
+	}
+	msg.SetStructuredMut(jArray)
+	return nil
+}
+
+func (p *timeplusProcessor) Close(ctx context.Context) error {
+	return p.querier.Close(ctx)
+}
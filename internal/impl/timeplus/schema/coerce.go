@@ -0,0 +1,347 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	typeTime    = reflect.TypeOf(time.Time{})
+	typeDecimal = reflect.TypeOf(decimal.Decimal{})
+	typeUUID    = reflect.TypeOf(uuid.UUID{})
+)
+
+// timeLayouts are the layouts tried, in order, when a string is coerced into a timestamp.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+func convert(v any, t reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(t), nil
+	}
+
+	if n, ok := v.(json.Number); ok {
+		v = numberValue(n, t)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Type() == t {
+		return rv, nil
+	}
+
+	switch {
+	case t.Kind() == reflect.Pointer:
+		elem, err := convert(v, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case t == typeTime:
+		ts, err := toTime(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(ts), nil
+	case isTimeWrapper(t):
+		// Types such as `types.Date` which embed a `time.Time`
+		ts, err := toTime(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		wrapper := reflect.New(t).Elem()
+		wrapper.Field(0).Set(reflect.ValueOf(ts))
+		return wrapper, nil
+	case t == typeDecimal:
+		d, err := toDecimal(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(d), nil
+	case t == typeUUID:
+		u, err := toUUID(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(u), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		s, err := toString(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(s).Convert(t), nil
+	case reflect.Bool:
+		b, err := toBool(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := toInt(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(t).Elem()
+		if out.OverflowInt(i) {
+			return reflect.Value{}, fmt.Errorf("value %v overflows %s", v, t)
+		}
+		out.SetInt(i)
+		return out, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := toUint(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(t).Elem()
+		if out.OverflowUint(u) {
+			return reflect.Value{}, fmt.Errorf("value %v overflows %s", v, t)
+		}
+		out.SetUint(u)
+		return out, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(v)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out := reflect.New(t).Elem()
+		out.SetFloat(f)
+		return out, nil
+	case reflect.Slice:
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return reflect.Value{}, fmt.Errorf("expected array, got %T", v)
+		}
+		out := reflect.MakeSlice(t, rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := convert(rv.Index(i).Interface(), t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %w", i, err)
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+	case reflect.Map:
+		if rv.Kind() != reflect.Map {
+			return reflect.Value{}, fmt.Errorf("expected object, got %T", v)
+		}
+		out := reflect.MakeMapWithSize(t, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := convert(iter.Key().Interface(), t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %v: %w", iter.Key().Interface(), err)
+			}
+			val, err := convert(iter.Value().Interface(), t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %v: %w", iter.Key().Interface(), err)
+			}
+			out.SetMapIndex(key, val)
+		}
+		return out, nil
+	}
+
+	if rv.Type().ConvertibleTo(t) {
+		return rv.Convert(t), nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", v, t)
+}
+
+func isTimeWrapper(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 1 && t.Field(0).Anonymous && t.Field(0).Type == typeTime
+}
+
+// numberValue converts a number into the Go value closest to the type `t`, avoiding a float64 for integers and
+// decimals that it cannot represent precisely.
+func numberValue(n json.Number, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == typeDecimal:
+		return n.String()
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return u
+		}
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
+
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if ts, err := time.Parse(layout, t); err == nil {
+				return ts, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as timestamp", t)
+	}
+
+	// Numbers are treated as unix timestamps in seconds
+	f, err := toFloat(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot convert %T to timestamp", v)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
+
+func toDecimal(v any) (decimal.Decimal, error) {
+	switch t := v.(type) {
+	case string:
+		return decimal.NewFromString(t)
+	case float32:
+		return decimal.NewFromFloat32(t), nil
+	case float64:
+		return decimal.NewFromFloat(t), nil
+	}
+
+	i, err := toInt(v)
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("cannot convert %T to decimal", v)
+	}
+	return decimal.NewFromInt(i), nil
+}
+
+func toUUID(v any) (uuid.UUID, error) {
+	switch t := v.(type) {
+	case string:
+		return uuid.Parse(t)
+	case []byte:
+		if len(t) == 16 {
+			return uuid.FromBytes(t)
+		}
+		return uuid.ParseBytes(t)
+	}
+	return uuid.UUID{}, fmt.Errorf("cannot convert %T to uuid", v)
+}
+
+func toString(v any) (string, error) {
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case []byte:
+		return string(t), nil
+	case time.Time:
+		return t.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return t.String(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+func toBool(v any) (bool, error) {
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case string:
+		return strconv.ParseBool(t)
+	}
+
+	f, err := toFloat(v)
+	if err != nil {
+		return false, fmt.Errorf("cannot convert %T to bool", v)
+	}
+	return f != 0, nil
+}
+
+func toInt(v any) (int64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("value %v overflows int64", v)
+		}
+		return int64(u), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f > math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer", v)
+		}
+		return int64(f), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, nil
+		}
+		return 0, nil
+	case reflect.String:
+		return strconv.ParseInt(rv.String(), 10, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to integer", v)
+}
+
+func toUint(v any) (uint64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), nil
+	case reflect.String:
+		return strconv.ParseUint(rv.String(), 10, 64)
+	}
+
+	i, err := toInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("value %v is negative", v)
+	}
+	return uint64(i), nil
+}
+
+func toFloat(v any) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.String:
+		return strconv.ParseFloat(rv.String(), 64)
+	}
+	if d, ok := v.(decimal.Decimal); ok {
+		f, _ := d.Float64()
+		return f, nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to float", v)
+}
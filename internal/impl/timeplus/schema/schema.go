@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/timeplus-io/proton-go-driver/v2/lib/column"
+)
+
+const (
+	// DefaultKindDefault is the kind of a column with a `DEFAULT` expression
+	DefaultKindDefault = "DEFAULT"
+
+	// DefaultKindMaterialized is the kind of a column with a `MATERIALIZED` expression
+	DefaultKindMaterialized = "MATERIALIZED"
+
+	// DefaultKindAlias is the kind of a column with an `ALIAS` expression
+	DefaultKindAlias = "ALIAS"
+)
+
+// ErrStreamNotFound is returned when the destination stream does not exist.
+var ErrStreamNotFound = errors.New("stream not found")
+
+// Column describes a column of a Timeplus stream. Always use `NewColumn` to create it.
+type Column struct {
+	Name        string
+	Type        string
+	DefaultKind string
+	DefaultExpr string
+
+	scanType reflect.Type
+}
+
+// NewColumn creates a column and resolves the Go type that the proton driver expects for values of `typ`.
+// If `typ` is unknown to the driver, values of this column are passed through without coercion.
+func NewColumn(name, typ, defaultKind, defaultExpr string) Column {
+	c := Column{
+		Name:        name,
+		Type:        typ,
+		DefaultKind: strings.ToUpper(defaultKind),
+		DefaultExpr: defaultExpr,
+	}
+
+	if col, err := column.Type(typ).Column(); err == nil {
+		c.scanType = col.ScanType()
+	}
+
+	return c
+}
+
+// Writable returns false if the value of the column is always calculated by the server, i.e. `MATERIALIZED` or `ALIAS` columns.
+func (c Column) Writable() bool {
+	return c.DefaultKind != DefaultKindMaterialized && c.DefaultKind != DefaultKindAlias
+}
+
+// Nullable returns true if the column accepts `NULL` values, i.e. the column is of a `nullable` type.
+func (c Column) Nullable() bool {
+	if c.scanType != nil {
+		return c.scanType.Kind() == reflect.Pointer
+	}
+	return strings.HasPrefix(c.Type, "nullable(")
+}
+
+// Coerce converts `v`, which is usually a value of a structured message, into the Go type the proton driver expects for this column.
+func (c Column) Coerce(v any) (any, error) {
+	if c.scanType == nil {
+		return v, nil
+	}
+
+	rv, err := convert(v, c.scanType)
+	if err != nil {
+		return nil, err
+	}
+
+	return rv.Interface(), nil
+}
+
+// This doesn't need to fully match, but be enough to prevent SQL injection as well as catch common errors. The
+// type is further validated by the proton driver.
+var validTypeRegex = regexp.MustCompile(`^[a-z0-9_]+(\([A-Za-z0-9_ ,'()/+-]*\))?$`)
+
+// ValidateType returns an error if `typ` is not a valid Timeplus data type.
+func ValidateType(typ string) error {
+	if !validTypeRegex.MatchString(typ) {
+		return fmt.Errorf("invalid Timeplus data type: %s", typ)
+	}
+	if _, err := column.Type(typ).Column(); err != nil {
+		return fmt.Errorf("invalid Timeplus data type: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnCoerce(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 500_000_000, time.UTC)
+	id := uuid.MustParse("0b7b7a30-6b4f-4e07-9a3b-5b1e0a2f4c11")
+	str := "hello"
+	maxUint := uint64(18446744073709551615)
+
+	tests := []struct {
+		name     string
+		typ      string
+		input    any
+		expected any
+	}{
+		{name: "int from float", typ: "int32", input: float64(5), expected: int32(5)},
+		{name: "int from json number", typ: "int64", input: json.Number("42"), expected: int64(42)},
+		{name: "large int from json number", typ: "int64", input: json.Number("9007199254740993"), expected: int64(9007199254740993)},
+		{name: "large uint from json number", typ: "uint64", input: json.Number("18446744073709551615"), expected: uint64(18446744073709551615)},
+		{name: "nullable large uint from json number", typ: "nullable(uint64)", input: json.Number("18446744073709551615"), expected: &maxUint},
+		{name: "int from integral json number", typ: "int32", input: json.Number("5.0"), expected: int32(5)},
+		{name: "decimal from json number", typ: "decimal(38, 18)", input: json.Number("12345678901234567.123456789"), expected: decimal.RequireFromString("12345678901234567.123456789")},
+		{name: "uint from string", typ: "uint16", input: "7", expected: uint16(7)},
+		{name: "float from int", typ: "float32", input: int64(3), expected: float32(3)},
+		{name: "bool from string", typ: "bool", input: "true", expected: true},
+		{name: "string from number", typ: "string", input: float64(1.5), expected: "1.5"},
+		{name: "string from object", typ: "string", input: map[string]any{"a": "b"}, expected: `{"a":"b"}`},
+		{name: "datetime64 from string", typ: "datetime64(3, 'UTC')", input: "2024-03-01T12:30:00.5Z", expected: ts},
+		{name: "datetime64 from unix", typ: "datetime64(3)", input: float64(ts.Unix()) + 0.5, expected: ts},
+		{name: "datetime from space separated string", typ: "datetime", input: "2024-03-01 12:30:00.5", expected: ts},
+		{name: "decimal from string", typ: "decimal(10, 2)", input: "12.50", expected: decimal.RequireFromString("12.50")},
+		{name: "decimal from float", typ: "decimal(10, 2)", input: 0.25, expected: decimal.NewFromFloat(0.25)},
+		{name: "uuid from string", typ: "uuid", input: id.String(), expected: id},
+		{name: "array", typ: "array(int8)", input: []any{float64(1), float64(2)}, expected: []int8{1, 2}},
+		{name: "map", typ: "map(string, float64)", input: map[string]any{"a": int64(1)}, expected: map[string]float64{"a": 1}},
+		{name: "nullable with value", typ: "nullable(string)", input: str, expected: &str},
+		{name: "nullable without value", typ: "nullable(string)", input: nil, expected: (*string)(nil)},
+		{name: "low cardinality", typ: "low_cardinality(string)", input: "x", expected: "x"},
+		{name: "unknown type is passed through", typ: "unknown_type", input: "x", expected: "x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			col := NewColumn("col", test.typ, "", "")
+
+			actual, err := col.Coerce(test.input)
+			require.NoError(t, err)
+
+			if expectedDecimal, ok := test.expected.(decimal.Decimal); ok {
+				require.IsType(t, decimal.Decimal{}, actual)
+				assert.True(t, expectedDecimal.Equal(actual.(decimal.Decimal)), "expected %v, got %v", expectedDecimal, actual)
+				return
+			}
+			if expectedTime, ok := test.expected.(time.Time); ok {
+				require.IsType(t, time.Time{}, actual)
+				assert.True(t, expectedTime.Equal(actual.(time.Time)), "expected %v, got %v", expectedTime, actual)
+				return
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestColumnCoerceErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   string
+		input any
+	}{
+		{name: "int overflow", typ: "int8", input: float64(300)},
+		{name: "int from fraction", typ: "int32", input: 1.5},
+		{name: "negative uint", typ: "uint32", input: float64(-1)},
+		{name: "invalid uuid", typ: "uuid", input: "nope"},
+		{name: "invalid timestamp", typ: "datetime64(3)", input: "yesterday"},
+		{name: "array from scalar", typ: "array(string)", input: "a"},
+		{name: "invalid array element", typ: "array(int32)", input: []any{"a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			col := NewColumn("col", test.typ, "", "")
+
+			_, err := col.Coerce(test.input)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestColumnWritable(t *testing.T) {
+	assert.True(t, NewColumn("a", "int32", "", "").Writable())
+	assert.True(t, NewColumn("a", "int32", "default", "1").Writable())
+	assert.False(t, NewColumn("a", "int32", "MATERIALIZED", "b + 1").Writable())
+	assert.False(t, NewColumn("a", "int32", "ALIAS", "b + 1").Writable())
+}
+
+func TestColumnNullable(t *testing.T) {
+	assert.True(t, NewColumn("a", "nullable(string)", "", "").Nullable())
+	assert.True(t, NewColumn("a", "nullable(unknown_type)", "", "").Nullable())
+	assert.True(t, NewColumn("a", "low_cardinality(nullable(string))", "", "").Nullable())
+	assert.False(t, NewColumn("a", "string", "", "").Nullable())
+	assert.False(t, NewColumn("a", "array(nullable(string))", "", "").Nullable())
+}
+
+func TestValidateType(t *testing.T) {
+	for _, typ := range []string{"string", "float64", "datetime64(3, 'UTC')", "decimal(10, 2)", "array(nullable(string))", "map(string, int32)"} {
+		assert.NoError(t, ValidateType(typ), typ)
+	}
+
+	for _, typ := range []string{"", "STRING", "not_a_type", "string; DROP STREAM s", "array(int32), ADD COLUMN x array(string)"} {
+		assert.Error(t, ValidateType(typ), typ)
+	}
+}
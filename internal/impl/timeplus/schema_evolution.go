@@ -0,0 +1,196 @@
+package timeplus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/redpanda-data/benthos/v4/public/bloblang"
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/schema"
+)
+
+const defaultNewColumnTypeMapping = `root = match this.value.type() {
+  this == "string" => "string"
+  this == "bytes" => "string"
+  this == "number" => "float64"
+  this == "bool" => "bool"
+  this == "timestamp" => "datetime64(3)"
+  this == "array" => "array(string)"
+  _ => "string"
+}`
+
+// schemaEvolver creates the destination stream and adds new columns to it based on the fields of the messages.
+type schemaEvolver struct {
+	logger       *service.Logger
+	client       Writer
+	stream       string
+	createStream bool
+	mapping      *bloblang.Executor
+}
+
+// columnType computes the type of a new column via the `new_column_type_mapping`.
+func (e *schemaEvolver) columnType(msg *service.Message, name string, value any) (string, error) {
+	original, err := msg.AsStructured()
+	if err != nil {
+		return "", fmt.Errorf("unable to extract JSON data from message that caused schema evolution: %w", err)
+	}
+
+	input := msg.Copy()
+	input.SetError(nil)
+	input.SetStructuredMut(map[string]any{
+		"name":    name,
+		"value":   value,
+		"message": original,
+		"stream":  e.stream,
+	})
+
+	result, err := input.BloblangQuery(e.mapping)
+	if err != nil {
+		return "", fmt.Errorf("unable to compute new column type for %s: %w", name, err)
+	}
+
+	v, err := result.AsBytes()
+	if err != nil {
+		return "", fmt.Errorf("unable to extract result from new column type mapping for %s: %w", name, err)
+	}
+
+	columnType := string(v)
+	if err := schema.ValidateType(columnType); err != nil {
+		return "", err
+	}
+	return columnType, nil
+}
+
+// needsEvolution returns true if the stream is missing or the batch contains a non-null field which is not yet part
+// of the stream.
+func needsEvolution(existing []schema.Column, streamExists bool, b service.MessageBatch) bool {
+	if !streamExists {
+		return true
+	}
+
+	known := make(map[string]struct{}, len(existing))
+	for _, col := range existing {
+		known[col.Name] = struct{}{}
+	}
+
+	for _, msg := range b {
+		structured, err := msg.AsStructured()
+		if err != nil {
+			continue
+		}
+		obj, ok := structured.(map[string]any)
+		if !ok {
+			continue
+		}
+		for name, value := range obj {
+			if _, exists := known[name]; !exists && value != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// newColumns returns the columns for all fields of the batch which are not yet part of the stream. Fields with
+// null values are skipped because their types cannot be determined.
+func (e *schemaEvolver) newColumns(existing []schema.Column, b service.MessageBatch) ([]schema.Column, error) {
+	known := map[string]struct{}{}
+	for _, col := range existing {
+		known[col.Name] = struct{}{}
+	}
+
+	var cols []schema.Column
+	for _, msg := range b {
+		structured, err := msg.AsStructured()
+		if err != nil {
+			continue
+		}
+		obj, ok := structured.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		names := make([]string, 0, len(obj))
+		for name, value := range obj {
+			if _, exists := known[name]; !exists && value != nil {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			columnType, err := e.columnType(msg, name, obj[name])
+			if err != nil {
+				return nil, err
+			}
+			known[name] = struct{}{}
+			cols = append(cols, schema.NewColumn(name, columnType, "", ""))
+		}
+	}
+
+	return cols, nil
+}
+
+// Evolve creates the stream if it is missing and adds the columns for new fields of the batch. It returns the
+// columns of the stream after the migration.
+func (e *schemaEvolver) Evolve(ctx context.Context, existing []schema.Column, streamExists bool, b service.MessageBatch) ([]schema.Column, error) {
+	if !streamExists && !e.createStream {
+		return nil, fmt.Errorf("stream %s: %w", e.stream, schema.ErrStreamNotFound)
+	}
+
+	cols, err := e.newColumns(existing, b)
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		if !streamExists {
+			return nil, errors.New("cannot create a stream from a batch without any non-null field")
+		}
+		return existing, nil
+	}
+
+	if !streamExists {
+		e.logger.Infof("identified write to non-existing stream - attempting to create stream: %s", e.stream)
+		if err := e.client.CreateStream(ctx, cols); err != nil {
+			return nil, fmt.Errorf("failed to create stream %s: %w", e.stream, err)
+		}
+	} else {
+		for _, col := range cols {
+			e.logger.Infof("identified new schema - attempting to alter stream to add column: %s %s", col.Name, col.Type)
+		}
+		if addErr := e.client.AddColumns(ctx, cols); addErr != nil {
+			// This may be due to a race with another writer, the refreshed schema tells us if the columns exist now.
+			refreshed, err := e.client.Describe(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if missing := missingColumns(refreshed, cols); len(missing) > 0 {
+				return nil, fmt.Errorf("failed to add columns %v to stream %s: %w", missing, e.stream, addErr)
+			}
+			e.logger.Warnf("unable to add new columns, they have been added by another writer in the meantime, error: %s", addErr)
+			return refreshed, nil
+		}
+	}
+
+	return e.client.Describe(ctx)
+}
+
+// missingColumns returns the names of the wanted columns that are not part of the existing columns.
+func missingColumns(existing, wanted []schema.Column) []string {
+	known := make(map[string]struct{}, len(existing))
+	for _, col := range existing {
+		known[col.Name] = struct{}{}
+	}
+
+	var missing []string
+	for _, col := range wanted {
+		if _, exists := known[col.Name]; !exists {
+			missing = append(missing, col.Name)
+		}
+	}
+	return missing
+}
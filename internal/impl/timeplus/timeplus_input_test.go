@@ -0,0 +1,198 @@
+package timeplus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/checkpoint"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/errclass"
+	tphttp "github.com/redpanda-data/connect/v4/internal/impl/timeplus/http"
+)
+
+func TestInputTimeplusBatching(t *testing.T) {
+	env := service.NewEnvironment()
+	ctx := context.Background()
+
+	conf, err := inputConfigSpec.ParseYAML(`
+query: select * from iot
+url: tcp://localhost:8463
+batching:
+  count: 3
+`, env)
+	require.NoError(t, err)
+
+	reader := &fakeReader{
+		batches: [][]map[string]any{
+			{{"a": 1}, {"a": 2}},
+			{{"a": 3}, {"a": 4}},
+			{{"a": 5}},
+		},
+	}
+
+	batching, err := conf.FieldBatchPolicy("batching")
+	require.NoError(t, err)
+	batcher, err := batching.NewBatcher(service.MockResources())
+	require.NoError(t, err)
+
+	tpInput := &timeplusInput{
+		log:     service.MockResources().Logger(),
+		reader:  reader,
+		batcher: batcher,
+		backoff: backoff.NewExponentialBackOff(),
+	}
+	t.Cleanup(func() { _ = tpInput.Close(ctx) })
+
+	require.NoError(t, tpInput.Connect(ctx))
+
+	// Batches from the reader are combined until the policy is triggered
+	batch, _, err := tpInput.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, batch, 4)
+
+	// Pending rows are flushed once the query ends
+	batch, _, err = tpInput.ReadBatch(ctx)
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+
+	_, _, err = tpInput.ReadBatch(ctx)
+	require.ErrorIs(t, err, service.ErrNotConnected)
+}
+
+func TestInputTimeplusErrorHandling(t *testing.T) {
+	ctx := context.Background()
+
+	fatalErr := &errclass.Error{Class: errclass.Fatal, Code: errclass.CodeSyntaxError, Err: errors.New("code: 62, message: syntax error")}
+	cancelErr := &errclass.Error{Class: errclass.QueryCancelled, Code: errclass.CodeQueryWasCancelled, Err: errors.New("code: 394, message: Query was cancelled")}
+
+	newInput := func(reader Reader, shutdownOnFatal bool, maxRetries uint64) *timeplusInput {
+		boff := backoff.NewExponentialBackOff()
+		boff.InitialInterval = time.Millisecond
+		boff.MaxInterval = time.Millisecond
+		boff.MaxElapsedTime = 0
+		boff.Reset()
+
+		return &timeplusInput{
+			log:             service.MockResources().Logger(),
+			reader:          reader,
+			backoff:         backoff.WithMaxRetries(boff, maxRetries),
+			shutdownOnFatal: shutdownOnFatal,
+		}
+	}
+
+	t.Run("query cancelled is retried", func(t *testing.T) {
+		reader := &fakeReader{
+			batches: [][]map[string]any{{{"a": 1}}},
+			readErr: cancelErr,
+		}
+		tpInput := newInput(reader, true, 3)
+
+		require.NoError(t, tpInput.Connect(ctx))
+
+		_, _, err := tpInput.ReadBatch(ctx)
+		require.NoError(t, err)
+
+		_, _, err = tpInput.ReadBatch(ctx)
+		require.ErrorIs(t, err, service.ErrNotConnected)
+	})
+
+	t.Run("fatal error is retried by default", func(t *testing.T) {
+		tpInput := newInput(&fakeReader{runErr: fatalErr}, false, 3)
+
+		err := tpInput.Connect(ctx)
+		var boffErr *service.ErrBackOff
+		require.ErrorAs(t, err, &boffErr)
+		require.ErrorIs(t, boffErr.Err, fatalErr)
+	})
+
+	t.Run("fatal error shuts down", func(t *testing.T) {
+		tpInput := newInput(&fakeReader{runErr: fatalErr}, true, 3)
+
+		require.ErrorIs(t, tpInput.Connect(ctx), service.ErrEndOfInput)
+
+		tpInput = newInput(&fakeReader{readErr: fatalErr}, true, 3)
+		require.NoError(t, tpInput.Connect(ctx))
+
+		_, _, err := tpInput.ReadBatch(ctx)
+		require.ErrorIs(t, err, service.ErrEndOfInput)
+	})
+
+	t.Run("retries are exhausted", func(t *testing.T) {
+		tpInput := newInput(&fakeReader{readErr: cancelErr}, false, 2)
+		require.NoError(t, tpInput.Connect(ctx))
+
+		for i := 0; i < 2; i++ {
+			_, _, err := tpInput.ReadBatch(ctx)
+			require.ErrorIs(t, err, service.ErrNotConnected)
+			require.NoError(t, tpInput.Connect(ctx))
+		}
+
+		_, _, err := tpInput.ReadBatch(ctx)
+		require.ErrorIs(t, err, service.ErrEndOfInput)
+	})
+}
+
+func TestInputTimeplusReconnect(t *testing.T) {
+	ctx := context.Background()
+
+	var queries, open int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&queries, 1)
+		atomic.AddInt32(&open, 1)
+		defer atomic.AddInt32(&open, -1)
+
+		// The row has an invalid position, which fails the batch while the query keeps running
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "event: query\ndata: {\"result\":{\"header\":[{\"name\":\"_tp_sn\",\"type\":\"int64\"}]}}\n\n")
+		_, _ = io.WriteString(w, "data: [[\"nope\"]]\n\n")
+		w.(http.Flusher).Flush()
+
+		<-req.Context().Done()
+	}))
+	t.Cleanup(ts.Close)
+
+	baseURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	boff := backoff.NewExponentialBackOff()
+	boff.InitialInterval = time.Millisecond
+	boff.MaxInterval = time.Millisecond
+	boff.MaxElapsedTime = 0
+
+	res := service.MockResources(service.MockResourcesOptAddCache("cp"))
+	tpInput := &timeplusInput{
+		log:                res.Logger(),
+		res:                res,
+		reader:             tphttp.NewSSEClient(res.Logger(), baseURL, "my_workspace", "", "", "", tphttp.Options{}),
+		sql:                "select * from iot",
+		backoff:            boff,
+		mErrors:            res.Metrics().NewCounter(metricErrors, "class"),
+		checkpointCache:    "cp",
+		checkpointCacheKey: "timeplus_position",
+		cp:                 checkpoint.NewCapped[*position](1024),
+	}
+	t.Cleanup(func() { _ = tpInput.Close(ctx) })
+
+	require.NoError(t, tpInput.Connect(ctx))
+	for i := 0; i < 2; i++ {
+		_, _, err := tpInput.ReadBatch(ctx)
+		require.ErrorIs(t, err, service.ErrNotConnected)
+		require.NoError(t, tpInput.Connect(ctx))
+	}
+
+	require.Equal(t, int32(3), atomic.LoadInt32(&queries))
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&open) == 1
+	}, time.Second, time.Millisecond*10)
+}
@@ -1,16 +1,21 @@
 package timeplus
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/redpanda-data/connect/v4/internal/impl/timeplus/schema"
 )
 
 func TestOutputTimeplus(t *testing.T) {
@@ -28,9 +33,45 @@ stream: mystream
 		require.ErrorContains(t, err, "workspace")
 	})
 
+	t.Run("Fail if schema evolution is enabled for Timeplus Enterprise", func(t *testing.T) {
+		outputConfig := `
+url: http://localhost:8000
+workspace: default
+stream: mystream
+schema_evolution:
+  enabled: true
+`
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		_, _, _, err = newTimeplusOutput(conf, service.MockResources())
+		require.ErrorContains(t, err, "schema_evolution is only supported for the `timeplusd` target or a `tcp` url")
+	})
+
+	t.Run("Workspace is not required for tcp url", func(t *testing.T) {
+		outputConfig := `
+url: tcp://localhost:8463
+stream: mystream
+`
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		err = out.Close(context.Background())
+		require.NoError(t, err)
+	})
+
 	t.Run("Successful send data to local Timeplus Enterprise", func(t *testing.T) {
 		ch := make(chan bool)
 		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodGet {
+				require.Equal(t, "/default/api/v1beta2/streams", req.RequestURI)
+				_, _ = w.Write([]byte(`[{"name":"mystream","columns":[{"name":"col1","type":"string"},{"name":"col2","type":"int32"},{"name":"col3","type":"int64"}]}]`))
+				return
+			}
+
 			require.Equal(t, http.MethodPost, req.Method)
 			require.Equal(t, "/default/api/v1beta2/streams/mystream/ingest", req.RequestURI)
 
@@ -92,6 +133,11 @@ stream: mystream
 	t.Run("Successful send data to remote Timeplus Enterprise", func(t *testing.T) {
 		ch := make(chan bool)
 		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodGet {
+				require.Equal(t, "/nextgen/api/v1beta2/streams", req.RequestURI)
+				_, _ = w.Write([]byte(`[{"name":"other","columns":[]},{"name":"test_rp","columns":[{"name":"col1","type":"string"},{"name":"col2","type":"int32"},{"name":"col3","type":"bool"},{"name":"col4","type":"float64"}]}]`))
+				return
+			}
 
 			require.Equal(t, http.MethodPost, req.Method)
 			require.Equal(t, "/nextgen/api/v1beta2/streams/test_rp/ingest", req.RequestURI)
@@ -155,6 +201,55 @@ apikey: 7v3fHptcgZBBkFyi4qpG1-scsUnrLbLLgA2PFXTy0H-bcqVBF5iPdU3KG1_k
 		require.NoError(t, err)
 	})
 
+	t.Run("Successful send gzip compressed data via TLS", func(t *testing.T) {
+		ch := make(chan bool)
+		svr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`[{"name":"mystream","columns":[{"name":"col1","type":"string"}]}]`))
+				return
+			}
+
+			require.Equal(t, "/default/api/v1beta2/streams/mystream/ingest", req.RequestURI)
+			require.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+
+			zr, err := gzip.NewReader(req.Body)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			require.Equal(t, "{\"columns\":[\"col1\"],\"data\":[[\"hello\"]]}", string(body))
+
+			close(ch)
+		}))
+		t.Cleanup(svr.Close)
+
+		outputConfig := fmt.Sprintf(`
+url: %s
+workspace: default
+stream: mystream
+compression: gzip
+tls:
+  enabled: true
+  skip_cert_verify: true
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		require.NoError(t, out.Connect(context.Background()))
+
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]any{"col1": "hello"})
+
+		require.NoError(t, out.WriteBatch(context.Background(), service.MessageBatch{msg}))
+
+		<-ch
+
+		require.NoError(t, out.Close(context.Background()))
+	})
 }
 
 func TestOutputTimeplusd(t *testing.T) {
@@ -163,6 +258,11 @@ func TestOutputTimeplusd(t *testing.T) {
 	t.Run("Successful ingest data", func(t *testing.T) {
 		ch := make(chan bool)
 		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodGet {
+				require.Equal(t, "/timeplusd/v1/ddl/streams", req.RequestURI)
+				_, _ = w.Write([]byte(`{"data":[{"name":"mystream","columns":[{"name":"col1","type":"string"},{"name":"_tp_time","type":"datetime64(3, 'UTC')","default":"now64(3, 'UTC')"}]}]}`))
+				return
+			}
 
 			require.Equal(t, http.MethodPost, req.Method)
 			require.Equal(t, "/timeplusd/v1/ingest/streams/mystream", req.RequestURI)
@@ -219,4 +319,285 @@ password: hello
 		require.NoError(t, err)
 	})
 
+	t.Run("Project messages onto the stream schema", func(t *testing.T) {
+		var bodies []string
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodGet {
+				_, _ = w.Write([]byte(`{"data":[{"name":"mystream","columns":[{"name":"id","type":"uuid"},{"name":"amount","type":"decimal(10, 2)"},{"name":"tags","type":"array(string)"},{"name":"ts","type":"datetime64(3)"},{"name":"note","type":"nullable(string)"}]}]}`))
+				return
+			}
+
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			bodies = append(bodies, string(body))
+		}))
+
+		outputConfig := fmt.Sprintf(`
+target: timeplusd
+url: %s
+stream: mystream
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		require.NoError(t, out.Connect(context.Background()))
+
+		batch := service.MessageBatch{
+			service.NewMessage([]byte(`{"id":"0b7b7a30-6b4f-4e07-9a3b-5b1e0a2f4c11","amount":"12.5","tags":["a","b"],"ts":1700000000,"extra":true}`)),
+			service.NewMessage([]byte(`{"id":"not a uuid","amount":1}`)),
+			service.NewMessage([]byte(`{"id":"6f1a1e8e-0b8e-4c41-9d39-98d4b4c5e0a2","amount":3,"tags":null,"note":null}`)),
+			service.NewMessage([]byte(`"unstructured"`)),
+		}
+
+		indexer := batch.Index()
+		err = out.WriteBatch(context.Background(), batch)
+
+		var batchErr *service.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		require.Equal(t, 2, batchErr.IndexedErrors())
+
+		failed := map[int]bool{}
+		batchErr.WalkMessagesIndexedBy(indexer, func(i int, _ *service.Message, err error) bool {
+			failed[i] = err != nil
+			return true
+		})
+		require.Equal(t, map[int]bool{0: false, 1: true, 2: false, 3: true}, failed)
+
+		require.Equal(t, []string{
+			`{"columns":["id","amount","tags","ts"],"data":[["0b7b7a30-6b4f-4e07-9a3b-5b1e0a2f4c11","12.5",["a","b"],"2023-11-14 22:13:20"]]}`,
+			`{"columns":["id","amount","note"],"data":[["6f1a1e8e-0b8e-4c41-9d39-98d4b4c5e0a2","3",null]]}`,
+		}, bodies)
+
+		require.NoError(t, out.Close(context.Background()))
+	})
+
+	t.Run("Fail to connect if stream does not exist", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		}))
+
+		outputConfig := fmt.Sprintf(`
+target: timeplusd
+url: %s
+stream: mystream
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		err = out.Connect(context.Background())
+		require.ErrorIs(t, err, schema.ErrStreamNotFound)
+	})
+}
+
+func TestOutputTimeplusSchemaEvolution(t *testing.T) {
+	env := service.NewEnvironment()
+
+	t.Run("Create stream and add columns", func(t *testing.T) {
+		var (
+			mut      sync.Mutex
+			columns  []string
+			requests []string
+		)
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mut.Lock()
+			defer mut.Unlock()
+
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			requests = append(requests, req.Method+" "+req.RequestURI+" "+string(body))
+
+			switch {
+			case req.Method == http.MethodGet:
+				if columns == nil {
+					_, _ = w.Write([]byte(`{"data":[]}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"data":[{"name":"mystream","columns":[` + strings.Join(columns, ",") + `]}]}`))
+			case req.RequestURI == "/timeplusd/v1/ddl/streams":
+				columns = []string{`{"name":"a","type":"string"}`, `{"name":"b","type":"float64"}`}
+			case req.RequestURI == "/timeplusd/v1/ddl/streams/mystream/columns":
+				columns = append(columns, string(body))
+			}
+		}))
+
+		outputConfig := fmt.Sprintf(`
+target: timeplusd
+url: %s
+stream: mystream
+schema_evolution:
+  enabled: true
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		require.NoError(t, out.Connect(context.Background()))
+
+		require.NoError(t, out.WriteBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"a":"hello","b":5,"c":null}`)),
+		}))
+
+		require.NoError(t, out.WriteBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"a":"world","c":true}`)),
+		}))
+
+		require.NoError(t, out.WriteBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"a":"again","c":false}`)),
+		}))
+
+		mut.Lock()
+		defer mut.Unlock()
+		require.Equal(t, []string{
+			`GET /timeplusd/v1/ddl/streams `,
+			`POST /timeplusd/v1/ddl/streams {"name":"mystream","columns":[{"name":"a","type":"string"},{"name":"b","type":"float64"}]}`,
+			`GET /timeplusd/v1/ddl/streams `,
+			`POST /timeplusd/v1/ingest/streams/mystream {"columns":["a","b"],"data":[["hello",5]]}`,
+			`POST /timeplusd/v1/ddl/streams/mystream/columns {"name":"c","type":"bool"}`,
+			`GET /timeplusd/v1/ddl/streams `,
+			`POST /timeplusd/v1/ingest/streams/mystream {"columns":["a","c"],"data":[["world",true]]}`,
+			`POST /timeplusd/v1/ingest/streams/mystream {"columns":["a","c"],"data":[["again",false]]}`,
+		}, requests)
+	})
+
+	t.Run("Fail when columns cannot be added", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.RequestURI == "/timeplusd/v1/ddl/streams/mystream/columns" {
+				http.Error(w, "not allowed", http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":[{"name":"mystream","columns":[{"name":"a","type":"string"}]}]}`))
+		}))
+
+		outputConfig := fmt.Sprintf(`
+target: timeplusd
+url: %s
+stream: mystream
+schema_evolution:
+  enabled: true
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		require.NoError(t, out.Connect(context.Background()))
+
+		err = out.WriteBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"a":"hello","b":5}`)),
+		})
+		require.ErrorContains(t, err, "failed to add columns [b] to stream mystream")
+	})
+
+	t.Run("Columns added by another writer", func(t *testing.T) {
+		var (
+			mut      sync.Mutex
+			columns  = []string{`{"name":"a","type":"string"}`}
+			requests []string
+		)
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mut.Lock()
+			defer mut.Unlock()
+
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			requests = append(requests, req.Method+" "+req.RequestURI+" "+string(body))
+
+			switch {
+			case req.Method == http.MethodGet:
+				_, _ = w.Write([]byte(`{"data":[{"name":"mystream","columns":[` + strings.Join(columns, ",") + `]}]}`))
+			case req.RequestURI == "/timeplusd/v1/ddl/streams/mystream/columns":
+				// Another writer added the column first
+				columns = append(columns, `{"name":"b","type":"float64"}`)
+				http.Error(w, "column already exists", http.StatusBadRequest)
+			}
+		}))
+
+		outputConfig := fmt.Sprintf(`
+target: timeplusd
+url: %s
+stream: mystream
+schema_evolution:
+  enabled: true
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		require.NoError(t, out.Connect(context.Background()))
+
+		require.NoError(t, out.WriteBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"a":"hello","b":5}`)),
+		}))
+
+		mut.Lock()
+		defer mut.Unlock()
+		require.Contains(t, requests, `POST /timeplusd/v1/ingest/streams/mystream {"columns":["a","b"],"data":[["hello",5]]}`)
+	})
+
+	t.Run("Invalid column type from mapping", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(`{"data":[{"name":"mystream","columns":[{"name":"a","type":"string"}]}]}`))
+		}))
+
+		outputConfig := fmt.Sprintf(`
+target: timeplusd
+url: %s
+stream: mystream
+schema_evolution:
+  enabled: true
+  new_column_type_mapping: 'root = "string; DROP STREAM mystream"'
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		require.NoError(t, out.Connect(context.Background()))
+
+		err = out.WriteBatch(context.Background(), service.MessageBatch{
+			service.NewMessage([]byte(`{"a":"hello","b":5}`)),
+		})
+		require.ErrorContains(t, err, "invalid Timeplus data type")
+	})
+
+	t.Run("Fail to connect if stream does not exist and creation is disabled", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			_, _ = w.Write([]byte(`{"data":[]}`))
+		}))
+
+		outputConfig := fmt.Sprintf(`
+target: timeplusd
+url: %s
+stream: mystream
+schema_evolution:
+  enabled: true
+  create_stream: false
+`, svr.URL)
+
+		conf, err := outputConfigSpec.ParseYAML(outputConfig, env)
+		require.NoError(t, err)
+
+		out, _, _, err := newTimeplusOutput(conf, service.MockResources())
+		require.NoError(t, err)
+
+		require.ErrorIs(t, out.Connect(context.Background()), schema.ErrStreamNotFound)
+	})
 }
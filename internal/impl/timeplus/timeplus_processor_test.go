@@ -0,0 +1,234 @@
+package timeplus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func TestProcessorTimeplusSQL(t *testing.T) {
+	env := service.NewEnvironment()
+	ctx := context.Background()
+
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "/my_workspace/api/v1beta2/queries", req.RequestURI)
+
+		var payload map[string]string
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&payload))
+		queries = append(queries, payload["sql"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "event: query\ndata: {\"result\":{\"header\":[{\"name\":\"id\",\"type\":\"string\"},{\"name\":\"name\",\"type\":\"string\"}]}}\n\n")
+		_, _ = io.WriteString(w, "data: [[\"a\",\"foo\"]]\n\n")
+		_, _ = io.WriteString(w, "data: [[\"a\",\"bar\"]]\n\n")
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("query with args", func(t *testing.T) {
+		queries = nil
+
+		conf, err := processorConfigSpec.ParseYAML(fmt.Sprintf(`
+url: %s
+workspace: my_workspace
+query: select id, name from table(devices) where id = $1 and region = $2
+args_mapping: root = [ this.id, "it's" ]
+`, ts.URL), env)
+		require.NoError(t, err)
+
+		proc, err := newTimeplusProcessor(conf, service.MockResources())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = proc.Close(ctx) })
+
+		batches, err := proc.ProcessBatch(ctx, service.MessageBatch{
+			service.NewMessage([]byte(`{"id":"a"}`)),
+		})
+		require.NoError(t, err)
+		require.Len(t, batches, 1)
+		require.Len(t, batches[0], 1)
+		require.NoError(t, batches[0][0].GetError())
+
+		b, err := batches[0][0].AsBytes()
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"id":"a","name":"foo"},{"id":"a","name":"bar"}]`, string(b))
+
+		assert.Equal(t, []string{`select id, name from table(devices) where id = 'a' and region = 'it\'s'`}, queries)
+	})
+
+	t.Run("exec only", func(t *testing.T) {
+		queries = nil
+
+		conf, err := processorConfigSpec.ParseYAML(fmt.Sprintf(`
+url: %s
+workspace: my_workspace
+unsafe_dynamic_query: true
+query: create stream if not exists ${! @tenant } (id string)
+exec_only: true
+`, ts.URL), env)
+		require.NoError(t, err)
+
+		proc, err := newTimeplusProcessor(conf, service.MockResources())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = proc.Close(ctx) })
+
+		msg := service.NewMessage([]byte(`{"id":"a"}`))
+		msg.MetaSetMut("tenant", "acme")
+
+		batches, err := proc.ProcessBatch(ctx, service.MessageBatch{msg})
+		require.NoError(t, err)
+		require.NoError(t, batches[0][0].GetError())
+
+		b, err := batches[0][0].AsBytes()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":"a"}`, string(b))
+
+		assert.Equal(t, []string{`create stream if not exists acme (id string)`}, queries)
+	})
+
+	t.Run("missing argument", func(t *testing.T) {
+		queries = nil
+
+		conf, err := processorConfigSpec.ParseYAML(fmt.Sprintf(`
+url: %s
+workspace: my_workspace
+query: select * from table(devices) where id = $2
+args_mapping: root = [ this.id ]
+`, ts.URL), env)
+		require.NoError(t, err)
+
+		proc, err := newTimeplusProcessor(conf, service.MockResources())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = proc.Close(ctx) })
+
+		batches, err := proc.ProcessBatch(ctx, service.MessageBatch{
+			service.NewMessage([]byte(`{"id":"a"}`)),
+		})
+		require.NoError(t, err)
+		require.ErrorContains(t, batches[0][0].GetError(), "no argument for placeholder $2")
+		assert.Empty(t, queries)
+	})
+}
+
+func TestProcessorTimeplusSQLCancellation(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The query hangs without a response until the test is done
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	t.Cleanup(func() {
+		close(release)
+		ts.Close()
+	})
+
+	conf, err := processorConfigSpec.ParseYAML(fmt.Sprintf(`
+url: %s
+workspace: my_workspace
+query: select id from table(devices)
+`, ts.URL), service.NewEnvironment())
+	require.NoError(t, err)
+
+	proc, err := newTimeplusProcessor(conf, service.MockResources())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = proc.Close(context.Background()) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		batches, err := proc.ProcessBatch(ctx, service.MessageBatch{service.NewMessage([]byte(`{}`))})
+		if assert.NoError(t, err) {
+			assert.ErrorIs(t, batches[0][0].GetError(), context.DeadlineExceeded)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("query was not cancelled by the context")
+	}
+}
+
+func TestBindArgs(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	for _, test := range []struct {
+		name     string
+		sql      string
+		args     []any
+		expected string
+		errMsg   string
+	}{
+		{
+			name:     "no args",
+			sql:      "select * from table(a) where b = $1",
+			expected: "select * from table(a) where b = $1",
+		},
+		{
+			name:     "scalars",
+			sql:      "select $1, $2, $3, $4, $5, $1",
+			args:     []any{"a'b\\c", int64(5), 1.5, true, nil},
+			expected: `select 'a\'b\\c', 5, 1.5, true, NULL, 'a\'b\\c'`,
+		},
+		{
+			name:     "json number",
+			sql:      "select $1",
+			args:     []any{json.Number("12")},
+			expected: "select 12",
+		},
+		{
+			name:     "timestamp",
+			sql:      "select $1",
+			args:     []any{ts},
+			expected: "select to_datetime64('2024-01-02 03:04:05.006', 9, 'UTC')",
+		},
+		{
+			name:     "array and map",
+			sql:      "select $1, $2",
+			args:     []any{[]any{"a", int64(1)}, map[string]any{"b": int64(2), "a": "x"}},
+			expected: "select ['a', 1], map('a', 'x', 'b', 2)",
+		},
+		{
+			name:     "placeholders in quotes",
+			sql:      `select '$1', "$1", ` + "`$1`" + `, 'it\'s $1', 'it''s $1', $1`,
+			args:     []any{"a"},
+			expected: `select '$1', "$1", ` + "`$1`" + `, 'it\'s $1', 'it''s $1', 'a'`,
+		},
+		{
+			name:   "missing arg",
+			sql:    "select $1, $2",
+			args:   []any{"a"},
+			errMsg: "no argument for placeholder $2",
+		},
+		{
+			name:   "unsupported type",
+			sql:    "select $1",
+			args:   []any{struct{}{}},
+			errMsg: "argument 1: unsupported type struct {}",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sql, err := bindArgs(test.sql, test.args)
+			if test.errMsg != "" {
+				require.EqualError(t, err, test.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	fileFormatRaw    = "raw"
+	fileFormatJSON   = "json"
+	fileFormatYAML   = "yaml"
+	fileFormatDotenv = "dotenv"
+)
+
+// fileSecretsClient looks up secrets from files. When the path is a directory,
+// such as a mounted Kubernetes secret, the key is the name of a file within it
+// and `name.field` looks up a field of the parsed file `name`. When the path
+// is a file, the key is a field of the parsed file.
+//
+// Files are read on each lookup so that rotated secrets are picked up.
+type fileSecretsClient struct {
+	logger     *slog.Logger
+	path       string
+	isDir      bool
+	format     string
+	trimPrefix string
+}
+
+func newFileSecretsLookup(_ context.Context, logger *slog.Logger, u *url.URL) (LookupFn, error) {
+	path := u.Path
+	if path == "" {
+		// Relative paths such as `file:./secrets` are parsed as opaque
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, errors.New("file secrets path must not be empty")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fileSecretsClient{
+		logger:     logger,
+		path:       path,
+		isDir:      info.IsDir(),
+		format:     u.Query().Get("format"),
+		trimPrefix: u.Query().Get(trimPrefixParam),
+	}
+
+	if f.format == "" {
+		if f.isDir {
+			f.format = fileFormatJSON
+		} else {
+			f.format = fileFormatFromExt(path)
+		}
+	}
+
+	switch f.format {
+	case fileFormatJSON, fileFormatYAML, fileFormatDotenv:
+	case fileFormatRaw:
+		if !f.isDir {
+			return nil, fmt.Errorf("file secrets format %v requires a directory", f.format)
+		}
+	default:
+		return nil, fmt.Errorf("file secrets format %v not recognized", f.format)
+	}
+
+	return f.lookup, nil
+}
+
+func fileFormatFromExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return fileFormatJSON
+	case ".yaml", ".yml":
+		return fileFormatYAML
+	}
+	return fileFormatDotenv
+}
+
+func (f *fileSecretsClient) lookup(_ context.Context, key string) (string, bool) {
+	if !strings.HasPrefix(key, f.trimPrefix) {
+		return "", false
+	}
+	key = strings.TrimPrefix(key, f.trimPrefix)
+
+	if !f.isDir {
+		return f.lookupField(f.path, f.format, key)
+	}
+
+	// Keys must not escape the directory, and the `..data` style entries used
+	// by Kubernetes for atomic updates are not secrets
+	if key == "" || !filepath.IsLocal(key) || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, "..") {
+		return "", false
+	}
+
+	// Kubernetes secret keys often contain dots, e.g. `tls.crt`, so a file
+	// matching the whole key takes precedence over a field lookup.
+	if b, ok := f.readFile(filepath.Join(f.path, key)); ok {
+		return strings.TrimRight(string(b), "\r\n"), true
+	}
+
+	name, field, found := strings.Cut(key, ".")
+	if !found || f.format == fileFormatRaw {
+		return "", false
+	}
+	return f.lookupField(filepath.Join(f.path, name), f.format, field)
+}
+
+func (f *fileSecretsClient) lookupField(path, format, field string) (string, bool) {
+	b, ok := f.readFile(path)
+	if !ok {
+		return "", false
+	}
+
+	var (
+		v   any
+		err error
+	)
+	switch format {
+	case fileFormatJSON:
+		err = json.Unmarshal(b, &v)
+	case fileFormatYAML:
+		err = yaml.Unmarshal(b, &v)
+	case fileFormatDotenv:
+		v, err = parseDotenv(b)
+	}
+	if err != nil {
+		f.logger.With("error", err, "path", path).Error("Failed to parse secrets file")
+		return "", false
+	}
+
+	return fieldValue(v, field)
+}
+
+func (f *fileSecretsClient) readFile(path string) ([]byte, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrInvalid) {
+			// An error that isn't due to file-not-found gets logged
+			f.logger.With("error", err, "path", path).Error("Failed to read secrets file")
+		}
+		return nil, false
+	}
+	return b, true
+}
+
+// fieldValue walks the dot separated `field` path of `v`. Objects and arrays
+// are returned as JSON.
+func fieldValue(v any, field string) (string, bool) {
+	for _, p := range strings.Split(field, ".") {
+		switch t := v.(type) {
+		case map[string]any:
+			var exists bool
+			if v, exists = t[p]; !exists {
+				return "", false
+			}
+		case []any:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return "", false
+			}
+			v = t[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch t := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return t, true
+	case map[string]any, []any:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+	return fmt.Sprintf("%v", v), true
+}
+
+// parseDotenv parses `KEY=value` lines, optionally prefixed with `export`.
+// Values may be quoted, escape sequences are only expanded within double
+// quotes.
+func parseDotenv(b []byte) (map[string]any, error) {
+	vars := map[string]any{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %v: expected KEY=value", n)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %w", n, err)
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
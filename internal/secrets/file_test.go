@@ -0,0 +1,139 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSecretsDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "password"), []byte("hunter2\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tls.crt"), []byte("cert"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db"), []byte(`{"user":"admin","hosts":["a","b"],"port":5432}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "..secret"), []byte("nope"), 0o600))
+
+	ctx := context.Background()
+
+	lookup, err := parseSecretsLookupURN(ctx, slog.Default(), "file://"+dir)
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		key    string
+		value  string
+		exists bool
+	}{
+		{key: "password", value: "hunter2", exists: true},
+		{key: "tls.crt", value: "cert", exists: true},
+		{key: "db.user", value: "admin", exists: true},
+		{key: "db.port", value: "5432", exists: true},
+		{key: "db.hosts", value: `["a","b"]`, exists: true},
+		{key: "db.hosts.1", value: "b", exists: true},
+		{key: "db.missing"},
+		{key: "missing"},
+		{key: "../" + filepath.Base(dir) + "/password"},
+		{key: "..secret"},
+	} {
+		v, exists := lookup(ctx, test.key)
+		assert.Equal(t, test.exists, exists, test.key)
+		assert.Equal(t, test.value, v, test.key)
+	}
+}
+
+func TestFileSecretsFile(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name     string
+		filename string
+		content  string
+		query    string
+	}{
+		{
+			name:     "dotenv",
+			filename: "secrets.env",
+			content: `
+# comment
+export FOO_USER=admin
+FOO_PASSWORD="hunter\n2"
+FOO_TOKEN='a b'
+FOO_HOST=localhost # comment
+`,
+		},
+		{
+			name:     "yaml",
+			filename: "secrets.yaml",
+			content: `
+FOO_USER: admin
+FOO_PASSWORD: "hunter\n2"
+FOO_TOKEN: a b
+FOO_HOST: localhost
+`,
+		},
+		{
+			name:     "json with explicit format",
+			filename: "secrets",
+			content:  `{"FOO_USER":"admin","FOO_PASSWORD":"hunter\n2","FOO_TOKEN":"a b","FOO_HOST":"localhost"}`,
+			query:    "?format=json",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, test.filename)
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+
+			lookup, err := parseSecretsLookupURN(ctx, slog.Default(), "file://"+path+test.query)
+			require.NoError(t, err)
+
+			for k, v := range map[string]string{
+				"FOO_USER":     "admin",
+				"FOO_PASSWORD": "hunter\n2",
+				"FOO_TOKEN":    "a b",
+				"FOO_HOST":     "localhost",
+			} {
+				actual, exists := lookup(ctx, k)
+				assert.True(t, exists, k)
+				assert.Equal(t, v, actual, k)
+			}
+
+			_, exists := lookup(ctx, "BAR")
+			assert.False(t, exists)
+		})
+	}
+
+	t.Run("trim prefix", func(t *testing.T) {
+		path := filepath.Join(dir, "prefixed.env")
+		require.NoError(t, os.WriteFile(path, []byte("USER=admin"), 0o600))
+
+		lookup, err := parseSecretsLookupURN(ctx, slog.Default(), "file://"+path+"?trimPrefix=SECRET_")
+		require.NoError(t, err)
+
+		v, exists := lookup(ctx, "SECRET_USER")
+		assert.True(t, exists)
+		assert.Equal(t, "admin", v)
+
+		_, exists = lookup(ctx, "USER")
+		assert.False(t, exists)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parseSecretsLookupURN(ctx, slog.Default(), "file://"+filepath.Join(dir, "missing"))
+		require.Error(t, err)
+
+		_, err = parseSecretsLookupURN(ctx, slog.Default(), "file://"+dir+"?format=toml")
+		require.ErrorContains(t, err, "not recognized")
+	})
+}
@@ -0,0 +1,309 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/common-go/secrets"
+)
+
+const (
+	vaultDefaultTTL = 5 * time.Minute
+
+	// Tokens are renewed by logging in again once less than this fraction of
+	// their lease is left.
+	vaultTokenRenewFraction = 0.2
+)
+
+var errVaultPermissionDenied = errors.New("permission denied")
+
+type vaultSecret struct {
+	value     string
+	exists    bool
+	expiresAt time.Time
+}
+
+// vaultSecretsClient reads secrets from a HashiCorp Vault KV secrets engine,
+// either version 1 or 2, and caches them for a TTL. Each secret is returned as
+// a JSON object of its key/value pairs.
+type vaultSecretsClient struct {
+	logger    *slog.Logger
+	client    *http.Client
+	addr      string
+	mount     string
+	kvVersion int
+	namespace string
+	ttl       time.Duration
+
+	// Either a static token or AppRole credentials are used
+	roleID   string
+	secretID string
+
+	tokenMut       sync.Mutex
+	token          string
+	tokenExpiresAt time.Time
+
+	cacheMut sync.Mutex
+	cache    map[string]vaultSecret
+	nowFn    func() time.Time
+}
+
+// newVaultSecretsLookup creates a lookup from a URN such as
+// `vault://vault.example.com:8200/connect/?mount=secret&kv_version=2`, the
+// path is the prefix of all secrets below the mount.
+func newVaultSecretsLookup(_ context.Context, logger *slog.Logger, u *url.URL) (LookupFn, error) {
+	q := u.Query()
+
+	scheme := "https"
+	if q.Get("tls") == "false" {
+		scheme = "http"
+	}
+
+	v := &vaultSecretsClient{
+		logger:    logger,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		addr:      scheme + "://" + u.Host,
+		mount:     strings.Trim(q.Get("mount"), "/"),
+		kvVersion: 2,
+		namespace: q.Get("namespace"),
+		ttl:       vaultDefaultTTL,
+		cache:     map[string]vaultSecret{},
+		nowFn:     time.Now,
+	}
+	if u.Host == "" {
+		return nil, errors.New("vault address must not be empty")
+	}
+	if v.mount == "" {
+		v.mount = "secret"
+	}
+
+	if s := q.Get("kv_version"); s != "" {
+		var err error
+		if v.kvVersion, err = strconv.Atoi(s); err != nil || (v.kvVersion != 1 && v.kvVersion != 2) {
+			return nil, fmt.Errorf("vault kv_version %v not recognized, expected 1 or 2", s)
+		}
+	}
+	if s := q.Get("ttl"); s != "" {
+		var err error
+		if v.ttl, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("failed to parse vault ttl: %w", err)
+		}
+	}
+
+	// Credentials may be provided via the environment in order to keep them
+	// out of the command line
+	switch auth := q.Get("auth"); auth {
+	case "", "token":
+		if v.token = q.Get("token"); v.token == "" {
+			v.token = os.Getenv("VAULT_TOKEN")
+		}
+		if v.token == "" {
+			return nil, errors.New("vault token auth requires a token or the VAULT_TOKEN environment variable")
+		}
+	case "approle":
+		if v.roleID = q.Get("role_id"); v.roleID == "" {
+			v.roleID = os.Getenv("VAULT_ROLE_ID")
+		}
+		if v.secretID = q.Get("secret_id"); v.secretID == "" {
+			v.secretID = os.Getenv("VAULT_SECRET_ID")
+		}
+		if v.roleID == "" {
+			return nil, errors.New("vault approle auth requires a role_id or the VAULT_ROLE_ID environment variable")
+		}
+	default:
+		return nil, fmt.Errorf("vault auth method %v not recognized", auth)
+	}
+
+	prefix := strings.TrimPrefix(u.Path, "/")
+	return lookupFn(secrets.NewSecretProvider, v, prefix, q.Get(trimPrefixParam))
+}
+
+// GetSecretValue returns the secret `name` as a JSON object. Cached secrets
+// are served until their TTL expires, and if refreshing an expired secret
+// fails then the stale value is served instead.
+func (v *vaultSecretsClient) GetSecretValue(ctx context.Context, name string) (string, bool) {
+	now := v.nowFn()
+
+	v.cacheMut.Lock()
+	cached, isCached := v.cache[name]
+	v.cacheMut.Unlock()
+
+	if isCached && now.Before(cached.expiresAt) {
+		return cached.value, cached.exists
+	}
+
+	value, exists, err := v.read(ctx, name)
+	if err != nil {
+		if isCached && cached.exists {
+			v.logger.With("error", err, "key", name).Warn("Failed to refresh secret, serving the cached value")
+			return cached.value, true
+		}
+		v.logger.With("error", err, "key", name).Error("Failed to look up secret")
+		return "", false
+	}
+
+	if v.ttl > 0 {
+		v.cacheMut.Lock()
+		v.cache[name] = vaultSecret{value: value, exists: exists, expiresAt: now.Add(v.ttl)}
+		v.cacheMut.Unlock()
+	}
+	return value, exists
+}
+
+// CheckSecretExists returns true if the secret `name` exists.
+func (v *vaultSecretsClient) CheckSecretExists(ctx context.Context, name string) bool {
+	_, exists := v.GetSecretValue(ctx, name)
+	return exists
+}
+
+func (v *vaultSecretsClient) read(ctx context.Context, name string) (string, bool, error) {
+	value, exists, err := v.readWithToken(ctx, name)
+	if errors.Is(err, errVaultPermissionDenied) && v.roleID != "" {
+		// The token may have been revoked before its lease expired, so log in
+		// again once
+		v.tokenMut.Lock()
+		v.token = ""
+		v.tokenMut.Unlock()
+		value, exists, err = v.readWithToken(ctx, name)
+	}
+	return value, exists, err
+}
+
+func (v *vaultSecretsClient) readWithToken(ctx context.Context, name string) (string, bool, error) {
+	token, err := v.getToken(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	secretPath := path.Join("/v1", v.mount, name)
+	if v.kvVersion == 2 {
+		secretPath = path.Join("/v1", v.mount, "data", name)
+	}
+
+	var res struct {
+		Data json.RawMessage `json:"data"`
+	}
+	found, err := v.do(ctx, http.MethodGet, secretPath, token, nil, &res)
+	if err != nil || !found {
+		return "", false, err
+	}
+
+	data := res.Data
+	if v.kvVersion == 2 {
+		var inner struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(res.Data, &inner); err != nil {
+			return "", false, fmt.Errorf("failed to decode secret: %w", err)
+		}
+		// Deleted versions of a KV v2 secret have no data
+		if len(inner.Data) == 0 || string(inner.Data) == "null" {
+			return "", false, nil
+		}
+		data = inner.Data
+	}
+	return string(data), true, nil
+}
+
+// getToken returns the static token, or logs in via AppRole when the current
+// token is missing or close to expiry.
+func (v *vaultSecretsClient) getToken(ctx context.Context) (string, error) {
+	v.tokenMut.Lock()
+	defer v.tokenMut.Unlock()
+
+	if v.roleID == "" || (v.token != "" && v.nowFn().Before(v.tokenExpiresAt)) {
+		return v.token, nil
+	}
+
+	var res struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int64  `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role_id": v.roleID}
+	if v.secretID != "" {
+		body["secret_id"] = v.secretID
+	}
+	if _, err := v.do(ctx, http.MethodPost, "/v1/auth/approle/login", "", body, &res); err != nil {
+		return "", fmt.Errorf("approle login failed: %w", err)
+	}
+	if res.Auth.ClientToken == "" {
+		return "", errors.New("approle login failed: no client token returned")
+	}
+
+	v.token = res.Auth.ClientToken
+	if lease := time.Duration(res.Auth.LeaseDuration) * time.Second; lease > 0 {
+		v.tokenExpiresAt = v.nowFn().Add(lease - time.Duration(float64(lease)*vaultTokenRenewFraction))
+	} else {
+		// Tokens without a lease never expire
+		v.tokenExpiresAt = time.Unix(1<<62, 0)
+	}
+	return v.token, nil
+}
+
+// do sends a request to Vault and decodes the response into `out`. It returns
+// false if the requested path does not exist.
+func (v *vaultSecretsClient) do(ctx context.Context, method, reqPath, token string, in, out any) (bool, error) {
+	var body io.Reader = http.NoBody
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return false, err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+reqPath, body)
+	if err != nil {
+		return false, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode == http.StatusForbidden:
+		return false, errVaultPermissionDenied
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		errBody, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("unexpected status code %v: %s", resp.StatusCode, errBody)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,221 @@
+// Copyright 2024 Redpanda Data, Inc.
+//
+// Licensed as a Redpanda Enterprise file under the Redpanda Community
+// License (the "License"); you may not use this file except in compliance with
+// the License. You may obtain a copy of the License at
+//
+// https://github.com/redpanda-data/connect/blob/main/licenses/rcl.md
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeVault struct {
+	mut      sync.Mutex
+	secrets  map[string]string
+	tokens   map[string]bool
+	reads    int
+	logins   int
+	failRead bool
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, string) {
+	t.Helper()
+
+	f := &fakeVault{
+		secrets: map[string]string{},
+		tokens:  map[string]bool{"root": true},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mut.Lock()
+		defer f.mut.Unlock()
+
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["role_id"] != "role" || body["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			f.logins++
+			token := fmt.Sprintf("approle-%v", f.logins)
+			f.tokens[token] = true
+			_, _ = fmt.Fprintf(w, `{"auth":{"client_token":%q,"lease_duration":60}}`, token)
+			return
+		}
+
+		if !f.tokens[r.Header.Get("X-Vault-Token")] {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		f.reads++
+		if f.failRead {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		data, exists := f.secrets[r.URL.Path]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"data":%v}`, data)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	return f, u.Host
+}
+
+func TestVaultSecretsKV(t *testing.T) {
+	f, host := newFakeVault(t)
+	f.secrets["/v1/secret/data/connect/db"] = `{"data":{"user":"admin","password":"hunter2"},"metadata":{"version":1}}`
+	f.secrets["/v1/kv/connect/db"] = `{"user":"admin","password":"hunter2"}`
+
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		name string
+		urn  string
+	}{
+		{
+			name: "kv v2",
+			urn:  "vault://" + host + "/connect/?tls=false&token=root",
+		},
+		{
+			name: "kv v1",
+			urn:  "vault://" + host + "/connect/?tls=false&token=root&mount=kv&kv_version=1",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			lookup, err := parseSecretsLookupURN(ctx, slog.Default(), test.urn)
+			require.NoError(t, err)
+
+			v, exists := lookup(ctx, "db.password")
+			assert.True(t, exists)
+			assert.Equal(t, "hunter2", v)
+
+			v, exists = lookup(ctx, "db")
+			assert.True(t, exists)
+			assert.JSONEq(t, `{"user":"admin","password":"hunter2"}`, v)
+
+			_, exists = lookup(ctx, "db.missing")
+			assert.False(t, exists)
+
+			_, exists = lookup(ctx, "missing")
+			assert.False(t, exists)
+		})
+	}
+
+	t.Run("invalid token", func(t *testing.T) {
+		lookup, err := parseSecretsLookupURN(ctx, slog.Default(), "vault://"+host+"/connect/?tls=false&token=nope")
+		require.NoError(t, err)
+
+		_, exists := lookup(ctx, "db.password")
+		assert.False(t, exists)
+	})
+
+	t.Run("token from env", func(t *testing.T) {
+		t.Setenv("VAULT_TOKEN", "root")
+
+		lookup, err := parseSecretsLookupURN(ctx, slog.Default(), "vault://"+host+"/connect/?tls=false")
+		require.NoError(t, err)
+
+		v, exists := lookup(ctx, "db.user")
+		assert.True(t, exists)
+		assert.Equal(t, "admin", v)
+	})
+}
+
+func TestVaultSecretsAppRole(t *testing.T) {
+	f, host := newFakeVault(t)
+	f.secrets["/v1/secret/data/db"] = `{"data":{"password":"hunter2"}}`
+
+	ctx := context.Background()
+
+	lookup, err := parseSecretsLookupURN(ctx, slog.Default(), "vault://"+host+"?tls=false&auth=approle&role_id=role&secret_id=secret&ttl=0s")
+	require.NoError(t, err)
+
+	v, exists := lookup(ctx, "db.password")
+	assert.True(t, exists)
+	assert.Equal(t, "hunter2", v)
+
+	_, _ = lookup(ctx, "db.password")
+	assert.Equal(t, 1, f.logins)
+
+	// Revoked tokens are replaced by logging in again
+	f.mut.Lock()
+	f.tokens = map[string]bool{}
+	f.mut.Unlock()
+
+	v, exists = lookup(ctx, "db.password")
+	assert.True(t, exists)
+	assert.Equal(t, "hunter2", v)
+	assert.Equal(t, 2, f.logins)
+}
+
+func TestVaultSecretsCache(t *testing.T) {
+	f, host := newFakeVault(t)
+	f.secrets["/v1/secret/data/db"] = `{"data":{"password":"hunter2"}}`
+
+	now := time.Now()
+	client := &vaultSecretsClient{
+		logger:    slog.Default(),
+		client:    http.DefaultClient,
+		addr:      "http://" + host,
+		mount:     "secret",
+		kvVersion: 2,
+		ttl:       time.Minute,
+		token:     "root",
+		cache:     map[string]vaultSecret{},
+		nowFn:     func() time.Time { return now },
+	}
+
+	ctx := context.Background()
+
+	value, exists := client.GetSecretValue(ctx, "db")
+	assert.True(t, exists)
+	assert.JSONEq(t, `{"password":"hunter2"}`, value)
+	assert.Equal(t, 1, f.reads)
+
+	f.mut.Lock()
+	f.secrets["/v1/secret/data/db"] = `{"data":{"password":"rotated"}}`
+	f.mut.Unlock()
+
+	// Served from the cache until the TTL expires
+	value, _ = client.GetSecretValue(ctx, "db")
+	assert.JSONEq(t, `{"password":"hunter2"}`, value)
+	assert.Equal(t, 1, f.reads)
+
+	now = now.Add(2 * time.Minute)
+	value, _ = client.GetSecretValue(ctx, "db")
+	assert.JSONEq(t, `{"password":"rotated"}`, value)
+	assert.Equal(t, 2, f.reads)
+
+	// Stale values are served when refreshing fails
+	f.mut.Lock()
+	f.failRead = true
+	f.mut.Unlock()
+
+	now = now.Add(2 * time.Minute)
+	value, exists = client.GetSecretValue(ctx, "db")
+	assert.True(t, exists)
+	assert.JSONEq(t, `{"password":"rotated"}`, value)
+	assert.Equal(t, 3, f.reads)
+}
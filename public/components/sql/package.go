@@ -30,5 +30,6 @@ import (
 	_ "github.com/lib/pq"
 	_ "github.com/microsoft/gocosmos"
 	_ "github.com/sijms/go-ora/v2"
+	_ "github.com/timeplus-io/proton-go-driver/v2"
 	_ "github.com/trinodb/trino-go-client/trino"
 )